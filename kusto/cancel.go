@@ -0,0 +1,107 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/google/uuid"
+)
+
+// serverCancelTimeout is the time the ".cancel query" command sent by WithServerCancellation() may take.
+const serverCancelTimeout = 30 * time.Second
+
+// CancelQuery asks the service to stop running the query with the x-ms-client-request-id clientRequestID, with the
+// ".cancel query" command, to free the resources of the cluster. Canceling the context of a query only stops the
+// client from reading its results. See WithClientRequestID() and RowIterator.ClientRequestID() for the ID of a query,
+// and WithServerCancellation() to cancel queries when their context is done.
+// It returns nil once the service accepted to cancel the query. Otherwise the error wraps the error of the command,
+// which keeps its errors.Kind, such as when the query already completed.
+func (c *Client) CancelQuery(ctx context.Context, clientRequestID string) error {
+	if clientRequestID == "" {
+		return errors.ES(errors.OpMgmt, errors.KClientArgs, "CancelQuery() requires a client request ID").SetNoRetry()
+	}
+
+	err := c.cancelQuery(ctx, clientRequestID)
+	if err == nil {
+		return nil
+	}
+	kind := errors.KOther
+	if kErr, ok := errors.GetKustoError(err); ok {
+		kind = kErr.Kind
+	}
+	return errors.E(errors.OpMgmt, kind, fmt.Errorf("could not cancel the query with client request ID %q: %w", clientRequestID, err))
+}
+
+func (c *Client) cancelQuery(ctx context.Context, clientRequestID string) error {
+	iter, err := c.MgmtCluster(ctx, kql.New(".cancel query ").AddString(clientRequestID))
+	if err != nil {
+		return err
+	}
+	defer iter.Stop()
+
+	for {
+		_, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// WithServerCancellation makes the client cancel the query on the service, with CancelQuery(), if its context is done
+// before the results were received, so the cluster stops computing a result no one will read. The cancellation is
+// sent in the background, and its failures are logged as warnings, see WithLogger(). If no ID was set with
+// WithClientRequestID(), the generated one is used. Stopping the RowIterator does not cancel the query.
+func WithServerCancellation() QueryOption {
+	return func(q *queryOptions) error {
+		q.serverCancellation = true
+		return nil
+	}
+}
+
+// watchCancellation cancels the query of opts on the service if ctx is done before the returned function is called,
+// which must be once the results were received or the query failed. It does nothing unless WithServerCancellation()
+// was used, and then sets the client request ID of opts if it has none.
+func (c *Client) watchCancellation(ctx context.Context, opts *queryOptions) (finished func()) {
+	if !opts.serverCancellation {
+		return func() {}
+	}
+	props := opts.requestProperties
+	if props.ClientRequestID == "" {
+		props.ClientRequestID = "KGC.execute;" + uuid.New().String()
+	}
+	id := props.ClientRequestID
+
+	done := make(chan struct{})
+	// canceled is set before done is closed, if ctx was done before the results were received.
+	var canceled bool
+	go func() {
+		select {
+		case <-done:
+			if !canceled {
+				return
+			}
+		case <-ctx.Done():
+		}
+		cancelCtx, cancel := context.WithTimeout(context.Background(), serverCancelTimeout)
+		defer cancel()
+		if err := c.CancelQuery(cancelCtx, id); err != nil {
+			c.Logger().Warn("kusto: could not cancel the query on the service", "clientRequestID", id, "error", err)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			canceled = ctx.Err() != nil
+			close(done)
+		})
+	}
+}
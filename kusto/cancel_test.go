@@ -0,0 +1,139 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cancelQueryResponse = `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"RunningQueryId","DataType":"String","ColumnType":"string"}],"Rows":[["id"]]}]}`
+
+// cancelServer answers the queries with captureResponse, or blocks until the client gives up if block is set, and
+// records the ".cancel query" commands it receives.
+type cancelServer struct {
+	*httptest.Server
+	block bool
+
+	mu       sync.Mutex
+	cancels  []string
+	canceled chan struct{}
+}
+
+func newCancelServer(t *testing.T, block bool) *cancelServer {
+	s := &cancelServer{block: block, canceled: make(chan struct{}, 1)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := queryMsg{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+
+		if strings.HasPrefix(msg.CSL, ".cancel query") {
+			assert.Equal(t, ClusterDatabase, msg.DB)
+			s.mu.Lock()
+			s.cancels = append(s.cancels, msg.CSL)
+			s.mu.Unlock()
+			_, _ = w.Write([]byte(cancelQueryResponse))
+			s.canceled <- struct{}{}
+			return
+		}
+
+		if s.block {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, captureResponse)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *cancelServer) commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.cancels...)
+}
+
+func TestCancelQuery(t *testing.T) {
+	t.Parallel()
+
+	s := newCancelServer(t, false)
+	client := retryClient(t, s.URL)
+
+	require.NoError(t, client.CancelQuery(context.Background(), "KGC.execute;1234"))
+	assert.Equal(t, []string{`.cancel query "KGC.execute;1234"`}, s.commands())
+
+	err := client.CancelQuery(context.Background(), "")
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+}
+
+func TestCancelQueryError(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(s.Close)
+
+	err := retryClient(t, s.URL).CancelQuery(context.Background(), "KGC.execute;1234")
+	require.Error(t, err)
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.OpMgmt, e.Op)
+	assert.Contains(t, err.Error(), "could not cancel the query")
+}
+
+func TestWithServerCancellation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Context done", func(t *testing.T) {
+		t.Parallel()
+
+		s := newCancelServer(t, true)
+		client := retryClient(t, s.URL)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := client.Query(ctx, "db", kql.New("test"), WithServerCancellation(), WithClientRequestID("my-id"))
+		require.Error(t, err)
+
+		select {
+		case <-s.canceled:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the query was not canceled on the service")
+		}
+		assert.Equal(t, []string{`.cancel query "my-id"`}, s.commands())
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		t.Parallel()
+
+		s := newCancelServer(t, false)
+		client := retryClient(t, s.URL)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		iter, err := client.Query(ctx, "db", kql.New("test"), WithServerCancellation())
+		require.NoError(t, err)
+		for {
+			if _, err := iter.Next(); err != nil {
+				assert.Equal(t, io.EOF, err)
+				break
+			}
+		}
+		iter.Stop()
+		cancel()
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, s.commands())
+	})
+}
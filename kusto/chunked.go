@@ -0,0 +1,70 @@
+package kusto
+
+import (
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// ChunkIterator returns the rows of a RowIterator in chunks, see RowIterator.Chunked().
+type ChunkIterator struct {
+	iter *RowIterator
+	size int
+
+	// err is the error that ended the iteration. It is returned once the rows read before it have been returned.
+	err error
+}
+
+// Chunked returns a ChunkIterator that returns the rows of the iterator in chunks of size rows, which is useful to
+// process the large results of some management commands, like ".show extents", in batches. Only one chunk is held in
+// memory at a time. The RowIterator must not be used directly while the ChunkIterator is in use.
+func (r *RowIterator) Chunked(size int) *ChunkIterator {
+	return &ChunkIterator{iter: r, size: size}
+}
+
+// Next returns the next chunk of rows. All chunks have the requested size, except the last one, which has the rows that
+// are left. io.EOF is returned once all the rows have been returned.
+// If the query fails, like with RowIterator.Next(), the rows read before the failure are returned first, and the error
+// is returned by the next call and all subsequent calls.
+func (c *ChunkIterator) Next() ([]*table.Row, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.size <= 0 {
+		c.err = errors.ES(c.iter.op, errors.KClientArgs, "Chunked() size must be greater than 0, was %d", c.size).SetNoRetry()
+		return nil, c.err
+	}
+
+	chunk := make([]*table.Row, 0, c.size)
+	for len(chunk) < c.size {
+		row, err := c.iter.Next()
+		if err != nil {
+			c.err = err
+			break
+		}
+		chunk = append(chunk, row)
+	}
+
+	if len(chunk) == 0 {
+		return nil, c.err
+	}
+	return chunk, nil
+}
+
+// Do calls f for every chunk of rows. If f returns a non-nil error, iteration stops and the error is returned.
+// The error that ended the query, if any, is returned after f was called for the rows read before it.
+func (c *ChunkIterator) Do(f func(rows []*table.Row) error) error {
+	for {
+		chunk, err := c.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(chunk); err != nil {
+			return err
+		}
+	}
+}
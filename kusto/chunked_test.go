@@ -0,0 +1,94 @@
+package kusto
+
+import (
+	"io"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chunkedIter(t *testing.T, n int, err error) *RowIterator {
+	rows, e := NewMockRows(table.Columns{{Name: "ID", Type: types.Long}})
+	require.NoError(t, e)
+	for i := 0; i < n; i++ {
+		require.NoError(t, rows.Row(value.Values{value.Long{Value: int64(i), Valid: true}}))
+	}
+	if err != nil {
+		require.NoError(t, rows.Error(err))
+	}
+	iter := &RowIterator{}
+	require.NoError(t, iter.Mock(rows))
+	return iter
+}
+
+func TestChunked(t *testing.T) {
+	t.Parallel()
+
+	failure := errors.ES(errors.OpMgmt, errors.KLimitsExceeded, "limits exceeded")
+
+	tests := []struct {
+		desc    string
+		rows    int
+		size    int
+		err     error
+		want    []int
+		wantErr error
+	}{
+		{desc: "Exact chunks", rows: 6, size: 3, want: []int{3, 3}},
+		{desc: "Final partial chunk", rows: 7, size: 3, want: []int{3, 3, 1}},
+		{desc: "No rows", rows: 0, size: 3},
+		{desc: "Error after rows", rows: 4, size: 3, err: failure, want: []int{3, 1}, wantErr: failure},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			chunks := chunkedIter(t, test.rows, test.err).Chunked(test.size)
+			var got []int
+			next := int64(0)
+			for {
+				chunk, err := chunks.Next()
+				if err != nil {
+					if test.wantErr == nil {
+						assert.Equal(t, io.EOF, err)
+					} else {
+						assert.Equal(t, test.wantErr, err)
+					}
+					break
+				}
+				got = append(got, len(chunk))
+				for _, row := range chunk {
+					assert.Equal(t, value.Long{Value: next, Valid: true}, row.Values[0])
+					next++
+				}
+			}
+			assert.Equal(t, test.want, got)
+
+			_, err := chunks.Next()
+			assert.Error(t, err, "the error should be returned by all subsequent calls")
+		})
+	}
+}
+
+func TestChunkedDo(t *testing.T) {
+	t.Parallel()
+
+	var got []int
+	err := chunkedIter(t, 5, nil).Chunked(2).Do(func(rows []*table.Row) error {
+		got = append(got, len(rows))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 1}, got)
+
+	err = chunkedIter(t, 5, nil).Chunked(0).Do(func(rows []*table.Row) error { return nil })
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+}
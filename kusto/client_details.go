@@ -21,6 +21,33 @@ type ClientDetails struct {
 	userNameForTracing string
 	// clientVersionForTracing is the version of the client.
 	clientVersionForTracing string
+	// applicationName is the name of the application, set with WithApplicationName().
+	applicationName string
+	// applicationVersion is the version of the application, set with WithApplicationVersion().
+	applicationVersion string
+}
+
+// WithApplicationName sets the name of the application that uses the client, which defaults to the application of the
+// connection string, or the name of the executable. It is the default of the Application() QueryOption, which is sent
+// in the x-ms-app header of every query, management command and streaming ingestion, where `.show queries` and the
+// ingestion audit show it as the Application. It is also added to the User-Agent and x-ms-client-version headers, see
+// WithApplicationVersion().
+func WithApplicationName(name string) Option {
+	return func(c *Client) {
+		c.clientDetails.applicationForTracing = name
+		c.clientDetails.applicationName = name
+	}
+}
+
+// WithApplicationVersion sets the version of the application that uses the client. It is added, with the name of the
+// application, to the User-Agent and x-ms-client-version headers of every request, like "App.{name}:{version}", which
+// is how the service records the version of the application, as it has no client request property for it. When
+// neither WithApplicationName() nor WithApplicationVersion() is used, the x-ms-client-version header only has the
+// version of the client, and the User-Agent is the default one of the http.Client.
+func WithApplicationVersion(v string) Option {
+	return func(c *Client) {
+		c.clientDetails.applicationVersion = v
+	}
 }
 
 func NewClientDetails(applicationForTracing string, userNameForTracing string) *ClientDetails {
@@ -88,8 +115,24 @@ func (c *ClientDetails) UserNameForTracing() string {
 	return c.userNameForTracing
 }
 
+// ClientVersionForTracing returns the version of the client, which is sent in the x-ms-client-version header. If the
+// name or the version of the application was set with WithApplicationName() or WithApplicationVersion(), they follow
+// it, like "App.{name}:{version}".
 func (c *ClientDetails) ClientVersionForTracing() string {
-	return defaultTracingValues().clientVersionForTracing
+	version := defaultTracingValues().clientVersionForTracing
+	if !c.hasApplicationDetails() {
+		return version
+	}
+	appVersion := c.applicationVersion
+	if appVersion == "" {
+		appVersion = NONE
+	}
+	return version + "|" + buildHeaderFormat(StringPair{Key: "App." + escape(c.ApplicationForTracing()), Value: appVersion})
+}
+
+// hasApplicationDetails reports whether WithApplicationName() or WithApplicationVersion() was used.
+func (c *ClientDetails) hasApplicationDetails() bool {
+	return c.applicationName != "" || c.applicationVersion != ""
 }
 
 func buildHeaderFormat(args ...StringPair) string {
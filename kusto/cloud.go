@@ -0,0 +1,81 @@
+package kusto
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+// KustoService is the cloud.ServiceName of Kusto in a cloud.Configuration. The Audience of its ServiceConfiguration is
+// the resource that tokens are requested for.
+const KustoService cloud.ServiceName = "kusto"
+
+// firstPartyTenant is the tenant of the first party applications, used when no authority is given.
+const firstPartyTenant = "f8cdef31-a31e-4b4a-93e4-5f571e91255a"
+
+// The configurations of the national clouds, to use with ConnectionStringBuilder.WithCloud().
+var (
+	// AzurePublicCloud is the configuration of the Azure public cloud.
+	AzurePublicCloud = kustoCloud(cloud.AzurePublic.ActiveDirectoryAuthorityHost, "https://kusto.kusto.windows.net")
+	// AzureGovernmentCloud is the configuration of Azure Government.
+	AzureGovernmentCloud = kustoCloud(cloud.AzureGovernment.ActiveDirectoryAuthorityHost, "https://kusto.kusto.usgovcloudapi.net")
+	// AzureChinaCloud is the configuration of Azure China, operated by 21Vianet.
+	AzureChinaCloud = kustoCloud(cloud.AzureChina.ActiveDirectoryAuthorityHost, "https://kusto.kusto.chinacloudapi.cn")
+	// AzureGermanyCloud is the configuration of Microsoft Cloud Deutschland.
+	AzureGermanyCloud = kustoCloud("https://login.microsoftonline.de/", "https://kusto.kusto.cloudapi.de")
+)
+
+func kustoCloud(authorityHost, audience string) cloud.Configuration {
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: authorityHost,
+		Services:                     map[cloud.ServiceName]cloud.ServiceConfiguration{KustoService: {Audience: audience}},
+	}
+}
+
+// WithCloud makes the client authenticate against the cloud c, such as AzureGovernmentCloud, instead of the one
+// discovered from the metadata of the cluster, or the public cloud if the discovery fails. c must have an
+// ActiveDirectoryAuthorityHost, and the Audience of its KustoService, which tokens are requested for.
+// It can be called before or after the methods that set the authentication.
+func (kcsb *ConnectionStringBuilder) WithCloud(c cloud.Configuration) *ConnectionStringBuilder {
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	kcsb.Cloud = &c
+	return kcsb
+}
+
+// validateCloud checks that c has the endpoints needed to authenticate.
+func validateCloud(c *cloud.Configuration) error {
+	if err := validateCloudURL("ActiveDirectoryAuthorityHost", c.ActiveDirectoryAuthorityHost); err != nil {
+		return err
+	}
+	kusto, ok := c.Services[KustoService]
+	if !ok {
+		return errors.ES(errors.OpServConn, errors.KClientArgs, "the cloud configuration has no KustoService in its Services").SetNoRetry()
+	}
+	return validateCloudURL("KustoService Audience", kusto.Audience)
+}
+
+func validateCloudURL(name, value string) error {
+	if isEmpty(value) {
+		return errors.ES(errors.OpServConn, errors.KClientArgs, "the %s of the cloud configuration cannot be empty", name).SetNoRetry()
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.ES(errors.OpServConn, errors.KClientArgs, "the %s of the cloud configuration must be an https URL, was %q", name, value).SetNoRetry()
+	}
+	return nil
+}
+
+// cloudInfoOf returns the CloudInfo of the cloud c, which was validated.
+func cloudInfoOf(c *cloud.Configuration) CloudInfo {
+	login := strings.TrimSuffix(c.ActiveDirectoryAuthorityHost, "/")
+	return CloudInfo{
+		LoginEndpoint:          login,
+		KustoClientAppID:       defaultKustoClientAppId,
+		KustoClientRedirectURI: defaultRedirectUri,
+		KustoServiceResourceID: strings.TrimSuffix(c.Services[KustoService].Audience, "/"),
+		FirstPartyAuthorityURL: login + "/" + firstPartyTenant,
+	}
+}
@@ -3,14 +3,15 @@ package kusto
 import (
 	"encoding/json"
 	"fmt"
-	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/utils"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"time"
+
+	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
 )
 
 // abstraction to query metadata and use this information for providing all
@@ -49,65 +50,131 @@ var defaultCloudInfo = CloudInfo{
 	FirstPartyAuthorityURL: defaultFirstPartyAuthorityUrl,
 }
 
-// cache to query it once per instance
-var cloudInfoCache sync.Map
+// DefaultCloudInfoTTL is how long discovered CloudInfo metadata is cached before it is fetched again.
+const DefaultCloudInfoTTL = time.Hour
+
+// cloudInfoCache caches the CloudInfo of each cluster, keyed by the lower case host name of the cluster.
+var cloudInfoCache sync.Map // map[string]*cloudInfoEntry
+
+// cloudInfoEntry is a cached CloudInfo. mu makes sure only one discovery runs at a time for a cluster.
+type cloudInfoEntry struct {
+	mu      sync.Mutex
+	info    CloudInfo
+	fetched time.Time
+	valid   bool
+	// notFound is true if the cluster has no metadata endpoint, and info holds the public cloud defaults.
+	notFound bool
+}
 
+// GetMetadata returns the CloudInfo of the cluster at kustoUri, which is discovered from the cluster's metadata
+// endpoint and cached for DefaultCloudInfoTTL.
 func GetMetadata(kustoUri string, httpClient *http.Client) (CloudInfo, error) {
-	// retrieve &return if exists
-	once, ok := cloudInfoCache.Load(kustoUri)
-	if !ok {
-		once = utils.NewOnce[CloudInfo]()
-		cloudInfoCache.Store(kustoUri, once)
+	return getMetadata(kustoUri, httpClient, DefaultCloudInfoTTL)
+}
+
+// InvalidateCloudInfo removes the cached CloudInfo for the cluster host, so the next client discovers it again.
+// host can be a host name, such as "mycluster.kusto.windows.net", or a cluster URI.
+func InvalidateCloudInfo(host string) {
+	cloudInfoCache.Delete(cacheHost(host))
+}
+
+// getMetadata returns the cached CloudInfo for kustoUri if it is younger than ttl, otherwise it discovers it. A ttl <= 0
+// uses DefaultCloudInfoTTL. Failed discoveries are not cached.
+func getMetadata(kustoUri string, httpClient *http.Client, ttl time.Duration) (CloudInfo, error) {
+	info, _, err := discoverMetadata(kustoUri, httpClient, ttl)
+	return info, err
+}
+
+// discoverMetadata is getMetadata, and also reports if the cluster has no metadata endpoint, in which case the public
+// cloud defaults are returned.
+func discoverMetadata(kustoUri string, httpClient *http.Client, ttl time.Duration) (CloudInfo, bool, error) {
+	if ttl <= 0 {
+		ttl = DefaultCloudInfoTTL
+	}
+
+	e, _ := cloudInfoCache.LoadOrStore(cacheHost(kustoUri), &cloudInfoEntry{})
+	entry := e.(*cloudInfoEntry)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.valid && time.Since(entry.fetched) < ttl {
+		return entry.info, entry.notFound, nil
+	}
+
+	info, notFound, err := fetchMetadata(kustoUri, httpClient)
+	if err != nil {
+		return CloudInfo{}, false, err
+	}
+	entry.info, entry.notFound, entry.fetched, entry.valid = info, notFound, time.Now(), true
+	return info, notFound, nil
+}
+
+// cacheHost extracts the host of a cluster URI or of a host, such as "mycluster.kusto.windows.net", with its port if it
+// has one.
+func cacheHost(s string) string {
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		return strings.ToLower(u.Host)
+	}
+	return strings.ToLower(strings.TrimSuffix(s, "/"))
+}
+
+// fetchMetadata queries the metadata endpoint of the cluster. If the cluster has no metadata endpoint, it returns the
+// public cloud defaults and true.
+func fetchMetadata(kustoUri string, httpClient *http.Client) (CloudInfo, bool, error) {
+	u, err := url.Parse(kustoUri)
+	if err != nil {
+		return CloudInfo{}, false, err
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	u = u.JoinPath(metadataPath)
+	// TODO should we make this timeout configurable.
+	req, err := http.NewRequest("GET", u.String(), nil)
+
+	if err != nil {
+		return CloudInfo{}, false, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
+	}
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return CloudInfo{}, false, err
 	}
+	defer resp.Body.Close()
 
-	return once.(utils.Once[CloudInfo]).Do(func() (CloudInfo, error) {
-		u, err := url.Parse(kustoUri)
-		if err != nil {
-			return CloudInfo{}, err
-		}
-		if !strings.HasPrefix(u.Path, "/") {
-			u.Path = "/" + u.Path
-		}
-		u = u.JoinPath(metadataPath)
-		// TODO should we make this timeout configurable.
-		req, err := http.NewRequest("GET", u.String(), nil)
-
-		if err != nil {
-			return CloudInfo{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
-		}
-		resp, err := httpClient.Do(req)
-
-		if err != nil {
-			return CloudInfo{}, err
-		}
-
-		// Handle internal server error as a special case and return as an error (to be consistent with other SDK's)
-		if resp.StatusCode >= 300 && resp.StatusCode != 404 {
-			return CloudInfo{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, fmt.Errorf("error %s when querying endpoint %s",
-				resp.Status, u.String()),
-			)
-		}
-
-		defer resp.Body.Close()
-
-		b, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return CloudInfo{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
-		}
-
-		// Covers scenarios of 200/OK with no body or a 404 where there is no body
-		if len(b) == 0 {
-			return defaultCloudInfo, nil
-		}
-
-		md := metaResp{}
-
-		if err := json.Unmarshal(b, &md); err != nil {
-			return CloudInfo{}, err
-		}
-		// this should be set in the map by now
-		return md.AzureAD, nil
-	})
+	// A cluster without a metadata endpoint is in the public cloud.
+	if resp.StatusCode == http.StatusNotFound {
+		return defaultCloudInfo, true, nil
+	}
+
+	// Handle internal server error as a special case and return as an error (to be consistent with other SDK's)
+	if resp.StatusCode >= 300 {
+		return CloudInfo{}, false, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, fmt.Errorf("error %s when querying endpoint %s",
+			resp.Status, u.String()),
+		)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return CloudInfo{}, false, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
+	}
+
+	// Covers scenarios of 200/OK with no body
+	if len(b) == 0 {
+		return defaultCloudInfo, false, nil
+	}
+
+	md := metaResp{}
+
+	if err := json.Unmarshal(b, &md); err != nil {
+		return CloudInfo{}, false, err
+	}
+	// this should be set in the map by now
+	return md.AzureAD, false, nil
 }
 
 func getEnvOrDefault(key, fallback string) string {
@@ -5,7 +5,10 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -95,7 +98,7 @@ func TestGetMetadata(t *testing.T) {
 			desc:    "Internal server error",
 			payload: "",
 			want:    CloudInfo{},
-			errwant: fmt.Sprintf("Op(Op(6)): Kind(KHTTPError): error 500 Internal Server Error when querying endpoint %s/test_cloud_info_internal_error%s", s.urlStr(), metadataPath),
+			errwant: fmt.Sprintf("Op(OpCloudInfo): Kind(KHTTPError): error 500 Internal Server Error when querying endpoint %s/test_cloud_info_internal_error%s", s.urlStr(), metadataPath),
 		},
 		{
 			name:    "test_cloud_info_missing_key",
@@ -134,7 +137,9 @@ func TestGetMetadata(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			s.code = test.code
 			s.payload = []byte(test.payload)
-			res, err := GetMetadata(s.urlStr()+"/"+test.name, &http.Client{}) // Adding test name to the path make sure multiple URL's can be cached
+			// The metadata is cached by host, so every case starts with an empty cache.
+			InvalidateCloudInfo(s.urlStr())
+			res, err := GetMetadata(s.urlStr()+"/"+test.name, &http.Client{})
 			if test.err {
 				assert.NotNil(t, err)
 				assert.Equal(t, test.errwant, err.Error())
@@ -145,3 +150,84 @@ func TestGetMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMetadataCache(t *testing.T) {
+	var hits int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write([]byte(`{"AzureAD": {"LoginEndpoint": "https://login.cached.com"}}`))
+	}))
+	defer s.Close()
+	defer InvalidateCloudInfo(s.URL)
+
+	for i := 0; i < 3; i++ {
+		ci, err := getMetadata(s.URL+"/", s.Client(), time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://login.cached.com", ci.LoginEndpoint)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "metadata should be fetched once and then cached")
+
+	_, err := getMetadata(s.URL, s.Client(), time.Nanosecond)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits), "an expired entry should be fetched again")
+
+	InvalidateCloudInfo(s.Listener.Addr().String())
+	_, err = GetMetadata(s.URL, s.Client())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits), "an invalidated entry should be fetched again")
+}
+
+func TestGetCommonCloudInfoFallback(t *testing.T) {
+	tests := []struct {
+		desc     string
+		code     int
+		err      bool
+		wantWarn bool
+	}{
+		{desc: "Not found", code: http.StatusNotFound, wantWarn: true},
+		{desc: "Server error", code: http.StatusInternalServerError, err: true},
+		{desc: "Redirect", code: http.StatusMovedPermanently, err: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(test.code)
+				_, _ = w.Write([]byte("<html>not a metadata endpoint</html>"))
+			}))
+			defer s.Close()
+			defer InvalidateCloudInfo(s.URL)
+
+			l := &recordingLogger{}
+			kcsb := &ConnectionStringBuilder{DataSource: s.URL}
+			ci, _, appID, err := getCommonCloudInfo(kcsb, func() *http.Client { return s.Client() }, 0, l)
+			if test.err {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), fmt.Sprint(test.code))
+				assert.Empty(t, l.warns)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, defaultCloudInfo, *ci)
+			assert.Equal(t, defaultKustoClientAppId, appID)
+			assert.Len(t, l.warns, 1)
+		})
+	}
+}
+
+func TestGetMetadataCacheByHost(t *testing.T) {
+	var hits int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write([]byte(`{"AzureAD": {"LoginEndpoint": "https://login.cached.com"}}`))
+	}))
+	defer s.Close()
+	defer InvalidateCloudInfo(s.URL)
+
+	for _, uri := range []string{s.URL, s.URL + "/", s.URL + "/path", strings.ToUpper(s.URL)} {
+		_, err := getMetadata(uri, s.Client(), time.Hour)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "the URIs of the same host should share the cached metadata")
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unicode"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -21,6 +22,7 @@ import (
 	v1 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v1"
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
 	"github.com/Azure/azure-kusto-go/kusto/internal/response"
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
 	truestedEndpoints "github.com/Azure/azure-kusto-go/kusto/trustedendpoints"
 	"github.com/google/uuid"
 )
@@ -39,6 +41,10 @@ type Conn struct {
 	client                             *http.Client
 	endpointValidated                  atomic.Bool
 	clientDetails                      *ClientDetails
+	cloudInfoTTL                       time.Duration
+	retryPolicy                        *RetryPolicy
+	logger                             Logger
+	maxFrameBytes                      int64
 }
 
 // NewConn returns a new Conn object with an injected http.Client
@@ -90,12 +96,12 @@ func (c *Conn) query(ctx context.Context, db string, query Statement, options *q
 		return execResp{}, errors.ES(errors.OpQuery, errors.KClientArgs, "a Stmt to Query() cannot begin with a period(.), only Mgmt() calls can do that").SetNoRetry()
 	}
 
-	return c.execute(ctx, execQuery, db, query, *options.requestProperties)
+	return c.execute(ctx, execQuery, db, query, options)
 }
 
 // mgmt is used to do management queries to Kusto.
 func (c *Conn) mgmt(ctx context.Context, db string, query Statement, options *queryOptions) (execResp, error) {
-	return c.execute(ctx, execMgmt, db, query, *options.requestProperties)
+	return c.execute(ctx, execMgmt, db, query, options)
 }
 
 func (c *Conn) queryToJson(ctx context.Context, db string, query Statement, options *queryOptions) (string, error) {
@@ -104,6 +110,7 @@ func (c *Conn) queryToJson(ctx context.Context, db string, query Statement, opti
 		return "", e
 	}
 
+	body = captureBody(body, options.responseCapture)
 	defer body.Close()
 	all, e := io.ReadAll(body)
 	return string(all), e
@@ -120,18 +127,19 @@ type execResp struct {
 	frameCh    chan frames.Frame
 }
 
-func (c *Conn) execute(ctx context.Context, execType int, db string, query Statement, properties requestProperties) (execResp, error) {
-	op, reqHeader, respHeader, body, e := c.doRequest(ctx, execType, db, query, properties)
+func (c *Conn) execute(ctx context.Context, execType int, db string, query Statement, options *queryOptions) (execResp, error) {
+	op, reqHeader, respHeader, body, e := c.doRequest(ctx, execType, db, query, *options.requestProperties)
 	if e != nil {
 		return execResp{}, e
 	}
+	body = captureBody(body, options.responseCapture)
 
 	var dec frames.Decoder
 	switch execType {
 	case execMgmt:
 		dec = &v1.Decoder{}
 	case execQuery:
-		dec = &v2.Decoder{}
+		dec = &v2.Decoder{MaxFrameBytes: c.maxFrameBytes}
 	default:
 		return execResp{}, errors.ES(op, errors.KInternal, "unknown execution type was %v", execType).SetNoRetry()
 	}
@@ -141,6 +149,32 @@ func (c *Conn) execute(ctx context.Context, execType int, db string, query State
 	return execResp{reqHeader: reqHeader, respHeader: respHeader, frameCh: frameCh}, nil
 }
 
+// capturedBody copies what is read from a response body to a writer, see WithResponseCapture().
+type capturedBody struct {
+	io.ReadCloser
+	w      io.Writer
+	failed bool
+}
+
+// captureBody returns body, copying what is read from it to w. It returns body itself if w is nil.
+func captureBody(body io.ReadCloser, w io.Writer) io.ReadCloser {
+	if w == nil {
+		return body
+	}
+	return &capturedBody{ReadCloser: body, w: w}
+}
+
+// Read implements io.Reader. A failure to write stops the capture, but is not returned.
+func (c *capturedBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.failed {
+		if _, werr := c.w.Write(p[:n]); werr != nil {
+			c.failed = true
+		}
+	}
+	return n, err
+}
+
 func (c *Conn) doRequest(ctx context.Context, execType int, db string, query Statement, properties requestProperties) (errors.Op, http.Header, http.Header,
 	io.ReadCloser, error) {
 	var op errors.Op
@@ -190,8 +224,28 @@ func (c *Conn) doRequest(ctx context.Context, execType int, db string, query Sta
 		return 0, nil, nil, nil, errors.ES(op, errors.KInternal, "internal error: did not understand the type of execType: %d", execType)
 	}
 
-	headers := c.getHeaders(properties)
-	responseHeaders, closer, err := c.doRequestImpl(ctx, op, endpoint, io.NopCloser(buff), headers, fmt.Sprintf("With query: %s", query.String()))
+	baseHeaders := c.getHeaders(properties)
+	var (
+		headers, responseHeaders http.Header
+		closer                   io.ReadCloser
+	)
+	// Every attempt gets its own copy of the body and headers, as a failed attempt may have consumed or changed them.
+	// Queries, which have no side effects, are also resent up to connRetries times when the connection fails before any
+	// response was received. Management commands are only retried by the retry policy.
+	err = c.retryPolicy.do(ctx, c.logger, func() error {
+		for attempt := 0; ; attempt++ {
+			var err error
+			headers = baseHeaders.Clone()
+			tracing.Inject(ctx, headers)
+			responseHeaders, closer, err = c.doRequestImpl(ctx, op, endpoint, io.NopCloser(bytes.NewReader(buff.Bytes())), headers, fmt.Sprintf("With query: %s", query.String()))
+			if err == nil || op != errors.OpQuery || attempt >= connRetries || ctx.Err() != nil || !isConnectionError(err) {
+				return err
+			}
+			if c.logger != nil {
+				c.logger.Warn("kusto: resending the query after a connection error", "attempt", attempt+1, "error", err)
+			}
+		}
+	})
 	return op, headers, responseHeaders, closer, err
 }
 
@@ -222,7 +276,7 @@ func (c *Conn) doRequestImpl(
 		c.auth.TokenProvider.SetHttp(c.client)
 		token, tokenType, tkerr := c.auth.TokenProvider.AcquireToken(ctx)
 		if tkerr != nil {
-			return nil, nil, errors.ES(op, errors.KInternal, "Error while getting token : %s", tkerr)
+			return nil, nil, errors.E(op, errors.KInternal, fmt.Errorf("Error while getting token : %w", tokenError{tkerr}))
 		}
 		headers.Add("Authorization", fmt.Sprintf("%s %s", tokenType, token))
 	}
@@ -237,7 +291,7 @@ func (c *Conn) doRequestImpl(
 	resp, err := c.client.Do(req.WithContext(ctx))
 	if err != nil {
 		// TODO(jdoak): We need a http error unwrap function that pulls out an *errors.Error.
-		return nil, nil, errors.E(op, errors.KHTTPError, fmt.Errorf("%v, %w", errorContext, err))
+		return nil, nil, errors.E(op, errors.KHTTPError, fmt.Errorf("%v, %w", errorContext, sendError{err}))
 	}
 
 	body, err := response.TranslateBody(resp, op)
@@ -246,15 +300,35 @@ func (c *Conn) doRequestImpl(
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, errors.HTTP(op, resp.Status, resp.StatusCode, body, fmt.Sprintf("error from Kusto endpoint, %v", errorContext))
+		httpErr := errors.HTTP(op, resp.Status, resp.StatusCode, body, fmt.Sprintf("error from Kusto endpoint, %v", errorContext))
+		httpErr.RetryAfter = retryAfter(resp.Header)
+		return nil, nil, httpErr
 	}
 	return resp.Header, body, nil
 }
 
+// tokenError wraps an error of the token provider, so that Ping() can report authentication failures.
+type tokenError struct {
+	error
+}
+
+func (e tokenError) Unwrap() error {
+	return e.error
+}
+
+// sendError wraps an error of the HTTP client, returned before any response was received.
+type sendError struct {
+	error
+}
+
+func (e sendError) Unwrap() error {
+	return e.error
+}
+
 func (c *Conn) validateEndpoint() error {
 	if !c.endpointValidated.Load() {
 		var err error
-		if cloud, err := GetMetadata(c.endpoint, c.client); err == nil {
+		if cloud, err := getMetadata(c.endpoint, c.client, c.cloudInfoTTL); err == nil {
 			err = truestedEndpoints.Instance.ValidateTrustedEndpoint(c.endpoint, cloud.LoginEndpoint)
 			if err == nil {
 				c.endpointValidated.Store(true)
@@ -299,6 +373,9 @@ func (c *Conn) getHeaders(properties requestProperties) http.Header {
 	}
 
 	header.Add(ClientVersionHeader, c.clientDetails.ClientVersionForTracing())
+	if c.clientDetails.hasApplicationDetails() {
+		header.Add("User-Agent", c.clientDetails.ClientVersionForTracing())
+	}
 	return header
 }
 
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/internal/gzipmagic"
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
 	"github.com/google/uuid"
 )
 
@@ -20,6 +22,8 @@ var (
 	streamingIngestDefaultTimeout = 10 * time.Minute
 )
 
+// StreamIngest sends payload to the streaming ingestion endpoint of the table. A payload that starts with the gzip magic
+// number is sent as gzip encoded, any other payload is sent uncompressed.
 func (c *Conn) StreamIngest(ctx context.Context, db, table string, payload io.Reader, format DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
 	streamUrl, err := url.Parse(c.endStreamIngest.String())
 	if err != nil {
@@ -47,6 +51,20 @@ func (c *Conn) StreamIngest(ctx context.Context, db, table string, payload io.Re
 		closeablePayload = io.NopCloser(payload)
 	}
 
+	// The payload is only declared as gzip encoded when it is, so that uncompressed payloads can be streamed too.
+	compressed := false
+	if !isBlobUri {
+		peeked, isGzip, err := gzipmagic.Peek(closeablePayload)
+		if err != nil {
+			return errors.E(errors.OpIngestStream, errors.KIO, err)
+		}
+		compressed = isGzip
+		closeablePayload = struct {
+			io.Reader
+			io.Closer
+		}{peeked, closeablePayload}
+	}
+
 	if clientRequestId == "" {
 		clientRequestId = "KGC.executeStreaming;" + uuid.New().String()
 	}
@@ -54,8 +72,9 @@ func (c *Conn) StreamIngest(ctx context.Context, db, table string, payload io.Re
 	properties := requestProperties{}
 	properties.ClientRequestID = clientRequestId
 	headers := c.getHeaders(properties)
+	tracing.Inject(ctx, headers)
 	headers.Del("Content-Type")
-	if !isBlobUri {
+	if compressed {
 		headers.Add("Content-Encoding", "gzip")
 	}
 
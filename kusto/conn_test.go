@@ -1,13 +1,24 @@
 package kusto
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	goErrors "errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
 	"github.com/Azure/azure-kusto-go/kusto/kql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHeaders(t *testing.T) {
@@ -199,3 +210,545 @@ func TestSetConnectorDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	timespan := func(d time.Duration) string { return value.Timespan{Valid: true, Value: d}.Marshal() }
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		deadline time.Duration
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:    "TestTimeout",
+			timeout: 10 * time.Minute,
+			want:    timespan(10 * time.Minute),
+		},
+		{
+			name:     "TestLaterDeadline",
+			timeout:  10 * time.Minute,
+			deadline: 20 * time.Minute,
+			want:     timespan(10 * time.Minute),
+		},
+		{
+			name:    "TestMaxTimeout",
+			timeout: time.Hour,
+			want:    timespan(time.Hour),
+		},
+		{
+			name:    "TestAboveMaxTimeout",
+			timeout: time.Hour + time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "TestNegativeTimeout",
+			timeout: -time.Second,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Capture
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tt.deadline != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			opts, err := setQueryOptions(ctx, errors.OpQuery, kql.New("test"), queryCall, WithRequestTimeout(tt.timeout))
+			if tt.wantErr {
+				require.Error(t, err)
+				e, ok := errors.GetKustoError(err)
+				require.True(t, ok)
+				assert.Equal(t, errors.KClientArgs, e.Kind)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, opts.requestProperties.Options[ServerTimeoutValue])
+		})
+	}
+
+	t.Run("TestSoonerDeadline", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		opts, err := setQueryOptions(ctx, errors.OpQuery, kql.New("test"), queryCall, WithRequestTimeout(10*time.Minute))
+		require.NoError(t, err)
+
+		got, ok := opts.requestProperties.Options[ServerTimeoutValue].(string)
+		require.True(t, ok)
+		ts := value.Timespan{}
+		require.NoError(t, ts.Unmarshal(got))
+		assert.LessOrEqual(t, ts.Value, time.Minute)
+		assert.Greater(t, ts.Value, 50*time.Second)
+	})
+}
+
+func TestClientRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options []QueryOption
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "TestGenerated",
+		},
+		{
+			name:    "TestOverride",
+			options: []QueryOption{WithClientRequestID("MyApp.query;1234")},
+			want:    "MyApp.query;1234",
+		},
+		{
+			name:    "TestEmpty",
+			options: []QueryOption{WithClientRequestID("")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Capture
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := setQueryOptions(context.Background(), errors.OpQuery, kql.New("test"), queryCall, tt.options...)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			client, err := New(NewConnectionStringBuilder("https://test.kusto.windows.net"))
+			require.NoError(t, err)
+
+			headers := client.conn.(*Conn).getHeaders(*opts.requestProperties)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			iter, _ := newRowIterator(ctx, cancel, execResp{reqHeader: headers}, v2.DataSetHeader{}, errors.OpQuery)
+
+			if tt.want != "" {
+				assert.Equal(t, tt.want, iter.ClientRequestID())
+			} else {
+				assert.True(t, strings.HasPrefix(iter.ClientRequestID(), "KGC.execute;"))
+			}
+		})
+	}
+}
+
+func TestWithParameters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		query   string
+		params  *kql.Parameters
+		options []QueryOption
+		wantErr bool
+	}{
+		{
+			name:   "TestUsed",
+			query:  "T | where name == user | take limit",
+			params: kql.NewParameters().AddString("user", "x").AddInt("limit", 100),
+		},
+		{
+			name:    "TestUnused",
+			query:   "T | take limit",
+			params:  kql.NewParameters().AddString("user", "x").AddInt("limit", 100),
+			wantErr: true,
+		},
+		{
+			name:    "TestAllowUnused",
+			query:   "T | take limit",
+			params:  kql.NewParameters().AddString("user", "x").AddInt("limit", 100),
+			options: []QueryOption{WithAllowUnusedParameters()},
+		},
+		{
+			name:    "TestReferenceWithAt",
+			query:   "T | where name == @user | take limit",
+			params:  kql.NewParameters().AddString("user", "x").AddInt("limit", 100),
+			wantErr: true,
+		},
+		{
+			name:    "TestInvalidName",
+			query:   "T | take limit",
+			params:  kql.NewParameters().AddString("user;", "x").AddInt("limit", 100),
+			wantErr: true,
+		},
+		{
+			name:    "TestNil",
+			query:   "T",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Capture
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := append([]QueryOption{WithParameters(tt.params)}, tt.options...)
+			opts, err := setQueryOptions(context.Background(), errors.OpQuery, kql.New("").AddUnsafe(tt.query), queryCall, options...)
+			if tt.wantErr {
+				require.Error(t, err)
+				e, ok := errors.GetKustoError(err)
+				require.True(t, ok)
+				assert.Equal(t, errors.KClientArgs, e.Kind)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.params.ToParameterCollection(), opts.requestProperties.Parameters)
+		})
+	}
+}
+
+func TestStmtParameterReferences(t *testing.T) {
+	t.Parallel()
+
+	defs := NewDefinitions().Must(ParamTypes{
+		"user":  ParamType{Type: types.String},
+		"limit": ParamType{Type: types.Int},
+	})
+	params := NewParameters().Must(QueryValues{"user": "x", "limit": int32(10)})
+
+	tests := []struct {
+		name    string
+		stmt    Stmt
+		options []QueryOption
+		wantErr string
+	}{
+		{
+			name: "TestUsed",
+			stmt: NewStmt("T | where name == user | take limit").MustDefinitions(defs).MustParameters(params),
+		},
+		{
+			name:    "TestUnused",
+			stmt:    NewStmt("T | take limit").MustDefinitions(defs).MustParameters(params),
+			wantErr: "parameters [user] are not used in the query",
+		},
+		{
+			name:    "TestAllowUnused",
+			stmt:    NewStmt("T | take limit").MustDefinitions(defs).MustParameters(params),
+			options: []QueryOption{WithAllowUnusedParameters()},
+		},
+		{
+			name:    "TestUndeclared",
+			stmt:    NewStmt("T | where name == user and age > @age | take limit").MustDefinitions(defs).MustParameters(params),
+			wantErr: "parameters [age] are used in the query but not declared",
+		},
+		{
+			name: "TestNoDefinitions",
+			stmt: NewStmt("T"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Capture
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := setQueryOptions(context.Background(), errors.OpQuery, tt.stmt, queryCall, tt.options...)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok, "got %v", err)
+			assert.Equal(t, errors.KClientArgs, e.Kind)
+			assert.Contains(t, e.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestQueryWithoutParametersIsNotScanned(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		query     Statement
+		queryType int
+	}{
+		{name: "TestInlineIngestion", query: kql.New(".ingest inline into table T <| alice@contoso.com,1"), queryType: mgmtCall},
+		{name: "TestMultiLineString", query: kql.New("print x = ```a@b```"), queryType: queryCall},
+		{name: "TestStmt", query: NewStmt("print x = ```a@b```"), queryType: queryCall},
+	}
+	for _, tt := range tests {
+		tt := tt // Capture
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := setQueryOptions(context.Background(), errors.OpQuery, tt.query, tt.queryType)
+			require.NoError(t, err)
+		})
+	}
+}
+
+// recordingTransport is an http.RoundTripper that records the headers of the requests it sends.
+type recordingTransport struct {
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.headers = append(r.headers, req.Header.Clone())
+	r.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer s.Close()
+
+	transport := &recordingTransport{}
+	httpClient := &http.Client{Transport: transport}
+	client := retryClient(t, s.URL, WithHTTPClient(httpClient))
+	assert.Equal(t, httpClient, client.HttpClient())
+
+	_, err := client.Query(context.Background(), "db", kql.New("test"))
+	require.Error(t, err)
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	require.Len(t, transport.headers, 1, "the query should be sent with the injected client")
+	headers := transport.headers[0]
+	assert.True(t, strings.HasPrefix(headers.Get(ClientRequestIdHeader), "KGC.execute;"))
+	assert.NotEmpty(t, headers.Get(ClientVersionHeader))
+	assert.Equal(t, "application/json; charset=utf-8", headers.Get("Content-Type"))
+}
+
+func TestApplicationNameAndVersion(t *testing.T) {
+	t.Parallel()
+
+	props := requestProperties{}
+	defaults, err := New(NewConnectionStringBuilder("https://test.kusto.windows.net"))
+	require.NoError(t, err)
+	headers := defaults.conn.(*Conn).getHeaders(props)
+	assert.Empty(t, headers.Get("User-Agent"), "the User-Agent should default to the one of the http.Client")
+	assert.Equal(t, defaultTracingValues().clientVersionForTracing, headers.Get(ClientVersionHeader))
+
+	kcsb := NewConnectionStringBuilder("https://test.kusto.windows.net")
+	kcsb.ApplicationForTracing = "connection string app"
+	fromKcsb, err := New(kcsb)
+	require.NoError(t, err)
+	headers = fromKcsb.conn.(*Conn).getHeaders(props)
+	assert.Equal(t, "connection string app", headers.Get(ApplicationHeader))
+	assert.Empty(t, headers.Get("User-Agent"), "the application of the connection string should not change the User-Agent")
+	assert.Equal(t, defaultTracingValues().clientVersionForTracing, headers.Get(ClientVersionHeader),
+		"the application of the connection string should not change the client version")
+
+	client, err := New(NewConnectionStringBuilder("https://test.kusto.windows.net"), WithApplicationName("my app"), WithApplicationVersion("1.2.3"))
+	require.NoError(t, err)
+	headers = client.conn.(*Conn).getHeaders(props)
+	assert.Equal(t, "my app", headers.Get(ApplicationHeader))
+	want := defaultTracingValues().clientVersionForTracing + "|App.{my_app}:{1.2.3}"
+	assert.Equal(t, want, headers.Get("User-Agent"))
+	assert.Equal(t, want, headers.Get(ClientVersionHeader))
+
+	headers = client.conn.(*Conn).getHeaders(requestProperties{Application: "other"})
+	assert.Equal(t, "other", headers.Get(ApplicationHeader), "Application() should override the name for a call")
+
+	named, err := New(NewConnectionStringBuilder("https://test.kusto.windows.net"), WithApplicationName("app"))
+	require.NoError(t, err)
+	assert.Equal(t, defaultTracingValues().clientVersionForTracing+"|App.{app}:{[none]}", named.conn.(*Conn).getHeaders(props).Get("User-Agent"))
+
+	versioned, err := New(kcsb, WithApplicationVersion("2.0"))
+	require.NoError(t, err)
+	assert.Equal(t, defaultTracingValues().clientVersionForTracing+"|App.{connection_string_app}:{2.0}", versioned.conn.(*Conn).getHeaders(props).Get(ClientVersionHeader),
+		"the version should follow the application of the connection string")
+}
+
+func TestNewIngestEndpoint(t *testing.T) {
+	t.Parallel()
+
+	for _, endpoint := range []string{"https://cluster.westus.kusto.windows.net", "https://ingest-cluster.westus.kusto.windows.net"} {
+		client, err := New(NewConnectionStringBuilder(endpoint))
+		require.NoError(t, err, endpoint)
+		assert.Equal(t, "https://cluster.westus.kusto.windows.net", client.Endpoint(), endpoint)
+
+		opts := &queryOptions{queryIngestion: true, requestProperties: &requestProperties{Options: map[string]interface{}{}}}
+		conn, err := client.getConn(mgmtCall, connOptions{queryOptions: opts})
+		require.NoError(t, err, endpoint)
+		assert.Equal(t, "https://ingest-cluster.westus.kusto.windows.net", conn.(*Conn).endpoint, endpoint)
+	}
+}
+
+type streamFormat string
+
+func (f streamFormat) CamelCase() string                      { return string(f) }
+func (f streamFormat) KnownOrDefault() DataFormatForStreaming { return f }
+
+func TestStreamIngestContentEncoding(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		encoding string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		encoding = r.Header.Get("Content-Encoding")
+		mu.Unlock()
+	}))
+	defer s.Close()
+
+	conn := retryClient(t, s.URL).conn.(*Conn)
+
+	tests := []struct {
+		desc    string
+		payload []byte
+		want    string
+	}{
+		{desc: "Gzip", payload: []byte{0x1f, 0x8b, 0x08, 0x00}, want: "gzip"},
+		{desc: "Plain", payload: []byte("a,1\n"), want: ""},
+	}
+
+	for _, test := range tests {
+		err := conn.StreamIngest(context.Background(), "db", "table", strings.NewReader(string(test.payload)), streamFormat("csv"), "", "", false)
+		require.NoError(t, err, test.desc)
+		mu.Lock()
+		assert.Equal(t, test.want, encoding, test.desc)
+		mu.Unlock()
+	}
+}
+
+const captureResponse = `[{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+	`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+	`"Columns":[{"ColumnName":"ID","ColumnType":"long"}],"Rows":[[1],[2]]},` +
+	`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, goErrors.New("write failed")
+}
+
+func TestWithResponseCapture(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(captureResponse))
+		_ = zw.Close()
+	}))
+	t.Cleanup(s.Close)
+	client := retryClient(t, s.URL)
+
+	query := func(t *testing.T, options ...QueryOption) {
+		iter, err := client.Query(context.Background(), "db", kql.New("test"), options...)
+		require.NoError(t, err)
+		defer iter.Stop()
+		rows := 0
+		require.NoError(t, iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+			require.Nil(t, e)
+			rows++
+			return nil
+		}))
+		assert.Equal(t, 2, rows)
+	}
+
+	t.Run("Capture", func(t *testing.T) {
+		t.Parallel()
+		buf := &bytes.Buffer{}
+		query(t, WithResponseCapture(buf))
+		assert.Equal(t, captureResponse, buf.String(), "the decompressed body should be captured")
+	})
+
+	t.Run("Writer fails", func(t *testing.T) {
+		t.Parallel()
+		query(t, WithResponseCapture(failingWriter{}))
+	})
+
+	t.Run("QueryToJson", func(t *testing.T) {
+		t.Parallel()
+		buf := &bytes.Buffer{}
+		got, err := client.QueryToJson(context.Background(), "db", kql.New("test"), WithResponseCapture(buf))
+		require.NoError(t, err)
+		assert.Equal(t, captureResponse, got)
+		assert.Equal(t, captureResponse, buf.String())
+	})
+
+	t.Run("Nil writer", func(t *testing.T) {
+		t.Parallel()
+		_, err := client.Query(context.Background(), "db", kql.New("test"), WithResponseCapture(nil))
+		require.Error(t, err)
+	})
+}
+
+func TestWithMaxFrameBytes(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(captureResponse))
+	}))
+	t.Cleanup(s.Close)
+
+	query := func(client *Client) error {
+		iter, err := client.Query(context.Background(), "db", kql.New("test"))
+		require.NoError(t, err)
+		defer iter.Stop()
+		return iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+			if e != nil {
+				return e
+			}
+			return nil
+		})
+	}
+
+	assert.NoError(t, query(retryClient(t, s.URL)))
+
+	err := query(retryClient(t, s.URL, WithMaxFrameBytes(100)))
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KLimitsExceeded, e.Kind)
+	assert.Contains(t, e.Error(), "100 bytes")
+}
+
+func TestWithErrorOnEmpty(t *testing.T) {
+	t.Parallel()
+
+	emptyResponse := strings.Replace(captureResponse, `"Rows":[[1],[2]]`, `"Rows":[]`, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ClientRequestIdHeader) == "empty" {
+			_, _ = w.Write([]byte(emptyResponse))
+			return
+		}
+		_, _ = w.Write([]byte(captureResponse))
+	}))
+	t.Cleanup(s.Close)
+	client := retryClient(t, s.URL)
+
+	query := func(options ...QueryOption) (int, error) {
+		iter, err := client.Query(context.Background(), "db", kql.New("test"), options...)
+		require.NoError(t, err)
+		defer iter.Stop()
+		rows := 0
+		err = iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+			rows++
+			return nil
+		})
+		return rows, err
+	}
+
+	rows, err := query(WithErrorOnEmpty())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rows)
+
+	_, err = query(WithClientRequestID("empty"))
+	assert.NoError(t, err, "an empty result is not an error without WithErrorOnEmpty()")
+
+	_, err = query(WithClientRequestID("empty"), WithErrorOnEmpty())
+	assert.True(t, goErrors.Is(err, errors.ErrNoRows), "got %v", err)
+}
@@ -0,0 +1,104 @@
+package kusto
+
+import (
+	goErrors "errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// clusterRefRe matches the cluster('name') references of a query, and captures the name.
+var clusterRefRe = regexp.MustCompile(`\bcluster\s*\(\s*(?:'([^']+)'|"([^"]+)")\s*\)`)
+
+// WithCrossClusterAuth checks, before a query is sent, that the clusters it references with cluster('name') accept
+// the token of the client. The service authorizes cross-cluster queries with the token of the request, which is for the
+// audience of the cluster of the client, so a referenced cluster whose audience or login endpoint differs, like a
+// cluster of another cloud, fails the call with an errors.KClientArgs error that names it.
+// A query that references other clusters and is rejected with HTTP status 403 also returns an errors.KClientArgs
+// error naming them, which wraps the *errors.HttpError of the response.
+// The CloudInfo of the referenced clusters is discovered and cached like the one of the client.
+func WithCrossClusterAuth() QueryOption {
+	return func(q *queryOptions) error {
+		q.crossClusterAuth = true
+		return nil
+	}
+}
+
+// clusterRefs returns the URIs of the clusters the query references, other than self, in order and without duplicates.
+// Names without a scheme use https, and names without a domain are in the public cloud, like Kusto resolves them.
+func clusterRefs(query, self string) []string {
+	seen := map[string]bool{cacheHost(self): true}
+	var refs []string
+	for _, m := range clusterRefRe.FindAllStringSubmatch(query, -1) {
+		name := strings.TrimSpace(m[1] + m[2])
+		switch {
+		case strings.Contains(name, "://"):
+		case strings.Contains(name, "."):
+			name = "https://" + name
+		default:
+			name = "https://" + name + ".kusto.windows.net"
+		}
+		name = strings.TrimSuffix(name, "/")
+		if key := cacheHost(name); !seen[key] {
+			seen[key] = true
+			refs = append(refs, name)
+		}
+	}
+	return refs
+}
+
+// tokenAudience returns the audience of the tokens of a cluster with the CloudInfo ci.
+func tokenAudience(ci CloudInfo) string {
+	if ci.LoginMfaRequired {
+		return strings.Replace(ci.KustoServiceResourceID, ".kusto.", ".kustomfa.", 1)
+	}
+	return ci.KustoServiceResourceID
+}
+
+// checkCrossCluster returns the clusters the query references if WithCrossClusterAuth() was used, and an error if
+// one of them does not accept the token of the client.
+func (c *Client) checkCrossCluster(op errors.Op, query Statement, opts *queryOptions) ([]string, error) {
+	if !opts.crossClusterAuth {
+		return nil, nil
+	}
+	refs := clusterRefs(query.String(), c.endpoint)
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	httpClient := c.http
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	own, err := getMetadata(c.endpoint, httpClient, c.cloudInfoTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range refs {
+		ci, err := getMetadata(ref, httpClient, c.cloudInfoTTL)
+		if err != nil {
+			return nil, errors.ES(op, errors.KClientArgs, "WithCrossClusterAuth() could not discover the authentication of cluster %q, which the query references: %s", ref, err)
+		}
+		if tokenAudience(ci) != tokenAudience(own) || !strings.EqualFold(ci.LoginEndpoint, own.LoginEndpoint) {
+			return nil, errors.ES(op, errors.KClientArgs,
+				"the query references cluster %q, which requires a token for %q from %s, but the client has a token for %q from %s",
+				ref, tokenAudience(ci), ci.LoginEndpoint, tokenAudience(own), own.LoginEndpoint).SetNoRetry()
+		}
+	}
+	return refs, nil
+}
+
+// crossClusterError converts a 403 response to a query that references the clusters refs into an errors.KClientArgs
+// error that names them. Other errors are returned as is.
+func crossClusterError(op errors.Op, refs []string, err error) error {
+	var httpErr *errors.HttpError
+	if len(refs) == 0 || !goErrors.As(err, &httpErr) || httpErr.StatusCode != http.StatusForbidden {
+		return err
+	}
+	return errors.E(op, errors.KClientArgs, fmt.Errorf("the caller may not be authorized on the cluster(s) %s that the query references: %w",
+		strings.Join(refs, ", "), err)).SetNoRetry()
+}
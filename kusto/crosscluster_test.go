@@ -0,0 +1,75 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterRefs(t *testing.T) {
+	t.Parallel()
+
+	query := `union cluster('help').database('Samples').T, cluster("other.westus.kusto.windows.net").database('db').T,
+		cluster( 'https://third.kusto.windows.net/' ).database('db').T, cluster('HELP').database('x').T, cluster('self.kusto.windows.net').database('db').T`
+	assert.Equal(t, []string{
+		"https://help.kusto.windows.net",
+		"https://other.westus.kusto.windows.net",
+		"https://third.kusto.windows.net",
+	}, clusterRefs(query, "https://self.kusto.windows.net"))
+	assert.Empty(t, clusterRefs("T | take 1", "https://self.kusto.windows.net"))
+}
+
+// metadataServer serves the CloudInfo with the resource ID, and rejects queries with HTTP status 403.
+func metadataServer(t *testing.T, resourceID string, queries *int32) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metadataPath {
+			_, _ = fmt.Fprintf(w, `{"AzureAD":{"LoginEndpoint":"https://login.microsoftonline.com","KustoServiceResourceId":%q}}`, resourceID)
+			return
+		}
+		atomic.AddInt32(queries, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestWithCrossClusterAuth(t *testing.T) {
+	t.Parallel()
+
+	var queries, otherQueries int32
+	self := metadataServer(t, "https://kusto.kusto.windows.net", &queries)
+	sameCloud := metadataServer(t, "https://kusto.kusto.windows.net", &otherQueries)
+	otherCloud := metadataServer(t, "https://kusto.kusto.chinacloudapi.cn", &otherQueries)
+	client := retryClient(t, self.URL)
+
+	query := kql.New("cluster('").AddUnsafe(otherCloud.URL).AddLiteral("').database('db').T")
+	_, err := client.Query(context.Background(), "db", query, WithCrossClusterAuth())
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+	assert.Contains(t, err.Error(), otherCloud.URL)
+	assert.Contains(t, err.Error(), "https://kusto.kusto.chinacloudapi.cn")
+	assert.Zero(t, atomic.LoadInt32(&queries), "a query that can't be authorized should not be sent")
+
+	query = kql.New("cluster('").AddUnsafe(sameCloud.URL).AddLiteral("').database('db').T")
+	_, err = client.Query(context.Background(), "db", query, WithCrossClusterAuth())
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+	assert.Contains(t, err.Error(), "may not be authorized on the cluster(s) "+sameCloud.URL)
+	var httpErr *errors.HttpError
+	require.True(t, goErrors.As(err, &httpErr))
+	assert.Equal(t, http.StatusForbidden, httpErr.StatusCode)
+
+	_, err = client.Query(context.Background(), "db", query)
+	require.Error(t, err)
+	assert.Equal(t, errors.KHTTPError, err.(*errors.HttpError).Kind, "403 errors are left as is without WithCrossClusterAuth()")
+	assert.Zero(t, atomic.LoadInt32(&otherQueries))
+}
@@ -0,0 +1,132 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// csvOptions are the options of WriteCSV().
+type csvOptions struct {
+	comma    rune
+	noHeader bool
+}
+
+// CSVOption is an option for RowIterator.WriteCSV().
+type CSVOption func(o *csvOptions)
+
+// WithCSVDelimiter sets the field delimiter written by WriteCSV(). The default is a comma.
+func WithCSVDelimiter(r rune) CSVOption {
+	return func(o *csvOptions) {
+		o.comma = r
+	}
+}
+
+// WithoutCSVHeader stops WriteCSV() from writing the header row with the column names.
+func WithoutCSVHeader() CSVOption {
+	return func(o *csvOptions) {
+		o.noHeader = true
+	}
+}
+
+// WriteCSV writes the rows of the iterator to w as CSV, with a header row that has the column names. Rows are written as
+// they are read, so the result is never held in memory. Values are formatted by type: datetime values in ISO 8601,
+// dynamic values as compact JSON, decimal values as their string and null values as empty fields.
+// If the query fails, or is cancelled through its context, the rows read before the failure are written and the error
+// is returned. The RowIterator must not be used directly while WriteCSV is in use.
+func (r *RowIterator) WriteCSV(w io.Writer, options ...CSVOption) error {
+	opts := csvOptions{comma: ','}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	if opts.comma == '"' || opts.comma == '\r' || opts.comma == '\n' || !utf8.ValidRune(opts.comma) || opts.comma == utf8.RuneError {
+		return errors.ES(r.op, errors.KClientArgs, "WithCSVDelimiter() delimiter %q is not valid", opts.comma).SetNoRetry()
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.comma
+
+	write := func(record []string) error {
+		if err := cw.Write(record); err != nil {
+			return errors.E(r.op, errors.KIO, err)
+		}
+		return nil
+	}
+	flush := func() error {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return errors.E(r.op, errors.KIO, err)
+		}
+		return nil
+	}
+
+	if !opts.noHeader {
+		header := make([]string, len(r.columns))
+		for i, col := range r.columns {
+			header[i] = col.Name
+		}
+		if err := write(header); err != nil {
+			return err
+		}
+	}
+
+	for {
+		row, err := r.Next()
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return ferr
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		record := make([]string, len(row.Values))
+		for i, v := range row.Values {
+			record[i] = csvField(v)
+		}
+		if err := write(record); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteTSV is like WriteCSV(), with tabs as the field delimiter.
+func (r *RowIterator) WriteTSV(w io.Writer) error {
+	return r.WriteCSV(w, WithCSVDelimiter('\t'))
+}
+
+// csvField formats v for WriteCSV().
+func csvField(v value.Kusto) string {
+	switch v := v.(type) {
+	case value.Real:
+		if !v.Valid {
+			return ""
+		}
+		return strconv.FormatFloat(v.Value, 'g', -1, 64)
+	case value.Timespan:
+		if !v.Valid {
+			return ""
+		}
+		return v.Marshal()
+	case value.Dynamic:
+		if !v.Valid {
+			return ""
+		}
+		buf := bytes.Buffer{}
+		if err := json.Compact(&buf, v.Value); err != nil {
+			return string(v.Value)
+		}
+		return buf.String()
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,125 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{
+		{Name: "Name", Type: types.String},
+		{Name: "Time", Type: types.DateTime},
+		{Name: "Bag", Type: types.Dynamic},
+		{Name: "Price", Type: types.Decimal},
+		{Name: "Ratio", Type: types.Real},
+		{Name: "Took", Type: types.Timespan},
+	}
+	rows := [][]value.Kusto{
+		{
+			value.String{Value: `a "quoted", value`, Valid: true},
+			value.DateTime{Value: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+			value.Dynamic{Value: []byte(`{ "a": [1, 2] }`), Valid: true},
+			value.Decimal{Value: "1.50", Valid: true},
+			value.Real{Value: 0.25, Valid: true},
+			value.Timespan{Value: 61 * time.Second, Valid: true},
+		},
+		{
+			value.String{Value: "", Valid: true},
+			value.DateTime{},
+			value.Dynamic{},
+			value.Decimal{},
+			value.Real{},
+			value.Timespan{},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		options []CSVOption
+		tsv     bool
+		iterErr error
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "CSV",
+			want: "Name,Time,Bag,Price,Ratio,Took\n" +
+				`"a ""quoted"", value",2023-01-02T03:04:05Z,"{""a"":[1,2]}",1.50,0.25,00:01:01` + "\n" +
+				",,,,,\n",
+		},
+		{
+			desc:    "CSV without header",
+			options: []CSVOption{WithoutCSVHeader()},
+			want: `"a ""quoted"", value",2023-01-02T03:04:05Z,"{""a"":[1,2]}",1.50,0.25,00:01:01` + "\n" +
+				",,,,,\n",
+		},
+		{
+			desc: "TSV",
+			tsv:  true,
+			want: "Name\tTime\tBag\tPrice\tRatio\tTook\n" +
+				`"a ""quoted"", value"` + "\t2023-01-02T03:04:05Z\t\"{\"\"a\"\":[1,2]}\"\t1.50\t0.25\t00:01:01\n" +
+				"\t\t\t\t\t\n",
+		},
+		{
+			desc:    "Rows written before the error",
+			iterErr: errors.ES(errors.OpQuery, errors.KHTTPError, "stream failed"),
+			want: "Name,Time,Bag,Price,Ratio,Took\n" +
+				`"a ""quoted"", value",2023-01-02T03:04:05Z,"{""a"":[1,2]}",1.50,0.25,00:01:01` + "\n" +
+				",,,,,\n",
+			wantErr: true,
+		},
+		{
+			desc:    "Invalid delimiter",
+			options: []CSVOption{WithCSVDelimiter('"')},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter, err := NewInMemoryRowIterator(columns, rows, test.iterErr)
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			buf := &bytes.Buffer{}
+			if test.tsv {
+				err = iter.WriteTSV(buf)
+			} else {
+				err = iter.WriteCSV(buf, test.options...)
+			}
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.want, buf.String())
+		})
+	}
+}
+
+func TestWriteCSVStopped(t *testing.T) {
+	t.Parallel()
+
+	iter, err := NewInMemoryRowIterator(table.Columns{{Name: "ID", Type: types.Long}}, [][]value.Kusto{{value.Long{Value: 1, Valid: true}}}, nil)
+	require.NoError(t, err)
+	iter.Stop()
+
+	buf := &bytes.Buffer{}
+	err = iter.WriteCSV(buf)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "ID\n", buf.String())
+}
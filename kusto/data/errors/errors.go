@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Separator is the string used to separate nested errors. By
@@ -40,6 +41,7 @@ const (
 	OpFileIngest    Op = 5 // OpFileIngest indicates the client is making a file ingestion call.
 	OpCloudInfo     Op = 6 // OpCloudInfo indicates an error fetching data from the cloud metadata.
 	OpTokenProvider Op = 7 // OpTokenProvider indicates an error creating a token provider.
+	OpIngestStatus  Op = 8 // OpIngestStatus indicates the client is retrieving the status of an ingestion.
 )
 
 // Kind field classifies the error as one of a set of standard conditions.
@@ -47,16 +49,17 @@ type Kind uint16
 
 //go:generate stringer -type Kind
 const (
-	KOther           Kind = 0 // Other indicates the error kind was not defined.
-	KIO              Kind = 1 // External I/O error such as network failure.
-	KInternal        Kind = 2 // Internal error or inconsistency at the server.
-	KDBNotExist      Kind = 3 // Database does not exist.
-	KTimeout         Kind = 4 // The request timed out.
-	KLimitsExceeded  Kind = 5 // The request was too large.
-	KClientArgs      Kind = 6 // The client supplied some type of arg(s) that were invalid.
-	KHTTPError       Kind = 7 // The HTTP client gave some type of error. This wraps the http library error types.
-	KBlobstore       Kind = 8 // The Blobstore API returned some type of error.
-	KLocalFileSystem Kind = 9 // The local fileystem had an error. This could be permission, missing file, etc....
+	KOther           Kind = 0  // Other indicates the error kind was not defined.
+	KIO              Kind = 1  // External I/O error such as network failure.
+	KInternal        Kind = 2  // Internal error or inconsistency at the server.
+	KDBNotExist      Kind = 3  // Database does not exist.
+	KTimeout         Kind = 4  // The request timed out.
+	KLimitsExceeded  Kind = 5  // The request was too large.
+	KClientArgs      Kind = 6  // The client supplied some type of arg(s) that were invalid.
+	KHTTPError       Kind = 7  // The HTTP client gave some type of error. This wraps the http library error types.
+	KBlobstore       Kind = 8  // The Blobstore API returned some type of error.
+	KLocalFileSystem Kind = 9  // The local fileystem had an error. This could be permission, missing file, etc....
+	KEntityNotFound  Kind = 10 // A table or a column does not exist, see Error.NotFound().
 )
 
 // Error is a core error for the Kusto package.
@@ -72,6 +75,10 @@ type Error struct {
 	restErrMsg []byte
 	decoded    map[string]interface{}
 	permanent  bool
+	// notFound is set for the errors of the service reporting an entity doesn't exist, see NotFound().
+	notFound *notFound
+	// serverMessage is the detailed message of an error response of the service, see ServerMessage().
+	serverMessage string
 
 	inner *Error
 }
@@ -81,6 +88,8 @@ type KustoError = Error
 type HttpError struct {
 	KustoError
 	StatusCode int
+	// RetryAfter is the delay the service asked for in the Retry-After header of the response, or 0 if it wasn't set.
+	RetryAfter time.Duration
 }
 
 // UnmarshalREST will unmarshal an error message from the server if the message is in
@@ -182,7 +191,7 @@ func Retry(err error) bool {
 		}
 
 		switch e.Kind {
-		case KOther, KIO, KInternal, KDBNotExist, KLimitsExceeded, KClientArgs, KLocalFileSystem:
+		case KOther, KIO, KInternal, KDBNotExist, KLimitsExceeded, KClientArgs, KLocalFileSystem, KEntityNotFound:
 			return false
 		case KHTTPError:
 			m := e.UnmarshalREST()
@@ -238,7 +247,12 @@ func HTTP(o Op, status string, statusCode int, body io.ReadCloser, prefix string
 		StatusCode: statusCode,
 	}
 
-	e.UnmarshalREST()
+	if m := e.UnmarshalREST(); m != nil {
+		if errMap, ok := m["error"].(map[string]interface{}); ok {
+			e.serverMessage = detailedMessage(errMap)
+			e.classify(errMap)
+		}
+	}
 	return &e
 }
 
@@ -366,13 +380,13 @@ func oneToErr(m map[string]interface{}, err *Error, op Op) *Error {
 		msg = msg + ";See https://docs.microsoft.com/en-us/azure/kusto/concepts/querylimits"
 	}
 
+	e := ES(op, kind, msg)
+	e.classify(errMap)
 	if err == nil {
-		return ES(op, kind, msg)
+		return e
 	}
 
-	err = W(ES(op, kind, msg), err)
-
-	return err
+	return W(e, err)
 }
 
 func (e *HttpError) IsThrottled() bool {
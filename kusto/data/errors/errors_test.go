@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/kylelemons/godebug/pretty"
@@ -197,3 +199,106 @@ func TestOneToErr(t *testing.T) {
 		}
 	}
 }
+
+func TestNotFound(t *testing.T) {
+	tests := []struct {
+		desc       string
+		body       string
+		kind       Kind
+		target     error
+		entityType EntityType
+		name       string
+		message    string
+	}{
+		{
+			desc:       "Database",
+			body:       `{"error":{"code":"BadRequest_EntityNotFound","message":"Request is invalid and cannot be executed.","@type":"Kusto.Data.Exceptions.EntityNotFoundException","@message":"Entity ID 'MyDb' of kind 'Database' was not found.","@permanent":true}}`,
+			kind:       KDBNotExist,
+			target:     ErrDatabaseNotFound,
+			entityType: EntityDatabase,
+			name:       "MyDb",
+			message:    "Entity ID 'MyDb' of kind 'Database' was not found.",
+		},
+		{
+			desc:       "Table from the entity properties",
+			body:       `{"error":{"code":"BadRequest_EntityNotFound","message":"Request is invalid and cannot be executed.","@message":"Table not found.","@entityType":"Table","@entityName":"Events"}}`,
+			kind:       KEntityNotFound,
+			target:     ErrTableNotFound,
+			entityType: EntityTable,
+			name:       "Events",
+			message:    "Table not found.",
+		},
+		{
+			desc:       "Table in a query",
+			body:       `{"error":{"code":"General_BadRequest","message":"Request is invalid and cannot be executed.","@type":"Kusto.Data.Exceptions.SemanticException","@message":"Semantic error: 'Events' operator: Failed to resolve table or column expression named 'Events'","@errorCode":"SEM0100"}}`,
+			kind:       KEntityNotFound,
+			target:     ErrTableNotFound,
+			entityType: EntityTable,
+			name:       "Events",
+			message:    "Semantic error: 'Events' operator: Failed to resolve table or column expression named 'Events'",
+		},
+		{
+			desc:       "Column in a query",
+			body:       `{"error":{"code":"General_BadRequest","message":"Request is invalid and cannot be executed.","@message":"Semantic error: 'where' operator: Failed to resolve scalar expression named 'Level'","@errorCode":"SEM0100"}}`,
+			kind:       KEntityNotFound,
+			target:     ErrColumnNotFound,
+			entityType: EntityColumn,
+			name:       "Level",
+			message:    "Semantic error: 'where' operator: Failed to resolve scalar expression named 'Level'",
+		},
+		{
+			desc:    "Other error",
+			body:    `{"error":{"code":"General_BadRequest","message":"Request is invalid and cannot be executed.","@message":"Syntax error: Query could not be parsed"}}`,
+			kind:    KHTTPError,
+			message: "Syntax error: Query could not be parsed",
+		},
+		{
+			desc: "Not JSON",
+			body: `bad gateway`,
+			kind: KHTTPError,
+		},
+	}
+
+	for _, test := range tests {
+		err := HTTP(OpQuery, "400 Bad Request", http.StatusBadRequest, io.NopCloser(strings.NewReader(test.body)), "error from Kusto endpoint")
+		if err.Kind != test.kind {
+			t.Errorf("TestNotFound(%s): got Kind %v, want %v", test.desc, err.Kind, test.kind)
+		}
+		if got := err.ServerMessage(); got != test.message {
+			t.Errorf("TestNotFound(%s): got ServerMessage() %q, want %q", test.desc, got, test.message)
+		}
+		if !strings.Contains(err.Error(), test.body) {
+			t.Errorf("TestNotFound(%s): the error should hold the body of the response, got %q", test.desc, err.Error())
+		}
+
+		entityType, name, ok := err.NotFound()
+		if ok != (test.target != nil) || entityType != test.entityType || name != test.name {
+			t.Errorf("TestNotFound(%s): got NotFound() %q, %q, %v, want %q, %q", test.desc, entityType, name, ok, test.entityType, test.name)
+		}
+		for _, target := range []error{ErrDatabaseNotFound, ErrTableNotFound, ErrColumnNotFound} {
+			wrapped := fmt.Errorf("ingest: %w", err)
+			if got := errors.Is(wrapped, target); got != (target == test.target) {
+				t.Errorf("TestNotFound(%s): errors.Is(err, %v) = %v", test.desc, target, got)
+			}
+		}
+		if test.target != nil && Retry(err) {
+			t.Errorf("TestNotFound(%s): a missing entity should not be retried", test.desc)
+		}
+	}
+
+	// Errors in the results of a query are classified too.
+	err := OneToErr(map[string]interface{}{
+		"OneApiErrors": []interface{}{
+			map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":     "General_BadRequest",
+					"message":  "Request is invalid and cannot be executed.",
+					"@message": "Failed to resolve table or column expression named 'Events'",
+				},
+			},
+		},
+	}, OpQuery)
+	if !errors.Is(err, ErrTableNotFound) || err.Kind != KEntityNotFound {
+		t.Errorf("TestNotFound(OneToErr): got %v, Kind %v, want a table not found error", err, err.Kind)
+	}
+}
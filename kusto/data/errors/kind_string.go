@@ -18,11 +18,12 @@ func _() {
 	_ = x[KHTTPError-7]
 	_ = x[KBlobstore-8]
 	_ = x[KLocalFileSystem-9]
+	_ = x[KEntityNotFound-10]
 }
 
-const _Kind_name = "KOtherKIOKInternalKDBNotExistKTimeoutKLimitsExceededKClientArgsKHTTPErrorKBlobstoreKLocalFileSystem"
+const _Kind_name = "KOtherKIOKInternalKDBNotExistKTimeoutKLimitsExceededKClientArgsKHTTPErrorKBlobstoreKLocalFileSystemKEntityNotFound"
 
-var _Kind_index = [...]uint8{0, 6, 9, 18, 29, 37, 52, 63, 73, 83, 99}
+var _Kind_index = [...]uint8{0, 6, 9, 18, 29, 37, 52, 63, 73, 83, 99, 114}
 
 func (i Kind) String() string {
 	if i >= Kind(len(_Kind_index)-1) {
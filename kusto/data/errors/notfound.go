@@ -0,0 +1,151 @@
+package errors
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// EntityType is the type of a Kusto entity that the service reported as not found, see Error.NotFound().
+type EntityType string
+
+const (
+	// EntityDatabase is a database.
+	EntityDatabase EntityType = "Database"
+	// EntityTable is a table.
+	EntityTable EntityType = "Table"
+	// EntityColumn is a column.
+	EntityColumn EntityType = "Column"
+)
+
+// ErrDatabaseNotFound, ErrTableNotFound and ErrColumnNotFound match, with errors.Is(), the errors of the service
+// reporting that a database, a table or a column doesn't exist.
+var (
+	ErrDatabaseNotFound = errors.New("database not found")
+	ErrTableNotFound    = errors.New("table not found")
+	ErrColumnNotFound   = errors.New("column not found")
+)
+
+// ErrNoRows matches, with errors.Is(), the error that a RowIterator returns instead of io.EOF for a query that used the
+// WithErrorOnEmpty() option and returned no rows. It mirrors sql.ErrNoRows.
+var ErrNoRows = errors.New("kusto: no rows in the primary result")
+
+var notFoundErrs = map[EntityType]error{
+	EntityDatabase: ErrDatabaseNotFound,
+	EntityTable:    ErrTableNotFound,
+	EntityColumn:   ErrColumnNotFound,
+}
+
+var (
+	// entityIDRE matches the message of an EntityNotFoundException, like "Entity ID 'db' of kind 'Database' was not found.".
+	entityIDRE = regexp.MustCompile(`Entity ID '([^']*)' of kind '([^']*)'`)
+	// unresolvedRE matches the message of the semantic errors of a query with a name that can't be resolved, like
+	// "Failed to resolve table or column expression named 'T'" or "Failed to resolve scalar expression named 'c'".
+	unresolvedRE = regexp.MustCompile(`Failed to resolve (table or column|table|scalar|column) expression named '([^']*)'`)
+)
+
+// notFound holds the entity of an error of the service reporting it doesn't exist.
+type notFound struct {
+	entityType EntityType
+	name       string
+}
+
+// Is implements "interface {Is(error) bool}" as used by the go stdlib errors package, to match ErrDatabaseNotFound,
+// ErrTableNotFound and ErrColumnNotFound.
+func (e *Error) Is(target error) bool {
+	if e == nil || e.notFound == nil {
+		return false
+	}
+	return notFoundErrs[e.notFound.entityType] == target
+}
+
+// NotFound returns the type and the name of the entity the service reported as not found, if the error is one.
+// Such errors have the Kind KDBNotExist for a database, and KEntityNotFound for a table or a column.
+func (e *Error) NotFound() (entityType EntityType, name string, ok bool) {
+	if e == nil || e.notFound == nil {
+		return "", "", false
+	}
+	return e.notFound.entityType, e.notFound.name, true
+}
+
+// ServerMessage returns the detailed message of the error response of the service, or "" if the error isn't one. It is
+// meant for logging, its content isn't stable.
+func (e *Error) ServerMessage() string {
+	if e == nil {
+		return ""
+	}
+	return e.serverMessage
+}
+
+// errorString returns the string value of key in errMap, the "error" object of a Kusto error.
+func errorString(errMap map[string]interface{}, key string) string {
+	s, _ := errMap[key].(string)
+	return s
+}
+
+// detailedMessage returns the detailed message of errMap, the "error" object of a Kusto error.
+func detailedMessage(errMap map[string]interface{}) string {
+	if msg := errorString(errMap, "@message"); msg != "" {
+		return msg
+	}
+	return errorString(errMap, "message")
+}
+
+// classify sets the kind and the entity of e from errMap, the "error" object of a Kusto error, if it reports an entity
+// doesn't exist.
+func (e *Error) classify(errMap map[string]interface{}) {
+	str := func(key string) string {
+		return errorString(errMap, key)
+	}
+
+	nf := notFoundOf(str("code"), str("@type"), str("@entityType"), str("@entityName"), detailedMessage(errMap))
+	if nf == nil {
+		return
+	}
+	e.notFound = nf
+	if nf.entityType == EntityDatabase {
+		e.Kind = KDBNotExist
+	} else {
+		e.Kind = KEntityNotFound
+	}
+	e.permanent = true
+}
+
+// notFoundOf returns the entity of a Kusto error with the code, type, entity and message that reports an entity doesn't
+// exist, or nil if it doesn't.
+func notFoundOf(code, typ, entityType, entityName, msg string) *notFound {
+	switch {
+	case code == "BadRequest_EntityNotFound" || strings.HasSuffix(typ, "EntityNotFoundException"):
+		if m := entityIDRE.FindStringSubmatch(msg); m != nil {
+			if entityName == "" {
+				entityName = m[1]
+			}
+			if entityType == "" {
+				entityType = m[2]
+			}
+		}
+	case code == "BadRequest_DatabaseNotExist" || strings.HasSuffix(typ, "DatabaseNotFoundException"):
+		entityType = string(EntityDatabase)
+	case strings.HasSuffix(typ, "TableNotFoundException"):
+		entityType = string(EntityTable)
+	default:
+		m := unresolvedRE.FindStringSubmatch(msg)
+		if m == nil {
+			return nil
+		}
+		entityName = m[2]
+		switch m[1] {
+		case "table or column", "table":
+			entityType = string(EntityTable)
+		default:
+			entityType = string(EntityColumn)
+		}
+	}
+
+	for _, t := range []EntityType{EntityDatabase, EntityTable, EntityColumn} {
+		if strings.EqualFold(entityType, string(t)) {
+			return &notFound{entityType: t, name: entityName}
+		}
+	}
+	return nil
+}
@@ -14,11 +14,14 @@ func _() {
 	_ = x[OpServConn-3]
 	_ = x[OpIngestStream-4]
 	_ = x[OpFileIngest-5]
+	_ = x[OpCloudInfo-6]
+	_ = x[OpTokenProvider-7]
+	_ = x[OpIngestStatus-8]
 }
 
-const _Op_name = "OpUnknownOpQueryOpMgmtOpServConnOpIngestStreamOpFileIngest"
+const _Op_name = "OpUnknownOpQueryOpMgmtOpServConnOpIngestStreamOpFileIngestOpCloudInfoOpTokenProviderOpIngestStatus"
 
-var _Op_index = [...]uint8{0, 9, 16, 22, 32, 46, 58}
+var _Op_index = [...]uint8{0, 9, 16, 22, 32, 46, 58, 69, 84, 98}
 
 func (i Op) String() string {
 	if i >= Op(len(_Op_index)-1) {
@@ -6,21 +6,36 @@ package table
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 )
 
 // decodeToStruct takes a list of columns and a row to decode into "p" which will be a pointer
 // to a struct (enforce in the decoder).
-func decodeToStruct(cols Columns, row value.Values, p interface{}) error {
+// If mapper is set, columns that don't match a field exactly are matched to the untagged field named after the result of
+// mapper, and if caseInsensitive is set, the columns that still don't match are matched to untagged fields ignoring case,
+// by the name mapper returns for them if it is set.
+func decodeToStruct(op errors.Op, cols Columns, row value.Values, p interface{}, caseInsensitive bool, mapper func(column string) string) error {
 	t := reflect.TypeOf(p)
 	v := reflect.ValueOf(p)
 	fields := newFields(cols, t)
+	if mapper != nil {
+		if err := fields.matchMapped(cols, t, mapper); err != nil {
+			return errors.ES(op, errors.KClientArgs, "%s", err).SetNoRetry()
+		}
+	}
+	if caseInsensitive {
+		if err := fields.matchIgnoringCase(cols, t, mapper); err != nil {
+			return errors.ES(op, errors.KClientArgs, "%s", err).SetNoRetry()
+		}
+	}
 
 	for i, col := range cols {
 		if err := fields.convert(col, row[i], t, v); err != nil {
-			return err
+			return errors.ES(op, errors.KInternal, "%s", err).SetNoRetry()
 		}
 	}
 	return nil
@@ -46,6 +61,98 @@ func newFields(cols Columns, ptr reflect.Type) fields {
 	return nFields
 }
 
+// matchMapped maps the columns that didn't match a field exactly to the untagged field named mapper(column). If more
+// than one column matches the same field, exactly or through mapper, an error naming them is returned instead.
+func (f fields) matchMapped(cols Columns, ptr reflect.Type, mapper func(column string) string) error {
+	untagged := map[string]bool{}
+	for i := 0; i < ptr.Elem().NumField(); i++ {
+		field := ptr.Elem().Field(i)
+		if strings.TrimSpace(field.Tag.Get("kusto")) == "" {
+			untagged[field.Name] = true
+		}
+	}
+
+	columns := map[string][]string{}
+	for _, col := range cols {
+		if name, ok := f.colNameToFieldName[col.Name]; ok {
+			columns[name] = append(columns[name], col.Name)
+		}
+	}
+	mapped := map[string]string{}
+	for _, col := range cols {
+		if _, ok := f.colNameToFieldName[col.Name]; ok {
+			continue
+		}
+		name := mapper(col.Name)
+		if !untagged[name] {
+			continue
+		}
+		mapped[col.Name] = name
+		columns[name] = append(columns[name], col.Name)
+	}
+
+	for _, col := range cols {
+		name, ok := mapped[col.Name]
+		if !ok || len(columns[name]) == 1 {
+			continue
+		}
+		sorted := append([]string(nil), columns[name]...)
+		sort.Strings(sorted)
+		return fmt.Errorf("columns %s all match struct field %s with the column name mapper", strings.Join(sorted, ", "), name)
+	}
+	for col, name := range mapped {
+		f.colNameToFieldName[col] = name
+	}
+	return nil
+}
+
+// matchIgnoringCase maps the columns that didn't match a field exactly to the untagged field with the same name
+// ignoring case, or to the one named mapper(column) ignoring case if mapper is not nil. If more than one field matches a
+// column that way, an error is returned instead of picking one.
+func (f fields) matchIgnoringCase(cols Columns, ptr reflect.Type, mapper func(column string) string) error {
+	untagged := map[string][]string{}
+	matched := map[string]bool{}
+	for i := 0; i < ptr.Elem().NumField(); i++ {
+		field := ptr.Elem().Field(i)
+		if strings.TrimSpace(field.Tag.Get("kusto")) != "" {
+			continue
+		}
+		lower := strings.ToLower(field.Name)
+		untagged[lower] = append(untagged[lower], field.Name)
+	}
+	for _, col := range cols {
+		if name, ok := f.colNameToFieldName[col.Name]; ok {
+			matched[name] = true
+		}
+	}
+
+	for _, col := range cols {
+		if _, ok := f.colNameToFieldName[col.Name]; ok {
+			continue
+		}
+		name := col.Name
+		if mapper != nil {
+			name = mapper(name)
+		}
+		candidates := untagged[strings.ToLower(name)]
+		switch len(candidates) {
+		case 0:
+			continue
+		case 1:
+			if matched[candidates[0]] {
+				continue
+			}
+			f.colNameToFieldName[col.Name] = candidates[0]
+			matched[candidates[0]] = true
+		default:
+			sorted := append([]string(nil), candidates...)
+			sort.Strings(sorted)
+			return fmt.Errorf("column %s matches more than one struct field when ignoring case: %s", col.Name, strings.Join(sorted, ", "))
+		}
+	}
+	return nil
+}
+
 // convert converts a KustoValue that is for Column col into "v" reflect.Value with reflect.Type "t".
 func (f fields) convert(col Column, k value.Kusto, t reflect.Type, v reflect.Value) error {
 	fieldName, ok := f.colNameToFieldName[col.Name]
@@ -57,9 +164,10 @@ func (f fields) convert(col Column, k value.Kusto, t reflect.Type, v reflect.Val
 		return nil
 	}
 
-	err := k.Convert(v.Elem().FieldByName(fieldName))
+	field := v.Elem().FieldByName(fieldName)
+	err := k.Convert(field)
 	if err != nil {
-		return fmt.Errorf("column %s could not store in struct.%s: %s", col.Name, fieldName, err.Error())
+		return fmt.Errorf("column %s of type %s could not store in struct.%s of type %s: %s", col.Name, col.Type, fieldName, field.Type(), err.Error())
 	}
 
 	return nil
@@ -57,6 +57,12 @@ type Row struct {
 	Op errors.Op
 	// Replace indicates whether the existing result set should be cleared and replaced with this row.
 	Replace bool
+	// CaseInsensitiveColumns makes ToStruct() match columns to untagged fields ignoring case, when no field
+	// matches exactly. It is set with the kusto.WithCaseInsensitiveColumns() option.
+	CaseInsensitiveColumns bool
+	// ColumnNameMapper, if set, makes ToStruct() match columns to the untagged field named after the result of calling
+	// it with the column name, when no field matches exactly. It is set with the kusto.WithColumnNameMapper() option.
+	ColumnNameMapper func(column string) string
 
 	columnNames []string
 }
@@ -135,8 +141,14 @@ func (r *Row) ExtractValues(ptrs ...interface{}) error {
 //     'column_name' into the field. A special case is the `column_name: "-"`
 //     tag, which instructs ToStruct to ignore the field during decoding.
 //
-//  2. Otherwise, if the name of a field matches the name of a column (ignoring case),
-//     decode the column into the field.
+//  2. Otherwise, if the name of a field matches the name of a column, decode the column into the field.
+//     If CaseInsensitiveColumns is set, a column that doesn't match any field exactly is decoded into the
+//     untagged field with the same name ignoring case. If more than one field matches, a KClientArgs
+//     error is returned.
+//
+//  3. If ColumnNameMapper is set, a column that doesn't match any field exactly is decoded into the untagged field
+//     named ColumnNameMapper(column). If more than one column matches the same field, a KClientArgs error is
+//     returned. CaseInsensitiveColumns then matches the result of ColumnNameMapper ignoring case.
 //
 // Slice and pointer fields will be set to nil if the source column is a null value, and a
 // non-nil value if the column is not NULL. To decode NULL values of other types, use
@@ -151,7 +163,7 @@ func (r *Row) ToStruct(p interface{}) error {
 		return errors.ES(r.Op, errors.KClientArgs, "row does not have the correct number of values(%d) for the number of columns(%d)", len(r.Values), len(r.ColumnTypes))
 	}
 
-	return decodeToStruct(r.ColumnTypes, r.Values, p)
+	return decodeToStruct(r.Op, r.ColumnTypes, r.Values, p, r.CaseInsensitiveColumns, r.ColumnNameMapper)
 }
 
 // String implements fmt.Stringer for a Row. This simply outputs a CSV version of the row.
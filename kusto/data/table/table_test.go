@@ -1,13 +1,16 @@
 package table
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRowColumns(t *testing.T) {
@@ -157,6 +160,170 @@ func TestRowToStruct(t *testing.T) {
 	}
 }
 
+func TestRowToStructNestedDynamic(t *testing.T) {
+	t.Parallel()
+
+	type Address struct {
+		City string `kusto:"city"`
+		Zip  *int   `kusto:"zip_code"`
+	}
+	type Person struct {
+		ID        int64 `kusto:"Id"`
+		Address   Address
+		Addresses []Address
+		Tags      map[string]any `kusto:"Labels"`
+		Extra     any
+	}
+
+	zip := 98052
+	row := &Row{
+		ColumnTypes: Columns{
+			{Name: "Id", Type: types.Long},
+			{Name: "Address", Type: types.Dynamic},
+			{Name: "Addresses", Type: types.Dynamic},
+			{Name: "Labels", Type: types.Dynamic},
+			{Name: "Extra", Type: types.Dynamic},
+		},
+		Values: value.Values{
+			value.Long{Value: 1, Valid: true},
+			value.Dynamic{Value: []byte(`{"city":"Redmond","zip_code":98052}`), Valid: true},
+			value.Dynamic{Value: []byte(`[{"city":"Seattle"},{"city":"Redmond","zip_code":98052}]`), Valid: true},
+			value.Dynamic{Value: []byte(`{"team":"kusto","level":3}`), Valid: true},
+			value.Dynamic{Value: []byte(`[1,"two"]`), Valid: true},
+		},
+	}
+
+	got := &Person{}
+	require.NoError(t, row.ToStruct(got))
+	assert.Equal(t, &Person{
+		ID:        1,
+		Address:   Address{City: "Redmond", Zip: &zip},
+		Addresses: []Address{{City: "Seattle"}, {City: "Redmond", Zip: &zip}},
+		Tags:      map[string]any{"team": "kusto", "level": float64(3)},
+		Extra:     []any{float64(1), "two"},
+	}, got)
+}
+
+func TestRowToStructMismatch(t *testing.T) {
+	t.Parallel()
+
+	row := &Row{
+		ColumnTypes: Columns{{Name: "Price", Type: types.Decimal}},
+		Values:      value.Values{value.Decimal{Value: "1.5", Valid: true}},
+		Op:          errors.OpQuery,
+	}
+
+	got := &struct {
+		Cost int64 `kusto:"Price"`
+	}{}
+	err := row.ToStruct(got)
+	require.Error(t, err)
+
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.KInternal, e.Kind)
+	assert.Equal(t, errors.OpQuery, e.Op)
+	assert.Contains(t, err.Error(), "column Price")
+	assert.Contains(t, err.Error(), "struct.Cost")
+}
+
+func TestRowToStructCaseInsensitive(t *testing.T) {
+	t.Parallel()
+
+	row := &Row{
+		ColumnTypes: Columns{{Name: "EventId", Type: types.Long}, {Name: "USERNAME", Type: types.String}, {Name: "Region", Type: types.String}},
+		Values:      value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "ada", Valid: true}, value.String{Value: "west", Valid: true}},
+		Op:          errors.OpQuery,
+	}
+
+	type event struct {
+		EventID  int64
+		UserName string
+		Location string `kusto:"Region"`
+	}
+
+	// Exact matching is the default.
+	got := event{}
+	require.NoError(t, row.ToStruct(&got))
+	assert.Equal(t, event{Location: "west"}, got)
+
+	row.CaseInsensitiveColumns = true
+	got = event{}
+	require.NoError(t, row.ToStruct(&got))
+	assert.Equal(t, event{EventID: 1, UserName: "ada", Location: "west"}, got)
+
+	// An exact match wins over a case insensitive one.
+	exact := struct {
+		EventId int64
+		EventID int64
+	}{}
+	require.NoError(t, row.ToStruct(&exact))
+	assert.Equal(t, int64(1), exact.EventId)
+	assert.Equal(t, int64(0), exact.EventID)
+
+	ambiguous := struct {
+		UserName string
+		Username string
+	}{}
+	err := row.ToStruct(&ambiguous)
+	require.Error(t, err)
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+	assert.Contains(t, err.Error(), "column USERNAME matches more than one struct field when ignoring case: UserName, Username")
+}
+
+func TestRowToStructColumnNameMapper(t *testing.T) {
+	t.Parallel()
+
+	snakeToCamel := func(column string) string {
+		parts := strings.Split(column, "_")
+		for i, p := range parts {
+			if p != "" {
+				parts[i] = strings.ToUpper(p[:1]) + p[1:]
+			}
+		}
+		return strings.Join(parts, "")
+	}
+	row := &Row{
+		ColumnTypes:      Columns{{Name: "event_id", Type: types.Long}, {Name: "user_name", Type: types.String}, {Name: "region", Type: types.String}},
+		Values:           value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "ada", Valid: true}, value.String{Value: "west", Valid: true}},
+		Op:               errors.OpQuery,
+		ColumnNameMapper: snakeToCamel,
+	}
+
+	type event struct {
+		EventId  int64
+		UserName string
+		Location string `kusto:"region"`
+	}
+	got := event{}
+	require.NoError(t, row.ToStruct(&got))
+	assert.Equal(t, event{EventId: 1, UserName: "ada", Location: "west"}, got)
+
+	// The mapper applies before the case insensitive matching.
+	row.CaseInsensitiveColumns = true
+	insensitive := struct {
+		EventID  int64
+		UserName string
+	}{}
+	require.NoError(t, row.ToStruct(&insensitive))
+	assert.Equal(t, int64(1), insensitive.EventID)
+	assert.Equal(t, "ada", insensitive.UserName)
+	row.CaseInsensitiveColumns = false
+
+	row.ColumnTypes = Columns{{Name: "user_name", Type: types.String}, {Name: "UserName", Type: types.String}, {Name: "user__name", Type: types.String}}
+	for i := 0; i < 3; i++ {
+		collision := struct{ UserName string }{}
+		err := row.ToStruct(&collision)
+		require.Error(t, err)
+		e, ok := errors.GetKustoError(err)
+		require.True(t, ok)
+		assert.Equal(t, errors.KClientArgs, e.Kind)
+		assert.Contains(t, err.Error(), "columns UserName, user__name, user_name all match struct field UserName")
+	}
+}
+
 func TestExtractValuePartial(t *testing.T) {
 	t.Parallel()
 	columns := Columns{
@@ -13,6 +13,11 @@ will not pass the Column.Valid() method and will be rejected by the API.
 */
 package types
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // Column represents a type of column defined for Kusto.
 // For more information, please see: https://docs.microsoft.com/en-us/azure/kusto/query/scalar-data-types/
 type Column string
@@ -58,3 +63,33 @@ var valid = map[Column]bool{
 	Timespan: true,
 	Decimal:  true,
 }
+
+// aliases are alternative names the service may use for a column type.
+var aliases = map[string]Column{
+	"uuid":     GUID,
+	"uniqueid": GUID,
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It normalizes the case of the type name and routes
+// aliases (such as "uuid") to the Column they represent.
+func (c *Column) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*c = normalize(s)
+	return nil
+}
+
+// normalize converts a type name sent by the service into a Column.
+func normalize(s string) Column {
+	lower := strings.ToLower(s)
+	if col, ok := aliases[lower]; ok {
+		return col
+	}
+	if col := Column(lower); col.Valid() {
+		return col
+	}
+	return Column(s)
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestColumnUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		json string
+		want Column
+	}{
+		{desc: "guid", json: `"guid"`, want: GUID},
+		{desc: "uuid alias", json: `"uuid"`, want: GUID},
+		{desc: "mixed case", json: `"Guid"`, want: GUID},
+		{desc: "string", json: `"string"`, want: String},
+		{desc: "unknown is kept", json: `"SByte"`, want: Column("SByte")},
+	}
+
+	for _, test := range tests {
+		var got Column
+		if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+			t.Errorf("TestColumnUnmarshalJSON(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestColumnUnmarshalJSON(%s): got %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
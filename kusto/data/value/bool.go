@@ -42,7 +42,8 @@ func (bo *Bool) Unmarshal(i interface{}) error {
 	return nil
 }
 
-// Convert Bool into reflect value.
+// Convert Bool into reflect value. A null Bool sets a *bool to nil and leaves a bool as is, use a *bool or a Bool to
+// tell null from false.
 func (bo Bool) Convert(v reflect.Value) error {
 	t := v.Type()
 	switch {
@@ -52,13 +53,13 @@ func (bo Bool) Convert(v reflect.Value) error {
 		}
 		return nil
 	case t.ConvertibleTo(reflect.TypeOf(new(bool))):
-		if bo.Valid {
-			b := new(bool)
-			if bo.Value {
-				*b = true
-			}
-			v.Set(reflect.ValueOf(b))
+		if !bo.Valid {
+			// Clear a value that a previous row may have set, null is not false.
+			v.Set(reflect.Zero(t))
+			return nil
 		}
+		b := bo.Value
+		v.Set(reflect.ValueOf(&b).Convert(t))
 		return nil
 	case t.ConvertibleTo(reflect.TypeOf(Bool{})):
 		v.Set(reflect.ValueOf(bo))
@@ -32,7 +32,28 @@ func (d *Decimal) ParseFloat(base int, prec uint, mode big.RoundingMode) (f *big
 	return big.ParseFloat(d.Value, base, prec, mode)
 }
 
-var DecRE = regexp.MustCompile(`^((\d+\.?\d*)|(\d*\.?\d+))$`) // Matches decimal numbers, with or without decimal dot, with optional parts missing.
+// Rat returns the exact value of the decimal. ok is false if the value is null or could not be parsed.
+func (d Decimal) Rat() (r *big.Rat, ok bool) {
+	if !d.Valid {
+		return nil, false
+	}
+	return new(big.Rat).SetString(d.Value)
+}
+
+// Float64 returns the float64 nearest to the value of the decimal, which may lose precision. ok is false if the value
+// is null or could not be parsed.
+func (d Decimal) Float64() (f float64, ok bool) {
+	r, ok := d.Rat()
+	if !ok {
+		return 0, false
+	}
+	f, _ = r.Float64()
+	return f, true
+}
+
+// DecRE matches decimal numbers, with or without decimal dot, with optional parts missing, an optional sign and an
+// optional exponent.
+var DecRE = regexp.MustCompile(`^[-+]?((\d+\.?\d*)|(\d*\.?\d+))([eE][-+]?\d+)?$`)
 
 // Unmarshal unmarshals i into Decimal. i must be a string representing a decimal type or nil.
 func (d *Decimal) Unmarshal(i interface{}) error {
@@ -1,9 +1,11 @@
 package value
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 // Dynamic represents a Kusto dynamic type.  Dynamic implements Kusto.
@@ -55,6 +57,44 @@ func (d *Dynamic) Unmarshal(i interface{}) error {
 	return nil
 }
 
+// MarshalJSON implements json.Marshaler. The JSON held by the dynamic is written as is, instead of as an encoded
+// string, so that query results can be written back out as JSON without escaping them twice. A null dynamic is
+// written as null.
+func (d Dynamic) MarshalJSON() ([]byte, error) {
+	if !d.Valid || len(d.Value) == 0 {
+		return []byte("null"), nil
+	}
+	if !json.Valid(d.Value) {
+		return nil, fmt.Errorf("value.Dynamic does not hold valid JSON: %q", d.Value)
+	}
+	return d.Value, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It stores the JSON value as is, and JSON null as a null dynamic.
+func (d *Dynamic) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		d.Value = nil
+		d.Valid = false
+		return nil
+	}
+
+	d.Value = append([]byte(nil), b...)
+	d.Valid = true
+	return nil
+}
+
+// Decode decodes the JSON held by the dynamic into v, which must be a pointer, in the same way as json.Unmarshal().
+// v is left unchanged if the dynamic is null.
+func (d Dynamic) Decode(v interface{}) error {
+	if !d.Valid || len(d.Value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(d.Value, v); err != nil {
+		return fmt.Errorf("could not decode value.Dynamic into %T: %s", v, err)
+	}
+	return nil
+}
+
 // Convert Dynamic into reflect value.
 func (d Dynamic) Convert(v reflect.Value) error {
 	t := v.Type()
@@ -79,19 +119,36 @@ func (d Dynamic) Convert(v reflect.Value) error {
 		}
 
 		ptr := reflect.New(t)
-		if err := json.Unmarshal([]byte(d.Value), ptr.Interface()); err != nil {
+		if err := unmarshalDynamic(d.Value, ptr.Elem()); err != nil {
 			return fmt.Errorf("Error occurred while trying to unmarshal Dynamic into a %s: %s", t.Kind(), err)
 		}
 
 		valueToSet = ptr.Elem()
 	case t.Kind() == reflect.Struct:
-		structPtr := reflect.New(t)
+		if !d.Valid && len(d.Value) == 0 {
+			return nil
+		}
 
-		if err := json.Unmarshal([]byte(d.Value), structPtr.Interface()); err != nil {
+		structPtr := reflect.New(t)
+		if err := unmarshalDynamic(d.Value, structPtr.Elem()); err != nil {
 			return fmt.Errorf("Could not unmarshal type dynamic into receiver: %s", err)
 		}
 
 		valueToSet = structPtr.Elem()
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		if !d.Valid {
+			return nil
+		}
+
+		ptr := reflect.New(t)
+		if err := json.Unmarshal(d.Value, ptr.Interface()); err != nil {
+			return fmt.Errorf("Could not unmarshal type dynamic into receiver: %s", err)
+		}
+		if ptr.Elem().IsNil() {
+			return nil
+		}
+
+		valueToSet = ptr.Elem()
 	default:
 		return fmt.Errorf("Column was type Kusto.Dynamic, receiver had base Kind %s ", t.Kind())
 	}
@@ -106,3 +163,115 @@ func (d Dynamic) Convert(v reflect.Value) error {
 	}
 	return nil
 }
+
+// unmarshalDynamic decodes the JSON in data into v, which must be settable. Nested struct fields are decoded with the
+// rules of encoding/json, except that a field with a `kusto:"name"` tag and no json tag is read from the key "name",
+// the same way a `kusto` tag maps a column to a field, and a `kusto:"-"` field is skipped.
+func unmarshalDynamic(data []byte, v reflect.Value) error {
+	if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+		return err
+	}
+	return applyKustoTags(data, v)
+}
+
+// applyKustoTags decodes the fields of the struct v that have a `kusto` tag, and recurses into nested structs and
+// slices of structs.
+func applyKustoTags(data []byte, v reflect.Value) error {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return applyKustoTags(data, v.Elem())
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil
+		}
+		for i := 0; i < v.Len() && i < len(items); i++ {
+			if err := applyKustoTags(items[i], v.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if !hasKustoTags(t, map[reflect.Type]bool{}) {
+		return nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return nil // Not an object, encoding/json has already reported a type mismatch if there was one.
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := strings.TrimSpace(field.Tag.Get("kusto")); tag != "" && field.Tag.Get("json") == "" {
+			if tag == "-" {
+				v.Field(i).Set(reflect.Zero(field.Type))
+				continue
+			}
+			msg, ok := raw[tag]
+			if !ok {
+				continue
+			}
+			fv := reflect.New(field.Type)
+			if err := unmarshalDynamic(msg, fv.Elem()); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			v.Field(i).Set(fv.Elem())
+			continue
+		}
+
+		if msg := lookupKey(raw, name, field.Tag.Get("json")); msg != nil {
+			if err := applyKustoTags(msg, v.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// lookupKey finds the JSON value encoding/json decoded a field from.
+func lookupKey(raw map[string]json.RawMessage, fieldName string, jsonTag string) json.RawMessage {
+	if name := strings.Split(jsonTag, ",")[0]; name != "" && name != "-" {
+		return raw[name]
+	}
+	if msg, ok := raw[fieldName]; ok {
+		return msg
+	}
+	for k, msg := range raw {
+		if strings.EqualFold(k, fieldName) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// hasKustoTags reports if t, or a struct nested in it, has a field with a `kusto` tag.
+func hasKustoTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("kusto") != "" || hasKustoTags(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
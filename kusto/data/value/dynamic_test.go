@@ -1,11 +1,13 @@
 package value_test
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type DynamicConverterTestCase struct {
@@ -210,3 +212,45 @@ func TestDynamicConverterNegative(t *testing.T) {
 
 	}
 }
+
+func TestDynamicJSON(t *testing.T) {
+	t.Parallel()
+
+	type record struct {
+		Name  string
+		Props value.Dynamic
+		Null  value.Dynamic
+		Ptr   *value.Dynamic
+	}
+
+	in := record{
+		Name:  "A",
+		Props: value.Dynamic{Value: []byte(`{"path":"C:\\temp","tags":["a","b"]}`), Valid: true},
+		Ptr:   &value.Dynamic{Value: []byte(`[1,2]`), Valid: true},
+	}
+
+	b, err := json.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, `{"Name":"A","Props":{"path":"C:\\temp","tags":["a","b"]},"Null":null,"Ptr":[1,2]}`, string(b))
+
+	out := record{}
+	require.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+
+	_, err = json.Marshal(value.Dynamic{Value: []byte(`{"broken"`), Valid: true})
+	assert.Error(t, err)
+}
+
+func TestDynamicDecode(t *testing.T) {
+	t.Parallel()
+
+	got := TestStruct{}
+	require.NoError(t, value.Dynamic{Value: []byte(`{"name":"A","id":1}`), Valid: true}.Decode(&got))
+	assert.Equal(t, TestStruct{Name: "A", ID: 1}, got)
+
+	unchanged := TestStruct{Name: "B"}
+	require.NoError(t, value.Dynamic{}.Decode(&unchanged))
+	assert.Equal(t, TestStruct{Name: "B"}, unchanged)
+
+	assert.Error(t, value.Dynamic{Value: []byte(`[1]`), Valid: true}.Decode(&got))
+}
@@ -3,6 +3,7 @@ package value
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 )
@@ -25,7 +26,30 @@ func (r Real) String() string {
 	return strconv.FormatFloat(r.Value, 'e', -1, 64)
 }
 
-// Unmarshal unmarshals i into Real. i must be a json.Number(that is a float64), float64 or nil.
+// Float64 returns the value, which may be NaN or an infinity. ok is false if the value is null.
+func (r Real) Float64() (f float64, ok bool) {
+	return r.Value, r.Valid
+}
+
+// Marshal marshals the Real into a Kusto compatible string. NaN and the infinities are "NaN", "Infinity" and
+// "-Infinity", which is how the service encodes them in JSON.
+func (r Real) Marshal() string {
+	switch {
+	case !r.Valid:
+		return ""
+	case math.IsNaN(r.Value):
+		return "NaN"
+	case math.IsInf(r.Value, 1):
+		return "Infinity"
+	case math.IsInf(r.Value, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(r.Value, 'g', -1, 64)
+}
+
+// Unmarshal unmarshals i into Real. i must be a json.Number(that is a float64), float64, nil, or a string, which is
+// how the service sends NaN and the infinities ("NaN", "Infinity" and "-Infinity"). A null is not Valid, while NaN is
+// a Valid value.
 func (r *Real) Unmarshal(i interface{}) error {
 	if i == nil {
 		r.Value = 0.0
@@ -44,8 +68,14 @@ func (r *Real) Unmarshal(i interface{}) error {
 		}
 	case float64:
 		myFloat = v
+	case string:
+		var err error
+		myFloat, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("Column with type 'real' had string value %q that is not a real", v)
+		}
 	default:
-		return fmt.Errorf("Column with type 'real' had value that was not a json.Number or float64, was %T", i)
+		return fmt.Errorf("Column with type 'real' had value that was not a json.Number, float64 or string, was %T", i)
 	}
 
 	r.Value = myFloat
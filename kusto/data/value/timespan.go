@@ -28,6 +28,11 @@ func (t Timespan) String() string {
 	return t.Value.String()
 }
 
+// Duration returns the value as a time.Duration. ok is false if the value is null.
+func (t Timespan) Duration() (d time.Duration, ok bool) {
+	return t.Value, t.Valid
+}
+
 // Marshal marshals the Timespan into a Kusto compatible string. The string is the contant invariant(c)
 // format. See https://docs.microsoft.com/en-us/dotnet/standard/base-types/standard-timespan-format-strings .
 func (t Timespan) Marshal() string {
@@ -74,7 +79,7 @@ func (t Timespan) Marshal() string {
 	val = val - (milliseconds * time.Millisecond)
 	ticks := val / tick
 	if milliseconds > 0 || ticks > 0 {
-		sb.WriteString(fmt.Sprintf(".%03d%d", milliseconds, ticks))
+		sb.WriteString(fmt.Sprintf(".%03d%04d", milliseconds, ticks))
 	}
 
 	// Remove any trailing 0's.
@@ -3,6 +3,8 @@ package value
 import (
 	"encoding/json"
 	"math"
+	"math/big"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -381,6 +383,21 @@ func TestReal(t *testing.T) {
 			i:    json.Number("23.2"),
 			want: Real{Value: 23.2, Valid: true},
 		},
+		{
+			desc: "value is a string infinity",
+			i:    "Infinity",
+			want: Real{Value: math.Inf(1), Valid: true},
+		},
+		{
+			desc: "value is a string negative infinity",
+			i:    "-Infinity",
+			want: Real{Value: math.Inf(-1), Valid: true},
+		},
+		{
+			desc: "value is a string that is not a real",
+			i:    "twelve",
+			err:  true,
+		},
 	}
 
 	for _, test := range tests {
@@ -402,6 +419,64 @@ func TestReal(t *testing.T) {
 	}
 }
 
+func TestRealSpecialValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		i       interface{}
+		marshal string
+		check   func(f float64) bool
+	}{
+		{desc: "NaN", i: "NaN", marshal: "NaN", check: math.IsNaN},
+		{desc: "Infinity", i: "Infinity", marshal: "Infinity", check: func(f float64) bool { return math.IsInf(f, 1) }},
+		{desc: "-Infinity", i: "-Infinity", marshal: "-Infinity", check: func(f float64) bool { return math.IsInf(f, -1) }},
+		{desc: "number", i: json.Number("1.5"), marshal: "1.5", check: func(f float64) bool { return f == 1.5 }},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var got Real
+			assert.NoError(t, got.Unmarshal(test.i))
+			f, ok := got.Float64()
+			assert.True(t, ok)
+			assert.True(t, test.check(f))
+			assert.Equal(t, test.marshal, got.Marshal())
+
+			var roundTrip Real
+			assert.NoError(t, roundTrip.Unmarshal(got.Marshal()))
+			assert.True(t, test.check(roundTrip.Value))
+
+			var plain float64
+			assert.NoError(t, got.Convert(reflect.ValueOf(&plain).Elem()))
+			assert.True(t, test.check(plain))
+
+			var ptr *float64
+			assert.NoError(t, got.Convert(reflect.ValueOf(&ptr).Elem()))
+			if assert.NotNil(t, ptr) {
+				assert.True(t, test.check(*ptr))
+			}
+		})
+	}
+}
+
+func TestRealNull(t *testing.T) {
+	t.Parallel()
+
+	var got Real
+	assert.NoError(t, got.Unmarshal(nil))
+	_, ok := got.Float64()
+	assert.False(t, ok)
+	assert.Equal(t, "", got.Marshal())
+
+	var ptr *float64
+	assert.NoError(t, got.Convert(reflect.ValueOf(&ptr).Elem()))
+	assert.Nil(t, ptr)
+}
+
 func TestString(t *testing.T) {
 	t.Parallel()
 
@@ -491,6 +566,9 @@ func TestTimespan(t *testing.T) {
 		{i: "03.00:00:00.111", want: Timespan{Value: 3*24*time.Hour + 111*time.Millisecond, Valid: true}},
 		{i: "03.00:00:00.111", want: Timespan{Value: 3*24*time.Hour + 111*time.Millisecond, Valid: true}},
 		{i: "364.23:59:59.9999999", want: Timespan{Value: 364*day + 23*time.Hour + 59*time.Minute + 59*time.Second + 9999999*100*time.Nanosecond, Valid: true}},
+		{i: "00:00:00.0000001", want: Timespan{Value: tick, Valid: true}},
+		{i: "1.02:03:04.5", want: Timespan{Value: day + 2*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Millisecond, Valid: true}},
+		{i: "-00:00:00.0012345", want: Timespan{Value: -12345 * tick, Valid: true}},
 	}
 
 	for _, test := range tests {
@@ -509,6 +587,10 @@ func TestTimespan(t *testing.T) {
 
 			assert.EqualValues(t, test.want, got)
 
+			d, ok := got.Duration()
+			assert.Equal(t, test.want.Value, d)
+			assert.Equal(t, test.want.Valid, ok)
+
 			strGot := got.Marshal()
 
 			if test.i == nil || !got.Valid {
@@ -549,6 +631,10 @@ func TestDecimal(t *testing.T) {
 		{desc: "Conversion of '1.',", i: "1.", want: Decimal{Value: "1.", Valid: true}},
 		{desc: "Conversion of '0.1',", i: "0.1", want: Decimal{Value: "0.1", Valid: true}},
 		{desc: "Conversion of '3.07',", i: "3.07", want: Decimal{Value: "3.07", Valid: true}},
+		{desc: "Conversion of '-3.07',", i: "-3.07", want: Decimal{Value: "-3.07", Valid: true}},
+		{desc: "Conversion of '1.5E+30',", i: "1.5E+30", want: Decimal{Value: "1.5E+30", Valid: true}},
+		{desc: "Conversion of '-2e-5',", i: "-2e-5", want: Decimal{Value: "-2e-5", Valid: true}},
+		{desc: "cannot be only an exponent", i: "e5", err: true},
 	}
 
 	for _, test := range tests {
@@ -569,6 +655,52 @@ func TestDecimal(t *testing.T) {
 	}
 }
 
+func TestDecimalRat(t *testing.T) {
+	t.Parallel()
+
+	highPrecision := "79228162514264337593543950335.0000000001"
+	wantHigh, _ := new(big.Rat).SetString(highPrecision)
+
+	tests := []struct {
+		desc      string
+		d         Decimal
+		wantRat   *big.Rat
+		wantFloat float64
+		wantOk    bool
+	}{
+		{desc: "positive", d: Decimal{Value: "3.07", Valid: true}, wantRat: big.NewRat(307, 100), wantFloat: 3.07, wantOk: true},
+		{desc: "negative", d: Decimal{Value: "-0.5", Valid: true}, wantRat: big.NewRat(-1, 2), wantFloat: -0.5, wantOk: true},
+		{desc: "scientific notation", d: Decimal{Value: "1.5E+3", Valid: true}, wantRat: big.NewRat(1500, 1), wantFloat: 1500, wantOk: true},
+		{desc: "negative exponent", d: Decimal{Value: "-2e-2", Valid: true}, wantRat: big.NewRat(-2, 100), wantFloat: -0.02, wantOk: true},
+		{desc: "beyond float64 precision", d: Decimal{Value: highPrecision, Valid: true}, wantRat: wantHigh, wantFloat: 7.922816251426434e+28, wantOk: true},
+		{desc: "null", d: Decimal{Valid: false}},
+		{desc: "not a number", d: Decimal{Value: "abc", Valid: true}},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := test.d.Rat()
+			assert.Equal(t, test.wantOk, ok)
+			f, fok := test.d.Float64()
+			assert.Equal(t, test.wantOk, fok)
+			if !test.wantOk {
+				assert.Nil(t, r)
+				return
+			}
+
+			assert.Zero(t, test.wantRat.Cmp(r), "got %s, want %s", r.RatString(), test.wantRat.RatString())
+			assert.Equal(t, test.wantFloat, f)
+		})
+	}
+
+	// The exact value keeps the digits that a float64 loses.
+	r, _ := Decimal{Value: highPrecision, Valid: true}.Rat()
+	assert.Equal(t, highPrecision, r.FloatString(10))
+}
+
 func timeMustParse(layout string, p string) time.Time {
 	t, err := time.Parse(layout, p)
 	if err != nil {
@@ -0,0 +1,179 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// showQueryPlan is the command that returns the execution plan of the query that follows it, without running it.
+const showQueryPlan = ".show queryplan <| "
+
+// planResultTypes are the ResultType of the rows of the ".show queryplan" command that hold the plan tree, by preference.
+var planResultTypes = []string{"QueryPlan", "RelopTree"}
+
+// QueryPlan is the execution plan of a query, returned by Client.ExplainQuery().
+type QueryPlan struct {
+	// Root is the root of the plan tree.
+	Root *PlanNode
+	// Results are the contents returned by the service, by their ResultType, such as "QueryPlan" or "RelopTree".
+	// They have more details than the tree, and their format isn't stable.
+	Results map[string]string
+}
+
+// PlanNode is an operator of a QueryPlan, with the operators it reads from.
+type PlanNode struct {
+	// Operator is the type of the operator, as reported by the service.
+	Operator string
+	// Properties are the attributes of the operator that are not operators themselves.
+	Properties map[string]interface{}
+	// Children are the operators that this one reads from.
+	Children []*PlanNode
+}
+
+// Walk calls f for n and all its descendants, depth first, with depth 0 for n.
+func (n *PlanNode) Walk(f func(node *PlanNode, depth int)) {
+	n.walk(f, 0)
+}
+
+func (n *PlanNode) walk(f func(node *PlanNode, depth int), depth int) {
+	f(n, depth)
+	for _, c := range n.Children {
+		c.walk(f, depth+1)
+	}
+}
+
+// explainStmt is a Statement that wraps a query in the ".show queryplan" command.
+type explainStmt struct {
+	Statement
+}
+
+func (e explainStmt) String() string {
+	return showQueryPlan + e.Statement.String()
+}
+
+// queryPlanRec is a row of the ".show queryplan" command.
+type queryPlanRec struct {
+	ResultType string
+	Format     string
+	Content    string
+}
+
+// ExplainQuery returns the execution plan that the service would use to run query against db, without running it,
+// with the ".show queryplan" command. The parameters of query and the options are sent with the command, as Mgmt() would.
+// It returns an errors.KClientArgs error if the cluster doesn't support the command.
+func (c *Client) ExplainQuery(ctx context.Context, db string, query Statement, options ...QueryOption) (*QueryPlan, error) {
+	iter, err := c.Mgmt(ctx, db, explainStmt{Statement: query}, options...)
+	if err != nil {
+		if unsupportedQueryPlan(err) {
+			return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "ExplainQuery() requires a cluster that supports the '.show queryplan' command: %s", err).SetNoRetry()
+		}
+		return nil, err
+	}
+	defer iter.Stop()
+
+	plan := &QueryPlan{Results: map[string]string{}}
+	formats := map[string]string{}
+	err = iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+		if inlineErr != nil {
+			return inlineErr
+		}
+		rec := queryPlanRec{}
+		if err := row.ToStruct(&rec); err != nil {
+			return err
+		}
+		plan.Results[rec.ResultType] = rec.Content
+		formats[rec.ResultType] = rec.Format
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resultType := range planResultTypes {
+		content, ok := plan.Results[resultType]
+		if !ok || !strings.EqualFold(formats[resultType], "json") {
+			continue
+		}
+		var tree interface{}
+		if err := json.Unmarshal([]byte(content), &tree); err != nil {
+			return nil, errors.ES(errors.OpMgmt, errors.KInternal, "ExplainQuery() could not decode the %s: %s", resultType, err)
+		}
+		plan.Root = planNode(tree)
+		return plan, nil
+	}
+	return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "ExplainQuery() got no JSON plan from the '.show queryplan' command, the cluster may not support it").SetNoRetry()
+}
+
+// unsupportedQueryPlan reports whether err is the service rejecting the ".show queryplan" command itself.
+func unsupportedQueryPlan(err error) bool {
+	var httpErr *errors.HttpError
+	if !goErrors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	msg := httpErr.ServerMessage()
+	if msg == "" {
+		msg = httpErr.Error()
+	}
+	return strings.Contains(strings.ToLower(msg), "queryplan")
+}
+
+// planNode returns the node of the JSON value v of a plan. Objects are operators: their objects and arrays of objects
+// are their children, in the order of their keys, and their other attributes are their properties.
+func planNode(v interface{}) *PlanNode {
+	node := &PlanNode{Properties: map[string]interface{}{}}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		node.Properties["Value"] = v
+		return node
+	}
+
+	for _, key := range []string{"$type", "Operator", "Kind", "Name"} {
+		if s, ok := obj[key].(string); ok && s != "" {
+			node.Operator = s
+			break
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch val := obj[k].(type) {
+		case map[string]interface{}:
+			node.Children = append(node.Children, planNode(val))
+		case []interface{}:
+			if !isObjects(val) {
+				node.Properties[k] = val
+				continue
+			}
+			for _, e := range val {
+				node.Children = append(node.Children, planNode(e))
+			}
+		default:
+			node.Properties[k] = val
+		}
+	}
+	return node
+}
+
+// isObjects reports whether a is a non-empty array of objects.
+func isObjects(a []interface{}) bool {
+	if len(a) == 0 {
+		return false
+	}
+	for _, e := range a {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryPlanResponse returns a response of the ".show queryplan" command with the rows, which are ResultType, Format
+// and Content.
+func queryPlanResponse(t *testing.T, rows ...[]string) []byte {
+	b, err := json.Marshal(map[string]interface{}{
+		"Tables": []interface{}{map[string]interface{}{
+			"TableName": "Table_0",
+			"Columns": []map[string]string{
+				{"ColumnName": "ResultType", "DataType": "String", "ColumnType": "string"},
+				{"ColumnName": "Format", "DataType": "String", "ColumnType": "string"},
+				{"ColumnName": "Content", "DataType": "String", "ColumnType": "string"},
+			},
+			"Rows": rows,
+		}},
+	})
+	require.NoError(t, err)
+	return b
+}
+
+const testPlan = `{"$type":"Project","Columns":["a","b"],"Source":{"$type":"Filter","Predicate":"a > 1",` +
+	`"Source":{"$type":"TableScan","Table":"T","Shards":[{"$type":"Shard","Id":1},{"$type":"Shard","Id":2}]}}}`
+
+func TestExplainQuery(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := queryMsg{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		assert.Equal(t, ".show queryplan <| T | where a > 1 | project a, b", msg.CSL)
+		assert.Equal(t, "db", msg.DB)
+		_, _ = w.Write(queryPlanResponse(t,
+			[]string{"QueryText", "Text", "T | where a > 1 | project a, b"},
+			[]string{"QueryPlan", "Json", testPlan},
+		))
+	}))
+	defer s.Close()
+
+	plan, err := retryClient(t, s.URL).ExplainQuery(context.Background(), "db", kql.New("T | where a > 1 | project a, b"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"QueryText": "T | where a > 1 | project a, b", "QueryPlan": testPlan}, plan.Results)
+
+	var got []string
+	plan.Root.Walk(func(node *PlanNode, depth int) {
+		got = append(got, strings.Repeat("  ", depth)+node.Operator)
+	})
+	assert.Equal(t, []string{"Project", "  Filter", "    TableScan", "      Shard", "      Shard"}, got)
+	assert.Equal(t, []interface{}{"a", "b"}, plan.Root.Properties["Columns"])
+	assert.Equal(t, "a > 1", plan.Root.Children[0].Properties["Predicate"])
+	assert.Equal(t, 2.0, plan.Root.Children[0].Children[0].Children[1].Properties["Id"])
+}
+
+func TestExplainQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		handler http.HandlerFunc
+		kind    errors.Kind
+	}{
+		{
+			desc: "Unsupported command",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":{"code":"BadRequest_SyntaxError","message":"Request is invalid and cannot be executed.",` +
+					`"@message":"Syntax error: unknown command '.show queryplan'"}}`))
+			},
+			kind: errors.KClientArgs,
+		},
+		{
+			desc: "No plan",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(queryPlanResponse(t, []string{"QueryText", "Text", "T"}))
+			},
+			kind: errors.KClientArgs,
+		},
+		{
+			desc: "Invalid plan",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(queryPlanResponse(t, []string{"QueryPlan", "Json", "{"}))
+			},
+			kind: errors.KInternal,
+		},
+		{
+			desc: "Query error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":{"code":"BadRequest_SyntaxError","@message":"Syntax error: unexpected token"}}`))
+			},
+			kind: errors.KHTTPError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			s := httptest.NewServer(test.handler)
+			defer s.Close()
+
+			_, err := retryClient(t, s.URL).ExplainQuery(context.Background(), "db", kql.New("T"))
+			require.Error(t, err)
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok)
+			assert.Equal(t, test.kind, e.Kind)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+const (
+	// DefaultBatchMaxBytes is the size at which a Batcher flushes when WithBatchMaxBytes() is not used.
+	DefaultBatchMaxBytes = int(maxStreamingSize)
+	// DefaultBatchMaxWait is the longest a record waits in a Batcher when WithBatchMaxWait() is not used.
+	DefaultBatchMaxWait = 1 * time.Second
+)
+
+// Batcher aggregates small records and ingests them as a single streaming ingestion, once either the size of the
+// buffered records reaches a threshold or the oldest buffered record has waited long enough.
+// Records are joined with newlines, so Batcher should only be used with line delimited formats (CSV, JSON, ...).
+// All the methods are safe to call from multiple goroutines.
+type Batcher struct {
+	ingestor    Ingestor
+	maxBytes    int
+	maxWait     time.Duration
+	onError     func(err error, records int)
+	fileOptions []FileOption
+
+	// flushMu serializes flushes, so that batches are ingested in the order they were filled.
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	buf     []byte
+	records int
+	timer   *time.Timer
+	closed  bool
+}
+
+// BatcherOption is an optional argument to NewBatcher().
+type BatcherOption func(b *Batcher)
+
+// WithBatchMaxBytes sets the size of the buffered records at which a Batcher flushes. Defaults to DefaultBatchMaxBytes.
+func WithBatchMaxBytes(n int) BatcherOption {
+	return func(b *Batcher) {
+		b.maxBytes = n
+	}
+}
+
+// WithBatchMaxWait sets how long a record can wait in a Batcher before the batch is flushed. Defaults to DefaultBatchMaxWait.
+func WithBatchMaxWait(d time.Duration) BatcherOption {
+	return func(b *Batcher) {
+		b.maxWait = d
+	}
+}
+
+// WithBatchErrorHandler sets a function that is called with the error of every batch that fails to be ingested by
+// a flush that was not requested with Flush() or Close(), along with the number of records in the batch.
+// Without it, those errors are dropped.
+func WithBatchErrorHandler(f func(err error, records int)) BatcherOption {
+	return func(b *Batcher) {
+		b.onError = f
+	}
+}
+
+// WithBatchFileOptions sets the options passed to FromReader() for every batch, such as the format or the mapping.
+func WithBatchFileOptions(options ...FileOption) BatcherOption {
+	return func(b *Batcher) {
+		b.fileOptions = options
+	}
+}
+
+// NewBatcher is the constructor for Batcher. ingestor is usually a *Streaming client, and is not closed by Close().
+func NewBatcher(ingestor Ingestor, options ...BatcherOption) (*Batcher, error) {
+	if ingestor == nil {
+		return nil, errors.ES(errors.OpIngestStream, errors.KClientArgs, "NewBatcher() requires an ingestor").SetNoRetry()
+	}
+
+	b := &Batcher{
+		ingestor: ingestor,
+		maxBytes: DefaultBatchMaxBytes,
+		maxWait:  DefaultBatchMaxWait,
+	}
+	for _, o := range options {
+		o(b)
+	}
+
+	if b.maxBytes <= 0 {
+		return nil, errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithBatchMaxBytes() requires a positive size, got %d", b.maxBytes).SetNoRetry()
+	}
+	if b.maxWait <= 0 {
+		return nil, errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithBatchMaxWait() requires a positive duration, got %s", b.maxWait).SetNoRetry()
+	}
+
+	return b, nil
+}
+
+// Add buffers the record. If the buffered records reach the size set by WithBatchMaxBytes(), the batch is ingested
+// before Add returns, and a failure is reported to the error handler. Add returns an error once the Batcher is closed.
+func (b *Batcher) Add(ctx context.Context, record []byte) error {
+	if len(record) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errors.ES(errors.OpIngestStream, errors.KClientArgs, "Add() was called on a closed Batcher").SetNoRetry()
+	}
+
+	b.buf = append(b.buf, record...)
+	if record[len(record)-1] != '\n' {
+		b.buf = append(b.buf, '\n')
+	}
+	b.records++
+
+	full := len(b.buf) >= b.maxBytes
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.maxWait, b.flushOnTimer)
+	}
+	b.mu.Unlock()
+
+	if full {
+		records, err := b.flush(ctx)
+		b.report(err, records)
+	}
+	return nil
+}
+
+// Flush ingests the buffered records, if any, and returns the error of the ingestion.
+func (b *Batcher) Flush(ctx context.Context) error {
+	_, err := b.flush(ctx)
+	return err
+}
+
+// Close ingests the buffered records and stops the Batcher. Further calls to Add() return an error.
+// Close does not close the ingestor passed to NewBatcher().
+func (b *Batcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	return b.Flush(ctx)
+}
+
+// take removes the buffered records from the Batcher.
+func (b *Batcher) take() ([]byte, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	buf, records := b.buf, b.records
+	b.buf, b.records = nil, 0
+	return buf, records
+}
+
+// flush ingests the buffered records and returns the number of records in the batch with the error of the ingestion.
+func (b *Batcher) flush(ctx context.Context) (int, error) {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	buf, records := b.take()
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	_, err := b.ingestor.FromReader(ctx, bytes.NewReader(buf), b.fileOptions...)
+	return records, err
+}
+
+func (b *Batcher) flushOnTimer() {
+	records, err := b.flush(context.Background())
+	b.report(err, records)
+}
+
+func (b *Batcher) report(err error, records int) {
+	if err != nil && b.onError != nil {
+		b.onError(err, records)
+	}
+}
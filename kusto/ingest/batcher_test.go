@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRecorder is an Ingestor that records the payload of every FromReader() call.
+type batchRecorder struct {
+	mu       sync.Mutex
+	payloads []string
+	err      error
+}
+
+func (r *batchRecorder) Close() error {
+	return nil
+}
+
+func (r *batchRecorder) FromFile(context.Context, string, ...FileOption) (*Result, error) {
+	panic("not implemented")
+}
+
+func (r *batchRecorder) FromReader(_ context.Context, reader io.Reader, _ ...FileOption) (*Result, error) {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloads = append(r.payloads, string(b))
+	if r.err != nil {
+		return nil, r.err
+	}
+	return newResult(), nil
+}
+
+func (r *batchRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.payloads...)
+}
+
+func TestBatcherMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{}
+	b, err := NewBatcher(rec, WithBatchMaxBytes(10), WithBatchMaxWait(time.Hour))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, b.Add(ctx, []byte("a,1")))
+	require.NoError(t, b.Add(ctx, []byte("b,2\n")))
+	assert.Empty(t, rec.get())
+
+	require.NoError(t, b.Add(ctx, []byte("c,3")))
+	assert.Equal(t, []string{"a,1\nb,2\nc,3\n"}, rec.get())
+
+	require.NoError(t, b.Add(ctx, []byte("d,4")))
+	require.NoError(t, b.Close(ctx))
+	assert.Equal(t, []string{"a,1\nb,2\nc,3\n", "d,4\n"}, rec.get())
+
+	err = b.Add(ctx, []byte("e,5"))
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+}
+
+func TestBatcherMaxWait(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{}
+	b, err := NewBatcher(rec, WithBatchMaxWait(10*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, b.Add(context.Background(), []byte("a,1")))
+	assert.Eventually(t, func() bool { return len(rec.get()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"a,1\n"}, rec.get())
+
+	require.NoError(t, b.Flush(context.Background()))
+	assert.Len(t, rec.get(), 1, "an empty batch should not be ingested")
+}
+
+func TestBatcherErrors(t *testing.T) {
+	t.Parallel()
+
+	ingestErr := errors.ES(errors.OpIngestStream, errors.KHTTPError, "failed")
+	rec := &batchRecorder{err: ingestErr}
+
+	var (
+		mu       sync.Mutex
+		reported []int
+	)
+	b, err := NewBatcher(rec, WithBatchMaxBytes(4), WithBatchMaxWait(time.Hour), WithBatchErrorHandler(func(err error, records int) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, ingestErr, err)
+		reported = append(reported, records)
+	}))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, b.Add(ctx, []byte("a,1")), "a failed batch is reported to the handler, not to Add")
+	require.NoError(t, b.Add(ctx, []byte("b")))
+	assert.Equal(t, ingestErr, b.Flush(ctx), "Flush returns the error of the batch")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{1}, reported)
+}
+
+func TestBatcherConcurrentAdd(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{}
+	b, err := NewBatcher(rec, WithBatchMaxBytes(100), WithBatchMaxWait(time.Millisecond))
+	require.NoError(t, err)
+
+	const goroutines, perGoroutine = 10, 100
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		g := g
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				assert.NoError(t, b.Add(context.Background(), []byte(fmt.Sprintf("%d,%d", g, i))))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, b.Close(context.Background()))
+
+	seen := map[string]bool{}
+	for _, p := range rec.get() {
+		for _, line := range strings.Split(strings.TrimSuffix(p, "\n"), "\n") {
+			assert.False(t, seen[line], "record %s was ingested twice", line)
+			seen[line] = true
+		}
+	}
+	assert.Len(t, seen, goroutines*perGoroutine)
+}
+
+func TestNewBatcherErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewBatcher(nil)
+	assert.Error(t, err)
+	_, err = NewBatcher(&batchRecorder{}, WithBatchMaxBytes(0))
+	assert.Error(t, err)
+	_, err = NewBatcher(&batchRecorder{}, WithBatchMaxWait(-time.Second))
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+)
+
+// dirConcurrency is the maximum amount of files that FromDir ingests at the same time.
+const dirConcurrency = 8
+
+// FromDir ingests the files in the directory dir, each one the same way FromFile would. The data format and the
+// compression of every file are discovered from its name, unless set by the options.
+// Use WithGlob to only ingest the files that match a pattern and WithRecursive to also ingest the files of sub
+// directories. Files are ingested in parallel, and the results are returned in the lexical order of the file paths.
+// By default, FromDir stops at the first file that fails to ingest and returns its error. With WithContinueOnError,
+// all the files are ingested and the returned error is an *errors.CombinedError of all the failures; the results of
+// the files that failed are nil.
+func (i *Ingestion) FromDir(ctx context.Context, dir string, options ...FileOption) ([]*Result, error) {
+	props := i.newProp()
+	for _, o := range options {
+		if err := o.Run(&props, QueuedClient, FromFile); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := dirFiles(dir, props.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*Result, len(files))
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, dirConcurrency)
+	wg := sync.WaitGroup{}
+	once := sync.Once{}
+	var firstErr error
+
+	for n, file := range files {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[n] = dirFileErr(file, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(n int, file string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[n], errs[n] = i.FromFile(ctx, file, options...)
+			if errs[n] != nil {
+				errs[n] = dirFileErr(file, errs[n])
+				if !props.Dir.ContinueOnError {
+					once.Do(func() {
+						firstErr = errs[n]
+						cancel()
+					})
+				}
+			}
+		}(n, file)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		return results, nil
+	case props.Dir.ContinueOnError:
+		return results, errors.GetCombinedError(failed...)
+	case firstErr != nil:
+		return nil, firstErr
+	default:
+		return nil, failed[0]
+	}
+}
+
+// dirFiles returns the paths of the files in dir that FromDir should ingest, in lexical order.
+func dirFiles(dir string, opts properties.Dir) ([]string, error) {
+	glob := opts.Glob
+	if glob == "" {
+		glob = "*"
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !opts.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		match, err := filepath.Match(glob, d.Name())
+		if err != nil {
+			return err
+		}
+		if match {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "could not list the files of directory %q: %s", dir, err).SetNoRetry()
+	}
+
+	return files, nil
+}
+
+// dirFileErr annotates err with the file that failed to ingest.
+func dirFileErr(file string, err error) error {
+	if e, ok := errors.GetKustoError(err); ok {
+		outer := errors.ES(e.Op, e.Kind, "could not ingest file %q", file)
+		if !errors.Retry(e) {
+			outer.SetNoRetry()
+		}
+		return errors.W(e, outer)
+	}
+	return fmt.Errorf("could not ingest file %q: %w", file, err)
+}
@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", "b.json", "c.csv", filepath.Join("sub", "d.csv")} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("a,b\n"), 0644))
+	}
+
+	tests := []struct {
+		desc    string
+		options []FileOption
+		failOn  string
+		want    []string
+		wantErr bool
+		wantNil []int
+	}{
+		{
+			desc: "All files",
+			want: []string{"a.csv", "b.json", "c.csv"},
+		},
+		{
+			desc:    "Glob",
+			options: []FileOption{WithGlob("*.csv")},
+			want:    []string{"a.csv", "c.csv"},
+		},
+		{
+			desc:    "Recursive",
+			options: []FileOption{WithGlob("*.csv"), WithRecursive()},
+			want:    []string{"a.csv", "c.csv", filepath.Join("sub", "d.csv")},
+		},
+		{
+			desc:    "Stop on error",
+			options: []FileOption{WithGlob("*.csv")},
+			failOn:  "a.csv",
+			wantErr: true,
+		},
+		{
+			desc:    "Continue on error",
+			options: []FileOption{WithContinueOnError()},
+			failOn:  "b.json",
+			want:    []string{"a.csv", "b.json", "c.csv"},
+			wantErr: true,
+			wantNil: []int{1},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				auth:     kusto.Authorization{},
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(client, "db", "table")
+			require.NoError(t, err)
+
+			mu := sync.Mutex{}
+			var ingested []string
+			ingestion.fs = resources.FsMock{
+				OnLocal: func(ctx context.Context, from string, props properties.All) error {
+					rel, err := filepath.Rel(dir, from)
+					require.NoError(t, err)
+					mu.Lock()
+					ingested = append(ingested, rel)
+					mu.Unlock()
+					if rel == test.failOn {
+						return errors.ES(errors.OpFileIngest, errors.KBlobstore, "upload failed").SetNoRetry()
+					}
+					return nil
+				},
+			}
+
+			results, err := ingestion.FromDir(context.Background(), dir, test.options...)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), filepath.Join(dir, test.failOn))
+				e, ok := errors.GetKustoError(err)
+				if test.wantNil == nil {
+					require.True(t, ok)
+					assert.Equal(t, errors.KBlobstore, e.Kind)
+					assert.Nil(t, results)
+					return
+				}
+				assert.IsType(t, &errors.CombinedError{}, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			sort.Strings(ingested)
+			assert.Equal(t, test.want, ingested)
+			require.Len(t, results, len(test.want))
+			for n, result := range results {
+				if len(test.wantNil) > 0 && test.wantNil[0] == n {
+					assert.Nil(t, result)
+					continue
+				}
+				require.NotNil(t, result)
+				assert.Equal(t, Queued, result.record.Status)
+				assert.Equal(t, filepath.Join(dir, test.want[n]), result.record.IngestionSourcePath)
+			}
+		})
+	}
+}
@@ -1,11 +1,61 @@
 package ingest
 
 import (
+	"net/url"
 	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 )
 
 const ingestPrefix = "ingest-"
 
+// kustoDomains are the domains of the Kusto endpoints, in the public and national clouds, Synapse and Fabric.
+var kustoDomains = []string{
+	".kusto.windows.net",
+	".kustomfa.windows.net",
+	".kusto.data.microsoft.com",
+	".kusto.usgovcloudapi.net",
+	".kusto.chinacloudapi.cn",
+	".kusto.cloudapi.de",
+	".kusto.azuresynapse.net",
+	".kusto.fabric.microsoft.com",
+}
+
 func removeIngestPrefix(s string) string {
 	return strings.Replace(s, ingestPrefix, "", 1)
 }
+
+// DataManagementURI returns the data management endpoint, which ingestion is sent to, of the cluster with the query
+// endpoint queryURL: "https://cluster.region.kusto.windows.net" gives "https://ingest-cluster.region.kusto.windows.net".
+// A data management endpoint is returned as is.
+// It returns an errors.KClientArgs error if queryURL is not an https URL with the host of a Kusto cluster. Clusters
+// behind a custom domain or a private endpoint need their data management endpoint to be known instead.
+// The clients don't need it: New() and the other constructors take a client of the query endpoint, and send
+// ingestion to its data management endpoint.
+func DataManagementURI(queryURL string) (string, error) {
+	u, err := url.Parse(queryURL)
+	if err != nil {
+		return "", errors.ES(errors.OpServConn, errors.KClientArgs, "could not parse the endpoint(%s): %s", queryURL, err).SetNoRetry()
+	}
+	if u.Scheme != "https" {
+		return "", errors.ES(errors.OpServConn, errors.KClientArgs, "the endpoint(%s) must be an https URL", queryURL).SetNoRetry()
+	}
+
+	host := strings.ToLower(u.Hostname())
+	known := false
+	for _, domain := range kustoDomains {
+		if strings.HasSuffix(host, domain) && len(host) > len(domain) {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", errors.ES(errors.OpServConn, errors.KClientArgs,
+			"the host of the endpoint(%s) is not a known Kusto cluster host, like cluster.region.kusto.windows.net", queryURL).SetNoRetry()
+	}
+
+	if !strings.HasPrefix(host, ingestPrefix) {
+		u.Host = ingestPrefix + u.Host
+	}
+	return u.String(), nil
+}
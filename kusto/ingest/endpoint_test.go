@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataManagementURI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc  string
+		query string
+		want  string
+		err   bool
+	}{
+		{desc: "Public cloud", query: "https://cluster.westus.kusto.windows.net", want: "https://ingest-cluster.westus.kusto.windows.net"},
+		{desc: "Port and path", query: "https://cluster.kusto.windows.net:443/", want: "https://ingest-cluster.kusto.windows.net:443/"},
+		{desc: "National cloud", query: "https://cluster.kusto.usgovcloudapi.net", want: "https://ingest-cluster.kusto.usgovcloudapi.net"},
+		{desc: "Fabric", query: "https://trd-abc.z1.kusto.fabric.microsoft.com", want: "https://ingest-trd-abc.z1.kusto.fabric.microsoft.com"},
+		{desc: "Mixed case", query: "https://Cluster.Kusto.Windows.Net", want: "https://ingest-Cluster.Kusto.Windows.Net"},
+		{desc: "Already an ingest endpoint", query: "https://ingest-cluster.kusto.windows.net", want: "https://ingest-cluster.kusto.windows.net"},
+		{desc: "Not https", query: "http://cluster.kusto.windows.net", err: true},
+		{desc: "Unknown host", query: "https://cluster.example.com", err: true},
+		{desc: "Bare domain", query: "https://.kusto.windows.net", err: true},
+		{desc: "Not a URL", query: "://cluster", err: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := DataManagementURI(test.query)
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package ingest
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/ingestoptions"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
 	"github.com/cenkalti/backoff/v4"
 )
 
@@ -94,8 +101,25 @@ func (o option) Run(p *properties.All, clientType ClientScope, sourceType Source
 	return o.run(p)
 }
 
+// alias returns o under the name of a deprecated option that is kept as an alias of it, so that the String() and the
+// errors of the deprecated option don't change.
+func alias(o FileOption, name string) FileOption {
+	opt := o.(option)
+	opt.name = name
+	return opt
+}
+
 // Database overrides the default database name.
+//
+// Deprecated: Use WithDatabase() instead.
 func Database(name string) FileOption {
+	return alias(WithDatabase(name), "Database")
+}
+
+// WithDatabase makes the call ingest into the database name, instead of the one the client was created with. With
+// WithTable(), a single client can ingest into many tables, sharing its resources and their cache.
+// The call fails with an errors.KClientArgs error if the database is empty.
+func WithDatabase(name string) FileOption {
 	return option{
 		run: func(p *properties.All) error {
 			p.Ingestion.DatabaseName = name
@@ -103,12 +127,20 @@ func Database(name string) FileOption {
 		},
 		clientScopes: QueuedClient | StreamingClient | ManagedClient,
 		sourceScope:  FromFile | FromReader | FromBlob,
-		name:         "Database",
+		name:         "WithDatabase",
 	}
 }
 
 // Table overrides the default table name.
+//
+// Deprecated: Use WithTable() instead.
 func Table(name string) FileOption {
+	return alias(WithTable(name), "Table")
+}
+
+// WithTable makes the call ingest into the table name, instead of the one the client was created with. See
+// WithDatabase(). The call fails with an errors.KClientArgs error if the table is empty.
+func WithTable(name string) FileOption {
 	return option{
 		run: func(p *properties.All) error {
 			p.Ingestion.TableName = name
@@ -116,12 +148,12 @@ func Table(name string) FileOption {
 		},
 		clientScopes: QueuedClient | StreamingClient | ManagedClient,
 		sourceScope:  FromFile | FromReader | FromBlob,
-		name:         "Table",
+		name:         "WithTable",
 	}
 }
 
-// DontCompress sets whether to compress the data. 	In streaming - do not pass DontCompress if file is not already compressed.
-
+// DontCompress sets whether to compress the data. Content that is already gzip compressed is never compressed again, so
+// DontCompress is only needed to send other content uncompressed.
 func DontCompress() FileOption {
 	return option{
 		run: func(p *properties.All) error {
@@ -134,6 +166,28 @@ func DontCompress() FileOption {
 	}
 }
 
+// WithUploadMode sets how the queued client uploads a local file to Blob Storage, for the cases where the default,
+// ingestoptions.UploadModeAuto, picks wrong. ingestoptions.UploadModeStream reads the file sequentially, which suits
+// network file systems that report unreliable sizes. ingestoptions.UploadModeFile uploads the blocks of the file
+// in parallel, based on its size, and fails for files that the client would compress; use it with DontCompress() or
+// compressed files.
+func WithUploadMode(mode ingestoptions.UploadMode) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			switch mode {
+			case ingestoptions.UploadModeAuto, ingestoptions.UploadModeStream, ingestoptions.UploadModeFile:
+			default:
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithUploadMode() does not support the upload mode %d", mode).SetNoRetry()
+			}
+			p.Source.UploadMode = mode
+			return nil
+		},
+		clientScopes: QueuedClient,
+		sourceScope:  FromFile,
+		name:         "WithUploadMode",
+	}
+}
+
 func backOff(off *backoff.ExponentialBackOff) FileOption {
 	return option{
 		run: func(p *properties.All) error {
@@ -146,8 +200,97 @@ func backOff(off *backoff.ExponentialBackOff) FileOption {
 	}
 }
 
+// WithStreamingSizeLimit sets the size, in bytes, above which the managed client uses queued ingestion instead of
+// streaming. For FromReader(), the managed client buffers up to n bytes of the compressed payload in memory: if the
+// payload ends within them it is streamed, otherwise the buffer and the rest of the payload are ingested with queued
+// ingestion. For files and blobs, n is compared to the size of the file, after estimating its compressed size.
+// n must be positive and at most the streaming ingestion limit of the service, 4MB, which is the default.
+func WithStreamingSizeLimit(n int) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if n <= 0 || int64(n) > maxStreamingSize {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithStreamingSizeLimit() requires a size between 1 and %d, got %d", maxStreamingSize, n).SetNoRetry()
+			}
+			p.ManagedStreaming.SizeLimit = int64(n)
+			return nil
+		},
+		clientScopes: ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithStreamingSizeLimit",
+	}
+}
+
+// WithRecordSplitting makes the managed client split a payload that is larger than the streaming size limit into
+// several streaming ingestions, instead of falling back to queued ingestion, which keeps the latency of streaming.
+// Chunks are cut after the last delimiter they hold, like '\n' for CSV or JSON lines, so a record is never split between
+// two ingestions, and every chunk is compressed on its own. Payloads in binary formats, which can't be split, are not.
+// If a record is larger than the limit, that record and the rest of the payload are ingested with queued ingestion.
+func WithRecordSplitting(delimiter byte) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.ManagedStreaming.SplitRecords = true
+			p.ManagedStreaming.SplitDelimiter = delimiter
+			return nil
+		},
+		clientScopes: ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithRecordSplitting",
+	}
+}
+
+// WithFallbackMinRemaining makes the managed client fall back from streaming to queued ingestion only if at least d
+// remains until the context deadline. Otherwise, the reason for the fallback (such as the streaming error) is returned,
+// annotated with errors.KTimeout, instead of starting a queued ingestion that would not finish in time.
+// If the context has no deadline, this has no effect. By default, the fallback only fails if the deadline has passed.
+func WithFallbackMinRemaining(d time.Duration) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if d < 0 {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithFallbackMinRemaining() requires a non-negative duration, got %s", d).SetNoRetry()
+			}
+			p.ManagedStreaming.FallbackMinRemaining = d
+			return nil
+		},
+		clientScopes: ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithFallbackMinRemaining",
+	}
+}
+
+// WithManagedStreamingRetries sets how many times the managed client retries a streaming ingestion that failed with a
+// transient error, such as a timeout, throttling or a server error, before falling back to queued ingestion. The
+// default is 2, and 0 falls back on the first transient error. Other errors are not retried: if the payload is too
+// large for streaming, or streaming ingestion is not enabled on the table, the managed client falls back to queued
+// ingestion right away, and other failures, such as an authorization failure, are returned.
+func WithManagedStreamingRetries(n int) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if n < 0 {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithManagedStreamingRetries() requires a non-negative number of retries, got %d", n).SetNoRetry()
+			}
+			p.ManagedStreaming.Retries = n
+			return nil
+		},
+		clientScopes: ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithManagedStreamingRetries",
+	}
+}
+
 // FlushImmediately  the service batching manager will not aggregate this file, thus overriding the batching policy
+//
+// Deprecated: Use WithFlushImmediately() instead.
 func FlushImmediately() FileOption {
+	return alias(WithFlushImmediately(), "FlushImmediately")
+}
+
+// WithFlushImmediately asks the service to ingest the data as soon as it is dequeued, instead of aggregating it with
+// other ingestions according to the table's ingestion batching policy. This lowers the latency of queued ingestion,
+// for any data format, at a cost: every ingestion creates its own extents, which are small when the data is small.
+// Many small extents make queries slower and make the service spend more resources merging them, so this should be
+// kept for ingestions that are both latency sensitive and large, or rare.
+// See: https://docs.microsoft.com/en-us/azure/data-explorer/kusto/management/batchingpolicy
+func WithFlushImmediately() FileOption {
 	return option{
 		run: func(p *properties.All) error {
 			p.Ingestion.FlushImmediately = true
@@ -155,11 +298,13 @@ func FlushImmediately() FileOption {
 		},
 		clientScopes: QueuedClient | ManagedClient,
 		sourceScope:  FromFile | FromReader | FromBlob,
-		name:         "FlushImmediately",
+		name:         "WithFlushImmediately",
 	}
 }
 
-// IgnoreFirstRecord tells Kusto to flush on write.
+// IgnoreFirstRecord tells the service to skip the first record of the data.
+//
+// Deprecated: Use WithIgnoreFirstRecord(), which also validates the format.
 func IgnoreFirstRecord() FileOption {
 	return option{
 		run: func(p *properties.All) error {
@@ -172,6 +317,25 @@ func IgnoreFirstRecord() FileOption {
 	}
 }
 
+// WithIgnoreFirstRecord tells the service to skip the first record of the data, such as the header row of a CSV export,
+// instead of ingesting it. It sets the ignoreFirstRecord ingestion property, and can only be used with the formats of
+// separated values: CSV, TSV, TSVE, PSV, SCSV and SOHSV. Other formats fail with an errors.KClientArgs error.
+// Streaming ingestion doesn't support it, so a managed client always uses queued ingestion with this option.
+func WithIgnoreFirstRecord() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Ingestion.Additional.IgnoreFirstRecord = true
+			if !p.Ingestion.Additional.FirstRecordMatchesFormat() {
+				return queued.FirstRecordFormatError(p.Ingestion.Additional.Format)
+			}
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithIgnoreFirstRecord",
+	}
+}
+
 // DataFormat indicates what type of encoding format was used for source data.
 // Not all options can be used in every method.
 type DataFormat = properties.DataFormat
@@ -226,11 +390,22 @@ func InferFormatFromFileName(fName string) DataFormat {
 	return properties.DataFormatDiscovery(fName)
 }
 
+// SniffFormat detects the format of the content of r from its first few KB: JSON, MultiJSON, CSV or TSV, also when it
+// is gzip compressed. Ambiguous content returns DFUnknown, and needs WithFileFormat(). The returned reader reads all of
+// the content of r, including the inspected bytes.
+// FromReader() uses it when no format is set, and falls back to CSV for DFUnknown.
+func SniffFormat(r io.Reader) (DataFormat, io.Reader, error) {
+	return properties.SniffFormat(r)
+}
+
 // IngestionMapping provides runtime mapping of the data being imported to the fields in the table.
 // "ref" will be JSON encoded, so it can be any type that can be JSON marshalled. If you pass a string
 // or []byte, it will be interpreted as already being JSON encoded.
 // mappingKind can only be: CSV, JSON, AVRO, Parquet or ORC.
 // The mappingKind parameter will also automatically set the FileFormat option.
+// The mapping is validated before anything is uploaded: it must be a JSON array of column mappings that all have a
+// Column, and for JSON mappings a DataType and a Properties.Path, and mappingKind must be the kind of mapping of the
+// format set by the options before it, if any. Otherwise an errors.KClientArgs error names the invalid entry.
 func IngestionMapping(mapping interface{}, mappingKind DataFormat) FileOption {
 	return option{
 		run: func(p *properties.All) error {
@@ -260,6 +435,20 @@ func IngestionMapping(mapping interface{}, mappingKind DataFormat) FileOption {
 				j = string(b)
 			}
 
+			if p.Ingestion.Additional.IngestionMappingRef != "" {
+				return errInlineAndRefMapping("IngestionMapping()")
+			}
+			if format := p.Ingestion.Additional.Format; format != DFUnknown && format.MappingKind() != mappingKind {
+				return errors.ES(
+					errors.OpUnknown,
+					errors.KClientArgs,
+					"IngestionMapping() option with a %v mapping cannot be used with the %v format", mappingKind, format,
+				).SetNoRetry()
+			}
+			if err := properties.ValidateMapping(j, mappingKind); err != nil {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "IngestionMapping() option has an invalid mapping: %s", err).SetNoRetry()
+			}
+
 			p.Ingestion.Additional.IngestionMapping = j
 			p.Ingestion.Additional.IngestionMappingType = mappingKind
 			p.Ingestion.Additional.Format = mappingKind
@@ -282,6 +471,9 @@ func IngestionMappingRef(refName string, mappingKind DataFormat) FileOption {
 			if !mappingKind.IsValidMappingKind() {
 				return errors.ES(errors.OpUnknown, errors.KClientArgs, "IngestionMappingRef() option does not support EncodingType %v", mappingKind).SetNoRetry()
 			}
+			if p.Ingestion.Additional.IngestionMapping != "" {
+				return errInlineAndRefMapping("IngestionMappingRef()")
+			}
 			p.Ingestion.Additional.IngestionMappingRef = refName
 			p.Ingestion.Additional.IngestionMappingType = mappingKind
 			p.Ingestion.Additional.Format = mappingKind
@@ -293,6 +485,82 @@ func IngestionMappingRef(refName string, mappingKind DataFormat) FileOption {
 	}
 }
 
+// WithIngestionMappingRef provides the name of a mapping that was created on the table with
+// ".create table ingestion mapping", instead of sending the mapping with every ingestion.
+// mappingKind can only be: CSV, JSON, AVRO, Parquet or ORC. Unlike IngestionMappingRef(), it does not set the format,
+// which is discovered from the file name or set with WithFileFormat(), and the ingestion fails with an errors.KClientArgs
+// error if mappingKind is not the kind of mapping used by that format (for example, JSON for MultiJSON).
+// It cannot be used with an inline mapping option, such as IngestionMapping().
+func WithIngestionMappingRef(name string, mappingKind DataFormat) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if name == "" {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "WithIngestionMappingRef() requires a mapping name").SetNoRetry()
+			}
+			if !mappingKind.IsValidMappingKind() {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "WithIngestionMappingRef() option does not support EncodingType %v", mappingKind).SetNoRetry()
+			}
+			if p.Ingestion.Additional.IngestionMapping != "" {
+				return errInlineAndRefMapping("WithIngestionMappingRef()")
+			}
+			p.Ingestion.Additional.IngestionMappingRef = name
+			p.Ingestion.Additional.IngestionMappingType = mappingKind
+			return nil
+		},
+		clientScopes: QueuedClient | StreamingClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithIngestionMappingRef",
+	}
+}
+
+// errInlineAndRefMapping is returned when an ingestion is given both an inline mapping and a mapping reference.
+func errInlineAndRefMapping(option string) error {
+	return errors.ES(errors.OpUnknown, errors.KClientArgs, "%s option cannot be used with both an inline ingestion mapping and a mapping reference", option).SetNoRetry()
+}
+
+// W3CColumnMapping maps a field of a W3C Extended Log File to a column of the table. Transform is optional.
+type W3CColumnMapping = properties.W3CColumnMapping
+
+// WithW3CLogMapping provides runtime mapping of the fields of a W3C Extended Log File to the columns of the table.
+// The data format must be W3CLogFile. If the format is not set, it is set to W3CLogFile.
+func WithW3CLogMapping(mapping []W3CColumnMapping) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			switch p.Ingestion.Additional.Format {
+			case DFUnknown:
+				p.Ingestion.Additional.Format = W3CLogFile
+			case W3CLogFile:
+			default:
+				return errors.ES(
+					errors.OpUnknown,
+					errors.KClientArgs,
+					"WithW3CLogMapping() option requires the W3CLogFile format, but the format is %v", p.Ingestion.Additional.Format,
+				).SetNoRetry()
+			}
+
+			w3cMapping := properties.W3CLogFileMapping(mapping)
+			if err := w3cMapping.Validate(); err != nil {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "WithW3CLogMapping() option: %s", err).SetNoRetry()
+			}
+
+			b, err := json.Marshal(w3cMapping)
+			if err != nil {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "WithW3CLogMapping() option could not be JSON encoded: %s", err).SetNoRetry()
+			}
+
+			if p.Ingestion.Additional.IngestionMappingRef != "" {
+				return errInlineAndRefMapping("WithW3CLogMapping()")
+			}
+			p.Ingestion.Additional.IngestionMapping = string(b)
+			p.Ingestion.Additional.IngestionMappingType = W3CLogFile
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithW3CLogMapping",
+	}
+}
+
 // DeleteSource deletes the source file from when it has been uploaded to Kusto.
 func DeleteSource() FileOption {
 	return option{
@@ -306,6 +574,194 @@ func DeleteSource() FileOption {
 	}
 }
 
+// WithRetainBlob keeps the blob that the client uploads for the ingestion once the ingestion succeeds, for auditing.
+// The blob is tagged with the blob index tag "kusto-retain=true", so that a retention or lifecycle policy can find it,
+// and the blob URL (without the SAS token) is returned by Result.BlobURL().
+// Setting a blob index tag requires the "t" permission on the SAS token of the container, which the containers the
+// service provides for ingestion may not grant: the upload then fails with HTTP 403 and an errors.KBlobstore error.
+// The tag is not replaced with blob metadata in that case, use WithBlobMetadata() to mark the blobs with metadata.
+// It cannot be used with WithDeleteBlobOnSuccess().
+func WithRetainBlob() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if p.Source.DeleteBlobOnSuccess {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithRetainBlob() cannot be used with WithDeleteBlobOnSuccess()").SetNoRetry()
+			}
+			p.Source.RetainBlob = true
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithRetainBlob",
+	}
+}
+
+// WithDeleteBlobOnSuccess asks the service to delete the blob that the client uploads for the ingestion once the
+// ingestion succeeds. A blob whose ingestion failed is kept, so that it can be inspected.
+// It cannot be used with WithRetainBlob().
+func WithDeleteBlobOnSuccess() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if p.Source.RetainBlob {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithDeleteBlobOnSuccess() cannot be used with WithRetainBlob()").SetNoRetry()
+			}
+			p.Source.DeleteBlobOnSuccess = true
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithDeleteBlobOnSuccess",
+	}
+}
+
+// WithSchemaValidation checks, before the data is sent, that every column of the ingestion mapping exists in the table
+// with the type the mapping declares, so that a misconfigured ingestion fails fast with an errors.KClientArgs error
+// listing the offending columns, instead of failing later in the service. The mapping is the inline one, or the one
+// named with IngestionMappingRef() or WithIngestionMappingRef(), which is fetched from the service.
+// The schema of the table and its mappings are cached by the client for a minute, so that ingesting many files only
+// costs one round-trip per minute. Without a mapping, only the existence of the table is checked.
+func WithSchemaValidation() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Source.ValidateSchema = true
+			return nil
+		},
+		clientScopes: QueuedClient | StreamingClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithSchemaValidation",
+	}
+}
+
+// WithCreateTableIfNotExists creates the table before ingesting, if it doesn't exist, by running a
+// ".create table ifnotexists" command with the client of the ingestor. schema is the column definition list of the
+// table, like "(id:long, name:string)". It is not checked against an existing table.
+// The command is run once for parallel ingestions into the same table, and its failure is returned as an errors.OpMgmt
+// error, to tell it apart from a failure of the ingestion.
+func WithCreateTableIfNotExists(schema string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			columns, err := parseColumnList(schema)
+			if err != nil {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithCreateTableIfNotExists() has an invalid schema: %s", err).SetNoRetry()
+			}
+			p.Source.CreateTableSchema = columns
+			return nil
+		},
+		clientScopes: QueuedClient | StreamingClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithCreateTableIfNotExists",
+	}
+}
+
+// blobMetadataKeyRe matches the metadata names that Azure Blob Storage accepts.
+var blobMetadataKeyRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// WithBlobMetadata sets custom metadata (such as the team or pipeline that owns the data) on the blobs that the client
+// uploads for the ingestion. Keys must start with a letter and contain only letters, digits and underscores.
+func WithBlobMetadata(metadata map[string]string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			m := make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				if !blobMetadataKeyRe.MatchString(k) {
+					return errors.ES(
+						errors.OpFileIngest,
+						errors.KClientArgs,
+						"WithBlobMetadata() key %q is not a valid metadata name, it must start with a letter and contain only letters, digits and underscores", k,
+					).SetNoRetry()
+				}
+				m[k] = v
+			}
+			p.Source.BlobMetadata = m
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithBlobMetadata",
+	}
+}
+
+// WithBlobNameTemplate sets the template for the names of the blobs that the client uploads for the ingestion, which
+// makes them easier to find in the storage account. The template can contain the placeholders {db}, {table},
+// {date} (the upload date, as YYYY-MM-DD), {uuid} and {file} (the name of the source file), which are expanded when
+// the blob is created, and the file extension is added to it. For example: "{db}_{table}_{date}_{uuid}".
+// To keep names unique, "_{uuid}" is appended to templates that don't contain {uuid}. Templates can't contain path
+// separators or any character that isn't URL-safe.
+func WithBlobNameTemplate(template string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if err := queued.ValidateBlobNameTemplate(template); err != nil {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithBlobNameTemplate(): %s", err).SetNoRetry()
+			}
+			p.Source.BlobNameTemplate = template
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithBlobNameTemplate",
+	}
+}
+
+// WithGlob makes FromDir and FromTarGz only ingest the files whose name matches pattern, using the syntax of
+// filepath.Match.
+// For example, "*.csv" ingests all the CSV files.
+func WithGlob(pattern string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithGlob() pattern %q is invalid: %s", pattern, err).SetNoRetry()
+			}
+			p.Dir.Glob = pattern
+			return nil
+		},
+		clientScopes: QueuedClient,
+		sourceScope:  FromFile,
+		name:         "WithGlob",
+	}
+}
+
+// WithRecursive makes FromDir also ingest the files of the sub directories.
+func WithRecursive() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Dir.Recursive = true
+			return nil
+		},
+		clientScopes: QueuedClient,
+		sourceScope:  FromFile,
+		name:         "WithRecursive",
+	}
+}
+
+// WithContinueOnError makes FromDir and FromTarGz ingest all the files, even if some of them fail to ingest.
+func WithContinueOnError() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Dir.ContinueOnError = true
+			return nil
+		},
+		clientScopes: QueuedClient,
+		sourceScope:  FromFile,
+		name:         "WithContinueOnError",
+	}
+}
+
+// WithDryRun makes the queued client complete and validate the ingestion properties (data format, mapping and
+// compression decisions), without uploading the data or ingesting it. The returned Result has the DryRun status and
+// its DryRun() method describes the decisions that were made. Invalid properties return the same errors as a real
+// ingestion would.
+func WithDryRun() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Source.DryRun = true
+			return nil
+		},
+		clientScopes: QueuedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithDryRun",
+	}
+}
+
 // IgnoreSizeLimit ignores the size limit for data ingestion.
 func IgnoreSizeLimit() FileOption {
 	return option{
@@ -320,6 +776,8 @@ func IgnoreSizeLimit() FileOption {
 }
 
 // Tags are tags to be associated with the ingested ata.
+//
+// Deprecated: Use WithTags(), which validates the tags and can be combined with WithDropByTags() and WithIngestIfNotExists().
 func Tags(tags []string) FileOption {
 	return option{
 		run: func(p *properties.All) error {
@@ -335,10 +793,12 @@ func Tags(tags []string) FileOption {
 // IfNotExists provides a string value that, if specified, prevents ingestion from succeeding if the table already
 // has data tagged with an ingest-by: tag with the same value. This ensures idempotent data ingestion.
 // For more information see: https://docs.microsoft.com/en-us/azure/kusto/management/extents-overview#ingest-by-extent-tags
+//
+// Deprecated: Use WithIngestIfNotExists(), which also tags the ingested data.
 func IfNotExists(ingestByTag string) FileOption {
 	return option{
 		run: func(p *properties.All) error {
-			p.Ingestion.Additional.IngestIfNotExists = ingestByTag
+			p.Ingestion.Additional.IngestIfNotExists = []string{ingestByTag}
 			return nil
 		},
 		sourceScope:  FromFile | FromReader | FromBlob,
@@ -347,6 +807,107 @@ func IfNotExists(ingestByTag string) FileOption {
 	}
 }
 
+// validateTags returns an errors.KClientArgs error if one of the tags is empty or holds control characters.
+func validateTags(option string, tags []string) error {
+	if len(tags) == 0 {
+		return errors.ES(errors.OpFileIngest, errors.KClientArgs, "%s requires at least one tag", option).SetNoRetry()
+	}
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.ES(errors.OpFileIngest, errors.KClientArgs, "%s cannot be used with an empty tag", option).SetNoRetry()
+		}
+		if strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+			return errors.ES(errors.OpFileIngest, errors.KClientArgs, "%s cannot be used with the tag %q, as it holds control characters", option, tag).SetNoRetry()
+		}
+	}
+	return nil
+}
+
+// prefixTags returns the tags with prefix added to each of them.
+func prefixTags(prefix string, tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		out = append(out, prefix+tag)
+	}
+	return out
+}
+
+// WithTags adds extent tags to the ingested data.
+// For more information see: https://docs.microsoft.com/en-us/azure/kusto/management/extents-overview#extent-tagging
+func WithTags(tags []string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if err := validateTags("WithTags()", tags); err != nil {
+				return err
+			}
+			p.Ingestion.Additional.Tags = append(p.Ingestion.Additional.Tags, tags...)
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithTags",
+	}
+}
+
+// WithDropByTags adds a drop-by: extent tag for every value to the ingested data, so that it can later be dropped
+// with ".drop extents <| .show table T extents where tags has 'drop-by:value'".
+func WithDropByTags(tags []string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if err := validateTags("WithDropByTags()", tags); err != nil {
+				return err
+			}
+			p.Ingestion.Additional.Tags = append(p.Ingestion.Additional.Tags, prefixTags("drop-by:", tags)...)
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithDropByTags",
+	}
+}
+
+// WithIngestIfNotExists makes the ingestion idempotent: it does nothing if the table already holds data tagged with
+// an ingest-by: tag with one of the values, and otherwise tags the ingested data with an ingest-by: tag for every value,
+// so that ingesting the same source again with the same values is a no-op.
+// For more information see: https://docs.microsoft.com/en-us/azure/kusto/management/extents-overview#ingest-by-extent-tags
+func WithIngestIfNotExists(tags []string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if err := validateTags("WithIngestIfNotExists()", tags); err != nil {
+				return err
+			}
+			p.Ingestion.Additional.IngestIfNotExists = append(p.Ingestion.Additional.IngestIfNotExists, tags...)
+			p.Ingestion.Additional.Tags = append(p.Ingestion.Additional.Tags, prefixTags("ingest-by:", tags)...)
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithIngestIfNotExists",
+	}
+}
+
+// WithIdempotencyKey makes the ingestion idempotent for key, which is usually computed from the payload: the data is
+// tagged with an ingest-by:<key> tag, and ingesting again with the same key while that data exists is a no-op on the
+// service. It is WithIngestIfNotExists() with a single value.
+// The check only finds data that is still in the table, so duplicates are only refused while the first copy is
+// retained, and ingest-by tags are meant for a short dedup window, like 30 days: use them sparingly, as every tag
+// adds to the cost of ingestion and of the table's metadata.
+func WithIdempotencyKey(key string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if err := validateTags("WithIdempotencyKey()", []string{key}); err != nil {
+				return err
+			}
+			p.Ingestion.Additional.IngestIfNotExists = append(p.Ingestion.Additional.IngestIfNotExists, key)
+			p.Ingestion.Additional.Tags = append(p.Ingestion.Additional.Tags, "ingest-by:"+key)
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithIdempotencyKey",
+	}
+}
+
 // ReportResultToTable option requests that the ingestion status will be tracked in an Azure table.
 // Note using Table status reporting is not recommended for high capacity ingestions, as it could slow down the ingestion.
 // In such cases, it's recommended to enable it temporarily for debugging failed ingestions.
@@ -363,12 +924,99 @@ func ReportResultToTable() FileOption {
 	}
 }
 
+// ReportLevel is which ingestion statuses the service reports, see WithReportLevel().
+type ReportLevel = properties.IngestionReportLevel
+
+//goland:noinspection GoUnusedConst - Part of the API
+const (
+	// ReportFailuresOnly reports the status of the failed ingestions only. It is the default.
+	ReportFailuresOnly ReportLevel = properties.FailuresOnly
+	// ReportNone reports no ingestion status.
+	ReportNone ReportLevel = properties.None
+	// ReportFailuresAndSuccesses reports the status of all the ingestions.
+	ReportFailuresAndSuccesses ReportLevel = properties.FailureAndSuccess
+)
+
+// ReportMethod is where the service reports the ingestion statuses to, see WithReportMethod().
+type ReportMethod = properties.IngestionReportMethod
+
+//goland:noinspection GoUnusedConst - Part of the API
+const (
+	// ReportToQueue reports the statuses to the status queues of the ingestion resources. It is the default.
+	ReportToQueue ReportMethod = properties.ReportStatusToQueue
+	// ReportToTable reports the statuses to the status table, which Result.Wait() and Result.WaitStatus() poll.
+	ReportToTable ReportMethod = properties.ReportStatusToTable
+	// ReportToQueueAndTable reports the statuses to both the status queues and the status table.
+	ReportToQueueAndTable ReportMethod = properties.ReportStatusToQueueAndTable
+)
+
+// WithReportLevel sets which ingestion statuses the service reports. By default, only failures are reported.
+// With ReportNone, Result.Wait() and Result.WaitStatus() don't poll the status table, and WithReportMethod() can only be
+// ReportToQueue. With ReportFailuresOnly and a table report method, the status of a successful ingestion stays Pending.
+func WithReportLevel(level ReportLevel) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			switch level {
+			case ReportFailuresOnly, ReportNone, ReportFailuresAndSuccesses:
+			default:
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithReportLevel() got an unknown report level %d", level).SetNoRetry()
+			}
+			p.Ingestion.ReportLevel = level
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithReportLevel",
+	}
+}
+
+// WithReportMethod sets where the service reports the ingestion statuses to. By default, they are reported to the
+// status queues, and Result.Wait() and Result.WaitStatus() only poll the status table with ReportToTable or
+// ReportToQueueAndTable. Like ReportResultToTable(), reporting to the table is not recommended for high capacity
+// ingestions.
+func WithReportMethod(method ReportMethod) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			switch method {
+			case ReportToQueue, ReportToTable, ReportToQueueAndTable:
+			default:
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithReportMethod() got an unknown report method %d", method).SetNoRetry()
+			}
+			p.Ingestion.ReportMethod = method
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithReportMethod",
+	}
+}
+
+// WithStatusPollInterval sets the interval between reads of the ingestion status table by Result.Wait() and
+// Result.WaitStatus(). By default, the status is read every 10 seconds. Only applies with ReportResultToTable().
+func WithStatusPollInterval(d time.Duration) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if d <= 0 {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithStatusPollInterval() requires a positive interval, got %s", d).SetNoRetry()
+			}
+			p.Status.PollInterval = d
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithStatusPollInterval",
+	}
+}
+
 // SetCreationTime option allows the user to override the data creation time the retention policies are considered against
 // If not set the data creation time is considered to be the time of ingestion
+//
+// Deprecated: Use WithCreationTime(), which also validates the time.
 func SetCreationTime(t time.Time) FileOption {
 	return option{
 		run: func(p *properties.All) error {
-			p.Ingestion.Additional.CreationTime = t
+			t := t.UTC()
+			p.Ingestion.Additional.CreationTime = &t
 			return nil
 		},
 		sourceScope:  FromFile | FromReader | FromBlob,
@@ -377,6 +1025,31 @@ func SetCreationTime(t time.Time) FileOption {
 	}
 }
 
+// CreationTimeMaxSkew is how far in the future WithCreationTime() accepts a time to be, to allow for clock skew.
+const CreationTimeMaxSkew = 5 * time.Minute
+
+// WithCreationTime sets the creation time of the ingested extents to t, instead of the time of ingestion. Retention
+// and caching policies are applied against it, which is useful when backfilling historical data. It returns an
+// errors.KClientArgs error if t is zero or more than CreationTimeMaxSkew in the future.
+func WithCreationTime(t time.Time) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if t.IsZero() {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithCreationTime() requires a time").SetNoRetry()
+			}
+			if t.After(time.Now().Add(CreationTimeMaxSkew)) {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithCreationTime() cannot be used with a time in the future, got %s", t.UTC().Format(time.RFC3339)).SetNoRetry()
+			}
+			t := t.UTC()
+			p.Ingestion.Additional.CreationTime = &t
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "WithCreationTime",
+	}
+}
+
 // ValidationOption is an an option for validating the ingestion input data.
 // These are defined as constants within this package.
 type ValidationOption int8
@@ -436,8 +1109,19 @@ func ValidationPolicy(policy ValPolicy) FileOption {
 // FileFormat can be used to indicate what type of encoding is supported for the file. This is only needed if
 // the file extension is not present. A file like: "input.json.gz" or "input.json" does not need this option, while
 // "input" would.
-// If an ingestion mapping is specified, there is no need to specify the file format.
+//
+// Deprecated: Use WithFileFormat() instead.
 func FileFormat(et DataFormat) FileOption {
+	return alias(WithFileFormat(et), "FileFormat")
+}
+
+// WithFileFormat sets the format the source is encoded in. This is only needed if the file extension is not present or
+// is misleading. A file like: "input.json.gz" or "input.json" does not need this option, while "input" or a temporary
+// file like "upload.tmp" would, as their format would otherwise default to CSV.
+// A format set with this option is never replaced by the one discovered from the file name, and also decides if the
+// source is compressed before it is sent: the binary formats, such as Parquet, ORC or Avro, are sent as is.
+// If an ingestion mapping is specified, there is no need to specify the file format.
+func WithFileFormat(et DataFormat) FileOption {
 	return option{
 		run: func(p *properties.All) error {
 			p.Ingestion.Additional.Format = et
@@ -445,7 +1129,7 @@ func FileFormat(et DataFormat) FileOption {
 		},
 		sourceScope:  FromFile | FromReader | FromBlob,
 		clientScopes: QueuedClient | StreamingClient | ManagedClient,
-		name:         "FileFormat",
+		name:         "WithFileFormat",
 	}
 }
 
@@ -462,6 +1146,92 @@ func ClientRequestId(clientRequestId string) FileOption {
 	}
 }
 
+// WithStreamChunkSize makes the streaming client send a reader as a series of streaming requests of at most n bytes each,
+// instead of a single request. Chunks are cut on the last newline inside the chunk, so this should only be used with line
+// delimited formats (CSV, JSON, ...), and a record longer than n bytes fails with an errors.KLimitsExceeded error. A gzip
+// compressed reader is decompressed to be cut. A chunk that fails with a transient error is retried on its own.
+func WithStreamChunkSize(n int) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if n <= 0 {
+				return errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithStreamChunkSize() requires a positive chunk size, got %d", n).SetNoRetry()
+			}
+			p.Streaming.ChunkSize = n
+			return nil
+		},
+		clientScopes: StreamingClient,
+		sourceScope:  FromReader,
+		name:         "WithStreamChunkSize",
+	}
+}
+
+// WithStreamingEnablePolling makes the streaming client retry, with an exponential backoff, the streaming ingestions that
+// fail because streaming ingestion is not enabled on the table, until timeout elapses. This covers the few minutes it
+// takes for a streaming ingestion policy that was just enabled to propagate. The last error is returned if the policy
+// is still not in effect after timeout. Other errors are not retried, so real configuration problems are not hidden.
+// The data is held in memory so that it can be sent again, once compressed. At most the 4 MiB limit of streaming
+// ingestion is held: a larger payload fails with an errors.KLimitsExceeded error, without being sent.
+func WithStreamingEnablePolling(timeout time.Duration) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if timeout <= 0 {
+				return errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithStreamingEnablePolling() requires a positive timeout, got %s", timeout).SetNoRetry()
+			}
+			p.Streaming.EnablePollingTimeout = timeout
+			return nil
+		},
+		clientScopes: StreamingClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithStreamingEnablePolling",
+	}
+}
+
+// WithCompressionLevel sets the compress/gzip level used when the client compresses the data before sending it, in the
+// range of gzip.BestSpeed to gzip.BestCompression. By default, gzip.DefaultCompression is used.
+// This has no effect on data that isn't compressed by the client, such as already compressed files or binary formats
+// like AVRO and Parquet.
+func WithCompressionLevel(level int) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if level < gzip.BestSpeed || level > gzip.BestCompression {
+				return errors.ES(
+					errors.OpFileIngest,
+					errors.KClientArgs,
+					"WithCompressionLevel() requires a level between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, level,
+				).SetNoRetry()
+			}
+			p.Source.CompressionLevel = level
+			return nil
+		},
+		clientScopes: QueuedClient | StreamingClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithCompressionLevel",
+	}
+}
+
+// DefaultCompressionMinBytes is the size under which the data is not compressed, unless WithCompressionMinBytes() is used.
+const DefaultCompressionMinBytes = 1024
+
+// WithCompressionMinBytes sets the size, in bytes, under which the client doesn't compress the data, as compressing a
+// small payload costs more CPU than it saves, and can even make it larger. 0 compresses the data whatever its size.
+// Defaults to DefaultCompressionMinBytes. The size of a file is known, and up to n bytes of a reader are buffered to
+// know if it is smaller. This only makes the client compress less: DontCompress(), compressed data and binary formats
+// are never compressed.
+func WithCompressionMinBytes(n int) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if n < 0 {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "WithCompressionMinBytes() requires a size of 0 or more, got %d", n).SetNoRetry()
+			}
+			p.Source.CompressionMinBytes = int64(n)
+			return nil
+		},
+		clientScopes: QueuedClient | StreamingClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithCompressionMinBytes",
+	}
+}
+
 // CompressionType sets the compression type of the data.
 // Use this if the file name does not expose the compression type.
 // This sets DontCompress to true for compressed data.
@@ -479,7 +1249,16 @@ func CompressionType(compressionType ingestoptions.CompressionType) FileOption {
 
 // RawDataSize is the uncompressed data size. Should be used to comunicate the file size to the service for efficient ingestion.
 // Also used by managed client in the decision to use queued ingestion instead of streaming (if > 4mb)
+//
+// Deprecated: Use WithRawDataSize() instead.
 func RawDataSize(size int64) FileOption {
+	return alias(WithRawDataSize(size), "RawDataSize")
+}
+
+// WithRawDataSize sets the uncompressed data size. Should be used to comunicate the file size to the service for efficient
+// ingestion. Also used by managed client in the decision to use queued ingestion instead of streaming (if > 4mb), and
+// as the total reported to WithProgress() when ingesting from a reader, whose size can't be known otherwise.
+func WithRawDataSize(size int64) FileOption {
 	return option{
 		run: func(p *properties.All) error {
 			p.Ingestion.RawDataSize = size
@@ -487,6 +1266,93 @@ func RawDataSize(size int64) FileOption {
 		},
 		clientScopes: QueuedClient | ManagedClient,
 		sourceScope:  FromFile | FromReader | FromBlob,
-		name:         "RawDataSize",
+		name:         "WithRawDataSize",
+	}
+}
+
+// WithBlockSize sets the size, in bytes, of the blocks the source is uploaded to Blob Storage in, between
+// queued.MinBlockSize (1 MiB) and queued.MaxBlockSize (4000 MiB). By default, files are uploaded in blocks of 8 MiB, and
+// readers and compressed files in blocks of the buffer size set with WithStaticBuffer(), or 1 MiB. Larger blocks can
+// improve the throughput of high bandwidth links. Every block being uploaded is held in memory when uploading a reader
+// or a compressed file.
+func WithBlockSize(bytes int64) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if bytes < queued.MinBlockSize || bytes > queued.MaxBlockSize {
+				return errors.ES(
+					errors.OpFileIngest,
+					errors.KClientArgs,
+					"WithBlockSize() requires a size between %d and %d bytes, got %d", queued.MinBlockSize, int64(queued.MaxBlockSize), bytes,
+				).SetNoRetry()
+			}
+			p.Source.BlockSize = bytes
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithBlockSize",
+	}
+}
+
+// WithBlockConcurrency sets the number of blocks of the source uploaded to Blob Storage in parallel, between 1 and
+// queued.MaxBlockConcurrency. By default, files are uploaded with 50 blocks in parallel, and readers and compressed
+// files with the number of buffers set with WithStaticBuffer(), or one block at a time.
+func WithBlockConcurrency(n int) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if n < 1 || n > queued.MaxBlockConcurrency {
+				return errors.ES(
+					errors.OpFileIngest,
+					errors.KClientArgs,
+					"WithBlockConcurrency() requires a value between 1 and %d, got %d", queued.MaxBlockConcurrency, n,
+				).SetNoRetry()
+			}
+			p.Source.BlockConcurrency = n
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithBlockConcurrency",
+	}
+}
+
+// WithProgress makes the upload of the source to Blob Storage call f with the number of bytes uploaded so far, and the
+// total to upload: the size of a file, or the size set with WithRawDataSize() for a reader, and -1 if it is unknown.
+// Bytes are counted before compression. f is called about every MiB, never concurrently, and once more with the final
+// count when the upload succeeded, with total set to that count.
+// The managed client only calls f when it falls back to queued ingestion, as streaming ingestion doesn't upload the source.
+func WithProgress(f func(uploaded, total int64)) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Source.Progress = f
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader,
+		name:         "WithProgress",
+	}
+}
+
+// EventHandler receives the events of a queued ingestion, set with WithEventHandler(). Its methods are called
+// synchronously, never concurrently for the same ingestion, so they should return quickly. New methods may be added
+// to EventHandler in a future release, so implementations should embed NopEventHandler.
+type EventHandler = properties.EventHandler
+
+// NopEventHandler is an EventHandler that ignores all the events.
+type NopEventHandler = properties.NopEventHandler
+
+// WithEventHandler makes the ingestion report its events to h: the start of every attempt to upload the source to
+// Blob Storage, with the URL of the blob without its SAS, the number of bytes uploaded so far, as often as
+// WithProgress() reports it, every retry of the upload, and the ID of the ingestion message once it was queued.
+// The managed client only reports events when it falls back to queued ingestion.
+func WithEventHandler(h EventHandler) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Source.Events = h
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromFile | FromReader | FromBlob,
+		name:         "WithEventHandler",
 	}
 }
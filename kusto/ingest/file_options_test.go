@@ -2,9 +2,20 @@ package ingest
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
-	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest/ingestoptions"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -25,13 +36,13 @@ func TestOptions(t *testing.T) {
 
 	client := kusto.NewMockClient()
 
-	queuedClient, err := New(client, "", "")
+	queuedClient, err := New(client, "db", "table")
 	require.NoError(t, err)
 
-	streamingClient, err := NewStreaming(client, "", "")
+	streamingClient, err := NewStreaming(client, "db", "table")
 	require.NoError(t, err)
 
-	managedClient, err := NewManaged(client, "", "")
+	managedClient, err := NewManaged(client, "db", "table")
 	require.NoError(t, err)
 
 	var tests = []struct {
@@ -109,6 +120,78 @@ func TestOptions(t *testing.T) {
 			op:       errors.OpIngestStream,
 			kind:     errors.KClientArgs,
 		},
+		{
+			desc:     "Invalid compression level for queued ingestor",
+			option:   WithCompressionLevel(42),
+			ingestor: queuedClient,
+			from:     fromFile,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Block size too small for queued ingestor",
+			option:   WithBlockSize(1024),
+			ingestor: queuedClient,
+			from:     fromFile,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Block size too large for queued ingestor",
+			option:   WithBlockSize(5000 * 1024 * 1024),
+			ingestor: queuedClient,
+			from:     fromReader,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Invalid block concurrency for queued ingestor",
+			option:   WithBlockConcurrency(0),
+			ingestor: queuedClient,
+			from:     fromFile,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Invalid upload mode for queued ingestor",
+			option:   WithUploadMode(ingestoptions.UploadMode(10)),
+			ingestor: queuedClient,
+			from:     fromFile,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Upload mode for queued ingestor from reader",
+			option:   WithUploadMode(ingestoptions.UploadModeStream),
+			ingestor: queuedClient,
+			from:     fromReader,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Block size for streaming ingestor",
+			option:   WithBlockSize(16 * 1024 * 1024),
+			ingestor: streamingClient,
+			from:     fromFile,
+			op:       errors.OpIngestStream,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Invalid blob metadata key for queued ingestor",
+			option:   WithBlobMetadata(map[string]string{"1team": "ingestion"}),
+			ingestor: queuedClient,
+			from:     fromFile,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Blob name template with a path separator for queued ingestor",
+			option:   WithBlobNameTemplate("{db}/{uuid}"),
+			ingestor: queuedClient,
+			from:     fromReader,
+			op:       errors.OpFileIngest,
+			kind:     errors.KClientArgs,
+		},
 		{
 			desc:     "Invalid option for managed ingestor from reader",
 			option:   DeleteSource(),
@@ -146,6 +229,9 @@ func TestOptions(t *testing.T) {
 	}
 }
 
+// jsonMapping is a valid inline JSON ingestion mapping.
+const jsonMapping = `[{"column":"a","datatype":"string","Properties":{"path":"$.a"}}]`
+
 func TestFileFormatAndMapping(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -179,21 +265,21 @@ func TestFileFormatAndMapping(t *testing.T) {
 		},
 		{
 			desc:                "Test just ingestion mapping",
-			options:             []FileOption{IngestionMapping("mapping", JSON)},
+			options:             []FileOption{IngestionMapping(jsonMapping, JSON)},
 			source:              FromFile,
 			expectedFormat:      JSON,
 			expectedMappingType: JSON,
 		},
 		{
 			desc:                "Test matching options",
-			options:             []FileOption{IngestionMapping("mapping", JSON), FileFormat(JSON)},
+			options:             []FileOption{IngestionMapping(jsonMapping, JSON), FileFormat(JSON)},
 			source:              FromFile,
 			expectedFormat:      JSON,
 			expectedMappingType: JSON,
 		},
 		{
 			desc:                "Test non-matching options",
-			options:             []FileOption{IngestionMapping("mapping", JSON), FileFormat(AVRO)},
+			options:             []FileOption{IngestionMapping(jsonMapping, JSON), FileFormat(AVRO)},
 			source:              FromFile,
 			expectedFormat:      JSON,
 			expectedMappingType: JSON,
@@ -203,11 +289,152 @@ func TestFileFormatAndMapping(t *testing.T) {
 				"format and ingestion mapping type must match (hint: using ingestion mapping sets the format automatically)",
 			).SetNoRetry(),
 		},
+		{
+			desc:                "Test mapping ref by name keeps the format for discovery",
+			options:             []FileOption{WithIngestionMappingRef("mapping", JSON)},
+			source:              FromFile,
+			expectedFormat:      DFUnknown,
+			expectedMappingType: JSON,
+		},
+		{
+			desc:                "Test mapping ref by name with a compatible format",
+			options:             []FileOption{FileFormat(MultiJSON), WithIngestionMappingRef("mapping", JSON)},
+			source:              FromReader,
+			expectedFormat:      MultiJSON,
+			expectedMappingType: JSON,
+		},
+		{
+			desc:    "Test mapping ref by name with the reader default format",
+			options: []FileOption{WithIngestionMappingRef("mapping", JSON)},
+			source:  FromReader,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"format and ingestion mapping type must match (hint: using ingestion mapping sets the format automatically)",
+			).SetNoRetry(),
+		},
+		{
+			desc:    "Test mapping ref by name with an inline mapping",
+			options: []FileOption{IngestionMapping(jsonMapping, JSON), WithIngestionMappingRef("mapping", JSON)},
+			source:  FromFile,
+			err:     errInlineAndRefMapping("WithIngestionMappingRef()"),
+		},
+		{
+			desc:    "Test inline mapping with a mapping ref",
+			options: []FileOption{IngestionMappingRef("mapping", JSON), IngestionMapping(jsonMapping, JSON)},
+			source:  FromFile,
+			err:     errInlineAndRefMapping("IngestionMapping()"),
+		},
+		{
+			desc:                "Test just W3C log mapping",
+			options:             []FileOption{WithW3CLogMapping([]W3CColumnMapping{{Field: "date", Column: "Date"}})},
+			source:              FromFile,
+			expectedFormat:      W3CLogFile,
+			expectedMappingType: W3CLogFile,
+		},
+		{
+			desc:                "Test W3C log mapping with matching format",
+			options:             []FileOption{FileFormat(W3CLogFile), WithW3CLogMapping([]W3CColumnMapping{{Field: "date", Column: "Date"}})},
+			source:              FromReader,
+			expectedFormat:      W3CLogFile,
+			expectedMappingType: W3CLogFile,
+		},
+		{
+			desc:    "Test W3C log mapping with non-matching format",
+			options: []FileOption{FileFormat(CSV), WithW3CLogMapping([]W3CColumnMapping{{Field: "date", Column: "Date"}})},
+			source:  FromFile,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"WithW3CLogMapping() option requires the W3CLogFile format, but the format is %v", CSV,
+			).SetNoRetry(),
+		},
+		{
+			desc:    "Test W3C log mapping without a column",
+			options: []FileOption{WithW3CLogMapping([]W3CColumnMapping{{Field: "date"}})},
+			source:  FromFile,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"WithW3CLogMapping() option: W3C log file mapping entry 0 has an empty Column",
+			).SetNoRetry(),
+		},
+		{
+			desc:                "Test inline mapping with a compatible format",
+			options:             []FileOption{FileFormat(TSV), IngestionMapping(`[{"Name":"a","DataType":"string","Ordinal":"0"}]`, CSV)},
+			source:              FromReader,
+			expectedFormat:      CSV,
+			expectedMappingType: CSV,
+		},
+		{
+			desc:    "Test inline mapping with a non-matching format",
+			options: []FileOption{FileFormat(AVRO), IngestionMapping(jsonMapping, JSON)},
+			source:  FromReader,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"IngestionMapping() option with a %v mapping cannot be used with the %v format", JSON, AVRO,
+			).SetNoRetry(),
+		},
+		{
+			desc:    "Test inline mapping that is not JSON",
+			options: []FileOption{IngestionMapping("mapping", JSON)},
+			source:  FromFile,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"IngestionMapping() option has an invalid mapping: ingestion mapping is not a JSON array of column mappings: invalid character 'm' looking for beginning of value",
+			).SetNoRetry(),
+		},
+		{
+			desc:    "Test inline mapping without a column",
+			options: []FileOption{IngestionMapping(`[{"column":"a"},{"datatype":"string"}]`, CSV)},
+			source:  FromFile,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"IngestionMapping() option has an invalid mapping: ingestion mapping entry 1 has no Column",
+			).SetNoRetry(),
+		},
+		{
+			desc:    "Test inline JSON mapping without a path",
+			options: []FileOption{IngestionMapping(`[{"column":"a","datatype":"string","path":"$.a"},{"column":"b","datatype":"string","Properties":{}}]`, JSON)},
+			source:  FromFile,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"IngestionMapping() option has an invalid mapping: ingestion mapping entry 1 has no Properties.Path, ConstValue or Transform",
+			).SetNoRetry(),
+		},
+		{
+			desc:                "Test inline JSON mapping without a data type",
+			options:             []FileOption{IngestionMapping([]map[string]interface{}{{"Column": "a", "Properties": map[string]string{"Path": "$.a"}}}, JSON)},
+			source:              FromFile,
+			expectedFormat:      JSON,
+			expectedMappingType: JSON,
+		},
+		{
+			desc:                "Test inline JSON mapping with a constant and a transform",
+			options:             []FileOption{IngestionMapping(`[{"Column":"c","Properties":{"ConstValue":"x"}},{"Column":"d","Properties":{"Transform":"SourceLocation"}}]`, JSON)},
+			source:              FromFile,
+			expectedFormat:      JSON,
+			expectedMappingType: JSON,
+		},
+		{
+			desc:    "Test inline mapping with an entry that is not an object",
+			options: []FileOption{IngestionMapping(`[{"column":"a"},"b"]`, Parquet)},
+			source:  FromFile,
+			err: errors.ES(
+				errors.OpUnknown,
+				errors.KClientArgs,
+				"IngestionMapping() option has an invalid mapping: ingestion mapping entry 1 is not a JSON object",
+			).SetNoRetry(),
+		},
 	}
 
 	client := kusto.NewMockClient()
 
-	queuedClient, err := New(client, "", "")
+	queuedClient, err := New(client, "db", "table")
 	require.NoError(t, err)
 
 	for _, test := range tests {
@@ -215,8 +442,9 @@ func TestFileFormatAndMapping(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			props := properties.All{}
-			_, all, err := queuedClient.prepForIngestion(context.Background(), test.options, props, test.source)
+			props := queuedClient.newProp()
+			var reader io.Reader = strings.NewReader("a,b\n")
+			_, all, err := queuedClient.prepForIngestion(context.Background(), test.options, props, test.source, &reader)
 
 			if test.err != nil {
 				assert.EqualError(t, err, test.err.Error())
@@ -231,3 +459,480 @@ func TestFileFormatAndMapping(t *testing.T) {
 	}
 
 }
+
+func TestIngestionMappingRefCommand(t *testing.T) {
+	t.Parallel()
+
+	props := properties.All{Ingestion: properties.Ingestion{
+		DatabaseName: "db",
+		TableName:    "table",
+		BlobPath:     "https://account.blob.core.windows.net/c/data.multijson",
+		Additional:   properties.Additional{AuthContext: "auth"},
+	}}
+	require.NoError(t, WithIngestionMappingRef("EventsMapping", JSON).Run(&props, QueuedClient, FromFile))
+
+	require.NoError(t, queued.CompleteFormatFromFileName(&props, props.Ingestion.BlobPath))
+	assert.Equal(t, MultiJSON, props.Ingestion.Additional.Format)
+
+	encoded, err := props.Ingestion.MarshalJSONString()
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+
+	var command struct {
+		Additional map[string]interface{} `json:"AdditionalProperties"`
+	}
+	require.NoError(t, json.Unmarshal(decoded, &command))
+	assert.Equal(t, "EventsMapping", command.Additional["ingestionMappingReference"])
+	assert.Equal(t, "Json", command.Additional["ingestionMappingType"])
+	assert.Equal(t, "multijson", command.Additional["format"])
+	assert.NotContains(t, command.Additional, "ingestionMapping")
+
+	mismatch := properties.All{}
+	require.NoError(t, WithIngestionMappingRef("EventsMapping", AVRO).Run(&mismatch, QueuedClient, FromFile))
+	err = queued.CompleteFormatFromFileName(&mismatch, "data.csv")
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+}
+
+func TestW3CLogMapping(t *testing.T) {
+	t.Parallel()
+
+	props := properties.All{}
+	err := WithW3CLogMapping([]W3CColumnMapping{
+		{Field: "date", Column: "Date"},
+		{Field: "time-taken", Column: "Duration", Transform: "DateTimeFromUnixMilliseconds"},
+	}).Run(&props, QueuedClient, FromFile)
+	require.NoError(t, err)
+
+	assert.JSONEq(t,
+		`[{"Column":"Date","Properties":{"Field":"date"}},{"Column":"Duration","Properties":{"Field":"time-taken","Transform":"DateTimeFromUnixMilliseconds"}}]`,
+		props.Ingestion.Additional.IngestionMapping,
+	)
+}
+
+func TestRetainAndDeleteBlob(t *testing.T) {
+	t.Parallel()
+
+	props := properties.All{}
+	require.NoError(t, WithRetainBlob().Run(&props, QueuedClient, FromFile))
+	assert.True(t, props.Source.RetainBlob)
+	err := WithDeleteBlobOnSuccess().Run(&props, QueuedClient, FromFile)
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+
+	props = properties.All{}
+	require.NoError(t, WithDeleteBlobOnSuccess().Run(&props, QueuedClient, FromReader))
+	assert.True(t, props.Source.DeleteBlobOnSuccess)
+	assert.Error(t, WithRetainBlob().Run(&props, QueuedClient, FromReader))
+
+	assert.Error(t, WithRetainBlob().Run(&properties.All{}, StreamingClient, FromReader))
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	client := kusto.NewMockClient()
+
+	queuedClient, err := New(client, "db", "table")
+	require.NoError(t, err)
+
+	noTableClient, err := New(client, "db", "")
+	require.NoError(t, err)
+
+	jsonFile := filepath.Join(t.TempDir(), "data.json.gz")
+	require.NoError(t, os.WriteFile(jsonFile, []byte{}, 0644))
+	binaryFile := filepath.Join(t.TempDir(), "upload123")
+	require.NoError(t, os.WriteFile(binaryFile, []byte("PAR1"), 0644))
+	smallFile := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(smallFile, []byte("a,b\n"), 0644))
+
+	tests := []struct {
+		desc     string
+		ingestor *Ingestion
+		from     from
+		path     string
+		options  []FileOption
+		want     DryRunDetails
+		kind     errors.Kind
+	}{
+		{
+			desc:     "Local file",
+			ingestor: queuedClient,
+			from:     fromFile,
+			path:     "file_options_test.go",
+			want:     DryRunDetails{Format: CSV, Compress: true},
+		},
+		{
+			desc:     "Compressed local file",
+			ingestor: queuedClient,
+			from:     fromFile,
+			path:     jsonFile,
+			options:  []FileOption{IngestionMappingRef("mapping", JSON)},
+			want:     DryRunDetails{Format: JSON, IngestionMappingRef: "mapping"},
+		},
+		{
+			desc:     "Blob",
+			ingestor: queuedClient,
+			from:     fromBlob,
+			path:     "https://account.blob.core.windows.net/container/data.tsv",
+			want:     DryRunDetails{Format: TSV},
+		},
+		{
+			desc:     "Reader smaller than the compression floor",
+			ingestor: queuedClient,
+			from:     fromReader,
+			want:     DryRunDetails{Format: CSV},
+		},
+		{
+			desc:     "Reader",
+			ingestor: queuedClient,
+			from:     fromReader,
+			options:  []FileOption{WithCompressionMinBytes(0)},
+			want:     DryRunDetails{Format: CSV, Compress: true},
+		},
+		{
+			desc:     "Local file smaller than the compression floor",
+			ingestor: queuedClient,
+			from:     fromFile,
+			path:     smallFile,
+			want:     DryRunDetails{Format: CSV},
+		},
+		{
+			desc:     "Reader with a binary format",
+			ingestor: queuedClient,
+			from:     fromReader,
+			options:  []FileOption{FileFormat(Parquet)},
+			want:     DryRunDetails{Format: Parquet},
+		},
+		{
+			desc:     "Local file without extension with a binary format",
+			ingestor: queuedClient,
+			from:     fromFile,
+			path:     binaryFile,
+			options:  []FileOption{WithFileFormat(Parquet)},
+			want:     DryRunDetails{Format: Parquet},
+		},
+		{
+			desc:     "Local file with a misleading extension with a binary format",
+			ingestor: queuedClient,
+			from:     fromFile,
+			path:     "file_options_test.go",
+			options:  []FileOption{WithFileFormat(ORC)},
+			want:     DryRunDetails{Format: ORC},
+		},
+		{
+			desc:     "Mapping does not match the format",
+			ingestor: queuedClient,
+			from:     fromReader,
+			options:  []FileOption{IngestionMapping(jsonMapping, JSON), FileFormat(AVRO)},
+			kind:     errors.KClientArgs,
+		},
+		{
+			desc:     "Missing table",
+			ingestor: noTableClient,
+			from:     fromReader,
+			kind:     errors.KClientArgs,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			options := append([]FileOption{WithDryRun()}, test.options...)
+
+			var result *Result
+			var err error
+			switch test.from {
+			case fromFile, fromBlob:
+				result, err = test.ingestor.FromFile(ctx, test.path, options...)
+			case fromReader:
+				result, err = test.ingestor.FromReader(ctx, bytes.NewReader([]byte("a,b\n")), options...)
+			}
+
+			if test.kind != errors.KOther {
+				e, ok := errors.GetKustoError(err)
+				require.True(t, ok, "expected errors.Error, got %v", err)
+				assert.Equal(t, test.kind, e.Kind)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, DryRun, result.record.Status)
+			require.NotNil(t, result.DryRun())
+			assert.Equal(t, test.want, *result.DryRun())
+
+			for err := range result.Wait(ctx) {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCallTimeTarget(t *testing.T) {
+	t.Parallel()
+
+	queuedClient, err := New(kusto.NewMockClient(), "db", "table")
+	require.NoError(t, err)
+
+	var reader io.Reader = strings.NewReader("a,b\n")
+	_, props, err := queuedClient.prepForIngestion(context.Background(), []FileOption{WithDatabase("otherDb"), WithTable("otherTable")}, queuedClient.newProp(), FromReader, &reader)
+	require.NoError(t, err)
+	assert.Equal(t, "otherDb", props.Ingestion.DatabaseName)
+	assert.Equal(t, "otherTable", props.Ingestion.TableName)
+	assert.Equal(t, "Database", Database("otherDb").String(), "the deprecated options keep their names")
+	assert.Equal(t, "Table", Table("otherTable").String(), "the deprecated options keep their names")
+
+	var got []string
+	streaming := &Streaming{
+		db:    "db",
+		table: "table",
+		streamConn: fakeStreamIngestor{onStreamIngest: func(_ context.Context, db, table string, _ io.Reader, _ kusto.DataFormatForStreaming, _ string, _ string, _ bool) error {
+			got = append(got, db+"/"+table)
+			return nil
+		}},
+	}
+
+	tests := []struct {
+		desc    string
+		options []FileOption
+		want    string
+		kind    errors.Kind
+	}{
+		{desc: "Client target", want: "db/table"},
+		{desc: "Override table", options: []FileOption{WithTable("otherTable")}, want: "db/otherTable"},
+		{desc: "Override both", options: []FileOption{WithDatabase("otherDb"), WithTable("otherTable")}, want: "otherDb/otherTable"},
+		{desc: "Empty database", options: []FileOption{WithDatabase("")}, kind: errors.KClientArgs},
+		{desc: "Empty table", options: []FileOption{WithTable("")}, kind: errors.KClientArgs},
+	}
+
+	for _, test := range tests {
+		got = nil
+		_, err := streaming.FromReader(context.Background(), bytes.NewReader([]byte("a,b\n")), test.options...)
+		if test.kind != errors.KOther {
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok, "%s: expected errors.Error, got %v", test.desc, err)
+			assert.Equal(t, test.kind, e.Kind, test.desc)
+			assert.Empty(t, got, "%s: nothing should be ingested", test.desc)
+			continue
+		}
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, []string{test.want}, got, test.desc)
+	}
+
+	noTarget, err := NewStreaming(kusto.NewMockClient(), "", "")
+	require.NoError(t, err)
+	_, err = noTarget.FromReader(context.Background(), bytes.NewReader([]byte("a,b\n")))
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "expected errors.Error, got %v", err)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+}
+
+func TestWithCreationTime(t *testing.T) {
+	t.Parallel()
+
+	newProps := func() properties.All {
+		return properties.All{Ingestion: properties.Ingestion{
+			DatabaseName: "db",
+			TableName:    "table",
+			BlobPath:     "https://account.blob.core.windows.net/c/data.csv",
+			Additional:   properties.Additional{AuthContext: "auth", Format: CSV},
+		}}
+	}
+	additional := func(t *testing.T, props properties.All) map[string]interface{} {
+		encoded, err := props.Ingestion.MarshalJSONString()
+		require.NoError(t, err)
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		require.NoError(t, err)
+		var command struct {
+			Additional map[string]interface{} `json:"AdditionalProperties"`
+		}
+		require.NoError(t, json.Unmarshal(decoded, &command))
+		return command.Additional
+	}
+
+	assert.NotContains(t, additional(t, newProps()), "creationTime", "the creation time should only be sent when set")
+
+	props := newProps()
+	created := time.Date(2020, 3, 10, 20, 59, 30, 0, time.FixedZone("UTC+2", 2*60*60))
+	require.NoError(t, WithCreationTime(created).Run(&props, QueuedClient, FromFile))
+	assert.Equal(t, "2020-03-10T18:59:30Z", additional(t, props)["creationTime"])
+
+	skewed := newProps()
+	require.NoError(t, WithCreationTime(time.Now().Add(time.Minute)).Run(&skewed, QueuedClient, FromFile), "a small skew is allowed")
+
+	for _, bad := range []time.Time{{}, time.Now().Add(time.Hour)} {
+		props := newProps()
+		err := WithCreationTime(bad).Run(&props, QueuedClient, FromFile)
+		require.Error(t, err)
+		assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+	}
+
+	err := WithCreationTime(created).Run(&props, StreamingClient, FromFile)
+	assert.Error(t, err, "streaming ingestion does not support the creation time")
+}
+
+func TestExtentTags(t *testing.T) {
+	t.Parallel()
+
+	props := properties.All{Ingestion: properties.Ingestion{
+		DatabaseName: "db",
+		TableName:    "table",
+		BlobPath:     "https://account.blob.core.windows.net/c/data.csv",
+		Additional:   properties.Additional{AuthContext: "auth", Format: CSV},
+	}}
+	for _, o := range []FileOption{WithTags([]string{"source:app"}), WithDropByTags([]string{"2020-03-10"}), WithIngestIfNotExists([]string{"batch-1"}), WithIdempotencyKey("sha-1f2e")} {
+		require.NoError(t, o.Run(&props, QueuedClient, FromFile))
+	}
+
+	encoded, err := props.Ingestion.MarshalJSONString()
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	var command struct {
+		Additional map[string]interface{} `json:"AdditionalProperties"`
+	}
+	require.NoError(t, json.Unmarshal(decoded, &command))
+	assert.Equal(t, `["source:app","drop-by:2020-03-10","ingest-by:batch-1","ingest-by:sha-1f2e"]`, command.Additional["tags"])
+	assert.Equal(t, `["batch-1","sha-1f2e"]`, command.Additional["ingestIfNotExists"])
+
+	for _, tags := range [][]string{nil, {""}, {" "}, {"a\nb"}} {
+		for _, o := range []FileOption{WithTags(tags), WithDropByTags(tags), WithIngestIfNotExists(tags)} {
+			err := o.Run(&properties.All{}, QueuedClient, FromFile)
+			require.Error(t, err, "%q should be rejected", tags)
+			assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+		}
+	}
+	for _, key := range []string{"", " ", "a\nb"} {
+		err := WithIdempotencyKey(key).Run(&properties.All{}, QueuedClient, FromFile)
+		require.Error(t, err, "%q should be rejected", key)
+		assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+	}
+	assert.Error(t, WithIdempotencyKey("key").Run(&properties.All{}, StreamingClient, FromFile), "streaming ingestion does not support ingest-by tags")
+}
+
+func TestWithFlushImmediately(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []DataFormat{CSV, JSON, Parquet, AVRO} {
+		props := properties.All{Ingestion: properties.Ingestion{
+			DatabaseName: "db",
+			TableName:    "table",
+			BlobPath:     "https://account.blob.core.windows.net/c/data",
+			Additional:   properties.Additional{AuthContext: "auth", Format: format},
+		}}
+		require.NoError(t, WithFlushImmediately().Run(&props, QueuedClient, FromReader))
+
+		encoded, err := props.Ingestion.MarshalJSONString()
+		require.NoError(t, err)
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		require.NoError(t, err)
+		var command struct {
+			FlushImmediately bool
+		}
+		require.NoError(t, json.Unmarshal(decoded, &command))
+		assert.True(t, command.FlushImmediately, "the queued message should ask to flush %s data immediately", format)
+	}
+
+	assert.Error(t, WithFlushImmediately().Run(&properties.All{}, StreamingClient, FromReader), "streaming ingestion is not batched")
+	assert.Equal(t, "FlushImmediately", FlushImmediately().String(), "the deprecated option keeps its name")
+}
+
+func TestWithIgnoreFirstRecord(t *testing.T) {
+	t.Parallel()
+
+	props := properties.All{Ingestion: properties.Ingestion{
+		DatabaseName: "db",
+		TableName:    "table",
+		BlobPath:     "https://account.blob.core.windows.net/c/data.csv",
+		Additional:   properties.Additional{AuthContext: "auth"},
+	}}
+	require.NoError(t, WithIgnoreFirstRecord().Run(&props, QueuedClient, FromFile))
+	require.NoError(t, queued.CompleteFormatFromFileName(&props, props.Ingestion.BlobPath))
+
+	encoded, err := props.Ingestion.MarshalJSONString()
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	var command struct {
+		Additional map[string]interface{} `json:"AdditionalProperties"`
+	}
+	require.NoError(t, json.Unmarshal(decoded, &command))
+	assert.Equal(t, true, command.Additional["ignoreFirstRecord"])
+
+	for _, format := range []DataFormat{CSV, TSV, TSVE, PSV, SCSV, SOHSV} {
+		props := properties.All{Ingestion: properties.Ingestion{Additional: properties.Additional{Format: format}}}
+		assert.NoError(t, WithIgnoreFirstRecord().Run(&props, QueuedClient, FromReader), "%s has a first record to skip", format)
+	}
+
+	for _, format := range []DataFormat{JSON, MultiJSON, Parquet, AVRO, ORC, Raw, TXT} {
+		props := properties.All{Ingestion: properties.Ingestion{Additional: properties.Additional{Format: format}}}
+		err := WithIgnoreFirstRecord().Run(&props, QueuedClient, FromReader)
+		require.Error(t, err, "%s should be rejected", format)
+		assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+	}
+
+	// The format discovered from the file name is checked too.
+	props = properties.All{}
+	require.NoError(t, WithIgnoreFirstRecord().Run(&props, QueuedClient, FromFile))
+	err = queued.CompleteFormatFromFileName(&props, "data.json")
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+
+	assert.Error(t, WithIgnoreFirstRecord().Run(&properties.All{}, StreamingClient, FromReader), "streaming ingestion does not support it")
+}
+
+func TestSniffFormat(t *testing.T) {
+	t.Parallel()
+
+	gzipped := func(s string) string {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		_, _ = zw.Write([]byte(s))
+		_ = zw.Close()
+		return buf.String()
+	}
+	long := strings.Repeat("a,b,c\n", 2000) + "unfinished"
+
+	tests := []struct {
+		desc    string
+		content string
+		want    DataFormat
+	}{
+		{desc: "JSON lines", content: "{\"a\":1}\n{\"a\":2}\n", want: JSON},
+		{desc: "JSON with a byte order mark", content: "\xef\xbb\xbf {\"a\":1}", want: JSON},
+		{desc: "Array", content: `[{"a":1},{"a":2}]`, want: MultiJSON},
+		{desc: "Object on several lines", content: "{\n  \"a\": 1\n}\n", want: MultiJSON},
+		{desc: "Truncated JSON", content: "{\"a\":\"" + strings.Repeat("x", 10000), want: DFUnknown},
+		{desc: "CSV", content: "a,b,c\n1,2,3\n", want: CSV},
+		{desc: "CSV with quotes", content: "\"a,1\",b\n\"c\td\",e\n", want: CSV},
+		{desc: "CSV starting with a bracket", content: "[a],b\n[c],d\n", want: CSV},
+		{desc: "CSV longer than the sniffed bytes", content: long, want: CSV},
+		{desc: "TSV", content: "a\tb\n1\t2\n", want: TSV},
+		{desc: "Gzip compressed CSV", content: gzipped("a,b\n1,2\n"), want: CSV},
+		{desc: "Gzip compressed JSON", content: gzipped("{\"a\":1}\n"), want: JSON},
+		{desc: "Both delimiters", content: "a,b\tc\nd,e\tf\n", want: DFUnknown},
+		{desc: "Uneven fields", content: "a,b\nc,d,e\n", want: DFUnknown},
+		{desc: "Single column", content: "a\nb\n", want: DFUnknown},
+		{desc: "Empty", content: "", want: DFUnknown},
+		{desc: "Binary", content: "PAR1\x00\x01\x02", want: DFUnknown},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			format, r, err := SniffFormat(strings.NewReader(test.content))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, format)
+
+			all, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, test.content, string(all), "the reader should return the whole content")
+		})
+	}
+}
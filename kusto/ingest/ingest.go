@@ -5,7 +5,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 
@@ -13,6 +18,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/utils"
 	"github.com/google/uuid"
 )
 
@@ -35,8 +41,15 @@ type Ingestion struct {
 	connMu     sync.Mutex
 	streamConn streamIngestor
 
-	bufferSize int
-	maxBuffers int
+	instrumentation instrumentation
+
+	http    *http.Client
+	schemas *schemaCache
+
+	bufferSize        int
+	maxBuffers        int
+	uploadConcurrency int
+	refreshInterval   time.Duration
 }
 
 // Option is an optional argument to New().
@@ -50,7 +63,40 @@ func WithStaticBuffer(bufferSize int, maxBuffers int) Option {
 	}
 }
 
-// New is a constructor for Ingestion.
+// WithUploadConcurrency limits the number of blob uploads the client runs in parallel to n, across all the
+// FromFile() and FromReader() calls made on it. It doesn't start uploads itself: each call still uploads from its own
+// goroutine, and waits for a free slot first, or for its context to end. Each upload still picks its storage account
+// from the ranked list returned by the service, so parallel uploads are spread across accounts.
+// By default, or if n is smaller than 1, uploads are not limited, and are only bound by the number of concurrent callers,
+// as before this option existed. Use a limit of 1 to serialize the uploads of concurrent callers.
+func WithUploadConcurrency(n int) Option {
+	return func(s *Ingestion) {
+		s.uploadConcurrency = n
+	}
+}
+
+// WithResourceRefreshInterval sets how often the client fetches the ingestion resources (queues and containers) from the
+// service. Resources are also fetched again shortly before the SAS tokens in them expire, and after Blob Storage rejects
+// a SAS token during an upload. Defaults to 1 hour.
+func WithResourceRefreshInterval(d time.Duration) Option {
+	return func(s *Ingestion) {
+		s.refreshInterval = d
+	}
+}
+
+// WithHTTPClient sets the *http.Client the ingest client uses for its own requests: the blob uploads, the queue
+// messages, the blob size lookups and the streaming ingestions. By default, it uses the one of the QueryClient passed
+// to New(), which is set with kusto.WithHTTPClient(). Requests made through the QueryClient itself, like fetching the
+// ingestion resources, keep using the QueryClient's. As with kusto.WithHTTPClient(), http.Client.Timeout applies to
+// every request regardless of the context passed in, and can cut large uploads short.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Ingestion) {
+		s.http = client
+	}
+}
+
+// New is a constructor for Ingestion. client is a client of the query endpoint of the cluster, or of its data
+// management endpoint: ingestion is always sent to the data management endpoint, see DataManagementURI().
 func New(client QueryClient, db, table string, options ...Option) (*Ingestion, error) {
 	mgr, err := resources.New(client)
 	if err != nil {
@@ -58,17 +104,25 @@ func New(client QueryClient, db, table string, options ...Option) (*Ingestion, e
 	}
 
 	i := &Ingestion{
-		client: client,
-		mgr:    mgr,
-		db:     db,
-		table:  table,
+		client:          client,
+		mgr:             mgr,
+		db:              db,
+		table:           table,
+		instrumentation: newInstrumentation(client),
+		schemas:         newSchemaCache(client),
 	}
 
 	for _, option := range options {
 		option(i)
 	}
+	mgr.SetRefreshInterval(i.refreshInterval)
+	mgr.SetLogger(i.instrumentation.log())
+	if i.http == nil {
+		i.http = client.HttpClient()
+	}
 
-	fs, err := queued.New(db, table, mgr, client.HttpClient(), queued.WithStaticBuffer(i.bufferSize, i.maxBuffers))
+	fs, err := queued.New(db, table, mgr, i.http, queued.WithStaticBuffer(i.bufferSize, i.maxBuffers),
+		queued.WithUploadConcurrency(i.uploadConcurrency), queued.WithMetrics(i.instrumentation.metrics), queued.WithLogger(i.instrumentation.log()))
 	if err != nil {
 		return nil, err
 	}
@@ -78,41 +132,90 @@ func New(client QueryClient, db, table string, options ...Option) (*Ingestion, e
 	return i, nil
 }
 
-func (i *Ingestion) prepForIngestion(ctx context.Context, options []FileOption, props properties.All, source SourceScope) (*Result, properties.All, error) {
-	result := newResult()
+// checkTarget checks that the database and table to ingest into, set when the client was created or with WithDatabase()
+// and WithTable(), are not empty.
+func checkTarget(op errors.Op, props *properties.All) error {
+	if props.Ingestion.DatabaseName == "" {
+		return errors.ES(op, errors.KClientArgs, "the database to ingest into cannot be empty, set it when creating the client or with WithDatabase()").SetNoRetry()
+	}
+	if props.Ingestion.TableName == "" {
+		return errors.ES(op, errors.KClientArgs, "the table to ingest into cannot be empty, set it when creating the client or with WithTable()").SetNoRetry()
+	}
+	return nil
+}
 
-	auth, err := i.mgr.AuthContext(ctx)
-	if err != nil {
-		return nil, properties.All{}, err
+// sniffReaderFormat sets the format of props from the content of reader, see SniffFormat(), if no format was set. It
+// returns the reader to read the whole content from. An error reading reader leaves the format unset: the returned
+// reader returns it again, so it is reported where the content is read.
+func sniffReaderFormat(props *properties.All, reader io.Reader) io.Reader {
+	if props.Ingestion.Additional.Format != DFUnknown {
+		return reader
 	}
+	format, reader, err := properties.SniffFormat(reader)
+	if err == nil {
+		props.Ingestion.Additional.Format = format
+	}
+	return reader
+}
 
-	props.Ingestion.Additional.AuthContext = auth
+// prepForIngestion runs the options on props and completes them. For a FromReader source, reader is the source: its
+// format is detected from its content if no format was set, and it is replaced with a reader of the whole content.
+func (i *Ingestion) prepForIngestion(ctx context.Context, options []FileOption, props properties.All, source SourceScope, reader *io.Reader) (*Result, properties.All, error) {
+	result := newResult()
 
 	for _, o := range options {
 		if err := o.Run(&props, QueuedClient, source); err != nil {
 			return nil, properties.All{}, err
 		}
 	}
+	if err := checkTarget(errors.OpFileIngest, &props); err != nil {
+		return nil, properties.All{}, err
+	}
+	if props.Ingestion.ReportLevel == properties.None && reportsToTable(props) {
+		return nil, properties.All{}, errors.ES(
+			errors.OpFileIngest,
+			errors.KClientArgs,
+			"ingestion statuses cannot be reported to the status table with ReportNone, see WithReportLevel()",
+		).SetNoRetry()
+	}
+
+	if !props.Source.DryRun {
+		auth, err := i.mgr.AuthContext(ctx)
+		if err != nil {
+			return nil, properties.All{}, err
+		}
+
+		props.Ingestion.Additional.AuthContext = auth
+	}
 
-	if source == FromReader && props.Ingestion.Additional.Format == DFUnknown {
-		props.Ingestion.Additional.Format = CSV
+	if source == FromReader {
+		*reader = sniffReaderFormat(&props, *reader)
+		if props.Ingestion.Additional.Format == DFUnknown {
+			props.Ingestion.Additional.Format = CSV
+		}
 	}
 
-	if props.Ingestion.Additional.IngestionMappingType != DFUnknown && props.Ingestion.Additional.Format != props.Ingestion.Additional.IngestionMappingType {
+	if !props.Ingestion.Additional.MappingMatchesFormat() {
 		return nil, properties.All{}, errors.ES(
 			errors.OpUnknown,
 			errors.KClientArgs,
 			"format and ingestion mapping type must match (hint: using ingestion mapping sets the format automatically)",
 		).SetNoRetry()
 	}
+	if !props.Ingestion.Additional.FirstRecordMatchesFormat() {
+		return nil, properties.All{}, queued.FirstRecordFormatError(props.Ingestion.Additional.Format)
+	}
+
+	if err := i.schemas.prepare(ctx, errors.OpFileIngest, &props); err != nil {
+		return nil, properties.All{}, err
+	}
 
-	if props.Ingestion.ReportLevel != properties.None {
+	if props.Ingestion.ReportLevel != properties.None && !props.Source.DryRun {
 		if props.Source.ID == uuid.Nil {
 			props.Source.ID = uuid.New()
 		}
 
-		switch props.Ingestion.ReportMethod {
-		case properties.ReportStatusToTable, properties.ReportStatusToQueueAndTable:
+		if reportsToTable(props) {
 			tableResources, err := i.mgr.GetTables()
 			if err != nil {
 				return nil, properties.All{}, err
@@ -135,7 +238,9 @@ func (i *Ingestion) prepForIngestion(ctx context.Context, options []FileOption,
 // FromFile allows uploading a data file for Kusto from either a local path or a blobstore URI path.
 // This method is thread-safe.
 func (i *Ingestion) FromFile(ctx context.Context, fPath string, options ...FileOption) (*Result, error) {
-	return i.fromFile(ctx, fPath, options, i.newProp())
+	return i.instrumentation.run(ctx, "kusto.ingest.FromFile", "queued", i.db, i.table, func(ctx context.Context) (*Result, error) {
+		return i.fromFile(ctx, fPath, options, i.newProp())
+	})
 }
 
 // fromFile is an internal function to allow managed streaming to pass a properties object to the ingestion.
@@ -153,15 +258,25 @@ func (i *Ingestion) fromFile(ctx context.Context, fPath string, options []FileOp
 		scope = FromBlob
 	}
 
-	result, props, err := i.prepForIngestion(ctx, options, props, scope)
+	result, props, err := i.prepForIngestion(ctx, options, props, scope, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	result.record.IngestionSourcePath = fPath
 
+	if props.Source.DryRun {
+		size := int64(-1)
+		if local {
+			if info, err := os.Stat(fPath); err == nil {
+				size = info.Size()
+			}
+		}
+		return dryRun(result, props, fPath, local, size)
+	}
+
 	if local {
-		err = i.fs.Local(ctx, fPath, props)
+		result.upload, err = i.fs.Local(ctx, fPath, props)
 	} else {
 		err = i.fs.Blob(ctx, fPath, 0, props)
 	}
@@ -174,30 +289,131 @@ func (i *Ingestion) fromFile(ctx context.Context, fPath string, options []FileOp
 	return result, nil
 }
 
+// FromBlob ingests a blob that is already in Azure Blob Storage, without downloading it or uploading it again.
+// blobURL must carry a SAS token, or one of the Kusto storage connection string credentials appended to it, such as
+// ";managed_identity=<id>", ";impersonate" or ";<account key>", so that the service can read the blob.
+// size is the raw (uncompressed) size of the data. If it is 0 it is estimated from the size of the blob, which is read
+// from Blob Storage. The format and compression are discovered from the blob name, unless an option sets them.
+// This method is thread-safe.
+func (i *Ingestion) FromBlob(ctx context.Context, blobURL string, size int64, options ...FileOption) (*Result, error) {
+	if err := validateBlobURL(blobURL); err != nil {
+		return nil, err
+	}
+
+	result, props, err := i.prepForIngestion(ctx, options, i.newProp(), FromBlob, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result.record.IngestionSourcePath = blobURL
+
+	if props.Source.DryRun {
+		return dryRun(result, props, blobURL, false, size)
+	}
+
+	if size == 0 {
+		blobSize, err := utils.FetchBlobSize(blobURL, ctx, i.http)
+		if err != nil {
+			return nil, errors.ES(errors.OpFileIngest, errors.KBlobstore, "could not get the size of the blob: %s", err)
+		}
+		size = utils.EstimateRawDataSize(utils.CompressionDiscovery(blobURL), blobSize)
+	}
+
+	if err := i.fs.Blob(ctx, blobURL, size, props); err != nil {
+		return nil, err
+	}
+
+	result.putQueued(i.mgr)
+	return result, nil
+}
+
+// validateBlobURL checks that blobURL points to Blob Storage and carries a credential the service can read it with.
+func validateBlobURL(blobURL string) error {
+	u, err := url.Parse(blobURL)
+	if err != nil {
+		return errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromBlob() could not parse the blob URL").SetNoRetry()
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromBlob() requires an https Blob Storage URL").SetNoRetry()
+	}
+
+	if u.Query().Get("sig") != "" {
+		return nil
+	}
+
+	// Storage connection strings can append a credential to the path, e.g. "/container/blob;managed_identity=system".
+	if i := strings.LastIndex(u.Path, ";"); i >= 0 && i < len(u.Path)-1 {
+		return nil
+	}
+
+	return errors.ES(
+		errors.OpFileIngest,
+		errors.KClientArgs,
+		"FromBlob() URL %q has no SAS token or storage credential, the service would not be able to read it", u.String(),
+	).SetNoRetry()
+}
+
 // FromReader allows uploading a data file for Kusto from an io.Reader. The content is uploaded to Blobstore and
 // ingested after all data in the reader is processed. Content should not use compression as the content will be
-// compressed with gzip. This method is thread-safe.
+// compressed with gzip. If no format is set, it is detected from the content with SniffFormat(), and is CSV if it
+// can't be. This method is thread-safe.
 func (i *Ingestion) FromReader(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
-	return i.fromReader(ctx, reader, options, i.newProp())
+	return i.instrumentation.run(ctx, "kusto.ingest.FromReader", "queued", i.db, i.table, func(ctx context.Context) (*Result, error) {
+		return i.fromReader(ctx, reader, options, i.newProp())
+	})
 }
 
 // fromReader is an internal function to allow managed streaming to pass a properties object to the ingestion.
 func (i *Ingestion) fromReader(ctx context.Context, reader io.Reader, options []FileOption, props properties.All) (*Result, error) {
-	result, props, err := i.prepForIngestion(ctx, options, props, FromReader)
+	result, props, err := i.prepForIngestion(ctx, options, props, FromReader, &reader)
 	if err != nil {
 		return nil, err
 	}
 
-	path, err := i.fs.Reader(ctx, reader, props)
+	if props.Source.DryRun {
+		_, size, err := queued.PeekSize(&props, reader)
+		if err != nil {
+			return nil, errors.E(errors.OpFileIngest, errors.KIO, err)
+		}
+		return dryRun(result, props, props.Source.OriginalSource, true, size)
+	}
+
+	path, upload, err := i.fs.Reader(ctx, reader, props)
 	if err != nil {
 		return nil, err
 	}
 
 	result.record.IngestionSourcePath = path
+	result.upload = upload
 	result.putQueued(i.mgr)
 	return result, nil
 }
 
+// dryRun completes and validates the properties the way an ingestion of from would, without uploading or ingesting
+// anything. compressible is true if the client would compress the source before uploading it, and size is the size of
+// the source, or -1 if it is unknown, to apply WithCompressionMinBytes() as an upload would.
+func dryRun(result *Result, props properties.All, from string, compressible bool, size int64) (*Result, error) {
+	if err := queued.CompleteFormatFromFileName(&props, from); err != nil {
+		return nil, err
+	}
+
+	switch "" {
+	case props.Ingestion.DatabaseName:
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "the database name cannot be an empty string").SetNoRetry()
+	case props.Ingestion.TableName:
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "the table name cannot be an empty string").SetNoRetry()
+	}
+
+	result.putProps(props)
+	result.record.Status = DryRun
+	result.dryRun = &DryRunDetails{
+		Format:              props.Ingestion.Additional.Format,
+		IngestionMappingRef: props.Ingestion.Additional.IngestionMappingRef,
+		Compress:            compressible && queued.ShouldCompressSize(&props, utils.CompressionDiscovery(from), size),
+	}
+	return result, nil
+}
+
 // Deprecated: Stream use a streaming ingest client instead - `ingest.NewStreaming`.
 // takes a payload that is encoded in format with a server stored mappingName, compresses it and uploads it to Kusto.
 // More information can be found here:
@@ -233,7 +449,7 @@ func (i *Ingestion) getStreamConn() (streamIngestor, error) {
 		return i.streamConn, nil
 	}
 
-	sc, err := kusto.NewConn(removeIngestPrefix(i.client.Endpoint()), i.client.Auth(), i.client.HttpClient(), i.client.ClientDetails())
+	sc, err := kusto.NewConn(removeIngestPrefix(i.client.Endpoint()), i.client.Auth(), i.http, i.client.ClientDetails())
 	if err != nil {
 		return nil, err
 	}
@@ -247,6 +463,9 @@ func (i *Ingestion) newProp() properties.All {
 			DatabaseName: i.db,
 			TableName:    i.table,
 		},
+		Source: properties.SourceOptions{
+			CompressionMinBytes: DefaultCompressionMinBytes,
+		},
 	}
 }
 
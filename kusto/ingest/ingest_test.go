@@ -6,10 +6,13 @@ import (
 	"testing"
 
 	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockClient struct {
@@ -162,3 +165,108 @@ func TestIngestion(t *testing.T) {
 		})
 	}
 }
+
+func TestFromBlob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		url      string
+		size     int64
+		wantSize int64
+		wantErr  bool
+	}{
+		{
+			desc:     "SAS token with size",
+			url:      "https://account.blob.core.windows.net/container/data.csv.gz?sv=2020-08-04&sig=abc",
+			size:     1024,
+			wantSize: 1024,
+		},
+		{
+			desc: "Managed identity without size",
+			url:  "https://account.blob.core.windows.net/container/data.json;managed_identity=system",
+		},
+		{
+			desc: "Impersonation without size",
+			url:  "https://account.blob.core.windows.net/container/data.json;impersonate",
+		},
+		{
+			desc:    "No credential",
+			url:     "https://account.blob.core.windows.net/container/data.csv",
+			size:    1024,
+			wantErr: true,
+		},
+		{
+			desc:    "Not https",
+			url:     "http://account.blob.core.windows.net/container/data.csv?sig=abc",
+			size:    1024,
+			wantErr: true,
+		},
+		{
+			desc:    "Local path",
+			url:     "data.csv",
+			size:    1024,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				auth:     kusto.Authorization{},
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(client, "db", "table")
+			require.NoError(t, err)
+
+			var gotFrom string
+			var gotSize int64
+			ingestion.fs = resources.FsMock{
+				OnBlob: func(ctx context.Context, from string, fileSize int64, props properties.All) error {
+					gotFrom, gotSize = from, fileSize
+					return nil
+				},
+			}
+
+			result, err := ingestion.FromBlob(context.Background(), test.url, test.size)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.False(t, errors.Retry(err))
+				assert.Empty(t, gotFrom)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, result)
+			assert.Equal(t, test.url, gotFrom)
+			assert.Equal(t, test.wantSize, gotSize)
+		})
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	client := mockClient{endpoint: "https://test.kusto.windows.net"}
+
+	ingestion, err := New(client, "db", "table")
+	require.NoError(t, err)
+	assert.NotNil(t, ingestion.http, "the QueryClient's http client should be used by default")
+
+	httpClient := &http.Client{}
+	ingestion, err = New(client, "db", "table", WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	assert.Same(t, httpClient, ingestion.http)
+
+	managed, err := NewManaged(client, "db", "table", WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	assert.Same(t, httpClient, managed.queued.http)
+}
@@ -10,6 +10,8 @@ func (c CompressionType) String() string {
 		return "gzip"
 	case ZIP:
 		return "zip"
+	case ZSTD:
+		return "zstd"
 	}
 	return "unknown compression type"
 }
@@ -24,4 +26,34 @@ const (
 	GZIP CompressionType = 2
 	// ZIP indicates that the file is ZIP compressed.
 	ZIP CompressionType = 3
+	// ZSTD indicates that the file is Zstandard compressed.
+	ZSTD CompressionType = 4
+)
+
+// UploadMode is how a local file is uploaded to Blob Storage for a queued ingestion.
+type UploadMode int8
+
+// String implements fmt.Stringer.
+func (u UploadMode) String() string {
+	switch u {
+	case UploadModeAuto:
+		return "auto"
+	case UploadModeStream:
+		return "stream"
+	case UploadModeFile:
+		return "file"
+	}
+	return "unknown upload mode"
+}
+
+//goland:noinspection GoUnusedConst - Part of the API
+const (
+	// UploadModeAuto uploads files that the client compresses as a stream, and the others with parallel block uploads
+	// of the file. This is the default.
+	UploadModeAuto UploadMode = 0
+	// UploadModeStream reads the file sequentially and uploads it as a stream, without relying on its size.
+	UploadModeStream UploadMode = 1
+	// UploadModeFile uploads the file with parallel block uploads, based on its size. It requires a file that the
+	// client doesn't compress.
+	UploadModeFile UploadMode = 2
 )
@@ -0,0 +1,117 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerProvider is implemented by clients that were given a TracerProvider, such as *kusto.Client.
+type tracerProvider interface {
+	TracerProvider() trace.TracerProvider
+}
+
+// tracerOf returns the tracer to create ingestion spans with, or nil if the client has no TracerProvider.
+func tracerOf(client QueryClient) trace.Tracer {
+	if c, ok := client.(tracerProvider); ok {
+		return tracing.Tracer(c.TracerProvider())
+	}
+	return nil
+}
+
+// metricsProvider is implemented by clients that were given a Metrics, such as *kusto.Client.
+type metricsProvider interface {
+	Metrics() kusto.Metrics
+}
+
+// metricsOf returns the Metrics to report ingestions to, which is kusto.NopMetrics if the client has none.
+func metricsOf(client QueryClient) kusto.Metrics {
+	if c, ok := client.(metricsProvider); ok {
+		return c.Metrics()
+	}
+	return kusto.NopMetrics{}
+}
+
+// loggerProvider is implemented by clients that were given a Logger, such as *kusto.Client.
+type loggerProvider interface {
+	Logger() kusto.Logger
+}
+
+// loggerOf returns the Logger to log the ingestions to, which is kusto.NopLogger if the client has none.
+func loggerOf(client QueryClient) kusto.Logger {
+	if c, ok := client.(loggerProvider); ok {
+		return c.Logger()
+	}
+	return kusto.NopLogger{}
+}
+
+// instrumentation holds what an ingest client reports its ingestions to.
+type instrumentation struct {
+	tracer  trace.Tracer
+	metrics kusto.Metrics
+	logger  kusto.Logger
+}
+
+func newInstrumentation(client QueryClient) instrumentation {
+	return instrumentation{tracer: tracerOf(client), metrics: metricsOf(client), logger: loggerOf(client)}
+}
+
+// log returns the Logger to log to, which is kusto.NopLogger if there is none.
+func (in instrumentation) log() kusto.Logger {
+	if in.logger == nil {
+		return kusto.NopLogger{}
+	}
+	return in.logger
+}
+
+// run runs the ingestion f in a span named name, and reports its duration. If there is no tracer, no span is created.
+func (in instrumentation) run(ctx context.Context, name, client, db, table string, f func(ctx context.Context) (*Result, error)) (*Result, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, in.tracer, name, tracing.IngestClient.String(client), tracing.Database.String(db), tracing.Table.String(table))
+	result, err := f(ctx)
+
+	if result != nil {
+		db, table = result.record.Database, result.record.Table
+		if span != nil {
+			span.SetAttributes(result.spanAttributes()...)
+		}
+	}
+	tracing.End(span, err)
+
+	if in.metrics != nil {
+		in.metrics.IngestDuration(db, table, time.Since(start))
+	}
+	return result, err
+}
+
+// spanAttributes returns the attributes of the ingestion span that are only known once the ingestion is done.
+func (r *Result) spanAttributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{tracing.Database.String(r.record.Database), tracing.Table.String(r.record.Table)}
+
+	format := r.format
+	if format == DFUnknown {
+		format = properties.DataFormatDiscovery(r.record.IngestionSourcePath)
+	}
+	if format != DFUnknown {
+		attrs = append(attrs, tracing.Format.String(format.String()))
+	}
+
+	size := r.upload.Size
+	if size == 0 {
+		size = r.bytesIngested
+	}
+	if size > 0 {
+		attrs = append(attrs, tracing.BlobSize.Int64(size))
+	}
+
+	if r.clientRequestID != "" {
+		attrs = append(attrs, tracing.ClientRequestID.String(r.clientRequestID))
+	}
+	return attrs
+}
@@ -0,0 +1,140 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStreamingSpans(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{desc: "Success"},
+		{desc: "Failure", err: fmt.Errorf("ingestion failed")},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			var inSpan bool
+			streaming := &Streaming{
+				db:              "db",
+				table:           "table",
+				instrumentation: instrumentation{tracer: tracing.Tracer(tp)},
+				streamConn: fakeStreamIngestor{onStreamIngest: func(ctx context.Context, _, _ string, payload io.Reader, _ kusto.DataFormatForStreaming, _ string, _ string, _ bool) error {
+					inSpan = trace.SpanContextFromContext(ctx).IsValid()
+					return test.err
+				}},
+			}
+
+			_, err := streaming.FromReader(context.Background(), bytes.NewReader([]byte("a,b\n")), ClientRequestId("request-id"), FileFormat(JSON))
+			if test.err != nil {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.True(t, inSpan, "the request should be made with the span in the context")
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, "kusto.ingest.FromReader", span.Name())
+			assert.Contains(t, span.Attributes(), tracing.IngestClient.String("streaming"))
+			assert.Contains(t, span.Attributes(), tracing.Database.String("db"))
+			assert.Contains(t, span.Attributes(), tracing.Table.String("table"))
+
+			if test.err != nil {
+				assert.Equal(t, codes.Error, span.Status().Code)
+				return
+			}
+			assert.Equal(t, codes.Unset, span.Status().Code)
+			assert.Contains(t, span.Attributes(), tracing.Format.String("json"))
+			assert.Contains(t, span.Attributes(), tracing.ClientRequestID.String("request-id"))
+		})
+	}
+}
+
+// durationMetrics records the IngestDuration calls.
+type durationMetrics struct {
+	kusto.NopMetrics
+	calls []string
+}
+
+func (m *durationMetrics) IngestDuration(db, table string, d time.Duration) {
+	m.calls = append(m.calls, db+"/"+table)
+}
+
+func TestIngestDurationMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := &durationMetrics{}
+	streaming := &Streaming{
+		db:              "db",
+		table:           "table",
+		instrumentation: instrumentation{metrics: m},
+		streamConn: fakeStreamIngestor{onStreamIngest: func(context.Context, string, string, io.Reader, kusto.DataFormatForStreaming, string, string, bool) error {
+			return nil
+		}},
+	}
+
+	_, err := streaming.FromReader(context.Background(), bytes.NewReader([]byte("a,b\n")), Table("other"))
+	require.NoError(t, err)
+	_, err = streaming.FromReader(context.Background(), bytes.NewReader([]byte("a,b\n")), WithRetainBlob())
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"db/other", "db/table"}, m.calls, "the database and table of the ingestion should be reported")
+}
+
+// infoLogger records the messages logged at the Info level.
+type infoLogger struct {
+	kusto.NopLogger
+	infos []string
+}
+
+func (l *infoLogger) Info(msg string, _ ...interface{}) {
+	l.infos = append(l.infos, msg)
+}
+
+func TestLoggerOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, kusto.NopLogger{}, loggerOf(kusto.NewMockClient()))
+	assert.Equal(t, kusto.NopLogger{}, instrumentation{}.log())
+
+	l := &infoLogger{}
+	client, err := kusto.New(kusto.NewConnectionStringBuilder("https://help.kusto.windows.net"), kusto.WithLogger(l))
+	require.NoError(t, err)
+	assert.Equal(t, l, loggerOf(client))
+}
+
+func TestTracerOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, tracerOf(kusto.NewMockClient()))
+
+	client, err := kusto.New(kusto.NewConnectionStringBuilder("https://help.kusto.windows.net"), kusto.WithTracerProvider(sdktrace.NewTracerProvider()))
+	require.NoError(t, err)
+	assert.NotNil(t, tracerOf(client))
+}
@@ -7,26 +7,71 @@ import (
 	"io"
 	"sync"
 	"sync/atomic"
+
+	"github.com/Azure/azure-kusto-go/kusto/internal/gzipmagic"
 )
 
-var compressPool = &sync.Pool{
+// compressPools holds a pool of gzip writers for every supported compression level.
+var compressPools = map[int]*sync.Pool{}
+
+func init() {
+	compressPools[gzip.DefaultCompression] = newCompressPool(gzip.DefaultCompression)
+	for level := gzip.BestSpeed; level <= gzip.BestCompression; level++ {
+		compressPools[level] = newCompressPool(level)
+	}
+}
+
+func newCompressPool(level int) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			zw, err := gzip.NewWriterLevel(nil, level)
+			if err != nil {
+				panic(err)
+			}
+			return zw
+		},
+	}
+}
+
+// copyBufferSize is the size of the buffers that the input is copied through, the same as the one io.Copy() allocates.
+const copyBufferSize = 32 * 1024
+
+// copyBuffers holds the buffers the input of a Streamer is copied through, so that concurrent uploads reuse them instead
+// of each allocating a new one.
+var copyBuffers = sync.Pool{
 	New: func() interface{} {
-		return gzip.NewWriter(nil)
+		b := make([]byte, copyBufferSize)
+		return &b
 	},
 }
 
+// onlyReader hides all the methods of a reader but Read().
+type onlyReader struct {
+	io.Reader
+}
+
 // Streamer implements an io.ReadCloser that converts data from a non-compressed stream to a compressed stream.
 type Streamer struct {
 	userInput   io.ReadCloser
 	outputRead  *io.PipeReader
 	outputWrite *io.PipeWriter
 	size        int64
+	level       int
 	err         atomic.Value // holds error
 }
 
 // New creates a new streamer object. Use Reset() to initialize it.
 func New() *Streamer {
-	return &Streamer{}
+	return &Streamer{level: gzip.DefaultCompression}
+}
+
+// NewLevel creates a new streamer object that compresses with the given compress/gzip level, in the range of
+// gzip.BestSpeed to gzip.BestCompression. Any other level uses gzip.DefaultCompression. Use Reset() to initialize it.
+func NewLevel(level int) *Streamer {
+	if _, ok := compressPools[level]; !ok {
+		level = gzip.DefaultCompression
+	}
+	return &Streamer{level: level}
 }
 
 // Reset resets the streamer object to defaults and accepts the io.ReadCloser.
@@ -47,29 +92,50 @@ func (s *Streamer) InputSize() int64 {
 }
 
 func Compress(payload io.Reader) io.Reader {
+	return CompressLevel(payload, gzip.DefaultCompression)
+}
+
+// CompressLevel is like Compress, but uses the given compress/gzip level. See NewLevel().
+func CompressLevel(payload io.Reader, level int) io.Reader {
 	var closer io.ReadCloser
 	var ok bool
 	if closer, ok = payload.(io.ReadCloser); !ok {
 		closer = io.NopCloser(payload)
 	}
-	zw := New()
+	zw := NewLevel(level)
 	zw.Reset(closer)
 
 	return zw
 }
 
+// Peek reports whether the content of payload is already gzip compressed, by looking for the gzip magic number in its
+// first two bytes. The returned reader must be used in place of payload, as it holds the bytes that were peeked.
+func Peek(payload io.Reader) (io.Reader, bool, error) {
+	return gzipmagic.Peek(payload)
+}
+
 // run copies the file into a buffer that we stream back via our Read() call.
 func (s *Streamer) run() {
-	zw := compressPool.Get().(*gzip.Writer)
+	pool := compressPools[s.level]
+	zw := pool.Get().(*gzip.Writer)
 	zw.Reset(s.outputWrite)
 
+	buf := copyBuffers.Get().(*[]byte)
+
 	go func() {
-		defer compressPool.Put(zw)
+		defer func() {
+			// The writer is reset so the pool doesn't keep a reference to the output of this stream, and the next
+			// stream starts with a clean writer state.
+			zw.Reset(io.Discard)
+			pool.Put(zw)
+			copyBuffers.Put(buf)
+		}()
 		defer s.outputWrite.Close()
 		defer zw.Close()
-		defer zw.Flush()
 
-		amount, err := io.Copy(zw, s.userInput)
+		// The input is wrapped to hide its WriteTo() method, if any, which io.CopyBuffer() would use instead of buf.
+		// *os.File has one that allocates its own buffer.
+		amount, err := io.CopyBuffer(zw, onlyReader{s.userInput}, *buf)
 		s.size = int64(amount)
 
 		if err != nil {
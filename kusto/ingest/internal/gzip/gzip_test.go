@@ -3,9 +3,11 @@ package gzip
 import (
 	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"math/rand"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -65,3 +67,166 @@ func TestStreamer(t *testing.T) {
 		t.Fatalf("TestStreamer(InputSize): got %d, want %d", streamer.InputSize(), len(str))
 	}
 }
+
+func TestStreamerLevel(t *testing.T) {
+	t.Parallel()
+
+	str := strings.Repeat("the quick brown fox jumps over the lazy dog,", 64*1024)
+
+	compress := func(level int) []byte {
+		compressedBuf := bytes.Buffer{}
+		if _, err := io.Copy(&compressedBuf, CompressLevel(strings.NewReader(str), level)); err != nil {
+			t.Fatalf("TestStreamerLevel(%d): got err == %s, want err == nil", level, err)
+		}
+
+		gzipReader, err := gzip.NewReader(bytes.NewReader(compressedBuf.Bytes()))
+		if err != nil {
+			t.Fatalf("TestStreamerLevel(%d)(gzip.NewReader(compressedBuf)): got err == %s, want err == nil", level, err)
+		}
+		gotBuf := bytes.Buffer{}
+		if _, err := io.Copy(&gotBuf, gzipReader); err != nil {
+			t.Fatalf("TestStreamerLevel(%d)(decompressing stream): got err == %s, want err == nil", level, err)
+		}
+		if gotBuf.String() != str {
+			t.Fatalf("TestStreamerLevel(%d)(input/output comparison): after compression/decompression the data was not the same", level)
+		}
+
+		return compressedBuf.Bytes()
+	}
+
+	fast := compress(gzip.BestSpeed)
+	best := compress(gzip.BestCompression)
+	if len(fast) <= len(best) {
+		t.Fatalf("TestStreamerLevel: BestSpeed output (%d bytes) should be larger than BestCompression output (%d bytes)", len(fast), len(best))
+	}
+
+	// An unsupported level falls back to the default compression.
+	if got, want := compress(42), compress(gzip.DefaultCompression); !bytes.Equal(got, want) {
+		t.Fatalf("TestStreamerLevel: an unsupported level should compress like gzip.DefaultCompression")
+	}
+}
+
+func TestStreamerConcurrent(t *testing.T) {
+	t.Parallel()
+
+	// The streams share pooled writers and buffers, which must not leak data from one stream to another.
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		str := strings.Repeat(randStringBytes(10), 16*1024+i)
+		go func() {
+			compressedBuf := bytes.Buffer{}
+			if _, err := io.Copy(&compressedBuf, Compress(strings.NewReader(str))); err != nil {
+				errs <- err
+				return
+			}
+			gzipReader, err := gzip.NewReader(&compressedBuf)
+			if err != nil {
+				errs <- err
+				return
+			}
+			gotBuf := bytes.Buffer{}
+			if _, err := io.Copy(&gotBuf, gzipReader); err != nil {
+				errs <- err
+				return
+			}
+			if gotBuf.String() != str {
+				errs <- fmt.Errorf("got %d bytes, want the %d bytes of the input", gotBuf.Len(), len(str))
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("TestStreamerConcurrent: got err == %s, want err == nil", err)
+		}
+	}
+}
+
+func TestPeek(t *testing.T) {
+	t.Parallel()
+
+	compressed := bytes.Buffer{}
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte("a,1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc    string
+		payload []byte
+		want    bool
+	}{
+		{desc: "Gzip", payload: compressed.Bytes(), want: true},
+		{desc: "Plain", payload: []byte("a,1\n"), want: false},
+		{desc: "Single byte", payload: []byte{0x1f}, want: false},
+		{desc: "Empty", payload: nil, want: false},
+	}
+
+	for _, test := range tests {
+		r, got, err := Peek(bytes.NewReader(test.payload))
+		if err != nil {
+			t.Fatalf("TestPeek(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		if got != test.want {
+			t.Errorf("TestPeek(%s): got %v, want %v", test.desc, got, test.want)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("TestPeek(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		if !bytes.Equal(content, test.payload) {
+			t.Errorf("TestPeek(%s): the returned reader did not hold the whole payload", test.desc)
+		}
+	}
+}
+
+// unpooledCompress compresses payload like Streamer did before its writers and buffers were pooled, for comparison.
+func unpooledCompress(payload io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, payload)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// BenchmarkStreamer compares the allocations per upload of Streamer with those of an unpooled compression. Run with
+// go test -bench Streamer -benchmem.
+func BenchmarkStreamer(b *testing.B) {
+	payload := []byte(strings.Repeat("2020-03-10T20:59:30.694177Z,11196991-b193-4610-ae12-bcc03d092927,v0.0.1,Hello world!\n", 8*1024))
+
+	benchmarks := []struct {
+		desc     string
+		compress func(io.Reader) io.Reader
+	}{
+		{desc: "Pooled", compress: Compress},
+		{desc: "Unpooled", compress: unpooledCompress},
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.desc, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(payload)))
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					// onlyReader hides the WriteTo() method of bytes.Reader, which io.Copy() would use instead
+					// of allocating a buffer, unlike with the file readers of uploads.
+					in := onlyReader{bytes.NewReader(payload)}
+					if _, err := io.Copy(io.Discard, bm.compress(in)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -82,7 +82,7 @@ var dfDescriptions = []dfDescriptor{
 	{"ApacheAvro", "avro", "", false, false},
 	{"Csv", "csv", ".csv", true, true},
 	{"Json", "json", ".json", true, true},
-	{"MultiJson", "multijson", "", false, true},
+	{"MultiJson", "multijson", ".multijson", false, true},
 	{"Orc", "orc", ".orc", true, false},
 	{"Parquet", "parquet", ".parquet", true, false},
 	{"Psv", "psv", ".psv", false, true},
@@ -170,6 +170,47 @@ func (d DataFormat) IsValidMappingKind() bool {
 	return false
 }
 
+// MappingKind returns the kind of ingestion mapping that is used with the format, such as CSV for TSV or JSON for
+// MultiJSON. It returns DFUnknown for formats that don't support mappings.
+func (d DataFormat) MappingKind() DataFormat {
+	switch d {
+	case CSV, PSV, Raw, SCSV, SOHSV, TSV, TSVE, TXT:
+		return CSV
+	case JSON, MultiJSON, SingleJSON:
+		return JSON
+	case AVRO, ApacheAVRO:
+		return AVRO
+	case ORC, Parquet, W3CLogFile:
+		return d
+	}
+	return DFUnknown
+}
+
+// MappingMatchesFormat returns false if both the format and the ingestion mapping type are set, and the mapping
+// type is not the kind of mapping used with the format.
+func (a Additional) MappingMatchesFormat() bool {
+	if a.Format == DFUnknown || a.IngestionMappingType == DFUnknown {
+		return true
+	}
+	return a.Format.MappingKind() == a.IngestionMappingType
+}
+
+// IsSeparatedValues returns true for the formats of records of separated values, such as CSV and TSV, whose first
+// record can be a header.
+func (d DataFormat) IsSeparatedValues() bool {
+	switch d {
+	case CSV, PSV, SCSV, SOHSV, TSV, TSVE:
+		return true
+	}
+	return false
+}
+
+// FirstRecordMatchesFormat returns false if IgnoreFirstRecord is set and the format is set to a format that is not of
+// separated values.
+func (a Additional) FirstRecordMatchesFormat() bool {
+	return !a.IgnoreFirstRecord || a.Format == DFUnknown || a.Format.IsSeparatedValues()
+}
+
 func (d DataFormat) ShouldCompress() bool {
 	if d > 0 && int(d) < len(dfDescriptions) {
 		return dfDescriptions[d].shouldCompress
@@ -178,7 +219,11 @@ func (d DataFormat) ShouldCompress() bool {
 	return true
 }
 
+// compressionExts are the file extensions of compressed files, which are stripped before discovering the data format.
+var compressionExts = []string{".gz", ".zip", ".zst", ".zstd"}
+
 // DataFormatDiscovery looks at the file name and tries to discern what the file format is.
+// A trailing compression extension is ignored, so "file.json.gz" is discovered as JSON.
 func DataFormatDiscovery(fName string) DataFormat {
 	name := fName
 
@@ -187,7 +232,15 @@ func DataFormatDiscovery(fName string) DataFormat {
 		name = u.Path
 	}
 
-	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(name), ".zip"), ".gz")))
+	name = strings.ToLower(name)
+	for _, compressionExt := range compressionExts {
+		if strings.HasSuffix(name, compressionExt) {
+			name = strings.TrimSuffix(name, compressionExt)
+			break
+		}
+	}
+
+	ext := filepath.Ext(name)
 
 	if ext == "" {
 		return DFUnknown
@@ -212,20 +265,77 @@ type All struct {
 	Streaming Streaming
 	// ManagedStreaming provides options that are used when doing an ingestion from a ManagedStreaming client.
 	ManagedStreaming ManagedStreaming
+	// Status provides options that are used when tracking the status of an ingestion.
+	Status Status
+	// Dir provides options that are used when ingesting the files of a directory.
+	Dir Dir
+}
+
+// Dir provides options that are used when ingesting the files of a directory.
+type Dir struct {
+	// Glob is the pattern that the names of the files must match. Empty means all files.
+	Glob string
+	// Recursive indicates to also ingest the files of sub directories.
+	Recursive bool
+	// ContinueOnError indicates to ingest all the files, even if ingesting some of them failed.
+	ContinueOnError bool
+}
+
+// Status provides options that are used when tracking the status of an ingestion.
+type Status struct {
+	// PollInterval is the interval between reads of the ingestion status table. 0 means the default interval.
+	PollInterval time.Duration
 }
 
 // ManagedStreaming provides options that are used when doing an ingestion from a ManagedStreaming client.
 type ManagedStreaming struct {
 	// Backoff is the backoff strategy to use when retrying a transiently failed ingestion.
 	Backoff backoff.BackOff
+	// Retries is how many times a transiently failed streaming ingestion is retried before falling back to queued
+	// ingestion.
+	Retries int
+	// FallbackMinRemaining is the minimum time that must remain before the context deadline to fall back to queued ingestion.
+	FallbackMinRemaining time.Duration
+	// SizeLimit is the size above which a payload is ingested with queued ingestion instead of streaming. 0 means the
+	// streaming ingestion limit of the service.
+	SizeLimit int64
+	// SplitRecords indicates to split a payload larger than SizeLimit into several streaming ingestions, cut after
+	// SplitDelimiter, instead of falling back to queued ingestion.
+	SplitRecords   bool
+	SplitDelimiter byte
 }
 
 // Streaming provides options that are used when doing a streaming ingestion.
 type Streaming struct {
 	// ClientRequestID is the client request ID to use for the ingestion.
 	ClientRequestId string
+	// ChunkSize is the maximum size of each streaming request made from a reader. 0 means the reader is sent as a single request.
+	ChunkSize int
+	// EnablePollingTimeout is how long a streaming ingestion is retried while the streaming ingestion policy of the table
+	// has not propagated yet. 0 means it isn't retried.
+	EnablePollingTimeout time.Duration
 }
 
+// EventHandler receives the events of a queued ingestion. See ingest.WithEventHandler().
+type EventHandler interface {
+	// OnUploadStart is called before every attempt to upload the source to the blob at blobURL, which has no SAS.
+	OnUploadStart(source, blobURL string)
+	// OnUploadProgress is called with the number of bytes of the source uploaded so far by the current attempt.
+	OnUploadProgress(uploaded int64)
+	// OnUploadRetry is called with the number of the failed attempt, starting at 1, and its error before every retry.
+	OnUploadRetry(attempt int, err error)
+	// OnIngestCommandSent is called with the ID of the ingestion message once it was queued to the service.
+	OnIngestCommandSent(opID string)
+}
+
+// NopEventHandler is an EventHandler that ignores all the events.
+type NopEventHandler struct{}
+
+func (NopEventHandler) OnUploadStart(string, string) {}
+func (NopEventHandler) OnUploadProgress(int64)       {}
+func (NopEventHandler) OnUploadRetry(int, error)     {}
+func (NopEventHandler) OnIngestCommandSent(string)   {}
+
 // SourceOptions are options that the user provides about the source that is going to be uploaded.
 type SourceOptions struct {
 	// ID allows someone to set the UUID for upload themselves. We aren't providing this option at this time, but here
@@ -235,7 +345,7 @@ type SourceOptions struct {
 	// DeleteLocalSource indicates to delete the local file after it has been consumed.
 	DeleteLocalSource bool
 
-	// DontCompress indicates to not compress the file. In streaming - do not pass DontCompress if file is not already compressed.
+	// DontCompress indicates to not compress the file.
 	DontCompress bool
 
 	// OriginalSource is the path to the original source file, used for deletion.
@@ -243,6 +353,51 @@ type SourceOptions struct {
 
 	// CompressionType is the type of compression used on the file.
 	CompressionType ingestoptions.CompressionType
+
+	// BlobNameTemplate is the template for the names of the blobs that are uploaded for the ingestion.
+	// Empty means the default name is used.
+	BlobNameTemplate string
+
+	// BlobMetadata is custom metadata set on the blobs that are uploaded for the ingestion.
+	BlobMetadata map[string]string
+
+	// DryRun indicates to only complete and validate the properties, without uploading or ingesting the source.
+	DryRun bool
+
+	// CompressionLevel is the compress/gzip level used when compressing the source. 0 means gzip.DefaultCompression.
+	CompressionLevel int
+
+	// CompressionMinBytes is the size, in bytes, under which the source is not compressed, when its size is known.
+	// 0 means the source is always compressed.
+	CompressionMinBytes int64
+
+	// RetainBlob indicates to tag the uploaded blob and ask the service to keep it once the ingestion succeeds.
+	RetainBlob bool
+
+	// DeleteBlobOnSuccess indicates to ask the service to delete the uploaded blob once the ingestion succeeds.
+	DeleteBlobOnSuccess bool
+
+	// ValidateSchema indicates to check the ingestion mapping against the schema of the table before ingesting.
+	ValidateSchema bool
+
+	// Progress is called with the number of bytes uploaded so far and the total to upload, -1 if it is unknown.
+	Progress func(uploaded, total int64)
+
+	// Events receives the events of the upload of the source and of the ingestion command, if set.
+	Events EventHandler
+
+	// BlockSize is the size of the blocks the source is uploaded to Blob Storage in. 0 uses the client's default.
+	BlockSize int64
+
+	// BlockConcurrency is the number of blocks uploaded in parallel. 0 uses the client's default.
+	BlockConcurrency int
+
+	// UploadMode is how a local file is uploaded to Blob Storage.
+	UploadMode ingestoptions.UploadMode
+
+	// CreateTableSchema is the column definition list of the table to create if it doesn't exist before ingesting.
+	// Empty means the table isn't created.
+	CreateTableSchema string
 }
 
 // Ingestion is a JSON serializable set of options that must be provided to the service.
@@ -258,7 +413,8 @@ type Ingestion struct {
 	// RawDataSize is the uncompressed data size. Should be used to comunicate the file size to the service for efficient ingestion.
 	RawDataSize int64 `json:",omitempty"`
 	// RetainBlobOnSuccess indicates if the source blob should be retained or deleted. True is preferrable.
-	RetainBlobOnSuccess bool `json:",omitempty"`
+	// nil leaves the decision to the service.
+	RetainBlobOnSuccess *bool `json:",omitempty"`
 	// FlushImmediately - the service batching manager will not aggregate this file, thus overriding the batching policy
 	FlushImmediately bool
 	// IgnoreSizeLimit - ignores the size limit for data ingestion.
@@ -289,16 +445,140 @@ type Additional struct {
 	IngestionMappingType DataFormat `json:"ingestionMappingType,omitempty"`
 	// ValidationPolicy is a JSON encoded string that tells our ingestion action what policies we want on the
 	// data being ingested and what to do when that is violated.
-	ValidationPolicy  string     `json:"validationPolicy,omitempty"`
-	Format            DataFormat `json:"format,omitempty"`
-	IgnoreFirstRecord bool       `json:"ignoreFirstRecord"`
-	// Tags is a list of tags to associated with the ingested data.
+	ValidationPolicy string     `json:"validationPolicy,omitempty"`
+	Format           DataFormat `json:"format,omitempty"`
+	// IgnoreFirstRecord tells the service to skip the first record of the data, such as the header of a CSV file.
+	IgnoreFirstRecord bool `json:"ignoreFirstRecord"`
+	// Tags is a list of tags to associated with the ingested data, including the drop-by: and ingest-by: tags.
 	Tags []string `json:"tags,omitempty"`
-	// IngestIfNotExists is a string value that, if specified, prevents ingestion from succeeding if the table already
-	// has data tagged with an ingest-by: tag with the same value. This ensures idempotent data ingestion.
-	IngestIfNotExists string `json:"ingestIfNotExists,omitempty"`
+	// IngestIfNotExists is a list of values that, if specified, prevents ingestion from succeeding if the table already
+	// has data tagged with an ingest-by: tag with one of the values. This ensures idempotent data ingestion.
+	IngestIfNotExists []string `json:"ingestIfNotExists,omitempty"`
 	// CreationTime is used to override the time considered for retantion policies, which by default is the time of ingestion.
-	CreationTime time.Time `json:"creationTime,omitempty"`
+	// nil leaves it to the service.
+	CreationTime *time.Time `json:"creationTime,omitempty"`
+}
+
+// jsonList returns l encoded as a JSON array, or an empty string if l is empty.
+func jsonList(l []string) (string, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+// W3CColumnMapping maps a field of a W3C Extended Log File to a column of the table.
+type W3CColumnMapping struct {
+	// Field is the name of the W3C field, as it appears in the #Fields directive of the log, e.g. "cs-uri-stem".
+	Field string
+	// Column is the name of the table column the field is ingested into.
+	Column string
+	// Transform is an optional transformation applied to the field, e.g. "DateTimeFromUnixSeconds".
+	Transform string
+}
+
+// W3CLogFileMapping is an ingestion mapping for data in the W3CLogFile format.
+// See: https://docs.microsoft.com/en-us/azure/data-explorer/kusto/management/w3c-log-file-mapping
+type W3CLogFileMapping []W3CColumnMapping
+
+// Validate validates that every column mapping has a field and a column.
+func (w W3CLogFileMapping) Validate() error {
+	if len(w) == 0 {
+		return fmt.Errorf("W3C log file mapping must have at least one column mapping")
+	}
+
+	for i, c := range w {
+		if strings.TrimSpace(c.Field) == "" {
+			return fmt.Errorf("W3C log file mapping entry %d has an empty Field", i)
+		}
+		if strings.TrimSpace(c.Column) == "" {
+			return fmt.Errorf("W3C log file mapping entry %d has an empty Column", i)
+		}
+	}
+
+	return nil
+}
+
+// ValidateMapping validates an inline ingestion mapping of the kind: it must be a JSON array of column mappings that all
+// have a Column, and for JSON mappings a Properties.Path, ConstValue or Transform to take the value of the column from.
+// The DataType is optional, as the service uses the type of the table column without it. Names are matched ignoring
+// case, like the service does, and the legacy forms of the mappings, with a Name for CSV or a Path for JSON, are
+// accepted.
+func ValidateMapping(mapping string, kind DataFormat) error {
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(mapping), &entries); err != nil {
+		return fmt.Errorf("ingestion mapping is not a JSON array of column mappings: %s", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("ingestion mapping must have at least one column mapping")
+	}
+
+	for i, raw := range entries {
+		var entry map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &entry); err != nil || entry == nil {
+			return fmt.Errorf("ingestion mapping entry %d is not a JSON object", i)
+		}
+		if !mappingString(entry, "Column") && !(kind == CSV && mappingString(entry, "Name")) {
+			return fmt.Errorf("ingestion mapping entry %d has no Column", i)
+		}
+		if _, ok := mappingField(entry, "DataType"); ok && !mappingString(entry, "DataType") {
+			return fmt.Errorf("ingestion mapping entry %d has a DataType that is not a type name", i)
+		}
+
+		var props map[string]json.RawMessage
+		if rawProps, ok := mappingField(entry, "Properties"); ok {
+			if err := json.Unmarshal(rawProps, &props); err != nil {
+				return fmt.Errorf("ingestion mapping entry %d has Properties that are not a JSON object", i)
+			}
+		}
+		if kind == JSON {
+			_, constant := mappingField(props, "ConstValue")
+			if !mappingString(props, "Path") && !mappingString(entry, "Path") && !constant && !mappingString(props, "Transform") {
+				return fmt.Errorf("ingestion mapping entry %d has no Properties.Path, ConstValue or Transform", i)
+			}
+		}
+	}
+	return nil
+}
+
+// mappingField returns the field of a column mapping with the name, ignoring case.
+func mappingField(entry map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	for k, v := range entry {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// mappingString reports whether the field of a column mapping with the name is a non-empty string.
+func mappingString(entry map[string]json.RawMessage, name string) bool {
+	raw, ok := mappingField(entry, name)
+	if !ok {
+		return false
+	}
+	var s string
+	return json.Unmarshal(raw, &s) == nil && strings.TrimSpace(s) != ""
+}
+
+// MarshalJSON implements json.Marshaller, encoding the mapping the way the service expects it.
+func (w W3CLogFileMapping) MarshalJSON() ([]byte, error) {
+	type w3cProperties struct {
+		Field     string
+		Transform string `json:",omitempty"`
+	}
+	type w3cColumn struct {
+		Column     string
+		Properties w3cProperties
+	}
+
+	columns := make([]w3cColumn, 0, len(w))
+	for _, c := range w {
+		columns = append(columns, w3cColumn{Column: c.Column, Properties: w3cProperties{Field: c.Field, Transform: c.Transform}})
+	}
+
+	return json.Marshal(columns)
 }
 
 // StatusTableDescription is a reference to the table status entry used for this ingestion command.
@@ -345,6 +625,15 @@ func (a Additional) MarshalJSON() ([]byte, error) {
 		m["ingestionMappingType"] = a.IngestionMappingType.CamelCase()
 	}
 
+	// The service expects the lists of tags as strings holding JSON arrays.
+	for key, list := range map[string][]string{"tags": a.Tags, "ingestIfNotExists": a.IngestIfNotExists} {
+		if _, ok := m[key]; ok {
+			if m[key], err = jsonList(list); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return json.Marshal(m)
 }
 
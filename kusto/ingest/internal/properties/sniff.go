@@ -0,0 +1,122 @@
+package properties
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// sniffSize is the number of bytes SniffFormat() reads from the start of the content to detect its format.
+const sniffSize = 8 * 1024
+
+// SniffFormat detects the format of the content of r from its first few KB, for the sources that have no file name to
+// discover it from. It recognizes JSON, MultiJSON, CSV and TSV content, also when it is gzip compressed.
+// It is conservative: content that doesn't clearly look like one of them returns DFUnknown, and the format must then
+// be set explicitly. The returned reader reads all of the content of r, including the bytes that were inspected. If
+// reading r fails, the error is returned, and the returned reader returns it too once it has returned the bytes read
+// before it.
+func SniffFormat(r io.Reader) (DataFormat, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	head, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return DFUnknown, br, err
+	}
+	// When the peek ends the content, the records it holds are complete.
+	complete := err == io.EOF
+
+	if len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b {
+		head, complete = gunzipHead(head, complete)
+	}
+	return sniff(head, complete), br, nil
+}
+
+// gunzipHead returns the start of the decompressed content of the gzip compressed head, and if it is all of it.
+func gunzipHead(head []byte, complete bool) ([]byte, bool) {
+	zr, err := gzip.NewReader(bytes.NewReader(head))
+	if err != nil {
+		return nil, false
+	}
+	out := make([]byte, sniffSize)
+	n, err := io.ReadFull(zr, out)
+	return out[:n], complete && err == io.ErrUnexpectedEOF
+}
+
+// sniff returns the format of content that starts with head, which is all of it if complete is true.
+func sniff(head []byte, complete bool) DataFormat {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	if len(trimmed) == 0 {
+		return DFUnknown
+	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		if format := sniffJSON(trimmed); format != DFUnknown {
+			return format
+		}
+	}
+	return sniffDelimited(head, complete)
+}
+
+// sniffJSON returns JSON for content that starts with a JSON object on a single line, MultiJSON for content that starts
+// with an array or an object that spans several lines, and DFUnknown if it doesn't start with a JSON value that the
+// head holds entirely.
+func sniffJSON(head []byte) DataFormat {
+	dec := json.NewDecoder(bytes.NewReader(head))
+	var first json.RawMessage
+	if err := dec.Decode(&first); err != nil {
+		return DFUnknown
+	}
+	// The value must be followed by another one, or by the end of the content, to rule out a CSV line like `[a],b`.
+	rest := bytes.TrimLeft(head[dec.InputOffset():], " \t\r\n")
+	if len(rest) > 0 && rest[0] != '{' && rest[0] != '[' {
+		return DFUnknown
+	}
+
+	if first[0] == '[' || bytes.ContainsAny(first, "\r\n") {
+		return MultiJSON
+	}
+	return JSON
+}
+
+// sniffDelimited returns CSV or TSV if the complete lines of head parse as records with the same number of fields, at
+// least two, with only one of the delimiters.
+func sniffDelimited(head []byte, complete bool) DataFormat {
+	if !complete {
+		end := bytes.LastIndexByte(head, '\n')
+		if end < 0 {
+			return DFUnknown
+		}
+		head = head[:end+1]
+	}
+
+	isCSV, isTSV := delimitedBy(head, ','), delimitedBy(head, '\t')
+	switch {
+	case isCSV && !isTSV:
+		return CSV
+	case isTSV && !isCSV:
+		return TSV
+	}
+	return DFUnknown
+}
+
+// delimitedBy reports if data parses as records of at least two fields separated by comma, all with the same number of
+// fields.
+func delimitedBy(data []byte, comma rune) bool {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.ReuseRecord = true
+
+	records := 0
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records > 0
+		}
+		if err != nil || len(record) < 2 {
+			return false
+		}
+		records++
+	}
+}
@@ -0,0 +1,53 @@
+package queued
+
+import (
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+)
+
+// uploadEvents reports the attempts to upload a source to the EventHandler set with the WithEventHandler() option, and
+// counts them. Its methods do nothing on a nil *uploadEvents, which is what newUploadEvents() returns when no handler
+// was set.
+type uploadEvents struct {
+	handler  properties.EventHandler
+	attempts int
+}
+
+func newUploadEvents(props *properties.All) *uploadEvents {
+	if props.Source.Events == nil {
+		return nil
+	}
+	return &uploadEvents{handler: props.Source.Events}
+}
+
+// start reports that an attempt to upload source to blobURL starts.
+func (e *uploadEvents) start(source, blobURL string) {
+	if e == nil {
+		return
+	}
+	e.attempts++
+	e.handler.OnUploadStart(source, blobURL)
+}
+
+// retry reports that the last attempt failed with err, and that the upload is attempted again.
+func (e *uploadEvents) retry(err error) {
+	if e == nil {
+		return
+	}
+	e.handler.OnUploadRetry(e.attempts, err)
+}
+
+// progressReport returns the callback that reports the progress of an upload to the WithProgress() callback and the
+// EventHandler of props, or nil if neither is set.
+func progressReport(props *properties.All) func(uploaded, total int64) {
+	progress, events := props.Source.Progress, props.Source.Events
+	switch {
+	case events == nil:
+		return progress
+	case progress == nil:
+		return func(uploaded, _ int64) { events.OnUploadProgress(uploaded) }
+	}
+	return func(uploaded, total int64) {
+		progress(uploaded, total)
+		events.OnUploadProgress(uploaded)
+	}
+}
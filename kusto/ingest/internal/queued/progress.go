@@ -0,0 +1,76 @@
+package queued
+
+import (
+	"io"
+	"sync"
+)
+
+// progressInterval is the number of bytes uploaded between two reports of the progress of an upload.
+const progressInterval = _1MiB
+
+// progress reports the progress of an upload to the callback set with the WithProgress() option. Its methods do
+// nothing on a nil *progress, which is what newProgress() returns when no callback was set.
+type progress struct {
+	mu       sync.Mutex
+	report   func(uploaded, total int64)
+	total    int64
+	uploaded int64
+	reported int64
+}
+
+// newProgress returns a progress for an upload of total bytes, -1 if it is unknown, or nil if report is nil.
+func newProgress(report func(uploaded, total int64), total int64) *progress {
+	if report == nil {
+		return nil
+	}
+	if total <= 0 {
+		total = -1
+	}
+	return &progress{report: report, total: total, reported: -1}
+}
+
+// set records that uploaded bytes were uploaded so far, and reports it if progressInterval bytes were uploaded since
+// the last report.
+func (p *progress) set(uploaded int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.uploaded = uploaded
+	if p.reported < 0 || p.uploaded-p.reported >= progressInterval {
+		p.reported = p.uploaded
+		p.report(p.uploaded, p.total)
+	}
+}
+
+// done reports the final count, uploaded, once the upload succeeded. The total is then the number of bytes uploaded,
+// even when it was unknown.
+func (p *progress) done(uploaded int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.uploaded, p.reported = uploaded, uploaded
+	p.report(uploaded, uploaded)
+}
+
+// progressReader is an io.Reader that records the bytes read from its reader on a progress.
+type progressReader struct {
+	reader   io.Reader
+	progress *progress
+	read     int64
+}
+
+// Read implements io.Reader.
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress.set(r.read)
+	}
+	return n, err
+}
@@ -3,15 +3,21 @@
 package queued
 
 import (
+	"bytes"
 	"context"
+	goErrors "errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/ingestoptions"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/gzip"
@@ -22,6 +28,8 @@ import (
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-storage-queue-go/azqueue"
 	"github.com/google/uuid"
 )
@@ -37,13 +45,20 @@ const (
 	BlockSize             = 8 * _1MiB
 	Concurrency           = 50
 	StorageMaxRetryPolicy = 3
+
+	// MinBlockSize and MaxBlockSize are the bounds of the block size of an upload. Blob Storage doesn't accept larger
+	// blocks, and azblob raises smaller ones to its minimum.
+	MinBlockSize = _1MiB
+	MaxBlockSize = blockblob.MaxStageBlockBytes
+	// MaxBlockConcurrency is the largest number of blocks of a file that azblob can upload in parallel.
+	MaxBlockConcurrency = math.MaxUint16
 )
 
 // Queued provides methods for taking data from various sources and ingesting it into Kusto using queued ingestion.
 type Queued interface {
 	io.Closer
-	Local(ctx context.Context, from string, props properties.All) error
-	Reader(ctx context.Context, reader io.Reader, props properties.All) (string, error)
+	Local(ctx context.Context, from string, props properties.All) (resources.UploadInfo, error)
+	Reader(ctx context.Context, reader io.Reader, props properties.All) (string, resources.UploadInfo, error)
 	Blob(ctx context.Context, from string, fileSize int64, props properties.All) error
 }
 
@@ -66,6 +81,12 @@ type Ingestion struct {
 
 	bufferSize int
 	maxBuffers int
+
+	// uploadSlots bounds the number of blob uploads that can run at the same time. nil means there is no bound.
+	uploadSlots chan struct{}
+
+	metrics kusto.Metrics
+	logger  kusto.Logger
 }
 
 // Option is an optional argument to New().
@@ -79,6 +100,32 @@ func WithStaticBuffer(bufferSize int, maxBuffers int) Option {
 	}
 }
 
+// WithUploadConcurrency limits the amount of blob uploads that can run in parallel across all callers to n.
+// If n is smaller than 1, uploads are not limited.
+func WithUploadConcurrency(n int) Option {
+	return func(s *Ingestion) {
+		if n < 1 {
+			s.uploadSlots = nil
+			return
+		}
+		s.uploadSlots = make(chan struct{}, n)
+	}
+}
+
+// WithMetrics sets the Metrics that uploads and upload retries are reported to.
+func WithMetrics(m kusto.Metrics) Option {
+	return func(s *Ingestion) {
+		s.metrics = m
+	}
+}
+
+// WithLogger sets the Logger that the changes of the container an upload is attempted on are logged to.
+func WithLogger(l kusto.Logger) Option {
+	return func(s *Ingestion) {
+		s.logger = l
+	}
+}
+
 // New is the constructor for Ingestion.
 func New(db, table string, mgr *resources.Manager, http *http.Client, options ...Option) (*Ingestion, error) {
 	i := &Ingestion{
@@ -104,14 +151,32 @@ func New(db, table string, mgr *resources.Manager, http *http.Client, options ..
 }
 
 // Local ingests a local file into Kusto.
-func (i *Ingestion) Local(ctx context.Context, from string, props properties.All) error {
+// If Blob Storage rejects the SAS of the container, the ingestion resources are fetched again and the upload is
+// retried once.
+func (i *Ingestion) Local(ctx context.Context, from string, props properties.All) (resources.UploadInfo, error) {
+	start := time.Now().UTC()
+	events := newUploadEvents(&props)
+	info, err := i.local(ctx, from, props, events)
+	if !isAuthFailure(err) {
+		return info, err
+	}
+
+	if rerr := i.mgr.ForceRefresh(ctx, start); rerr != nil {
+		return info, err
+	}
+	i.uploadRetry(accountOf(err), err)
+	events.retry(err)
+	return i.local(ctx, from, props, events)
+}
+
+func (i *Ingestion) local(ctx context.Context, from string, props properties.All, events *uploadEvents) (resources.UploadInfo, error) {
 	containers, err := i.mgr.GetRankedStorageContainers()
 	if err != nil {
-		return err
+		return resources.UploadInfo{}, err
 	}
 
 	if len(containers) == 0 {
-		return errors.ES(
+		return resources.UploadInfo{}, errors.ES(
 			errors.OpFileIngest,
 			errors.KBlobstore,
 			"no Blob Storage container resources are defined, there is no container to upload to",
@@ -120,55 +185,78 @@ func (i *Ingestion) Local(ctx context.Context, from string, props properties.All
 
 	queues, err := i.mgr.GetRankedStorageQueues()
 	if err != nil {
-		return err
+		return resources.UploadInfo{}, err
 	}
 
 	// We want to check the queue size here so we don't upload a file and then find we don't have a Kusto queue to stick
 	// it in. If we don't have a container, that is handled by containerQueue().
 	if len(queues) == 0 {
-		return errors.ES(errors.OpFileIngest, errors.KBlobstore, "no Kusto queue resources are defined, there is no queue to upload to").SetNoRetry()
+		return resources.UploadInfo{}, errors.ES(errors.OpFileIngest, errors.KBlobstore, "no Kusto queue resources are defined, there is no queue to upload to").SetNoRetry()
 	}
 
 	// Go over all the containers and try to upload the file to each one. If we succeed, we are done.
-	for attempts, containerUri := range containers {
-		if attempts >= StorageMaxRetryPolicy {
-			return errors.ES(errors.OpFileIngest, errors.KBlobstore, "max retry policy reached").SetNoRetry()
+	rotation := newContainerRotation(containers)
+	rotation.onRetry = func(account string, err error) {
+		i.uploadRetry(account, err)
+		events.retry(err)
+	}
+	for {
+		containerUri, err := rotation.next()
+		if err != nil {
+			return resources.UploadInfo{}, err
 		}
 
 		client, containerName, err := i.upstreamContainer(containerUri)
 		if err != nil {
 			i.mgr.ReportStorageResourceResult(containerUri.Account(), false)
+			rotation.failed(containerUri, err)
 			continue
 		}
 
-		blobURL, size, err := i.localToBlob(ctx, from, client, containerName, &props)
+		blobURL, size, info, err := i.localToBlob(ctx, from, client, containerName, &props, events)
 		if err == nil {
+			info.Size = size
 			i.mgr.ReportStorageResourceResult(containerUri.Account(), true)
-			return i.Blob(ctx, blobURL, size, props)
+			return info, i.Blob(ctx, blobURL, size, props)
+		}
+
+		// All the SAS tokens come from the same fetch, so the other containers would reject it as well.
+		if isAuthFailure(err) {
+			return resources.UploadInfo{}, err
 		}
 
 		// check if the error is retryable
 		if errors.Retry(err) {
 			i.mgr.ReportStorageResourceResult(containerUri.Account(), false)
+			rotation.failed(containerUri, err)
 			continue
 		} else {
-			return err
+			return resources.UploadInfo{}, err
 		}
 	}
-
-	return errors.ES(errors.OpFileIngest, errors.KBlobstore, "could not upload file to any container")
 }
 
 // Reader uploads a file via an io.Reader.
-// If the function succeeds, it returns the path of the created blob.
-func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+// If the function succeeds, it returns the path of the created blob and the metadata Blob Storage returned for it.
+// If Blob Storage rejects the SAS of the container, the ingestion resources are fetched again for the next call, but
+// the upload is not retried, as the reader can't be replayed.
+func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properties.All) (string, resources.UploadInfo, error) {
+	start := time.Now().UTC()
+	blobName, info, err := i.reader(ctx, reader, props)
+	if isAuthFailure(err) {
+		_ = i.mgr.ForceRefresh(ctx, start)
+	}
+	return blobName, info, err
+}
+
+func (i *Ingestion) reader(ctx context.Context, reader io.Reader, props properties.All) (string, resources.UploadInfo, error) {
 	containers, err := i.mgr.GetRankedStorageContainers()
 	if err != nil {
-		return "", err
+		return "", resources.UploadInfo{}, err
 	}
 
 	if len(containers) == 0 {
-		return "", errors.ES(
+		return "", resources.UploadInfo{}, errors.ES(
 			errors.OpFileIngest,
 			errors.KBlobstore,
 			"no Blob Storage container resources are defined, there is no container to upload to",
@@ -177,48 +265,100 @@ func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properti
 
 	queues, err := i.mgr.GetRankedStorageQueues()
 	if err != nil {
-		return "", err
+		return "", resources.UploadInfo{}, err
 	}
 
 	// We want to check the queue size here so so we don't upload a file and then find we don't have a Kusto queue to stick
 	// it in. If we don't have a container, that is handled by containerQueue().
 	if len(queues) == 0 {
-		return "", errors.ES(errors.OpFileIngest, errors.KBlobstore, "no Kusto queue resources are defined, there is no queue to upload to").SetNoRetry()
+		return "", resources.UploadInfo{}, errors.ES(errors.OpFileIngest, errors.KBlobstore, "no Kusto queue resources are defined, there is no queue to upload to").SetNoRetry()
 	}
 
 	compression := utils.CompressionDiscovery(props.Source.OriginalSource)
 	shouldCompress := ShouldCompress(&props, compression)
-	blobName := GenBlobName(i.db, i.table, nower(), filepath.Base(uuid.New().String()), filepath.Base(props.Source.OriginalSource), compression, shouldCompress, props.Ingestion.Additional.Format.String())
+	if shouldCompress {
+		var size int64
+		if reader, size, err = PeekSize(&props, reader); err != nil {
+			return "", resources.UploadInfo{}, errors.E(errors.OpFileIngest, errors.KIO, err)
+		}
+		shouldCompress = ShouldCompressSize(&props, compression, size)
+	}
+	blobName := i.blobName(&props, filepath.Base(props.Source.OriginalSource), compression, shouldCompress)
 
 	size := int64(0)
+	events := newUploadEvents(&props)
+
+	// The progress is counted on the source, before it is compressed, so that it can be compared to its raw size.
+	var counted *progressReader
+	if progress := newProgress(progressReport(&props), props.Ingestion.RawDataSize); progress != nil {
+		counted = &progressReader{reader: reader, progress: progress}
+		reader = counted
+	}
 
 	if shouldCompress {
-		reader = gzip.Compress(reader)
+		gstream := gzip.NewLevel(props.Source.CompressionLevel)
+		gstream.Reset(io.NopCloser(reader))
+		// Closing the streamer stops its compression goroutine if the upload stopped before reading all of it. It is
+		// shared by all the attempts below, which is only safe as long as none of them read from it.
+		defer gstream.Close()
+		reader = gstream
 	}
 
+	// The reader can't be rewound, so an upload can only move on to another container if the failed attempt read
+	// nothing from it: the next one would otherwise upload the rest of the data only. This counts what the uploads read,
+	// after compression, as the compression goroutine reads ahead of them.
+	uploaded := &progressReader{reader: reader}
+
 	// Go over all the containers and try to upload the file to each one. If we succeed, we are done.
-	for attempts, containerUri := range containers {
-		if attempts >= StorageMaxRetryPolicy {
-			return "", errors.ES(errors.OpFileIngest, errors.KBlobstore, "max retry policy reached").SetNoRetry()
+	rotation := newContainerRotation(containers)
+	rotation.onRetry = func(account string, err error) {
+		i.uploadRetry(account, err)
+		events.retry(err)
+	}
+	for {
+		containerUri, err := rotation.next()
+		if err != nil {
+			return blobName, resources.UploadInfo{}, err
 		}
 
 		client, containerName, err := i.upstreamContainer(containerUri)
 		if err != nil {
 			i.mgr.ReportStorageResourceResult(containerUri.Account(), false)
+			rotation.failed(containerUri, err)
 			continue
 		}
 
-		_, err = i.uploadStream(
+		release, err := i.acquireUploadSlot(ctx)
+		if err != nil {
+			return "", resources.UploadInfo{}, err
+		}
+		events.start(props.Source.OriginalSource, blobURLWithoutSAS(client, containerName, blobName))
+		resp, err := i.uploadStream(
 			ctx,
-			reader,
+			uploaded,
 			client,
 			containerName,
 			blobName,
-			&azblob.UploadStreamOptions{BlockSize: int64(i.bufferSize), Concurrency: i.maxBuffers},
+			&azblob.UploadStreamOptions{
+				BlockSize:   blockSize(&props, int64(i.bufferSize)),
+				Concurrency: blockConcurrency(&props, i.maxBuffers),
+				HTTPHeaders: sourceHTTPHeaders(&props, compression, shouldCompress),
+				Metadata:    blobMetadata(&props),
+				Tags:        blobTags(&props),
+			},
 		)
+		release()
 
 		if err != nil {
+			if isAuthFailure(err) || ctx.Err() != nil {
+				return "", resources.UploadInfo{}, uploadError(ctx, err)
+			}
 			i.mgr.ReportStorageResourceResult(containerUri.Account(), false)
+			if uploaded.read > 0 {
+				return "", resources.UploadInfo{}, errors.E(errors.OpFileIngest, errors.KBlobstore,
+					fmt.Errorf("problem uploading to Blob Storage after %d bytes were read from the reader, which can't be uploaded again: %w", uploaded.read, err)).SetNoRetry()
+			}
+			rotation.failed(containerUri, err)
 			continue
 		}
 
@@ -226,11 +366,133 @@ func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properti
 		if gz, ok := reader.(*gzip.Streamer); ok {
 			size = gz.InputSize()
 		}
+		if counted != nil {
+			counted.progress.done(counted.read)
+		}
+		if size > 0 {
+			i.observer().IngestBytes(props.Ingestion.DatabaseName, props.Ingestion.TableName, size)
+		}
 		err = i.Blob(ctx, fullUrl(client, containerName, blobName), size, props)
-		return blobName, err
+		info := uploadInfo(client, containerName, blobName, resp.ETag, resp.LastModified, resp.RequestID)
+		info.Size = size
+		return blobName, info, err
 	}
+}
 
-	return blobName, errors.ES(errors.OpFileIngest, errors.KBlobstore, "problem uploading to Blob Storage")
+// containerRotation decides which container an upload is attempted on next. Containers are tried in the order
+// given by the resource manager, which ranks storage accounts by their recent success rate and shuffles accounts of
+// the same rank on every call, so concurrent uploads don't all start with the same account.
+// When Blob Storage throttles an upload, the remaining containers of that storage account are skipped, so that the
+// next attempt is made against a sibling account. Throttled attempts don't count towards StorageMaxRetryPolicy, which
+// means a throttled upload is retried up to once per storage account.
+type containerRotation struct {
+	containers []*resources.URI
+	pos        int
+	attempts   int
+	throttled  map[string]bool
+	tried      []string
+	lastErr    error
+
+	// onRetry, if set, is called with the account and error of the failed attempt before every retry.
+	onRetry func(account string, err error)
+}
+
+func newContainerRotation(containers []*resources.URI) *containerRotation {
+	return &containerRotation{containers: containers, throttled: map[string]bool{}}
+}
+
+// next returns the next container to upload to, or an error if there are no attempts left.
+func (r *containerRotation) next() (*resources.URI, error) {
+	if r.attempts >= StorageMaxRetryPolicy {
+		return nil, r.err("max retry policy reached").SetNoRetry()
+	}
+
+	for ; r.pos < len(r.containers); r.pos++ {
+		if !r.throttled[r.containers[r.pos].Account()] {
+			if r.lastErr != nil && r.onRetry != nil {
+				r.onRetry(r.tried[len(r.tried)-1], r.lastErr)
+			}
+			r.pos++
+			return r.containers[r.pos-1], nil
+		}
+	}
+
+	return nil, r.err("could not upload file to any container")
+}
+
+// failed records that uploading to container failed with err.
+func (r *containerRotation) failed(container *resources.URI, err error) {
+	r.lastErr = err
+	account := container.Account()
+	if len(r.tried) == 0 || r.tried[len(r.tried)-1] != account {
+		r.tried = append(r.tried, account)
+	}
+
+	if isThrottled(err) {
+		r.throttled[account] = true
+		return
+	}
+	r.attempts++
+}
+
+func (r *containerRotation) err(msg string) *errors.Error {
+	if r.lastErr == nil {
+		return errors.ES(errors.OpFileIngest, errors.KBlobstore, "%s", msg)
+	}
+	return errors.ES(errors.OpFileIngest, errors.KBlobstore, "%s, tried storage accounts %v: %s", msg, r.tried, r.lastErr)
+}
+
+// isThrottled reports if err is Blob Storage telling us that the storage account is busy.
+func isThrottled(err error) bool {
+	var respErr *azcore.ResponseError
+	if !goErrors.As(err, &respErr) {
+		return false
+	}
+	return respErr.StatusCode == http.StatusServiceUnavailable || respErr.StatusCode == http.StatusTooManyRequests
+}
+
+// accountOf returns the storage account of the Blob Storage request that failed with err, or "" if it is unknown.
+func accountOf(err error) string {
+	var respErr *azcore.ResponseError
+	if !goErrors.As(err, &respErr) || respErr.RawResponse == nil || respErr.RawResponse.Request == nil {
+		return ""
+	}
+	host := respErr.RawResponse.Request.URL.Hostname()
+	if i := strings.IndexByte(host, '.'); i > 0 {
+		return host[:i]
+	}
+	return host
+}
+
+// isAuthFailure reports if err is Blob Storage rejecting our SAS token, which happens when it expired or was rotated.
+func isAuthFailure(err error) bool {
+	var respErr *azcore.ResponseError
+	if !goErrors.As(err, &respErr) {
+		return false
+	}
+	return respErr.StatusCode == http.StatusForbidden
+}
+
+// observer returns the Metrics to report to, which is NopMetrics if none was set.
+func (i *Ingestion) observer() kusto.Metrics {
+	if i.metrics == nil {
+		return kusto.NopMetrics{}
+	}
+	return i.metrics
+}
+
+// log returns the Logger to log to, which is NopLogger if none was set.
+func (i *Ingestion) log() kusto.Logger {
+	if i.logger == nil {
+		return kusto.NopLogger{}
+	}
+	return i.logger
+}
+
+// uploadRetry reports that an upload to the storage account failed with err, and is attempted again.
+func (i *Ingestion) uploadRetry(account string, err error) {
+	i.observer().BlobUploadRetry(account, err)
+	i.log().Warn("kusto: retrying the upload to Blob Storage", "account", account, "error", err)
 }
 
 // Blob ingests a file from Azure Blob Storage into Kusto.
@@ -245,12 +507,16 @@ func (i *Ingestion) Blob(ctx context.Context, from string, fileSize int64, props
 		props.Ingestion.RawDataSize = fileSize
 	}
 
-	props.Ingestion.RetainBlobOnSuccess = !props.Source.DeleteLocalSource
+	props.Ingestion.RetainBlobOnSuccess = retainBlobOnSuccess(&props)
 
 	err := CompleteFormatFromFileName(&props, from)
 	if err != nil {
 		return err
 	}
+	// The ID is set here, instead of when the message is marshaled, so that it can be reported to the EventHandler.
+	if props.Ingestion.ID == uuid.Nil {
+		props.Ingestion.ID = uuid.New()
+	}
 
 	j, err := props.Ingestion.MarshalJSONString()
 	if err != nil {
@@ -273,6 +539,9 @@ func (i *Ingestion) Blob(ctx context.Context, from string, fileSize int64, props
 			continue
 		} else {
 			i.mgr.ReportStorageResourceResult(queueUri.Account(), true)
+			if props.Source.Events != nil {
+				props.Source.Events.OnIngestCommandSent(props.Ingestion.ID.String())
+			}
 			return props.ApplyDeleteLocalSourceOption()
 		}
 	}
@@ -280,18 +549,40 @@ func (i *Ingestion) Blob(ctx context.Context, from string, fileSize int64, props
 	return errors.ES(errors.OpFileIngest, errors.KBlobstore, "could not upload file to any queue")
 }
 
+// FirstRecordFormatError returns the error of an ingestion that ignores the first record of data in format, which is not
+// a format of separated values.
+func FirstRecordFormatError(format properties.DataFormat) error {
+	return errors.ES(
+		errors.OpFileIngest,
+		errors.KClientArgs,
+		"WithIgnoreFirstRecord() requires a format of separated values, such as CSV or TSV, the format is %v", format,
+	).SetNoRetry()
+}
+
+// CompleteFormatFromFileName discovers the format from the file extension if it was not set, and checks that the
+// ingestion mapping type, if any, matches the format. A format that was set, such as with the WithFileFormat() option,
+// is kept whatever the extension of from.
 func CompleteFormatFromFileName(props *properties.All, from string) error {
 	// If they did not tell us how the file was encoded, try to discover it from the file extension.
-	if props.Ingestion.Additional.Format != properties.DFUnknown {
-		return nil
+	if props.Ingestion.Additional.Format == properties.DFUnknown {
+		et := properties.DataFormatDiscovery(from)
+		if et == properties.DFUnknown {
+			// If we can't figure out the file type, default to CSV.
+			et = properties.CSV
+		}
+		props.Ingestion.Additional.Format = et
 	}
 
-	et := properties.DataFormatDiscovery(from)
-	if et == properties.DFUnknown {
-		// If we can't figure out the file type, default to CSV.
-		et = properties.CSV
+	if !props.Ingestion.Additional.MappingMatchesFormat() {
+		return errors.ES(
+			errors.OpFileIngest,
+			errors.KClientArgs,
+			"ingestion mapping type %v does not match the format %v of %q", props.Ingestion.Additional.IngestionMappingType, props.Ingestion.Additional.Format, from,
+		).SetNoRetry()
+	}
+	if !props.Ingestion.Additional.FirstRecordMatchesFormat() {
+		return FirstRecordFormatError(props.Ingestion.Additional.Format)
 	}
-	props.Ingestion.Additional.Format = et
 
 	return nil
 }
@@ -339,16 +630,35 @@ func createPipeline(http *http.Client) pipeline.Pipeline {
 
 var nower = time.Now
 
-// localToBlob copies from a local to an Azure Blobstore blob. It returns the URL of the Blob, the local file info and an
-// error if there was one.
-func (i *Ingestion) localToBlob(ctx context.Context, from string, client *azblob.Client, container string, props *properties.All) (string, int64, error) {
+// acquireUploadSlot blocks until an upload is allowed to run, according to WithUploadConcurrency().
+// The returned function must be called when the upload is done.
+func (i *Ingestion) acquireUploadSlot(ctx context.Context) (func(), error) {
+	if i.uploadSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case i.uploadSlots <- struct{}{}:
+		return func() { <-i.uploadSlots }, nil
+	case <-ctx.Done():
+		return nil, errors.ES(errors.OpFileIngest, errors.KTimeout, "context ended while waiting to upload to Blob Storage: %s", ctx.Err()).SetNoRetry()
+	}
+}
+
+// localToBlob copies from a local to an Azure Blobstore blob. It returns the URL of the Blob, the local file size, the
+// metadata Blob Storage returned for the upload and an error if there was one.
+func (i *Ingestion) localToBlob(ctx context.Context, from string, client *azblob.Client, container string, props *properties.All, events *uploadEvents) (string, int64, resources.UploadInfo, error) {
 	compression := utils.CompressionDiscovery(from)
-	shouldCompress := ShouldCompress(props, compression)
-	blobName := GenBlobName(i.db, i.table, nower(), filepath.Base(uuid.New().String()), filepath.Base(from), compression, shouldCompress, props.Ingestion.Additional.Format.String())
+
+	release, err := i.acquireUploadSlot(ctx)
+	if err != nil {
+		return "", 0, resources.UploadInfo{}, err
+	}
+	defer release()
 
 	file, err := os.Open(from)
 	if err != nil {
-		return "", 0, errors.ES(
+		return "", 0, resources.UploadInfo{}, errors.ES(
 			errors.OpFileIngest,
 			errors.KLocalFileSystem,
 			"problem retrieving source file %q: %s", from, err,
@@ -358,54 +668,116 @@ func (i *Ingestion) localToBlob(ctx context.Context, from string, client *azblob
 	defer file.Close()
 	stat, err := file.Stat()
 	if err != nil {
-		return "", 0, errors.ES(
+		return "", 0, resources.UploadInfo{}, errors.ES(
 			errors.OpFileIngest,
 			errors.KLocalFileSystem,
 			"could not Stat the file(%s): %s", from, err,
 		).SetNoRetry()
 	}
+	shouldCompress := ShouldCompressSize(props, compression, stat.Size())
+	blobName := i.blobName(props, filepath.Base(from), compression, shouldCompress)
 
-	if shouldCompress {
-		gstream := gzip.New()
-		gstream.Reset(file)
+	mode := props.Source.UploadMode
+	if mode == ingestoptions.UploadModeFile && shouldCompress {
+		return "", 0, resources.UploadInfo{}, errors.ES(
+			errors.OpFileIngest,
+			errors.KClientArgs,
+			"WithUploadMode(%s) cannot upload the file %q, which the client compresses: use DontCompress() or a compressed file", mode, from,
+		).SetNoRetry()
+	}
+	events.start(from, blobURLWithoutSAS(client, container, blobName))
 
-		_, err = i.uploadStream(
-			ctx,
-			gstream,
-			client,
-			container,
-			blobName,
-			&azblob.UploadStreamOptions{BlockSize: int64(i.bufferSize), Concurrency: i.maxBuffers},
-		)
+	if shouldCompress || mode == ingestoptions.UploadModeStream {
+		// A stream is uploaded as it is read, so the size of the file is the number of bytes that were read.
+		total := stat.Size()
+		if mode == ingestoptions.UploadModeStream {
+			total = -1
+		}
+		progress := newProgress(progressReport(props), total)
+		read := &progressReader{reader: file, progress: progress}
+
+		options := &azblob.UploadStreamOptions{
+			BlockSize:   blockSize(props, int64(i.bufferSize)),
+			Concurrency: blockConcurrency(props, i.maxBuffers),
+			HTTPHeaders: sourceHTTPHeaders(props, compression, shouldCompress),
+			Metadata:    blobMetadata(props),
+			Tags:        blobTags(props),
+		}
+		var payload io.Reader = read
+		if shouldCompress {
+			gstream := gzip.NewLevel(props.Source.CompressionLevel)
+			gstream.Reset(io.NopCloser(read))
+			// Closing the streamer stops its compression goroutine if the upload stopped before reading all of it.
+			defer gstream.Close()
+			payload = gstream
+		}
 
+		resp, err := i.uploadStream(ctx, payload, client, container, blobName, options)
 		if err != nil {
-			return "", 0, errors.ES(errors.OpFileIngest, errors.KBlobstore, "problem uploading to Blob Storage: %s", err)
+			return "", 0, resources.UploadInfo{}, uploadError(ctx, err)
 		}
-		return fullUrl(client, container, blobName), gstream.InputSize(), nil
+		progress.done(read.read)
+		i.observer().IngestBytes(props.Ingestion.DatabaseName, props.Ingestion.TableName, read.read)
+		return fullUrl(client, container, blobName), read.read, uploadInfo(client, container, blobName, resp.ETag, resp.LastModified, resp.RequestID), nil
 	}
 
+	progress := newProgress(progressReport(props), stat.Size())
 	// The high-level API UploadFileToBlockBlob function uploads blocks in parallel for optimal performance, and can handle large files as well.
 	// This function calls StageBlock/CommitBlockList for files larger 256 MBs, and calls Upload for any file smaller
-	_, err = i.uploadBlob(
-		ctx,
-		file,
-		client,
-		container,
-		blobName,
-		&azblob.UploadFileOptions{
-			BlockSize:   BlockSize,
-			Concurrency: Concurrency,
-		},
-	)
+	options := &azblob.UploadFileOptions{
+		BlockSize:   blockSize(props, BlockSize),
+		Concurrency: uint16(blockConcurrency(props, Concurrency)),
+		HTTPHeaders: sourceHTTPHeaders(props, compression, shouldCompress),
+		Metadata:    blobMetadata(props),
+		Tags:        blobTags(props),
+	}
+	if progress != nil {
+		options.Progress = progress.set
+	}
+	resp, err := i.uploadBlob(ctx, file, client, container, blobName, options)
 
 	if err != nil {
-		return "", 0, errors.ES(errors.OpFileIngest, errors.KBlobstore, "problem uploading to Blob Storage: %s", err)
+		return "", 0, resources.UploadInfo{}, uploadError(ctx, err)
 	}
+	progress.done(stat.Size())
 
-	return fullUrl(client, container, blobName), stat.Size(), nil
+	i.observer().IngestBytes(props.Ingestion.DatabaseName, props.Ingestion.TableName, stat.Size())
+	return fullUrl(client, container, blobName), stat.Size(), uploadInfo(client, container, blobName, resp.ETag, resp.LastModified, resp.RequestID), nil
+}
+
+// uploadError wraps the error of a Blob Storage upload. An upload that stopped because ctx ended returns a KTimeout
+// error that is not retried, instead of a KBlobstore error that would make the upload move on to another container.
+func uploadError(ctx context.Context, err error) error {
+	if ctx.Err() != nil || goErrors.Is(err, context.Canceled) || goErrors.Is(err, context.DeadlineExceeded) {
+		return errors.E(errors.OpFileIngest, errors.KTimeout, fmt.Errorf("upload to Blob Storage stopped as the context ended: %w", err)).SetNoRetry()
+	}
+	return errors.E(errors.OpFileIngest, errors.KBlobstore, fmt.Errorf("problem uploading to Blob Storage: %w", err))
+}
+
+// uploadInfo converts the headers of a Blob Storage upload response into a resources.UploadInfo.
+func uploadInfo(client *azblob.Client, container, blobName string, etag *azcore.ETag, lastModified *time.Time, requestID *string) resources.UploadInfo {
+	info := resources.UploadInfo{URL: blobURLWithoutSAS(client, container, blobName)}
+	if etag != nil {
+		info.ETag = string(*etag)
+	}
+	if lastModified != nil {
+		info.LastModified = *lastModified
+	}
+	if requestID != nil {
+		info.RequestID = *requestID
+	}
+	return info
 }
 
 func GenBlobName(databaseName string, tableName string, time time.Time, guid string, fileName string, compressionFileExtension ingestoptions.CompressionType, shouldCompress bool, dataFormat string) string {
+	extension := blobNameExtension(compressionFileExtension, shouldCompress, dataFormat)
+
+	blobName := fmt.Sprintf("%s_%s_%s_%s_%s.%s", databaseName, tableName, time, guid, fileName, extension)
+
+	return blobName
+}
+
+func blobNameExtension(compressionFileExtension ingestoptions.CompressionType, shouldCompress bool, dataFormat string) string {
 	extension := "gz"
 	if !shouldCompress {
 		if compressionFileExtension == ingestoptions.CTNone {
@@ -416,10 +788,67 @@ func GenBlobName(databaseName string, tableName string, time time.Time, guid str
 
 		extension = dataFormat
 	}
+	return extension
+}
 
-	blobName := fmt.Sprintf("%s_%s_%s_%s_%s.%s", databaseName, tableName, time, guid, fileName, extension)
+// blobNamePlaceholders are the placeholders that can be used in a blob name template.
+var blobNamePlaceholders = []string{"{db}", "{table}", "{date}", "{uuid}", "{file}"}
 
-	return blobName
+// blobNameTemplateRe matches the characters that a blob name template may contain outside of placeholders.
+var blobNameTemplateRe = regexp.MustCompile(`^[A-Za-z0-9._~-]*$`)
+
+// blobNameUnsafeRe matches the characters of placeholder values that are replaced to keep blob names URL-safe.
+var blobNameUnsafeRe = regexp.MustCompile(`[^A-Za-z0-9._~-]`)
+
+// ValidateBlobNameTemplate validates a template for GenBlobNameFromTemplate. The template may only contain the
+// placeholders {db}, {table}, {date}, {uuid} and {file}, and URL-safe characters other than path separators.
+func ValidateBlobNameTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("the blob name template cannot be empty")
+	}
+	if strings.Contains(template, "..") {
+		return fmt.Errorf("the blob name template %q cannot contain \"..\"", template)
+	}
+
+	rest := template
+	for _, p := range blobNamePlaceholders {
+		rest = strings.ReplaceAll(rest, p, "")
+	}
+	if !blobNameTemplateRe.MatchString(rest) {
+		return fmt.Errorf("the blob name template %q can only contain the placeholders %v, letters, digits and any of \"._~-\"", template, blobNamePlaceholders)
+	}
+	return nil
+}
+
+// GenBlobNameFromTemplate generates a blob name by expanding the placeholders of a template that was validated with
+// ValidateBlobNameTemplate. If the template does not contain {uuid}, the guid is appended, so that names never collide.
+func GenBlobNameFromTemplate(template string, databaseName string, tableName string, time time.Time, guid string, fileName string, compressionFileExtension ingestoptions.CompressionType, shouldCompress bool, dataFormat string) string {
+	if !strings.Contains(template, "{uuid}") {
+		template += "_{uuid}"
+	}
+
+	safe := func(s string) string {
+		return blobNameUnsafeRe.ReplaceAllString(s, "_")
+	}
+	name := strings.NewReplacer(
+		"{db}", safe(databaseName),
+		"{table}", safe(tableName),
+		"{date}", time.UTC().Format("2006-01-02"),
+		"{uuid}", safe(guid),
+		"{file}", safe(fileName),
+	).Replace(template)
+
+	return name + "." + blobNameExtension(compressionFileExtension, shouldCompress, dataFormat)
+}
+
+// blobName generates the name of the blob that the source fileName is uploaded to.
+func (i *Ingestion) blobName(props *properties.All, fileName string, compressionFileExtension ingestoptions.CompressionType, shouldCompress bool) string {
+	guid := uuid.New().String()
+	format := props.Ingestion.Additional.Format.String()
+	if props.Source.BlobNameTemplate != "" {
+		return GenBlobNameFromTemplate(props.Source.BlobNameTemplate, props.Ingestion.DatabaseName, props.Ingestion.TableName, nower(), guid, fileName, compressionFileExtension, shouldCompress, format)
+	}
+	return GenBlobName(props.Ingestion.DatabaseName, props.Ingestion.TableName, nower(), filepath.Base(guid), fileName, compressionFileExtension, shouldCompress, format)
 }
 
 // Do not compress if user specified in DontCompress or CompressionType,
@@ -442,6 +871,112 @@ func ShouldCompress(props *properties.All, compressionFileExtension ingestoption
 	return props.Ingestion.Additional.Format.ShouldCompress()
 }
 
+// ShouldCompressSize is ShouldCompress for a source of size bytes, or of an unknown size if size is negative. A source
+// smaller than props.Source.CompressionMinBytes is not compressed, as gzip costs more than it saves on small payloads.
+func ShouldCompressSize(props *properties.All, compressionFileExtension ingestoptions.CompressionType, size int64) bool {
+	if !ShouldCompress(props, compressionFileExtension) {
+		return false
+	}
+	return size < 0 || size >= props.Source.CompressionMinBytes
+}
+
+// PeekSize reads up to props.Source.CompressionMinBytes bytes of reader, to know the size of a source smaller than that
+// for ShouldCompressSize(). The returned reader must be used in place of reader, as it holds the bytes that were read.
+// The size is -1 if the source is not smaller.
+func PeekSize(props *properties.All, reader io.Reader) (io.Reader, int64, error) {
+	floor := props.Source.CompressionMinBytes
+	if floor <= 0 {
+		return reader, -1, nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(reader, floor))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(buf)) < floor {
+		return bytes.NewReader(buf), int64(len(buf)), nil
+	}
+	return io.MultiReader(bytes.NewReader(buf), reader), -1, nil
+}
+
+// sourceHTTPHeaders returns the HTTP headers to set on the blob that holds an uploaded source, so that the service
+// knows how to decompress it. compressionFileExtension is the compression discovered from the source's name.
+func sourceHTTPHeaders(props *properties.All, compressionFileExtension ingestoptions.CompressionType, shouldCompress bool) *blob.HTTPHeaders {
+	if shouldCompress {
+		return nil
+	}
+
+	compression := props.Source.CompressionType
+	if compression == ingestoptions.CTUnknown {
+		compression = compressionFileExtension
+	}
+
+	if compression != ingestoptions.ZSTD {
+		return nil
+	}
+
+	encoding := compression.String()
+	return &blob.HTTPHeaders{BlobContentEncoding: &encoding}
+}
+
+// blockSize returns the block size set with WithBlockSize(), or def if none was set.
+func blockSize(props *properties.All, def int64) int64 {
+	if props.Source.BlockSize > 0 {
+		return props.Source.BlockSize
+	}
+	return def
+}
+
+// blockConcurrency returns the number of blocks to upload in parallel set with WithBlockConcurrency(), or def if none
+// was set.
+func blockConcurrency(props *properties.All, def int) int {
+	if props.Source.BlockConcurrency > 0 {
+		return props.Source.BlockConcurrency
+	}
+	return def
+}
+
+// blobMetadata returns the custom metadata to set on the blob that holds an uploaded source.
+func blobMetadata(props *properties.All) map[string]*string {
+	if len(props.Source.BlobMetadata) == 0 {
+		return nil
+	}
+
+	metadata := make(map[string]*string, len(props.Source.BlobMetadata))
+	for k, v := range props.Source.BlobMetadata {
+		v := v
+		metadata[k] = &v
+	}
+	return metadata
+}
+
+// RetainBlobTag is the blob index tag set on the blobs uploaded with SourceOptions.RetainBlob, so that retention
+// policies can find them.
+const RetainBlobTag = "kusto-retain"
+
+// blobTags returns the blob index tags to set on the blob that holds an uploaded source.
+func blobTags(props *properties.All) map[string]string {
+	if !props.Source.RetainBlob {
+		return nil
+	}
+	return map[string]string{RetainBlobTag: "true"}
+}
+
+// retainBlobOnSuccess returns the RetainBlobOnSuccess value to send to the service.
+func retainBlobOnSuccess(props *properties.All) *bool {
+	var retain bool
+	switch {
+	case props.Source.RetainBlob:
+		retain = true
+	case props.Source.DeleteBlobOnSuccess:
+		retain = false
+	case props.Source.DeleteLocalSource:
+		return nil
+	default:
+		retain = true
+	}
+	return &retain
+}
+
 // This allows mocking the stat func later on
 var statFunc = os.Stat
 
@@ -485,6 +1020,17 @@ func fullUrl(client *azblob.Client, container string, blob string) string {
 	return parseURL.String()
 }
 
+// blobURLWithoutSAS is fullUrl() without the SAS token, so that it can be handed out without leaking the credential.
+func blobURLWithoutSAS(client *azblob.Client, container string, blob string) string {
+	u, err := url.Parse(fullUrl(client, container, blob))
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+
+	return u.String()
+}
+
 func (i *Ingestion) Close() error {
 	i.mgr.Close()
 	return nil
@@ -6,16 +6,26 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/ingestoptions"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/utils"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 )
 
@@ -40,6 +50,40 @@ func TestFormatDiscovery(t *testing.T) {
 		{".txt", properties.TXT},
 		{".whatever", properties.DFUnknown},
 		{".w3clogfile", properties.W3CLogFile},
+		{".multijson", properties.MultiJSON},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+
+			got := properties.DataFormatDiscovery(test.input)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestCompoundFormatDiscovery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  properties.DataFormat
+	}{
+		{"file.json.gz", properties.JSON},
+		{"file.JSON.GZ", properties.JSON},
+		{"file.csv.zip", properties.CSV},
+		{"file.Csv.Zip", properties.CSV},
+		{"file.multijson.gz", properties.MultiJSON},
+		{"file.MultiJson.zip", properties.MultiJSON},
+		{"file.tsv.zst", properties.TSV},
+		{"file.parquet.zstd", properties.Parquet},
+		{"file.w3clogfile.gz", properties.W3CLogFile},
+		{"/path/to/a/file.psv.gz", properties.PSV},
+		{"https://somehost.somedomain.com:8080/v1/somestuff/file.json.gz?sas=token", properties.JSON},
+		{"file.gz", properties.DFUnknown},
+		{"file.whatever.gz", properties.DFUnknown},
 	}
 
 	for _, test := range tests {
@@ -64,6 +108,9 @@ func TestCompressionDiscovery(t *testing.T) {
 		{"https://somehost.somedomain.com:8080/v1/somestuff/file.zip", ingestoptions.ZIP},
 		{"/path/to/a/file.gz", ingestoptions.GZIP},
 		{"/path/to/a/file.zip", ingestoptions.ZIP},
+		{"https://somehost.somedomain.com:8080/v1/somestuff/file.zst", ingestoptions.ZSTD},
+		{"/path/to/a/file.zst", ingestoptions.ZSTD},
+		{"/path/to/a/file.ZSTD", ingestoptions.ZSTD},
 		{"/path/to/a/file", ingestoptions.CTNone},
 	}
 
@@ -79,25 +126,78 @@ func TestCompressionDiscovery(t *testing.T) {
 
 }
 
+// The upload response headers returned by fakeBlobstore.
+var (
+	fakeETag         = azcore.ETag("0x8DB5E1F3A2C4B7D")
+	fakeLastModified = time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC)
+	fakeRequestID    = "6d7c8a5c-301e-0021-5b34-946d1a000000"
+)
+
 type fakeBlobstore struct {
 	out       *bytes.Buffer
 	shouldErr bool
+	metadata  map[string]*string
+	tags      map[string]string
+
+	// block, if set, makes uploads wait until it is closed. Uploads don't write to out when it is set.
+	block       chan struct{}
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *fakeBlobstore) wait() {
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+
+	<-f.block
+
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
 }
 
-func (f *fakeBlobstore) uploadBlobStream(_ context.Context, reader io.Reader, _ *azblob.Client, _ string, _ string, _ *azblob.UploadStreamOptions) (azblob.UploadStreamResponse, error) {
+func (f *fakeBlobstore) current() (inFlight, maxInFlight int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inFlight, f.maxInFlight
+}
+
+func (f *fakeBlobstore) uploadBlobStream(_ context.Context, reader io.Reader, _ *azblob.Client, _ string, _ string, o *azblob.UploadStreamOptions) (azblob.UploadStreamResponse, error) {
+	f.mu.Lock()
+	f.metadata = o.Metadata
+	f.tags = o.Tags
+	f.mu.Unlock()
 	if f.shouldErr {
 		return azblob.UploadStreamResponse{}, fmt.Errorf("error")
 	}
+	if f.block != nil {
+		f.wait()
+		_, err := io.Copy(io.Discard, reader)
+		return azblob.UploadStreamResponse{}, err
+	}
 	_, err := io.Copy(f.out, reader)
-	return azblob.UploadStreamResponse{}, err
+	return azblob.UploadStreamResponse{ETag: &fakeETag, LastModified: &fakeLastModified, RequestID: &fakeRequestID}, err
 }
 
-func (f *fakeBlobstore) uploadBlobFile(_ context.Context, fi *os.File, _ *azblob.Client, _ string, _ string, _ *azblob.UploadFileOptions) (azblob.UploadFileResponse, error) {
+func (f *fakeBlobstore) uploadBlobFile(_ context.Context, fi *os.File, _ *azblob.Client, _ string, _ string, o *azblob.UploadFileOptions) (azblob.UploadFileResponse, error) {
+	f.mu.Lock()
+	f.metadata = o.Metadata
+	f.tags = o.Tags
+	f.mu.Unlock()
 	if f.shouldErr {
 		return azblob.UploadFileResponse{}, fmt.Errorf("error")
 	}
+	if f.block != nil {
+		f.wait()
+		return azblob.UploadFileResponse{}, nil
+	}
 	_, err := io.Copy(f.out, fi)
-	return azblob.UploadFileResponse{}, err
+	return azblob.UploadFileResponse{ETag: &fakeETag, LastModified: &fakeLastModified, RequestID: &fakeRequestID}, err
 }
 
 func TestLocalToBlob(t *testing.T) {
@@ -199,7 +299,7 @@ func TestLocalToBlob(t *testing.T) {
 			uploadBlob:   fbs.uploadBlobFile,
 		}
 
-		_, _, err := in.localToBlob(context.Background(), test.from, to, "test", &properties.All{})
+		_, _, info, err := in.localToBlob(context.Background(), test.from, to, "test", &properties.All{Ingestion: properties.Ingestion{DatabaseName: "database", TableName: "table"}}, nil)
 		switch {
 		case err == nil && test.err:
 			t.Errorf("TestLocalToBlob(%s): got err == nil, want err != nil", test.desc)
@@ -211,6 +311,15 @@ func TestLocalToBlob(t *testing.T) {
 			continue
 		}
 
+		if !strings.HasPrefix(info.URL, "https://account.windows.net/test/database_table_") {
+			t.Errorf("TestLocalToBlob(%s): got upload URL %s, want a blob of the test container", test.desc, info.URL)
+		}
+		info.URL = ""
+		wantInfo := resources.UploadInfo{ETag: string(fakeETag), LastModified: fakeLastModified, RequestID: fakeRequestID}
+		if info != wantInfo {
+			t.Errorf("TestLocalToBlob(%s): got upload info %+v, want %+v", test.desc, info, wantInfo)
+		}
+
 		gotBuf := &bytes.Buffer{}
 		zr, err := gzip.NewReader(fbs.out)
 		if err != nil {
@@ -227,6 +336,446 @@ func TestLocalToBlob(t *testing.T) {
 	}
 }
 
+func TestUploadConcurrency(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := os.CreateTemp("", "upload_concurrency")
+	if err != nil {
+		panic(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Remove(f.Name())
+	})
+	_, _ = f.Write([]byte("hello world"))
+	_ = f.Close()
+
+	tests := []struct {
+		desc        string
+		concurrency int
+		uploads     int
+	}{
+		{desc: "Serial uploads", concurrency: 1, uploads: 4},
+		{desc: "Parallel uploads", concurrency: 3, uploads: 6},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fbs := &fakeBlobstore{block: make(chan struct{})}
+			in := &Ingestion{
+				db:           "database",
+				table:        "table",
+				uploadStream: fbs.uploadBlobStream,
+				uploadBlob:   fbs.uploadBlobFile,
+			}
+			WithUploadConcurrency(test.concurrency)(in)
+
+			wg := sync.WaitGroup{}
+			errs := make(chan error, test.uploads)
+			for i := 0; i < test.uploads; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _, _, err := in.localToBlob(context.Background(), f.Name(), to, "test", &properties.All{}, nil)
+					errs <- err
+				}()
+			}
+
+			assert.Eventually(t, func() bool {
+				inFlight, _ := fbs.current()
+				return inFlight == test.concurrency
+			}, 5*time.Second, time.Millisecond)
+
+			// Give any upload that ignored the limit a chance to start.
+			time.Sleep(50 * time.Millisecond)
+			_, maxInFlight := fbs.current()
+			assert.Equal(t, test.concurrency, maxInFlight)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, _, _, err := in.localToBlob(ctx, f.Name(), to, "test", &properties.All{}, nil)
+			assert.Error(t, err)
+			assert.False(t, errors.Retry(err))
+
+			close(fbs.block)
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBlobMetadata(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "data.csv")
+	compressed := filepath.Join(dir, "data.csv.gz")
+	for _, name := range []string{plain, compressed} {
+		if err := os.WriteFile(name, []byte("hello world"), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	team, pipeline := "ingestion", "42"
+	want := map[string]*string{"team": &team, "pipeline_id": &pipeline}
+
+	tests := []struct {
+		desc     string
+		from     string
+		metadata map[string]string
+		want     map[string]*string
+	}{
+		{desc: "Stream upload", from: plain, metadata: map[string]string{"team": team, "pipeline_id": pipeline}, want: want},
+		{desc: "File upload", from: compressed, metadata: map[string]string{"team": team, "pipeline_id": pipeline}, want: want},
+		{desc: "No metadata", from: plain},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fbs := &fakeBlobstore{out: &bytes.Buffer{}}
+			in := &Ingestion{
+				db:           "database",
+				table:        "table",
+				uploadStream: fbs.uploadBlobStream,
+				uploadBlob:   fbs.uploadBlobFile,
+			}
+
+			props := &properties.All{Source: properties.SourceOptions{BlobMetadata: test.metadata}}
+			_, _, _, err := in.localToBlob(context.Background(), test.from, to, "test", props, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, fbs.metadata)
+		})
+	}
+}
+
+func TestRetainBlob(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.blob.core.windows.net/container?sv=2020&sig=secret", nil)
+	require.NoError(t, err)
+
+	from := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(from, []byte("hello world"), 0644))
+
+	retain := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		desc     string
+		source   properties.SourceOptions
+		wantTags map[string]string
+		want     *bool
+	}{
+		{desc: "Default", want: retain(true)},
+		{desc: "Delete local source", source: properties.SourceOptions{DeleteLocalSource: true}},
+		{desc: "Retain", source: properties.SourceOptions{RetainBlob: true, DeleteLocalSource: true}, wantTags: map[string]string{RetainBlobTag: "true"}, want: retain(true)},
+		{desc: "Delete on success", source: properties.SourceOptions{DeleteBlobOnSuccess: true}, want: retain(false)},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fbs := &fakeBlobstore{out: &bytes.Buffer{}}
+			in := &Ingestion{
+				db:           "database",
+				table:        "table",
+				uploadStream: fbs.uploadBlobStream,
+				uploadBlob:   fbs.uploadBlobFile,
+			}
+
+			props := &properties.All{Source: test.source}
+			blobURL, _, info, err := in.localToBlob(context.Background(), from, to, "container", props, nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantTags, fbs.tags)
+			assert.Equal(t, test.want, retainBlobOnSuccess(props))
+
+			assert.Contains(t, blobURL, "sig=secret", "the service needs the SAS to read the blob")
+			assert.True(t, strings.HasPrefix(info.URL, "https://account.blob.core.windows.net/container/"), info.URL)
+			assert.NotContains(t, info.URL, "?")
+		})
+	}
+}
+
+// bytesMetrics records the IngestBytes calls.
+type bytesMetrics struct {
+	kusto.NopMetrics
+	mu    sync.Mutex
+	bytes []int64
+}
+
+func (m *bytesMetrics) IngestBytes(db, table string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes = append(m.bytes, n)
+}
+
+func TestUploadMetrics(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	for _, from := range []string{filepath.Join(dir, "data.csv"), filepath.Join(dir, "data.csv.gz")} {
+		require.NoError(t, os.WriteFile(from, []byte("hello world"), 0644))
+
+		m := &bytesMetrics{}
+		fbs := &fakeBlobstore{out: &bytes.Buffer{}}
+		in := &Ingestion{
+			db:           "database",
+			table:        "table",
+			uploadStream: fbs.uploadBlobStream,
+			uploadBlob:   fbs.uploadBlobFile,
+			metrics:      m,
+		}
+
+		_, _, _, err := in.localToBlob(context.Background(), from, to, "test", &properties.All{}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{11}, m.bytes, from)
+	}
+}
+
+type progressCall struct {
+	uploaded, total int64
+}
+
+func TestUploadProgress(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte("hello world\n"), 300*1024)
+	size := int64(len(content))
+	dir := t.TempDir()
+
+	for _, from := range []string{filepath.Join(dir, "data.csv"), filepath.Join(dir, "data.csv.gz")} {
+		require.NoError(t, os.WriteFile(from, content, 0644))
+
+		var calls []progressCall
+		fbs := &fakeBlobstore{out: &bytes.Buffer{}}
+		in := &Ingestion{uploadStream: fbs.uploadBlobStream, uploadBlob: fbs.uploadBlobFile}
+		props := &properties.All{Source: properties.SourceOptions{Progress: func(uploaded, total int64) {
+			calls = append(calls, progressCall{uploaded, total})
+		}}}
+
+		_, _, _, err := in.localToBlob(context.Background(), from, to, "test", props, nil)
+		require.NoError(t, err)
+		require.NotEmpty(t, calls, from)
+		assert.Equal(t, progressCall{size, size}, calls[len(calls)-1], "%s: the final count should be reported", from)
+	}
+
+	// A reader of unknown size reports a total of -1, about every progressInterval bytes.
+	var calls []progressCall
+	p := newProgress(func(uploaded, total int64) { calls = append(calls, progressCall{uploaded, total}) }, 0)
+	_, err = io.Copy(io.Discard, &progressReader{reader: bytes.NewReader(content), progress: p})
+	require.NoError(t, err)
+	p.done(size)
+
+	require.Len(t, calls, int(size/progressInterval)+2)
+	for i, call := range calls[:len(calls)-1] {
+		assert.Equal(t, int64(-1), call.total)
+		if i > 0 {
+			assert.GreaterOrEqual(t, call.uploaded-calls[i-1].uploaded, int64(progressInterval))
+		}
+	}
+	assert.Equal(t, progressCall{size, size}, calls[len(calls)-1])
+
+	assert.Nil(t, newProgress(nil, size), "no progress should be tracked without a callback")
+}
+
+// recordingEvents records the events of an upload.
+type recordingEvents struct {
+	properties.NopEventHandler
+	starts   []string
+	progress []int64
+	retries  []int
+}
+
+func (r *recordingEvents) OnUploadStart(source, blobURL string) {
+	r.starts = append(r.starts, source+" "+blobURL)
+}
+
+func (r *recordingEvents) OnUploadProgress(uploaded int64) {
+	r.progress = append(r.progress, uploaded)
+}
+
+func (r *recordingEvents) OnUploadRetry(attempt int, _ error) {
+	r.retries = append(r.retries, attempt)
+}
+
+func TestUploadEvents(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net/?sig=secret", nil)
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte("hello world\n"), 300*1024)
+	size := int64(len(content))
+	from := filepath.Join(t.TempDir(), "data.csv.gz")
+	require.NoError(t, os.WriteFile(from, content, 0644))
+
+	rec := &recordingEvents{}
+	var progressed int64
+	props := &properties.All{Source: properties.SourceOptions{
+		Events:   rec,
+		Progress: func(uploaded, _ int64) { progressed = uploaded },
+	}}
+	events := newUploadEvents(props)
+
+	fbs := &fakeBlobstore{out: &bytes.Buffer{}, shouldErr: true}
+	in := &Ingestion{uploadStream: fbs.uploadBlobStream, uploadBlob: fbs.uploadBlobFile}
+	_, _, _, err = in.localToBlob(context.Background(), from, to, "test", props, events)
+	require.Error(t, err)
+	events.retry(err)
+
+	fbs.shouldErr = false
+	blobURL, _, _, err := in.localToBlob(context.Background(), from, to, "test", props, events)
+	require.NoError(t, err)
+
+	require.Len(t, rec.starts, 2)
+	assert.Contains(t, blobURL, "sig=secret")
+	assert.Equal(t, from+" "+strings.Split(blobURL, "?")[0], rec.starts[1], "the blob URL should not have the SAS")
+	assert.Equal(t, []int{1}, rec.retries)
+	require.NotEmpty(t, rec.progress)
+	assert.Equal(t, size, rec.progress[len(rec.progress)-1])
+	assert.Equal(t, size, progressed, "the WithProgress() callback should still be called")
+
+	assert.Nil(t, newUploadEvents(&properties.All{}), "no events should be reported without a handler")
+	assert.Nil(t, progressReport(&properties.All{}))
+}
+
+func TestUploadBlockOptions(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	type blocks struct {
+		size        int64
+		concurrency int
+	}
+	tests := []struct {
+		desc   string
+		file   string
+		source properties.SourceOptions
+		want   blocks
+	}{
+		{desc: "Compressed upload defaults to the static buffer", file: "data.csv", want: blocks{2 * _1MiB, 3}},
+		{desc: "File upload defaults", file: "data.csv.gz", want: blocks{BlockSize, Concurrency}},
+		{desc: "Compressed upload", file: "data.csv", source: properties.SourceOptions{BlockSize: 16 * _1MiB, BlockConcurrency: 8}, want: blocks{16 * _1MiB, 8}},
+		{desc: "File upload", file: "data.csv.gz", source: properties.SourceOptions{BlockSize: 64 * _1MiB, BlockConcurrency: 100}, want: blocks{64 * _1MiB, 100}},
+	}
+
+	for _, test := range tests {
+		from := filepath.Join(dir, test.file)
+		require.NoError(t, os.WriteFile(from, []byte("a,b\n"), 0644))
+
+		var got blocks
+		in := &Ingestion{
+			bufferSize: 2 * _1MiB,
+			maxBuffers: 3,
+			uploadStream: func(_ context.Context, r io.Reader, _ *azblob.Client, _, _ string, o *azblob.UploadStreamOptions) (azblob.UploadStreamResponse, error) {
+				got = blocks{o.BlockSize, o.Concurrency}
+				_, err := io.Copy(io.Discard, r)
+				return azblob.UploadStreamResponse{}, err
+			},
+			uploadBlob: func(_ context.Context, _ *os.File, _ *azblob.Client, _, _ string, o *azblob.UploadFileOptions) (azblob.UploadFileResponse, error) {
+				got = blocks{o.BlockSize, int(o.Concurrency)}
+				return azblob.UploadFileResponse{}, nil
+			},
+		}
+
+		_, _, _, err := in.localToBlob(context.Background(), from, to, "test", &properties.All{Source: test.source}, nil)
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, test.want, got, test.desc)
+	}
+}
+
+func TestUploadMode(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	content := []byte("a,b\n")
+
+	tests := []struct {
+		desc       string
+		file       string
+		mode       ingestoptions.UploadMode
+		wantStream bool
+		wantErr    bool
+	}{
+		{desc: "Auto compresses as a stream", file: "data.csv", wantStream: true},
+		{desc: "Auto uploads compressed files", file: "data.csv.gz"},
+		{desc: "Stream", file: "data.csv.gz", mode: ingestoptions.UploadModeStream, wantStream: true},
+		{desc: "File", file: "data.csv.gz", mode: ingestoptions.UploadModeFile},
+		{desc: "File cannot be compressed", file: "data.csv", mode: ingestoptions.UploadModeFile, wantErr: true},
+	}
+
+	for _, test := range tests {
+		from := filepath.Join(dir, test.file)
+		require.NoError(t, os.WriteFile(from, content, 0644))
+
+		streamed, uploaded := false, false
+		in := &Ingestion{
+			bufferSize: 2 * _1MiB,
+			maxBuffers: 3,
+			uploadStream: func(_ context.Context, r io.Reader, _ *azblob.Client, _, _ string, _ *azblob.UploadStreamOptions) (azblob.UploadStreamResponse, error) {
+				streamed = true
+				_, err := io.Copy(io.Discard, r)
+				return azblob.UploadStreamResponse{}, err
+			},
+			uploadBlob: func(context.Context, *os.File, *azblob.Client, string, string, *azblob.UploadFileOptions) (azblob.UploadFileResponse, error) {
+				uploaded = true
+				return azblob.UploadFileResponse{}, nil
+			},
+		}
+
+		var progress [][2]int64
+		props := &properties.All{Source: properties.SourceOptions{UploadMode: test.mode, Progress: func(uploaded, total int64) {
+			progress = append(progress, [2]int64{uploaded, total})
+		}}}
+		_, size, _, err := in.localToBlob(context.Background(), from, to, "test", props, nil)
+		if test.wantErr {
+			require.Error(t, err, test.desc)
+			assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind, test.desc)
+			assert.False(t, streamed || uploaded, test.desc)
+			continue
+		}
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, test.wantStream, streamed, test.desc)
+		assert.Equal(t, !test.wantStream, uploaded, test.desc)
+		assert.Equal(t, int64(len(content)), size, test.desc)
+		require.NotEmpty(t, progress, test.desc)
+		assert.Equal(t, [2]int64{int64(len(content)), int64(len(content))}, progress[len(progress)-1], test.desc)
+		if test.mode == ingestoptions.UploadModeStream {
+			assert.Equal(t, int64(-1), progress[0][1], "a streamed file should not rely on its size")
+		}
+	}
+}
+
 type fileInfo struct {
 	os.FileInfo
 	isDir bool
@@ -336,6 +885,12 @@ func TestShouldCompress(t *testing.T) {
 				OriginalSource: "https://somehost.somedomain.com:8080/v1/somestuff/file.gz"}},
 			want: false,
 		},
+		{
+			name: "Guess by name is ZSTD",
+			props: &properties.All{Source: properties.SourceOptions{CompressionType: ingestoptions.CTUnknown,
+				OriginalSource: "https://somehost.somedomain.com:8080/v1/somestuff/file.csv.zst"}},
+			want: false,
+		},
 		{
 			name: "DontCompress is true",
 			props: &properties.All{Source: properties.SourceOptions{CompressionType: ingestoptions.CTNone,
@@ -349,13 +904,33 @@ func TestShouldCompress(t *testing.T) {
 				OriginalSource: "https://somehost.somedomain.com:8080/v1/somestuff/file.avro"}},
 			want: false,
 		},
+		{
+			name: "Binary format set on a file without extension",
+			props: &properties.All{
+				Ingestion: properties.Ingestion{Additional: properties.Additional{Format: properties.Parquet}},
+				Source:    properties.SourceOptions{OriginalSource: "/tmp/upload123"},
+			},
+			want: false,
+		},
+		{
+			name: "Binary format set on a file with a misleading extension",
+			props: &properties.All{
+				Ingestion: properties.Ingestion{Additional: properties.Additional{Format: properties.ORC}},
+				Source:    properties.SourceOptions{OriginalSource: "/tmp/upload123.csv"},
+			},
+			want: false,
+		},
 	}
 
 	for _, test := range tests {
 		test := test // capture
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
+			format := test.props.Ingestion.Additional.Format
 			CompleteFormatFromFileName(test.props, test.props.Source.OriginalSource)
+			if format != properties.DFUnknown {
+				assert.Equal(t, format, test.props.Ingestion.Additional.Format, "a format that was set must not be discovered from the file name")
+			}
 
 			got := ShouldCompress(test.props,
 				utils.CompressionDiscovery(test.props.Source.OriginalSource))
@@ -363,3 +938,413 @@ func TestShouldCompress(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldCompressSize(t *testing.T) {
+	t.Parallel()
+
+	floor := properties.All{Source: properties.SourceOptions{CompressionMinBytes: 10}}
+	dontCompress := properties.All{Source: properties.SourceOptions{CompressionMinBytes: 10, DontCompress: true}}
+	binary := properties.All{Source: properties.SourceOptions{CompressionMinBytes: 10}}
+	binary.Ingestion.Additional.Format = properties.Parquet
+
+	tests := []struct {
+		name  string
+		props properties.All
+		size  int64
+		want  bool
+	}{
+		{name: "Unknown size", props: floor, size: -1, want: true},
+		{name: "Smaller than the floor", props: floor, size: 9, want: false},
+		{name: "Floor", props: floor, size: 10, want: true},
+		{name: "No floor", props: properties.All{}, size: 0, want: true},
+		{name: "DontCompress", props: dontCompress, size: 100, want: false},
+		{name: "Binary format", props: binary, size: 100, want: false},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, ShouldCompressSize(&test.props, ingestoptions.CTUnknown, test.size))
+		})
+	}
+}
+
+func TestPeekSize(t *testing.T) {
+	t.Parallel()
+
+	props := &properties.All{Source: properties.SourceOptions{CompressionMinBytes: 4}}
+	for data, wantSize := range map[string]int64{"": 0, "abc": 3, "abcd": -1, "abcdefgh": -1} {
+		reader, size, err := PeekSize(props, strings.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, wantSize, size, data)
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, data, string(got))
+	}
+
+	reader := strings.NewReader("abc")
+	got, size, err := PeekSize(&properties.All{}, reader)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), size)
+	assert.Equal(t, reader, got)
+}
+
+func TestSourceHTTPHeaders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		source         string
+		compression    ingestoptions.CompressionType
+		shouldCompress bool
+		want           string
+	}{
+		{name: "Compressed by the client", source: "/path/to/a/file.csv", shouldCompress: true},
+		{name: "GZIP source", source: "/path/to/a/file.csv.gz"},
+		{name: "ZSTD source by name", source: "/path/to/a/file.csv.zstd", want: "zstd"},
+		{name: "ZSTD source by option", source: "/path/to/a/file", compression: ingestoptions.ZSTD, want: "zstd"},
+		{name: "Option overrides name", source: "/path/to/a/file.zst", compression: ingestoptions.CTNone},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			props := &properties.All{Source: properties.SourceOptions{CompressionType: test.compression}}
+			got := sourceHTTPHeaders(props, utils.CompressionDiscovery(test.source), test.shouldCompress)
+			if test.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, test.want, *got.BlobContentEncoding)
+			}
+		})
+	}
+}
+
+func TestContainerRotation(t *testing.T) {
+	t.Parallel()
+
+	mustParse := func(s string) *resources.URI {
+		u, err := resources.Parse(s)
+		if err != nil {
+			panic(err)
+		}
+		return u
+	}
+
+	containers := []*resources.URI{
+		mustParse("https://a.blob.core.windows.net/container0"),
+		mustParse("https://a.blob.core.windows.net/container1"),
+		mustParse("https://b.blob.core.windows.net/container0"),
+		mustParse("https://b.blob.core.windows.net/container1"),
+		mustParse("https://c.blob.core.windows.net/container0"),
+		mustParse("https://d.blob.core.windows.net/container0"),
+	}
+
+	throttled := errors.E(errors.OpFileIngest, errors.KBlobstore,
+		fmt.Errorf("problem uploading to Blob Storage: %w", &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}))
+	failed := errors.ES(errors.OpFileIngest, errors.KBlobstore, "problem uploading to Blob Storage: error")
+
+	tests := []struct {
+		desc         string
+		err          error
+		wantAccounts []string
+		wantErr      string
+	}{
+		{
+			desc:         "Throttling rotates to the next account until all accounts were tried",
+			err:          throttled,
+			wantAccounts: []string{"a", "b", "c", "d"},
+			wantErr:      "could not upload file to any container, tried storage accounts [a b c d]",
+		},
+		{
+			desc:         "Other errors stop at the max retry policy",
+			err:          failed,
+			wantAccounts: []string{"a", "a", "b"},
+			wantErr:      "max retry policy reached, tried storage accounts [a b]",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rotation := newContainerRotation(containers)
+			var gotAccounts, retried []string
+			rotation.onRetry = func(account string, err error) {
+				assert.Equal(t, test.err, err)
+				retried = append(retried, account)
+			}
+			for {
+				container, err := rotation.next()
+				if err != nil {
+					assert.Contains(t, err.Error(), test.wantErr)
+					break
+				}
+				gotAccounts = append(gotAccounts, container.Account())
+				rotation.failed(container, test.err)
+			}
+			assert.Equal(t, test.wantAccounts, gotAccounts)
+			assert.Equal(t, test.wantAccounts[:len(test.wantAccounts)-1], retried, "every attempt after the first is a retry of the previous one")
+		})
+	}
+}
+
+func TestValidateBlobNameTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		template string
+		err      bool
+	}{
+		{template: "{db}_{table}_{date}_{uuid}"},
+		{template: "exports-{file}.v1~{uuid}"},
+		{template: "static"},
+		{template: "", err: true},
+		{template: "{db}/{table}", err: true},
+		{template: "{db}\\{table}", err: true},
+		{template: "..{uuid}", err: true},
+		{template: "{unknown}_{uuid}", err: true},
+		{template: "name with spaces", err: true},
+		{template: "{uuid}?sas=1", err: true},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.template, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateBlobNameTemplate(test.template)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGenBlobNameFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 4, 5, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	guid := "0a1b2c3d-0000-0000-0000-000000000000"
+
+	tests := []struct {
+		desc           string
+		template       string
+		db             string
+		fileName       string
+		shouldCompress bool
+		want           string
+	}{
+		{
+			desc:           "All placeholders",
+			template:       "{db}_{table}_{date}_{uuid}_{file}",
+			db:             "db",
+			fileName:       "data.csv",
+			shouldCompress: true,
+			want:           "db_table_2023-04-06_" + guid + "_data.csv.gz",
+		},
+		{
+			desc:     "Uuid is always added",
+			template: "{table}-{date}",
+			db:       "db",
+			want:     "table-2023-04-06_" + guid + ".csv",
+		},
+		{
+			desc:     "Unsafe values are replaced",
+			template: "{db}_{file}_{uuid}",
+			db:       "my db",
+			fileName: "a file?.csv",
+			want:     "my_db_a_file_.csv_" + guid + ".csv",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			require.NoError(t, ValidateBlobNameTemplate(test.template))
+			got := GenBlobNameFromTemplate(test.template, test.db, "table", now, guid, test.fileName, ingestoptions.CTNone, test.shouldCompress, "csv")
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	t.Parallel()
+
+	forbidden := errors.E(errors.OpFileIngest, errors.KBlobstore, fmt.Errorf("problem uploading to Blob Storage: %w", &azcore.ResponseError{StatusCode: http.StatusForbidden}))
+	assert.True(t, isAuthFailure(forbidden))
+	assert.False(t, isAuthFailure(&azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}))
+	assert.False(t, isAuthFailure(errors.ES(errors.OpFileIngest, errors.KBlobstore, "403")))
+	assert.False(t, isAuthFailure(nil))
+}
+
+// cancellingBlobstore fakes uploads that are aborted mid-copy: they read part of the payload, cancel the context,
+// and fail the way azblob does when the context of a transfer ends.
+type cancellingBlobstore struct {
+	cancel context.CancelFunc
+	read   int
+}
+
+func (c *cancellingBlobstore) abort(ctx context.Context, reader io.Reader) error {
+	n, _ := io.CopyN(io.Discard, reader, 4)
+	c.read = int(n)
+	c.cancel()
+	<-ctx.Done()
+	return fmt.Errorf("transfer aborted: %w", ctx.Err())
+}
+
+func (c *cancellingBlobstore) uploadBlobStream(ctx context.Context, reader io.Reader, _ *azblob.Client, _ string, _ string, _ *azblob.UploadStreamOptions) (azblob.UploadStreamResponse, error) {
+	return azblob.UploadStreamResponse{}, c.abort(ctx, reader)
+}
+
+func (c *cancellingBlobstore) uploadBlobFile(ctx context.Context, fi *os.File, _ *azblob.Client, _ string, _ string, _ *azblob.UploadFileOptions) (azblob.UploadFileResponse, error) {
+	return azblob.UploadFileResponse{}, c.abort(ctx, fi)
+}
+
+func TestUploadCancellation(t *testing.T) {
+	t.Parallel()
+
+	to, err := azblob.NewClientWithNoCredential("https://account.windows.net", nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	content := strings.Repeat("hello world\n", 1000)
+	plain, compressed := filepath.Join(dir, "data.csv"), filepath.Join(dir, "data.csv.gz")
+	require.NoError(t, os.WriteFile(plain, []byte(content), 0644))
+	require.NoError(t, os.WriteFile(compressed, []byte(content), 0644))
+
+	mgr, err := resources.New(resources.SuccessfulFakeResources())
+	require.NoError(t, err)
+	t.Cleanup(mgr.Close)
+
+	tests := []struct {
+		desc   string
+		upload func(ctx context.Context, in *Ingestion) error
+	}{
+		{
+			desc: "Compressed stream upload",
+			upload: func(ctx context.Context, in *Ingestion) error {
+				_, _, _, err := in.localToBlob(ctx, plain, to, "test", &properties.All{}, nil)
+				return err
+			},
+		},
+		{
+			desc: "File upload",
+			upload: func(ctx context.Context, in *Ingestion) error {
+				_, _, _, err := in.localToBlob(ctx, compressed, to, "test", &properties.All{}, nil)
+				return err
+			},
+		},
+		{
+			desc: "Reader upload",
+			upload: func(ctx context.Context, in *Ingestion) error {
+				props := properties.All{Ingestion: properties.Ingestion{Additional: properties.Additional{Format: properties.CSV}}}
+				_, _, err := in.Reader(ctx, strings.NewReader(content), props)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cbs := &cancellingBlobstore{cancel: cancel}
+			in := &Ingestion{
+				db:           "database",
+				table:        "table",
+				mgr:          mgr,
+				uploadStream: cbs.uploadBlobStream,
+				uploadBlob:   cbs.uploadBlobFile,
+			}
+
+			err := test.upload(ctx, in)
+			require.Error(t, err)
+			assert.Greater(t, cbs.read, 0, "the upload should have started")
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok, "got %T: %s", err, err)
+			assert.Equal(t, errors.OpFileIngest, e.Op)
+			assert.Equal(t, errors.KTimeout, e.Kind)
+			assert.False(t, errors.Retry(err))
+			assert.ErrorIs(t, err, context.Canceled)
+		})
+	}
+}
+
+// failingBlobstore fakes uploads that fail after reading n bytes of the payload.
+type failingBlobstore struct {
+	n        int64
+	attempts int
+}
+
+func (f *failingBlobstore) uploadBlobStream(_ context.Context, reader io.Reader, _ *azblob.Client, _ string, _ string, _ *azblob.UploadStreamOptions) (azblob.UploadStreamResponse, error) {
+	f.attempts++
+	if _, err := io.CopyN(io.Discard, reader, f.n); err != nil {
+		return azblob.UploadStreamResponse{}, err
+	}
+	return azblob.UploadStreamResponse{}, fmt.Errorf("connection reset")
+}
+
+func TestReaderUploadRetries(t *testing.T) {
+	t.Parallel()
+
+	row := func(kind, uri string) value.Values {
+		return value.Values{value.String{Valid: true, Value: kind}, value.String{Valid: true, Value: uri}}
+	}
+	mgr, err := resources.New(resources.FakeResources([]value.Values{
+		row("TempStorage", "https://a.blob.core.windows.net/container0"),
+		row("TempStorage", "https://b.blob.core.windows.net/container0"),
+		row("TempStorage", "https://c.blob.core.windows.net/container0"),
+		row("SecuredReadyForAggregationQueue", "https://a.queue.core.windows.net/queue0"),
+	}, false))
+	require.NoError(t, err)
+	t.Cleanup(mgr.Close)
+
+	content := strings.Repeat("hello world\n", 1000)
+
+	tests := []struct {
+		desc         string
+		read         int64
+		compress     bool
+		wantAttempts int
+	}{
+		{desc: "Nothing read is retried", read: 0, wantAttempts: StorageMaxRetryPolicy},
+		{desc: "Partly read is not retried", read: 10, wantAttempts: 1},
+		{desc: "Partly read compressed is not retried", read: 10, compress: true, wantAttempts: 1},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fbs := &failingBlobstore{n: test.read}
+			in := &Ingestion{
+				db:           "database",
+				table:        "table",
+				mgr:          mgr,
+				uploadStream: fbs.uploadBlobStream,
+			}
+			props := properties.All{Ingestion: properties.Ingestion{Additional: properties.Additional{Format: properties.CSV}}}
+			props.Source.DontCompress = !test.compress
+
+			_, _, err := in.Reader(context.Background(), strings.NewReader(content), props)
+			require.Error(t, err)
+			assert.Equal(t, test.wantAttempts, fbs.attempts)
+			assert.False(t, errors.Retry(err))
+		})
+	}
+}
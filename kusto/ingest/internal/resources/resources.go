@@ -23,7 +23,13 @@ const (
 	defaultInitialInterval = 1 * time.Second
 	defaultMultiplier      = 2
 	retryCount             = 4
-	fetchInterval          = 1 * time.Hour
+	// DefaultRefreshInterval is how often the ingestion resources are fetched again by default.
+	DefaultRefreshInterval = 1 * time.Hour
+	fetchInterval          = DefaultRefreshInterval
+	// sasExpiryMargin is how long before the SAS of a resource expires the resources are fetched again.
+	sasExpiryMargin = 5 * time.Minute
+	// minRefreshInterval is the minimum time between two scheduled fetches of the resources.
+	minRefreshInterval = 1 * time.Minute
 )
 
 // mgmter is a private interface that allows us to write hermetic tests against the kusto.Client.Mgmt() method.
@@ -127,6 +133,20 @@ func (u *URI) SAS() url.Values {
 	return u.sas
 }
 
+// Expiry returns the signed expiry time ("se") of the SAS in the URI, if it has one.
+func (u *URI) Expiry() (time.Time, bool) {
+	se := u.sas.Get("se")
+	if se == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z", "2006-01-02"} {
+		if t, err := time.Parse(layout, se); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
 // String implements fmt.Stringer.
 func (u *URI) String() string {
 	return u.u.String()
@@ -137,6 +157,20 @@ func (u *URI) URL() *url.URL {
 	return u.u
 }
 
+// UploadInfo is the metadata Blob Storage returned for an uploaded blob.
+type UploadInfo struct {
+	// ETag is the ETag of the uploaded blob.
+	ETag string
+	// LastModified is the time the blob was last modified, as reported by Blob Storage.
+	LastModified time.Time
+	// RequestID is the x-ms-request-id of the request that committed the blob.
+	RequestID string
+	// URL is the URL of the uploaded blob, without the SAS token.
+	URL string
+	// Size is the raw (uncompressed) size of the uploaded data, or 0 if it is unknown.
+	Size int64
+}
+
 // token represents a Kusto identity token.
 type token struct {
 	AuthContext string `kusto:"AuthorizationContext"`
@@ -152,7 +186,16 @@ type Manager struct {
 	authTokenCacheExpiration time.Time
 	authLock                 sync.Mutex
 	fetchLock                sync.Mutex
+	refreshLock              sync.Mutex
 	rankedStorageAccount     *RankedStorageAccountSet
+	refreshInterval          atomic.Int64 // Stores a time.Duration, 0 means DefaultRefreshInterval
+	sasExpiry                atomic.Value // Stores time.Time, the earliest SAS expiry of the fetched resources
+	logger                   atomic.Value // Stores loggerHolder
+}
+
+// loggerHolder lets atomic.Value store Loggers of different concrete types.
+type loggerHolder struct {
+	kusto.Logger
 }
 
 // New is the constructor for Manager.
@@ -180,18 +223,66 @@ func (m *Manager) Close() {
 	}
 }
 
+// SetRefreshInterval sets how often the resources are fetched again. A value <= 0 uses DefaultRefreshInterval.
+// Regardless of the interval, resources are fetched again shortly before the SAS tokens in them expire.
+func (m *Manager) SetRefreshInterval(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	m.refreshInterval.Store(int64(d))
+}
+
+// SetLogger sets the Logger that the fetches of the resources are logged to.
+func (m *Manager) SetLogger(l kusto.Logger) {
+	if l == nil {
+		l = kusto.NopLogger{}
+	}
+	m.logger.Store(loggerHolder{l})
+}
+
+func (m *Manager) log() kusto.Logger {
+	if h, ok := m.logger.Load().(loggerHolder); ok {
+		return h.Logger
+	}
+	return kusto.NopLogger{}
+}
+
+func (m *Manager) interval() time.Duration {
+	if d := time.Duration(m.refreshInterval.Load()); d > 0 {
+		return d
+	}
+	return fetchInterval
+}
+
+// refreshDue returns the time the resources should be fetched again, and false if they were never fetched.
+func (m *Manager) refreshDue() (time.Time, bool) {
+	lastFetchTime, ok := m.lastFetchTime.Load().(time.Time)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	due := lastFetchTime.Add(m.interval())
+	if expiry, ok := m.sasExpiry.Load().(time.Time); ok && !expiry.IsZero() {
+		if beforeExpiry := expiry.Add(-sasExpiryMargin); beforeExpiry.Before(due) {
+			due = beforeExpiry
+		}
+	}
+	// Short lived SAS tokens should not make us fetch on every tick.
+	if earliest := lastFetchTime.Add(minRefreshInterval); due.Before(earliest) {
+		due = earliest
+	}
+	return due, true
+}
+
 func (m *Manager) renewResources() {
 	tickDuration := 30 * time.Second
 
 	tick := time.NewTicker(tickDuration)
-	count := fetchInterval // Start with a fetch immediately.
 
 	for {
 		select {
 		case <-tick.C:
-			count += tickDuration
-			if count >= fetchInterval {
-				count = 0 * time.Second
+			if due, ok := m.refreshDue(); !ok || !time.Now().UTC().Before(due) {
 				m.fetchRetry(context.Background())
 			}
 		case <-m.done:
@@ -201,6 +292,20 @@ func (m *Manager) renewResources() {
 	}
 }
 
+// ForceRefresh fetches the resources again, for example after Blob Storage rejected a SAS token. To avoid a storm of
+// refreshes when many uploads fail at the same time, the resources are not fetched again if they were already
+// fetched after since, which should be the time the failed operation started.
+func (m *Manager) ForceRefresh(ctx context.Context, since time.Time) error {
+	m.refreshLock.Lock()
+	defer m.refreshLock.Unlock()
+
+	if lastFetchTime, ok := m.lastFetchTime.Load().(time.Time); ok && lastFetchTime.After(since) {
+		return nil
+	}
+	m.log().Info("kusto: refreshing the ingestion resources after they were rejected")
+	return m.fetch(ctx)
+}
+
 // AuthContext returns a string representing the authorization context. This auth token is a temporary token
 // that can be used to write a message via ingestion.  This is different than the ADAL token.
 func (m *Manager) AuthContext(ctx context.Context) (string, error) {
@@ -275,6 +380,19 @@ type Ingestion struct {
 
 var errDoNotCare = errors.New("don't care about this")
 
+// sasExpiry returns the earliest expiry time of the SAS tokens in the resources, or the zero time if none has one.
+func (i *Ingestion) sasExpiry() time.Time {
+	var earliest time.Time
+	for _, uris := range [][]*URI{i.Queues, i.Containers, i.Tables} {
+		for _, u := range uris {
+			if e, ok := u.Expiry(); ok && (earliest.IsZero() || e.Before(earliest)) {
+				earliest = e
+			}
+		}
+	}
+	return earliest
+}
+
 func (i *Ingestion) importRec(rec ingestResc, rankedStorageAccounts *RankedStorageAccountSet) error {
 	u, err := Parse(rec.Root)
 	if err != nil {
@@ -352,6 +470,7 @@ func (m *Manager) fetch(ctx context.Context) error {
 	}, retryCtx)
 
 	if err != nil {
+		m.log().Error("kusto: could not fetch the ingestion resources", "error", err)
 		return fmt.Errorf("problem getting ingestion resources from Kusto: %s", err)
 	}
 
@@ -372,12 +491,15 @@ func (m *Manager) fetch(ctx context.Context) error {
 		},
 	)
 	if err != nil {
+		m.log().Error("kusto: could not read the ingestion resources", "error", err)
 		return fmt.Errorf("problem reading ingestion resources from Kusto: %s", err)
 	}
 
 	m.resources.Store(ingest)
+	m.sasExpiry.Store(ingest.sasExpiry())
 
 	m.lastFetchTime.Store(time.Now().UTC())
+	m.log().Debug("kusto: fetched the ingestion resources", "containers", len(ingest.Containers), "queues", len(ingest.Queues), "sasExpiry", ingest.sasExpiry())
 
 	return nil
 }
@@ -397,6 +519,7 @@ func (m *Manager) fetchRetry(ctx context.Context) error {
 		cancel()
 		if err != nil {
 			attempts++
+			m.log().Warn("kusto: the periodic refresh of the ingestion resources failed", "attempt", attempts, "error", err)
 			if attempts > retryCount {
 				return fmt.Errorf("failed to fetch ingestion resources")
 			}
@@ -418,7 +541,11 @@ func initBackoff() backoff.BackOff {
 // of fetching from source.
 func (m *Manager) getResources() (Ingestion, error) {
 	lastFetchTime, ok := m.lastFetchTime.Load().(time.Time)
-	if !ok || lastFetchTime.Add(2*fetchInterval).Before(time.Now().UTC()) {
+	stale := ok && lastFetchTime.Add(2*m.interval()).Before(time.Now().UTC())
+	if expiry, hasExpiry := m.sasExpiry.Load().(time.Time); hasExpiry && !expiry.IsZero() && !time.Now().UTC().Before(expiry) {
+		stale = true
+	}
+	if !ok || stale {
 		err := m.fetchRetry(context.Background())
 		if err != nil {
 			return Ingestion{}, err
@@ -3,6 +3,7 @@ package resources
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -270,3 +271,73 @@ func TestResources(t *testing.T) {
 		})
 	}
 }
+
+func TestURIExpiry(t *testing.T) {
+	t.Parallel()
+
+	u := mustParse("https://account.blob.core.windows.net/storageroot?sv=2018-03-28&se=2023-05-03T10%3A20%3A30Z&sig=secret")
+	got, ok := u.Expiry()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2023, 5, 3, 10, 20, 30, 0, time.UTC), got)
+
+	_, ok = mustParse("https://account.blob.core.windows.net/storageroot?sig=secret").Expiry()
+	assert.False(t, ok)
+
+	_, ok = mustParse("https://account.blob.core.windows.net/storageroot?se=garbage").Expiry()
+	assert.False(t, ok)
+}
+
+func TestRefreshDue(t *testing.T) {
+	t.Parallel()
+
+	fetched := time.Date(2023, 5, 3, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc     string
+		interval time.Duration
+		expiry   time.Time
+		want     time.Time
+	}{
+		{desc: "Default interval", want: fetched.Add(DefaultRefreshInterval)},
+		{desc: "Custom interval", interval: 10 * time.Minute, want: fetched.Add(10 * time.Minute)},
+		{desc: "SAS expires before the interval", expiry: fetched.Add(30 * time.Minute), want: fetched.Add(25 * time.Minute)},
+		{desc: "SAS expires after the interval", expiry: fetched.Add(2 * time.Hour), want: fetched.Add(DefaultRefreshInterval)},
+		{desc: "Short lived SAS", expiry: fetched.Add(time.Minute), want: fetched.Add(minRefreshInterval)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			m := &Manager{}
+			_, ok := m.refreshDue()
+			assert.False(t, ok)
+
+			m.SetRefreshInterval(test.interval)
+			m.lastFetchTime.Store(fetched)
+			m.sasExpiry.Store(test.expiry)
+
+			got, ok := m.refreshDue()
+			assert.True(t, ok)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestForceRefresh(t *testing.T) {
+	t.Parallel()
+
+	m := &Manager{client: SuccessfulFakeResources(), rankedStorageAccount: newDefaultRankedStorageAccountSet()}
+	start := time.Now().UTC()
+	assert.NoError(t, m.ForceRefresh(context.Background(), start))
+	first := m.lastFetchTime.Load().(time.Time)
+
+	// The resources were fetched after the failure started, so we shouldn't fetch them again.
+	m.client = FakeResources(nil, false).SetMgmtErr()
+	assert.NoError(t, m.ForceRefresh(context.Background(), start))
+	assert.Equal(t, first, m.lastFetchTime.Load().(time.Time))
+
+	// A failure that started after the last fetch triggers a new one.
+	assert.Error(t, m.ForceRefresh(context.Background(), time.Now().UTC()))
+}
@@ -123,24 +123,32 @@ type FsMock struct {
 	OnLocal  func(ctx context.Context, from string, props properties.All) error
 	OnReader func(ctx context.Context, reader io.Reader, props properties.All) (string, error)
 	OnBlob   func(ctx context.Context, from string, fileSize int64, props properties.All) error
+	// Upload is returned by Local() and Reader() as the metadata of the uploaded blob.
+	Upload UploadInfo
 }
 
 func (f FsMock) Close() error {
 	return nil
 }
 
-func (f FsMock) Local(ctx context.Context, from string, props properties.All) error {
+func (f FsMock) Local(ctx context.Context, from string, props properties.All) (UploadInfo, error) {
 	if f.OnLocal != nil {
-		return f.OnLocal(ctx, from, props)
+		if err := f.OnLocal(ctx, from, props); err != nil {
+			return UploadInfo{}, err
+		}
 	}
-	return nil
+	return f.Upload, nil
 }
 
-func (f FsMock) Reader(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+func (f FsMock) Reader(ctx context.Context, reader io.Reader, props properties.All) (string, UploadInfo, error) {
 	if f.OnReader != nil {
-		return f.OnReader(ctx, reader, props)
+		path, err := f.OnReader(ctx, reader, props)
+		if err != nil {
+			return path, UploadInfo{}, err
+		}
+		return path, f.Upload, nil
 	}
-	return "", nil
+	return "", f.Upload, nil
 }
 
 func (f FsMock) Blob(ctx context.Context, from string, fileSize int64, props properties.All) error {
@@ -1,6 +1,10 @@
 package status
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/google/uuid"
@@ -9,6 +13,8 @@ import (
 const (
 	defaultTimeoutMsec = 10000
 	fullMetadata       = "application/json;odata=fullmetadata"
+	// maxFilterComparisons is the maximum number of comparisons that the filter of a query to Azure Table Storage can hold.
+	maxFilterComparisons = 15
 )
 
 // TableClient allows reading and writing to azure tables.
@@ -49,6 +55,45 @@ func (c *TableClient) Read(ingestionSourceID string) (map[string]interface{}, er
 	return entity.Properties, nil
 }
 
+// ReadMany reads the table records containing the ingestion status of many ingestion sources, by ingestion source ID.
+// The records are read with a query for every maxFilterComparisons IDs, whose pages are merged. IDs with no record
+// are not in the result. ctx is checked between the queries.
+func (c *TableClient) ReadMany(ctx context.Context, ingestionSourceIDs []string) (map[string]map[string]interface{}, error) {
+	records := make(map[string]map[string]interface{}, len(ingestionSourceIDs))
+	for start := 0; start < len(ingestionSourceIDs); start += maxFilterComparisons {
+		end := start + maxFilterComparisons
+		if end > len(ingestionSourceIDs) {
+			end = len(ingestionSourceIDs)
+		}
+
+		filters := make([]string, 0, end-start)
+		for _, id := range ingestionSourceIDs[start:end] {
+			filters = append(filters, fmt.Sprintf("PartitionKey eq '%s'", strings.ReplaceAll(id, "'", "''")))
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := c.table.QueryEntities(defaultTimeoutMsec, fullMetadata, &storage.QueryOptions{Filter: strings.Join(filters, " or ")})
+		for {
+			if err != nil {
+				return nil, err
+			}
+			for _, entity := range page.Entities {
+				records[entity.PartitionKey] = entity.Properties
+			}
+			if page.NextLink == nil {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			page, err = page.NextResults(nil)
+		}
+	}
+	return records, nil
+}
+
 // Write reads a table record cotaining ingestion status.
 func (c *TableClient) Write(ingestionSourceID string, data map[string]interface{}) error {
 	var emptyID = uuid.Nil.String()
@@ -22,7 +22,10 @@ import (
 const EstimatedCompressionFactor = 11
 
 func FetchBlobSize(fPath string, ctx context.Context, client *http.Client) (size int64, err error) {
-	if !strings.Contains(fPath, ".blob.") || strings.Contains(fPath, "Managed_Identity=") || strings.Contains(fPath, "Token=") {
+	// Blobs that Kusto reads with its own credential can't be read by us, so we don't know their size.
+	lower := strings.ToLower(fPath)
+	if !strings.Contains(lower, ".blob.") || strings.Contains(lower, "managed_identity=") || strings.Contains(lower, "token=") ||
+		strings.HasSuffix(lower, ";impersonate") {
 		return 0, nil
 	}
 
@@ -105,6 +108,8 @@ func CompressionDiscovery(fName string) ingestoptions.CompressionType {
 		return ingestoptions.GZIP
 	case ".zip":
 		return ingestoptions.ZIP
+	case ".zst", ".zstd":
+		return ingestoptions.ZSTD
 	}
 	return ingestoptions.CTNone
 }
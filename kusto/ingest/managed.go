@@ -2,7 +2,9 @@ package ingest
 
 import (
 	"bytes"
+	gz "compress/gzip"
 	"context"
+	goErrors "errors"
 	"fmt"
 	"io"
 	"time"
@@ -26,6 +28,19 @@ const (
 	retryCount             = 2
 )
 
+// errTooLargeForStreaming is the reason for falling back to queued ingestion when the payload is larger than limit.
+func errTooLargeForStreaming(limit int64) error {
+	return errors.ES(errors.OpIngestStream, errors.KLimitsExceeded, "payload is larger than the streaming ingestion limit of %d bytes", limit)
+}
+
+// streamingSizeLimit returns the size above which the managed client uses queued ingestion, as set by WithStreamingSizeLimit().
+func streamingSizeLimit(props properties.All) int64 {
+	if props.ManagedStreaming.SizeLimit > 0 {
+		return props.ManagedStreaming.SizeLimit
+	}
+	return maxStreamingSize
+}
+
 type Managed struct {
 	queued    *Ingestion
 	streaming *Streaming
@@ -37,10 +52,11 @@ func NewManaged(client QueryClient, db, table string, options ...Option) (*Manag
 	if err != nil {
 		return nil, err
 	}
-	streaming, err := NewStreaming(client, db, table)
+	streaming, err := newStreaming(client, db, table, queued.http)
 	if err != nil {
 		return nil, err
 	}
+	streaming.schemas = queued.schemas
 
 	return &Managed{
 		queued:    queued,
@@ -49,8 +65,9 @@ func NewManaged(client QueryClient, db, table string, options ...Option) (*Manag
 }
 
 // Attempts to stream with retries, on success - return res,nil.
-// If failed permanently - return err,nil.
-// If failed transiently - return nil,nil.
+// If failed permanently - return nil,err.
+// If failed transiently - return nil,err where canFallback(err) is true, the caller should fallback to queued.
+// Only transient errors are retried, up to the ManagedStreaming.Retries property times, see WithManagedStreamingRetries().
 func (m *Managed) streamWithRetries(ctx context.Context, payloadProvider func() io.Reader, props properties.All, isBlobUri bool) (*Result, error) {
 	var result *Result
 
@@ -58,7 +75,7 @@ func (m *Managed) streamWithRetries(ctx context.Context, payloadProvider func()
 	i := 0
 	managedUuid := uuid.New().String()
 
-	actualBackoff := backoff.WithContext(backoff.WithMaxRetries(props.ManagedStreaming.Backoff, retryCount), ctx)
+	actualBackoff := backoff.WithContext(backoff.WithMaxRetries(props.ManagedStreaming.Backoff, uint64(props.ManagedStreaming.Retries)), ctx)
 
 	var err error = nil
 	err = backoff.Retry(func() error {
@@ -85,26 +102,87 @@ func (m *Managed) streamWithRetries(ctx context.Context, payloadProvider func()
 		return result, nil
 	}
 
-	if errors.Retry(err) {
-		// Caller should fallback to queued
-		return nil, nil
+	return nil, err
+}
+
+// canFallback reports if the managed client falls back to queued ingestion after the streaming error err: the error is
+// transient, the payload is too large for streaming, or streaming ingestion is not enabled on the table. The latter two
+// are not retried.
+func canFallback(err error) bool {
+	return errors.Retry(err) || goErrors.Is(err, errPayloadTooLarge) || isStreamingNotEnabled(err)
+}
+
+// fallback is called before falling back to queued ingestion, with the reason for the fallback. It returns the error of
+// checkFallback(), or logs the fallback.
+func (m *Managed) fallback(ctx context.Context, props properties.All, reason error) error {
+	if err := checkFallback(ctx, props, reason); err != nil {
+		return err
+	}
+	m.streaming.instrumentation.log().Info("kusto: falling back to queued ingestion", "database", props.Ingestion.DatabaseName,
+		"table", props.Ingestion.TableName, "reason", reason)
+	return nil
+}
+
+// checkFallback is called before falling back to queued ingestion, with the reason for the fallback.
+// If the context deadline is closer than the ManagedStreaming.FallbackMinRemaining property, queued ingestion would
+// probably not finish in time, so an error annotated with errors.KTimeout is returned instead.
+func checkFallback(ctx context.Context, props properties.All, reason error) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
 	}
 
-	return nil, err
+	remaining := time.Until(deadline)
+	if remaining > props.ManagedStreaming.FallbackMinRemaining {
+		return nil
+	}
+
+	e := errors.ES(
+		errors.OpFileIngest,
+		errors.KTimeout,
+		"not falling back to queued ingestion, only %s remain until the context deadline (minimum is %s)",
+		remaining.Round(time.Millisecond),
+		props.ManagedStreaming.FallbackMinRemaining,
+	).SetNoRetry()
+	if _, ok := reason.(*errors.Error); ok {
+		return errors.W(reason, e)
+	}
+	return errors.ES(errors.OpFileIngest, errors.KTimeout, "%s: %s", e.Err, reason).SetNoRetry()
 }
 
 func (m *Managed) FromFile(ctx context.Context, fPath string, options ...FileOption) (*Result, error) {
+	return m.streaming.instrumentation.run(ctx, "kusto.ingest.FromFile", "managed", m.streaming.db, m.streaming.table, func(ctx context.Context) (*Result, error) {
+		return m.fromFile(ctx, fPath, options)
+	})
+}
+
+func (m *Managed) fromFile(ctx context.Context, fPath string, options []FileOption) (*Result, error) {
 	props := m.newProp()
 	file, err, local := prepFileAndProps(fPath, &props, options, ManagedClient)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := m.streaming.schemas.prepare(ctx, errors.OpIngestStream, &props); err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+
+	// Streaming ingestion can't skip the first record.
+	if props.Ingestion.Additional.IgnoreFirstRecord {
+		if file != nil {
+			file.Close()
+		}
+		return m.queued.fromFile(ctx, fPath, []FileOption{}, props)
+	}
+
 	if !local {
 		var size int64
 		var compressionTypeForEstimation ingestoptions.CompressionType
 		if size = props.Ingestion.RawDataSize; size == 0 {
-			size, err = utils.FetchBlobSize(fPath, ctx, m.queued.client.HttpClient())
+			size, err = utils.FetchBlobSize(fPath, ctx, m.queued.http)
 			if err != nil {
 				// Failed fetch blob properties
 				return nil, err
@@ -117,13 +195,19 @@ func (m *Managed) FromFile(ctx context.Context, fPath string, options ...FileOpt
 		}
 
 		// File is not compressed and user says its compressed, raw 10 mb -> do
-		if !shouldUseQueuedIngestBySize(compressionTypeForEstimation, size) {
+		limit := streamingSizeLimit(props)
+		reason := errTooLargeForStreaming(limit)
+		if !shouldUseQueuedIngestBySize(compressionTypeForEstimation, size, limit) {
 			res, err := m.streamWithRetries(ctx, func() io.Reader { return generateBlobUriPayloadReader(fPath) }, props, true)
-			if err != nil || res != nil {
+			if err == nil || !canFallback(err) {
 				return res, err
 			}
+			reason = err
 		}
 
+		if err := m.fallback(ctx, props, reason); err != nil {
+			return nil, err
+		}
 		return m.queued.fromFile(ctx, fPath, []FileOption{}, props)
 	}
 
@@ -131,16 +215,22 @@ func (m *Managed) FromFile(ctx context.Context, fPath string, options ...FileOpt
 	return m.managedStreamImpl(ctx, file, props)
 }
 
-func shouldUseQueuedIngestBySize(compression ingestoptions.CompressionType, fileSize int64) bool {
+func shouldUseQueuedIngestBySize(compression ingestoptions.CompressionType, fileSize int64, limit int64) bool {
 	switch compression {
 	case ingestoptions.GZIP, ingestoptions.ZIP:
-		return fileSize > maxStreamingSize
+		return fileSize > limit
 	}
 
-	return fileSize/utils.EstimatedCompressionFactor > maxStreamingSize
+	return fileSize/utils.EstimatedCompressionFactor > limit
 }
 
 func (m *Managed) FromReader(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+	return m.streaming.instrumentation.run(ctx, "kusto.ingest.FromReader", "managed", m.streaming.db, m.streaming.table, func(ctx context.Context) (*Result, error) {
+		return m.fromReader(ctx, reader, options)
+	})
+}
+
+func (m *Managed) fromReader(ctx context.Context, reader io.Reader, options []FileOption) (*Result, error) {
 	props := m.newProp()
 
 	for _, prop := range options {
@@ -149,41 +239,171 @@ func (m *Managed) FromReader(ctx context.Context, reader io.Reader, options ...F
 			return nil, err
 		}
 	}
+	if err := checkTarget(errors.OpFileIngest, &props); err != nil {
+		return nil, err
+	}
+
+	reader = sniffReaderFormat(&props, reader)
+
+	if err := m.streaming.schemas.prepare(ctx, errors.OpIngestStream, &props); err != nil {
+		return nil, err
+	}
+
+	// Streaming ingestion can't skip the first record.
+	if props.Ingestion.Additional.IgnoreFirstRecord {
+		return m.queued.fromReader(ctx, reader, []FileOption{}, props)
+	}
 
 	return m.managedStreamImpl(ctx, io.NopCloser(reader), props)
 }
 
 func (m *Managed) managedStreamImpl(ctx context.Context, payload io.ReadCloser, props properties.All) (*Result, error) {
 	defer payload.Close()
-	compress := queued.ShouldCompress(&props, ingestoptions.CTUnknown)
-	var compressed io.Reader = payload
-	if compress {
-		compressed = gzip.Compress(io.NopCloser(payload))
+	peeked, alreadyCompressed, err := gzip.Peek(payload)
+	if err != nil {
+		return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+	}
+
+	if props.ManagedStreaming.SplitRecords && props.Ingestion.Additional.Format.ShouldCompress() {
+		raw := peeked
+		if alreadyCompressed {
+			zr, err := gz.NewReader(peeked)
+			if err != nil {
+				return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+			}
+			defer zr.Close()
+			raw = zr
+		}
+		return m.streamSplit(ctx, raw, props)
+	}
+
+	compressed := peeked
+	if alreadyCompressed {
+		props.Source.DontCompress = true
+	} else if queued.ShouldCompress(&props, ingestoptions.CTUnknown) {
+		var size int64
+		if peeked, size, err = queued.PeekSize(&props, peeked); err != nil {
+			return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+		}
+		compressed = peeked
+		if queued.ShouldCompressSize(&props, ingestoptions.CTUnknown, size) {
+			compressed = gzip.CompressLevel(io.NopCloser(peeked), props.Source.CompressionLevel)
+		}
 		props.Source.DontCompress = true
 	}
 
-	maxSize := maxStreamingSize
+	// Only up to the limit is buffered: if the payload ends within it, it is streamed, otherwise the buffer and the
+	// rest of the payload are ingested with queued ingestion.
+	maxSize := streamingSizeLimit(props)
 
-	buf, err := io.ReadAll(io.LimitReader(compressed, int64(maxSize+1)))
+	buf, err := io.ReadAll(io.LimitReader(compressed, maxSize+1))
 	if err != nil {
 		return nil, err
 	}
 
-	if shouldUseQueuedIngestBySize(ingestoptions.GZIP, int64(len(buf))) {
+	if shouldUseQueuedIngestBySize(ingestoptions.GZIP, int64(len(buf)), maxSize) {
+		if err := m.fallback(ctx, props, errTooLargeForStreaming(maxSize)); err != nil {
+			return nil, err
+		}
 		combinedBuf := io.MultiReader(bytes.NewReader(buf), compressed)
 		return m.queued.fromReader(ctx, combinedBuf, []FileOption{}, props)
 	}
 
 	res, err := m.streamWithRetries(ctx, func() io.Reader { return bytes.NewReader(buf) }, props, false)
-	if err != nil || res != nil {
+	if err == nil || !canFallback(err) {
 		return res, err
 	}
 
+	if err := m.fallback(ctx, props, err); err != nil {
+		return nil, err
+	}
+
 	// Theres no size estimation when ingesting from stream. If we did not already use queued ingestion
 	// we can assume all the original payload reader is < 4mb, therefore no need to combine
 	return m.queued.fromReader(ctx, bytes.NewReader(buf), []FileOption{}, props)
 }
 
+// streamSplit ingests the payload, which is not compressed, as a series of streaming ingestions of at most
+// streamingSizeLimit() bytes each once compressed, as set by WithRecordSplitting(). Chunks are cut after the last
+// delimiter they hold, so records are never split between two ingestions, and every chunk is compressed on its own.
+// If a record doesn't fit in a chunk, or a chunk fails to stream, that chunk and the rest of the payload are ingested
+// with queued ingestion, and the Result is the one of the queued ingestion.
+func (m *Managed) streamSplit(ctx context.Context, payload io.Reader, props properties.All) (*Result, error) {
+	limit := streamingSizeLimit(props)
+	compress := queued.ShouldCompress(&props, ingestoptions.CTUnknown)
+	chunkProps := props
+	chunkProps.Source.DontCompress = true
+	baseRequestId := props.Streaming.ClientRequestId
+
+	// fallback ingests the rest of the payload, starting with the chunk that could not be streamed, with queued ingestion.
+	fallback := func(reason error, chunk, carry []byte) (*Result, error) {
+		if err := m.fallback(ctx, props, reason); err != nil {
+			return nil, err
+		}
+		rest := io.MultiReader(bytes.NewReader(chunk), bytes.NewReader(carry), payload)
+		return m.queued.fromReader(ctx, rest, []FileOption{}, props)
+	}
+
+	buf := make([]byte, limit)
+	var carry []byte
+	var ingested int64
+	for chunkNum := 0; ; chunkNum++ {
+		n := copy(buf, carry)
+		carry = nil
+
+		read, err := io.ReadFull(payload, buf[n:])
+		n += read
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return nil, errors.E(errors.OpIngestStream, errors.KIO, fmt.Errorf("reader failed after %d bytes were ingested: %w", ingested, err))
+		}
+
+		chunk := buf[:n]
+		if !eof {
+			idx := bytes.LastIndexByte(chunk, props.ManagedStreaming.SplitDelimiter)
+			if idx < 0 {
+				return fallback(errTooLargeForStreaming(limit), chunk, nil)
+			}
+			carry = append([]byte(nil), chunk[idx+1:]...)
+			chunk = chunk[:idx+1]
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		compressed := chunk
+		if compress && int64(len(chunk)) >= props.Source.CompressionMinBytes {
+			if compressed, err = io.ReadAll(gzip.CompressLevel(bytes.NewReader(chunk), props.Source.CompressionLevel)); err != nil {
+				return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+			}
+		}
+		if int64(len(compressed)) > limit {
+			return fallback(errTooLargeForStreaming(limit), chunk, carry)
+		}
+
+		if baseRequestId != "" {
+			chunkProps.Streaming.ClientRequestId = fmt.Sprintf("%s;%d", baseRequestId, chunkNum)
+		}
+		if _, err := m.streamWithRetries(ctx, func() io.Reader { return bytes.NewReader(compressed) }, chunkProps, false); err != nil {
+			if !canFallback(err) {
+				return nil, err
+			}
+			return fallback(err, chunk, carry)
+		}
+		ingested += int64(len(chunk))
+
+		if eof {
+			break
+		}
+	}
+
+	result := newResult()
+	result.putProps(props)
+	result.record.Status = "Success"
+	result.bytesIngested = ingested
+	return result, nil
+}
+
 func (m *Managed) newProp() properties.All {
 	exp := backoff.NewExponentialBackOff()
 	exp.InitialInterval = defaultInitialInterval
@@ -194,8 +414,12 @@ func (m *Managed) newProp() properties.All {
 			DatabaseName: m.streaming.db,
 			TableName:    m.streaming.table,
 		},
+		Source: properties.SourceOptions{
+			CompressionMinBytes: DefaultCompressionMinBytes,
+		},
 		ManagedStreaming: properties.ManagedStreaming{
 			Backoff: exp,
+			Retries: retryCount,
 		},
 	}
 }
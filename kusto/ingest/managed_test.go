@@ -2,10 +2,14 @@ package ingest
 
 import (
 	"bytes"
+	gz "compress/gzip"
 	"context"
 	goErrors "errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -375,7 +379,8 @@ func TestManaged(t *testing.T) {
 
 			off := backoff.NewExponentialBackOff()
 			off.InitialInterval = time.Millisecond
-			test.options = append([]FileOption{backOff(off)}, test.options...)
+			// The test data is smaller than DefaultCompressionMinBytes, and the payloads are expected compressed.
+			test.options = append([]FileOption{backOff(off), WithCompressionMinBytes(0)}, test.options...)
 
 			counter = 0
 
@@ -444,3 +449,441 @@ func initFile(t *testing.T, reader *bytes.Reader) ([]byte, []byte) {
 	require.NoError(t, err)
 	return data, compressedBytes
 }
+
+func TestManagedFallbackMinRemaining(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		options        []FileOption
+		timeout        time.Duration
+		expectFallback bool
+	}{
+		{
+			name:           "No deadline",
+			expectFallback: true,
+		},
+		{
+			name:           "Enough time remaining",
+			options:        []FileOption{WithFallbackMinRemaining(time.Second)},
+			timeout:        time.Hour,
+			expectFallback: true,
+		},
+		{
+			name:    "Not enough time remaining",
+			options: []FileOption{WithFallbackMinRemaining(time.Hour)},
+			timeout: time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			streamErr := errors.E(errors.OpIngestStream, errors.KHTTPError, fmt.Errorf("stream failed"))
+			mockClient := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				auth:     kusto.Authorization{},
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+
+			ingestion, err := New(mockClient, "defaultDb", "defaultTable")
+			require.NoError(t, err)
+			fellBack := false
+			ingestion.fs = resources.FsMock{
+				OnReader: func(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+					fellBack = true
+					return "", nil
+				},
+			}
+			logger := &infoLogger{}
+			managed := Managed{
+				queued: ingestion,
+				streaming: &Streaming{
+					db:              "defaultDb",
+					table:           "defaultTable",
+					client:          mockClient,
+					instrumentation: instrumentation{logger: logger},
+					streamConn: fakeStreamIngestor{
+						onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+							return streamErr
+						},
+					},
+				},
+			}
+
+			ctx := context.Background()
+			if test.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+
+			off := backoff.NewExponentialBackOff()
+			off.InitialInterval = time.Millisecond
+			options := append([]FileOption{backOff(off)}, test.options...)
+
+			result, err := managed.FromReader(ctx, strings.NewReader("a,b,c\n"), options...)
+			assert.Equal(t, test.expectFallback, fellBack)
+			if test.expectFallback {
+				require.NoError(t, err)
+				assert.Equal(t, Queued, result.record.Status)
+				assert.Equal(t, []string{"kusto: falling back to queued ingestion"}, logger.infos, "the fallback should be logged")
+				return
+			}
+			assert.Empty(t, logger.infos)
+
+			require.Error(t, err)
+			assert.Nil(t, result)
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok)
+			assert.Equal(t, errors.KTimeout, e.Kind)
+			assert.False(t, errors.Retry(err))
+			assert.Contains(t, err.Error(), "stream failed")
+		})
+	}
+}
+
+func TestManagedStreamingSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	payload := strings.Repeat("a,b,c\n", 1000)
+
+	tests := []struct {
+		name       string
+		options    []FileOption
+		wantQueued bool
+	}{
+		{name: "Default limit streams small payloads"},
+		{name: "Payload within the limit is streamed", options: []FileOption{WithStreamingSizeLimit(len(payload))}},
+		{name: "Payload larger than the limit is queued", options: []FileOption{WithStreamingSizeLimit(10)}, wantQueued: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(mockClient, "defaultDb", "defaultTable")
+			require.NoError(t, err)
+
+			var queuedPayload, streamedPayload []byte
+			ingestion.fs = resources.FsMock{
+				OnReader: func(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+					queuedPayload, err = io.ReadAll(reader)
+					return "", err
+				},
+			}
+			managed := Managed{
+				queued: ingestion,
+				streaming: &Streaming{
+					db:     "defaultDb",
+					table:  "defaultTable",
+					client: mockClient,
+					streamConn: fakeStreamIngestor{
+						onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+							streamedPayload, err = io.ReadAll(payload)
+							return err
+						},
+					},
+				},
+			}
+
+			_, err = managed.FromReader(context.Background(), strings.NewReader(payload), test.options...)
+			require.NoError(t, err)
+
+			got := streamedPayload
+			if test.wantQueued {
+				assert.Nil(t, streamedPayload)
+				got = queuedPayload
+			} else {
+				assert.Nil(t, queuedPayload)
+			}
+
+			zr, err := gz.NewReader(bytes.NewReader(got))
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, payload, string(decompressed), "the whole payload should be ingested")
+		})
+	}
+
+	for _, n := range []int{0, -1, int(maxStreamingSize) + 1} {
+		assert.Error(t, WithStreamingSizeLimit(n).Run(&properties.All{}, ManagedClient, FromReader))
+	}
+}
+
+func TestManagedIgnoreFirstRecord(t *testing.T) {
+	t.Parallel()
+
+	mockClient := mockClient{
+		endpoint: "https://test.kusto.windows.net",
+		onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+			if query.String() == ".get ingestion resources" {
+				return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+			}
+			return nil, nil
+		},
+	}
+	ingestion, err := New(mockClient, "defaultDb", "defaultTable")
+	require.NoError(t, err)
+
+	var queuedProps []properties.All
+	ingestion.fs = resources.FsMock{
+		OnReader: func(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+			queuedProps = append(queuedProps, props)
+			return "", nil
+		},
+		OnLocal: func(ctx context.Context, from string, props properties.All) error {
+			queuedProps = append(queuedProps, props)
+			return nil
+		},
+	}
+	managed := Managed{
+		queued: ingestion,
+		streaming: &Streaming{
+			db:     "defaultDb",
+			table:  "defaultTable",
+			client: mockClient,
+			streamConn: fakeStreamIngestor{
+				onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+					t.Error("streaming ingestion can't skip the first record")
+					return nil
+				},
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(path, []byte("name,value\na,1\n"), 0644))
+
+	_, err = managed.FromFile(context.Background(), path, WithIgnoreFirstRecord())
+	require.NoError(t, err)
+	_, err = managed.FromReader(context.Background(), strings.NewReader("name,value\na,1\n"), WithIgnoreFirstRecord())
+	require.NoError(t, err)
+
+	require.Len(t, queuedProps, 2)
+	for _, props := range queuedProps {
+		assert.True(t, props.Ingestion.Additional.IgnoreFirstRecord)
+	}
+
+	_, err = managed.FromReader(context.Background(), strings.NewReader(`{"name":"a"}`), WithFileFormat(JSON), WithIgnoreFirstRecord())
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+}
+
+func TestManagedRecordSplitting(t *testing.T) {
+	t.Parallel()
+
+	// Random digits compress poorly, so that a 4KB limit holds only a few hundred records. Smaller chunks are sometimes
+	// stored uncompressed by compress/flate, which makes them larger than the limit.
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "%d,%d\n", uuid.New().ID(), i)
+	}
+	payload := sb.String()
+
+	tests := []struct {
+		name       string
+		payload    string
+		compressed bool
+		wantQueued bool
+	}{
+		{name: "Payload is split", payload: payload},
+		{name: "Compressed payload is split", payload: payload, compressed: true},
+		{name: "Record larger than the limit is queued", payload: "a,b\n" + strings.Repeat("c", 5000) + "\nd,e\n", wantQueued: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(mockClient, "defaultDb", "defaultTable")
+			require.NoError(t, err)
+
+			var queued []byte
+			ingestion.fs = resources.FsMock{
+				OnReader: func(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+					queued, err = io.ReadAll(reader)
+					return "", err
+				},
+			}
+			var chunks []string
+			managed := Managed{
+				queued: ingestion,
+				streaming: &Streaming{
+					db:     "defaultDb",
+					table:  "defaultTable",
+					client: mockClient,
+					streamConn: fakeStreamIngestor{
+						onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+							compressed, err := io.ReadAll(payload)
+							require.NoError(t, err)
+							assert.LessOrEqual(t, len(compressed), 4096)
+							zr, err := gz.NewReader(bytes.NewReader(compressed))
+							require.NoError(t, err)
+							chunk, err := io.ReadAll(zr)
+							require.NoError(t, err)
+							chunks = append(chunks, string(chunk))
+							return nil
+						},
+					},
+				},
+			}
+
+			var reader io.Reader = strings.NewReader(test.payload)
+			if test.compressed {
+				reader = gzip.Compress(reader)
+			}
+			_, err = managed.FromReader(context.Background(), reader, WithStreamingSizeLimit(4096), WithRecordSplitting('\n'), WithCompressionMinBytes(0))
+			require.NoError(t, err)
+
+			for _, chunk := range chunks {
+				assert.True(t, strings.HasSuffix(chunk, "\n"), "a record was split: %q", chunk)
+			}
+			got := strings.Join(chunks, "")
+			if test.wantQueued {
+				// The rest is handed to the uploader uncompressed, which compresses it.
+				require.NotNil(t, queued)
+				got += string(queued)
+			} else {
+				assert.Nil(t, queued)
+				assert.Greater(t, len(chunks), 1)
+			}
+			assert.Equal(t, test.payload, got, "the whole payload should be ingested")
+		})
+	}
+}
+
+func TestManagedStreamingRetries(t *testing.T) {
+	t.Parallel()
+
+	httpErr := func(code int, body string) func() error {
+		return func() error {
+			return errors.HTTP(errors.OpIngestStream, http.StatusText(code), code, io.NopCloser(strings.NewReader(body)), "error from Kusto endpoint")
+		}
+	}
+	notEnabled := `{"error":{"code":"BadRequest","@type":"Kusto.DataNode.Exceptions.StreamingIngestionPolicyNotEnabledException","message":"Streaming ingestion policy is not enabled"}}`
+
+	tests := []struct {
+		name           string
+		options        []FileOption
+		streamErr      func() error
+		wantAttempts   int
+		expectFallback bool
+	}{
+		{name: "Server error", streamErr: httpErr(http.StatusServiceUnavailable, ""), wantAttempts: 3, expectFallback: true},
+		{name: "Throttling", streamErr: httpErr(http.StatusTooManyRequests, ""), wantAttempts: 3, expectFallback: true},
+		{
+			name: "Network error",
+			streamErr: func() error {
+				return errors.E(errors.OpIngestStream, errors.KHTTPError, fmt.Errorf("connection reset"))
+			},
+			wantAttempts:   3,
+			expectFallback: true,
+		},
+		{
+			name:           "Custom retries",
+			options:        []FileOption{WithManagedStreamingRetries(4)},
+			streamErr:      httpErr(http.StatusGatewayTimeout, ""),
+			wantAttempts:   5,
+			expectFallback: true,
+		},
+		{
+			name:           "No retries",
+			options:        []FileOption{WithManagedStreamingRetries(0)},
+			streamErr:      httpErr(http.StatusInternalServerError, ""),
+			wantAttempts:   1,
+			expectFallback: true,
+		},
+		{name: "Payload too large", streamErr: httpErr(http.StatusRequestEntityTooLarge, ""), wantAttempts: 1, expectFallback: true},
+		{name: "Streaming not enabled", streamErr: httpErr(http.StatusBadRequest, notEnabled), wantAttempts: 1, expectFallback: true},
+		{name: "Unauthorized", streamErr: httpErr(http.StatusUnauthorized, ""), wantAttempts: 1},
+		{name: "Bad request", streamErr: httpErr(http.StatusBadRequest, `{"error":{"code":"BadRequest","message":"bad format"}}`), wantAttempts: 1},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockClient := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(mockClient, "defaultDb", "defaultTable")
+			require.NoError(t, err)
+			fellBack := false
+			ingestion.fs = resources.FsMock{
+				OnReader: func(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+					fellBack = true
+					return "", nil
+				},
+			}
+			attempts := 0
+			managed := Managed{
+				queued: ingestion,
+				streaming: &Streaming{
+					db:     "defaultDb",
+					table:  "defaultTable",
+					client: mockClient,
+					streamConn: fakeStreamIngestor{
+						onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+							attempts++
+							return test.streamErr()
+						},
+					},
+				},
+			}
+
+			off := backoff.NewExponentialBackOff()
+			off.InitialInterval = time.Millisecond
+			options := append([]FileOption{backOff(off)}, test.options...)
+
+			result, err := managed.FromReader(context.Background(), strings.NewReader("a,b,c\n"), options...)
+			assert.Equal(t, test.wantAttempts, attempts)
+			assert.Equal(t, test.expectFallback, fellBack)
+			if test.expectFallback {
+				require.NoError(t, err)
+				assert.Equal(t, Queued, result.record.Status)
+				return
+			}
+			require.Error(t, err)
+			assert.Nil(t, result)
+			assert.False(t, errors.Retry(err))
+		})
+	}
+
+	assert.Error(t, WithManagedStreamingRetries(-1).Run(&properties.All{}, ManagedClient, FromReader))
+}
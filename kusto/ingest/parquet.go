@@ -0,0 +1,109 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetMapping is an ingestion mapping generated by GenerateParquetMapping().
+type ParquetMapping struct {
+	// Mapping is the JSON ingestion mapping, to use with IngestionMapping(m.Mapping, Parquet).
+	Mapping string
+	// Unmapped are the columns of the file that the table has no column of the same name for, in the order of the
+	// file. They are not ingested.
+	Unmapped []string
+	// Missing are the columns of the table that the file has no column of the same name for, sorted. They are left
+	// empty by the ingestion.
+	Missing []string
+}
+
+// parquetColumnMapping is an entry of the mapping generated by GenerateParquetMapping().
+type parquetColumnMapping struct {
+	Column     string
+	DataType   string
+	Properties struct {
+		Path string
+	}
+}
+
+// GenerateParquetMapping reads the schema of the Parquet file at path and generates an ingestion mapping of its top
+// level columns to the columns of the same name of table, with the types of the table columns. Names must match
+// exactly. The columns of the file that the table doesn't have, and the columns of the table that the file doesn't
+// have, are returned in the Unmapped and Missing fields, so they are not dropped silently: check them before using the
+// mapping. It fails with errors.KClientArgs if the file is not a Parquet file or if no column matches.
+// Only the footer of the file is read.
+func GenerateParquetMapping(ctx context.Context, path string, client QueryClient, db, table string) (*ParquetMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "GenerateParquetMapping() could not open %q: %s", path, err).SetNoRetry()
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "GenerateParquetMapping() could not read %q: %s", path, err).SetNoRetry()
+	}
+
+	pf, err := parquet.OpenFile(f, info.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "GenerateParquetMapping() could not read the schema of %q: %s", path, err).SetNoRetry()
+	}
+	var fileColumns []string
+	for _, c := range pf.Root().Columns() {
+		fileColumns = append(fileColumns, c.Name())
+	}
+
+	tableColumns, err := newSchemaCache(client).columns(ctx, db, table)
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "GenerateParquetMapping() could not get the schema of table %q: %s", table, err)
+	}
+
+	result := &ParquetMapping{}
+	var mapping []parquetColumnMapping
+	inFile := make(map[string]bool, len(fileColumns))
+	for _, name := range fileColumns {
+		inFile[name] = true
+		cslType, ok := tableColumns[name]
+		if !ok {
+			result.Unmapped = append(result.Unmapped, name)
+			continue
+		}
+		m := parquetColumnMapping{Column: name, DataType: cslType}
+		m.Properties.Path = parquetPath(name)
+		mapping = append(mapping, m)
+	}
+	for name := range tableColumns {
+		if !inFile[name] {
+			result.Missing = append(result.Missing, name)
+		}
+	}
+	sort.Strings(result.Missing)
+
+	if len(mapping) == 0 {
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs,
+			"GenerateParquetMapping() found no column of %q in table %q: the file has the columns %v, and the table %v",
+			path, table, fileColumns, result.Missing).SetNoRetry()
+	}
+
+	b, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KInternal, "GenerateParquetMapping() could not encode the mapping: %s", err).SetNoRetry()
+	}
+	result.Mapping = string(b)
+	return result, nil
+}
+
+// parquetPath returns the path of the top level Parquet column name in a mapping, like $.name or $['my name'].
+func parquetPath(name string) string {
+	if !kql.RequiresQuoting(name) && name != "" && !unicode.IsDigit([]rune(name)[0]) {
+		return "$." + name
+	}
+	return "$['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name) + "']"
+}
@@ -0,0 +1,128 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeParquet writes a Parquet file without rows whose schema has the string columns, in order, and returns its path.
+func writeParquet(t *testing.T, columns ...string) string {
+	fields := make([]reflect.StructField, len(columns))
+	for i, c := range columns {
+		fields[i] = reflect.StructField{Name: fmt.Sprintf("F%d", i), Type: reflect.TypeOf(""), Tag: reflect.StructTag(fmt.Sprintf("parquet:%q", c))}
+	}
+	return writeParquetSchema(t, parquet.SchemaOf(reflect.New(reflect.StructOf(fields)).Interface()))
+}
+
+// writeParquetSchema writes a Parquet file without rows that has the schema, and returns its path.
+func writeParquetSchema(t *testing.T, schema *parquet.Schema) string {
+	path := filepath.Join(t.TempDir(), "data.parquet")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, parquet.NewWriter(f, schema).Close())
+	require.NoError(t, f.Close())
+	return path
+}
+
+func TestGenerateParquetMapping(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	client := schemaClient(t, &calls)
+	ctx := context.Background()
+
+	got, err := GenerateParquetMapping(ctx, writeParquet(t, "id", "extra", "name"), client, "db", "table")
+	require.NoError(t, err)
+	assert.Equal(t, &ParquetMapping{
+		Mapping: `[{"Column":"id","DataType":"long","Properties":{"Path":"$.id"}},` +
+			`{"Column":"name","DataType":"string","Properties":{"Path":"$.name"}}]`,
+		Unmapped: []string{"extra"},
+		Missing:  []string{"ok"},
+	}, got)
+
+	got, err = GenerateParquetMapping(ctx, writeParquet(t, "ok", "name", "id"), client, "db", "table")
+	require.NoError(t, err)
+	assert.Equal(t, &ParquetMapping{
+		Mapping: `[{"Column":"ok","DataType":"bool","Properties":{"Path":"$.ok"}},` +
+			`{"Column":"name","DataType":"string","Properties":{"Path":"$.name"}},` +
+			`{"Column":"id","DataType":"long","Properties":{"Path":"$.id"}}]`,
+	}, got)
+}
+
+func TestGenerateParquetMappingGroups(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	client := schemaClient(t, &calls)
+
+	type row struct {
+		ID   int64    `parquet:"id"`
+		Tags []string `parquet:"tags,list"`
+		Name struct {
+			First string `parquet:"first"`
+			Last  string `parquet:"last"`
+		} `parquet:"name"`
+	}
+	got, err := GenerateParquetMapping(context.Background(), writeParquetSchema(t, parquet.SchemaOf(row{})), client, "db", "table")
+	require.NoError(t, err)
+	assert.Equal(t, &ParquetMapping{
+		Mapping: `[{"Column":"id","DataType":"long","Properties":{"Path":"$.id"}},` +
+			`{"Column":"name","DataType":"string","Properties":{"Path":"$.name"}}]`,
+		Unmapped: []string{"tags"},
+		Missing:  []string{"ok"},
+	}, got, "only the top level columns should be mapped")
+}
+
+func TestGenerateParquetMappingErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	client := schemaClient(t, &calls)
+	ctx := context.Background()
+
+	csv := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(csv, []byte("1,a,true\n2,b,false\n"), 0644))
+	corrupt := filepath.Join(t.TempDir(), "corrupt.parquet")
+	require.NoError(t, os.WriteFile(corrupt, []byte("PAR1\xff\xff\xff\xff\x04\x00\x00\x00PAR1"), 0644))
+
+	tests := []struct {
+		desc     string
+		path     string
+		wantKind errors.Kind
+	}{
+		{desc: "Missing file", path: filepath.Join(t.TempDir(), "missing.parquet"), wantKind: errors.KLocalFileSystem},
+		{desc: "Not Parquet", path: csv, wantKind: errors.KClientArgs},
+		{desc: "Corrupt metadata", path: corrupt, wantKind: errors.KClientArgs},
+		{desc: "No matching column", path: writeParquet(t, "a", "b"), wantKind: errors.KClientArgs},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := GenerateParquetMapping(ctx, test.path, client, "db", "table")
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok, "got %v", err)
+			assert.Equal(t, test.wantKind, e.Kind)
+		})
+	}
+}
+
+func TestParquetPath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "$.id", parquetPath("id"))
+	assert.Equal(t, "$['my name']", parquetPath("my name"))
+	assert.Equal(t, "$['1st']", parquetPath("1st"))
+	assert.Equal(t, `$['it\'s']`, parquetPath("it's"))
+}
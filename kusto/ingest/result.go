@@ -4,18 +4,66 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/status"
 )
 
+// defaultStatusPollInterval is the default interval between reads of the ingestion status table.
+const defaultStatusPollInterval = 10 * time.Second
+
+// statusReader reads the status of an ingestion from the status table.
+type statusReader interface {
+	Read(ingestionSourceID string) (map[string]interface{}, error)
+}
+
+// batchStatusReader is implemented by the statusReaders that can read the status of many ingestions at once.
+type batchStatusReader interface {
+	ReadMany(ctx context.Context, ingestionSourceIDs []string) (map[string]map[string]interface{}, error)
+}
+
 // Result provides a way for users track the state of ingestion jobs.
 type Result struct {
+	// mu guards record, which the polling of Wait() and WaitStatus() updates while other goroutines may read it, such
+	// as with BatchStatus().
+	mu            sync.Mutex
 	record        statusRecord
-	tableClient   *status.TableClient
+	tableClient   statusReader
 	reportToTable bool
+	bytesIngested int64
+	pollInterval  time.Duration
+	dryRun        *DryRunDetails
+	upload        resources.UploadInfo
+
+	// format and clientRequestID are only kept for the attributes of the ingestion span.
+	format          DataFormat
+	clientRequestID string
+}
+
+// DryRunDetails describes the decisions made for an ingestion that used the WithDryRun option.
+type DryRunDetails struct {
+	// Format is the data format the source would be ingested with, after discovering it from the file name.
+	Format DataFormat
+	// IngestionMappingRef is the name of the pre-created ingestion mapping that would be used, if any.
+	IngestionMappingRef string
+	// Compress is true if the client would compress the source before uploading it.
+	Compress bool
+}
+
+// StatusUpdate is an update of the status of an ingestion, see Result.WaitStatus().
+type StatusUpdate struct {
+	// Status is the status of the ingestion, such as Pending, Succeeded or Failed.
+	Status StatusCode
+	// FailureStatus indicates if a failed ingestion may be retried.
+	FailureStatus FailureStatusCode
+	// Details is the human readable reason of a failure.
+	Details string
+	// Err is set if the status could not be retrieved. It is the last update sent.
+	Err error
 }
 
 // newResult creates an initial ingestion status record.
@@ -26,9 +74,18 @@ func newResult() *Result {
 	return ret
 }
 
+// reportsToTable reports if props ask the service to report the ingestion status to the status table.
+func reportsToTable(props properties.All) bool {
+	m := props.Ingestion.ReportMethod
+	return m == properties.ReportStatusToTable || m == properties.ReportStatusToQueueAndTable
+}
+
 // putProps sets the record to a failure state and adds the error to the record details.
 func (r *Result) putProps(props properties.All) {
-	r.reportToTable = props.Ingestion.ReportMethod == properties.ReportStatusToTable || props.Ingestion.ReportMethod == properties.ReportStatusToQueueAndTable
+	r.reportToTable = props.Ingestion.ReportLevel != properties.None && reportsToTable(props)
+	r.pollInterval = props.Status.PollInterval
+	r.format = props.Ingestion.Additional.Format
+	r.clientRequestID = props.Streaming.ClientRequestId
 	r.record.FromProps(props)
 }
 
@@ -78,12 +135,52 @@ func (r *Result) putQueued(mgr *resources.Manager) {
 	r.tableClient = client
 }
 
+// DryRun returns the details of an ingestion that used the WithDryRun option, or nil for a real ingestion.
+func (r *Result) DryRun() *DryRunDetails {
+	return r.dryRun
+}
+
+// SourceID returns the ID of the ingestion source, which keys the map returned by BatchStatus().
+func (r *Result) SourceID() string {
+	return r.currentRecord().IngestionSourceID.String()
+}
+
+// BlobETag returns the ETag of the blob that a queued ingestion uploaded the local file or reader content to.
+// It is empty if nothing was uploaded, such as when ingesting from an existing blob.
+func (r *Result) BlobETag() string {
+	return r.upload.ETag
+}
+
+// BlobLastModified returns the last modified time Blob Storage reported for the uploaded blob, see BlobETag().
+func (r *Result) BlobLastModified() time.Time {
+	return r.upload.LastModified
+}
+
+// BlobRequestID returns the x-ms-request-id of the Blob Storage request that committed the uploaded blob. It can be
+// used to correlate an ingestion with the storage account logs, see BlobETag().
+func (r *Result) BlobRequestID() string {
+	return r.upload.RequestID
+}
+
+// BlobURL returns the URL of the blob that a queued ingestion uploaded the local file or reader content to, without
+// the SAS token, see BlobETag(). Together with WithRetainBlob(), it can be used to find the blob after the ingestion.
+func (r *Result) BlobURL() string {
+	return r.upload.URL
+}
+
+// BytesIngested returns the amount of uncompressed bytes that were sent by a chunked streaming ingestion.
+// See WithStreamChunkSize.
+func (r *Result) BytesIngested() int64 {
+	return r.bytesIngested
+}
+
 // Wait returns a channel that can be checked for ingestion results.
-// In order to check actual status please use the ReportResultToTable option when ingesting data.
+// In order to check actual status please use the ReportResultToTable option, or WithReportMethod() with a table
+// report method, when ingesting data.
 func (r *Result) Wait(ctx context.Context) chan error {
 	ch := make(chan error, 1)
 
-	if r.record.Status.IsFinal() || !r.reportToTable {
+	if r.currentRecord().Status.IsFinal() || !r.reportToTable {
 		close(ch)
 		return ch
 	}
@@ -92,16 +189,199 @@ func (r *Result) Wait(ctx context.Context) chan error {
 		defer close(ch)
 
 		r.poll(ctx)
-		if !r.record.Status.IsSuccess() {
-			ch <- r.record
+		if record := r.currentRecord(); !record.Status.IsSuccess() {
+			ch <- record
+		}
+	}()
+
+	return ch
+}
+
+// WaitStatus returns a channel of updates to the status of the ingestion, read from the status table every poll interval
+// (see WithStatusPollInterval). The current status is sent first, and then every change of it. The channel is closed
+// when a final status was sent, or when the context is done.
+// If reading the status table fails, an update with Err set to an errors.OpIngestStatus error is sent before closing.
+// In order to track the actual status, please use the ReportResultToTable option, or WithReportMethod() with a table
+// report method, when ingesting data. Otherwise, a single update with the status of the ingestion request (such as Queued) is sent.
+func (r *Result) WaitStatus(ctx context.Context) <-chan StatusUpdate {
+	ch := make(chan StatusUpdate, 1)
+
+	if r.currentRecord().Status.IsFinal() || r.tableClient == nil {
+		ch <- r.statusUpdate()
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+
+		send := func(u StatusUpdate) bool {
+			select {
+			case ch <- u:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		last := r.statusUpdate()
+		if !send(last) {
+			return
+		}
+
+		timer := time.NewTimer(r.statusPollInterval())
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.updateRecord(func(record *statusRecord) {
+					record.Status = StatusRetrievalCanceled
+					record.FailureStatus = Transient
+				})
+				select {
+				case ch <- StatusUpdate{Status: StatusRetrievalCanceled, FailureStatus: Transient, Err: errors.E(errors.OpIngestStatus, errors.KTimeout, ctx.Err())}:
+				default:
+				}
+				return
+
+			case <-timer.C:
+				smap, err := r.tableClient.Read(r.SourceID())
+				if err != nil {
+					details := "Failed reading from Status Table: " + err.Error()
+					r.updateRecord(func(record *statusRecord) {
+						record.Status = StatusRetrievalFailed
+						record.FailureStatus = Transient
+						record.Details = details
+					})
+					send(StatusUpdate{
+						Status:        StatusRetrievalFailed,
+						FailureStatus: Transient,
+						Details:       details,
+						Err:           errors.ES(errors.OpIngestStatus, errors.KBlobstore, "failed reading from the status table: %s", err),
+					})
+					return
+				}
+
+				r.updateRecord(func(record *statusRecord) { record.FromMap(smap) })
+				if u := r.statusUpdate(); u != last {
+					if !send(u) {
+						return
+					}
+					last = u
+				}
+				if last.Status.IsFinal() {
+					return
+				}
+
+				timer.Reset(r.statusPollInterval())
+			}
 		}
 	}()
 
 	return ch
 }
 
+// BatchStatus reads the current status of many ingestions, with one query to the status table for every few of them
+// instead of one per ingestion. The statuses are returned by Result.SourceID(). The Results that are final, or that
+// did not use the ReportResultToTable option, are not read and have their current status, like Result.WaitStatus()
+// sends first. The Results are not modified.
+// If reading the status table fails, an errors.OpIngestStatus error is returned.
+func BatchStatus(ctx context.Context, results []*Result) (map[string]StatusUpdate, error) {
+	statuses := make(map[string]StatusUpdate, len(results))
+
+	var readers []statusReader
+	pending := map[statusReader][]*Result{}
+	for _, r := range results {
+		if r.currentRecord().Status.IsFinal() || r.tableClient == nil {
+			statuses[r.SourceID()] = r.statusUpdate()
+			continue
+		}
+		if _, ok := pending[r.tableClient]; !ok {
+			readers = append(readers, r.tableClient)
+		}
+		pending[r.tableClient] = append(pending[r.tableClient], r)
+	}
+
+	for _, reader := range readers {
+		rs := pending[reader]
+		ids := make([]string, 0, len(rs))
+		for _, r := range rs {
+			ids = append(ids, r.SourceID())
+		}
+
+		records, err := readStatuses(ctx, reader, ids)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.E(errors.OpIngestStatus, errors.KTimeout, ctx.Err())
+			}
+			return nil, errors.ES(errors.OpIngestStatus, errors.KBlobstore, "failed reading from the status table: %s", err)
+		}
+
+		for _, r := range rs {
+			current := &Result{record: r.currentRecord()}
+			if smap, ok := records[r.SourceID()]; ok {
+				current.record.FromMap(smap)
+			}
+			statuses[r.SourceID()] = current.statusUpdate()
+		}
+	}
+	return statuses, nil
+}
+
+// readStatuses reads the status records of the ingestions ids from reader, at once if it is a batchStatusReader.
+func readStatuses(ctx context.Context, reader statusReader, ids []string) (map[string]map[string]interface{}, error) {
+	if batch, ok := reader.(batchStatusReader); ok {
+		return batch.ReadMany(ctx, ids)
+	}
+
+	records := make(map[string]map[string]interface{}, len(ids))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		smap, err := reader.Read(id)
+		if err != nil {
+			return nil, err
+		}
+		records[id] = smap
+	}
+	return records, nil
+}
+
+func (r *Result) statusUpdate() StatusUpdate {
+	record := r.currentRecord()
+	u := StatusUpdate{Status: record.Status}
+	if record.Status.IsFinal() && !record.Status.IsSuccess() {
+		u.FailureStatus = record.FailureStatus
+		u.Details = record.Details
+	}
+	return u
+}
+
+// currentRecord returns a copy of the record, which the polling of Wait() and WaitStatus() may be updating.
+func (r *Result) currentRecord() statusRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.record
+}
+
+// updateRecord runs update on the record, guarded from the concurrent reads of currentRecord().
+func (r *Result) updateRecord(update func(record *statusRecord)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(&r.record)
+}
+
+func (r *Result) statusPollInterval() time.Duration {
+	if r.pollInterval > 0 {
+		return r.pollInterval
+	}
+	return defaultStatusPollInterval
+}
+
 func (r *Result) poll(ctx context.Context) {
-	const pollInterval = 10 * time.Second
+	pollInterval := r.statusPollInterval()
 	attempts := 3
 	delay := [3]int{120, 60, 10} // attempts are counted backwards
 
@@ -114,25 +394,29 @@ func (r *Result) poll(ctx context.Context) {
 		for {
 			select {
 			case <-ctx.Done():
-				r.record.Status = StatusRetrievalCanceled
-				r.record.FailureStatus = Transient
+				r.updateRecord(func(record *statusRecord) {
+					record.Status = StatusRetrievalCanceled
+					record.FailureStatus = Transient
+				})
 				return
 
 			case <-timer.C:
-				smap, err := r.tableClient.Read(r.record.IngestionSourceID.String())
+				smap, err := r.tableClient.Read(r.SourceID())
 				if err != nil {
 					if attempts == 0 {
-						r.record.Status = StatusRetrievalFailed
-						r.record.FailureStatus = Transient
-						r.record.Details = "Failed reading from Status Table: " + err.Error()
+						r.updateRecord(func(record *statusRecord) {
+							record.Status = StatusRetrievalFailed
+							record.FailureStatus = Transient
+							record.Details = "Failed reading from Status Table: " + err.Error()
+						})
 						return
 					}
 
 					attempts = attempts - 1
 					time.Sleep(time.Duration(delay[attempts]+rand.Intn(5)) * time.Second)
 				} else {
-					r.record.FromMap(smap)
-					if r.record.Status.IsFinal() {
+					r.updateRecord(func(record *statusRecord) { record.FromMap(smap) })
+					if r.currentRecord().Status.IsFinal() {
 						return
 					}
 				}
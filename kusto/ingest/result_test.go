@@ -0,0 +1,374 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatusReader returns the given statuses in order, repeating the last one.
+type fakeStatusReader struct {
+	mu       sync.Mutex
+	statuses []map[string]interface{}
+	err      error
+	reads    int
+}
+
+func (f *fakeStatusReader) Read(_ string) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	s := f.statuses[f.reads]
+	if f.reads < len(f.statuses)-1 {
+		f.reads++
+	}
+	return s, nil
+}
+
+func pendingResult(t *testing.T, reader statusReader) *Result {
+	props := properties.All{}
+	require.NoError(t, ReportResultToTable().Run(&props, QueuedClient, FromFile))
+	require.NoError(t, WithStatusPollInterval(time.Millisecond).Run(&props, QueuedClient, FromFile))
+	props.Source.ID = uuid.New()
+
+	r := newResult()
+	r.putProps(props)
+	r.record.Status = Pending
+	r.tableClient = reader
+	return r
+}
+
+func collectUpdates(ch <-chan StatusUpdate) []StatusUpdate {
+	var updates []StatusUpdate
+	for u := range ch {
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+func TestWaitStatus(t *testing.T) {
+	t.Parallel()
+
+	pending := map[string]interface{}{"Status": "Pending"}
+
+	tests := []struct {
+		desc   string
+		reader *fakeStatusReader
+		want   []StatusUpdate
+	}{
+		{
+			desc:   "Success",
+			reader: &fakeStatusReader{statuses: []map[string]interface{}{pending, pending, {"Status": "Succeeded"}}},
+			want:   []StatusUpdate{{Status: Pending}, {Status: Succeeded}},
+		},
+		{
+			desc: "Failure",
+			reader: &fakeStatusReader{statuses: []map[string]interface{}{
+				pending,
+				{"Status": "Failed", "FailureStatus": "Permanent", "Details": "bad format"},
+			}},
+			want: []StatusUpdate{{Status: Pending}, {Status: Failed, FailureStatus: Permanent, Details: "bad format"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := pendingResult(t, test.reader)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			assert.Equal(t, test.want, collectUpdates(r.WaitStatus(ctx)))
+		})
+	}
+}
+
+func TestWaitStatusReadError(t *testing.T) {
+	t.Parallel()
+
+	r := pendingResult(t, &fakeStatusReader{err: fmt.Errorf("table is gone")})
+	updates := collectUpdates(r.WaitStatus(context.Background()))
+
+	require.Len(t, updates, 2)
+	assert.Equal(t, Pending, updates[0].Status)
+	assert.Equal(t, StatusRetrievalFailed, updates[1].Status)
+	e, ok := errors.GetKustoError(updates[1].Err)
+	require.True(t, ok)
+	assert.Equal(t, errors.OpIngestStatus, e.Op)
+	assert.Contains(t, e.Error(), "table is gone")
+}
+
+func TestWaitStatusCanceled(t *testing.T) {
+	t.Parallel()
+
+	r := pendingResult(t, &fakeStatusReader{statuses: []map[string]interface{}{{"Status": "Pending"}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := r.WaitStatus(ctx)
+
+	assert.Equal(t, StatusUpdate{Status: Pending}, <-ch)
+	cancel()
+
+	for u := range ch {
+		assert.Equal(t, StatusRetrievalCanceled, u.Status)
+		e, ok := errors.GetKustoError(u.Err)
+		require.True(t, ok)
+		assert.Equal(t, errors.OpIngestStatus, e.Op)
+	}
+}
+
+func TestWaitStatusWithoutTable(t *testing.T) {
+	t.Parallel()
+
+	r := newResult()
+	r.record.Status = Queued
+
+	assert.Equal(t, []StatusUpdate{{Status: Queued}}, collectUpdates(r.WaitStatus(context.Background())))
+}
+
+// fakeBatchStatusReader returns the statuses by ingestion source ID, and records the IDs of every ReadMany() call.
+type fakeBatchStatusReader struct {
+	fakeStatusReader
+	byID  map[string]map[string]interface{}
+	calls [][]string
+}
+
+func (f *fakeBatchStatusReader) ReadMany(_ context.Context, ids []string) (map[string]map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, ids)
+	if f.err != nil {
+		return nil, f.err
+	}
+	records := map[string]map[string]interface{}{}
+	for _, id := range ids {
+		if s, ok := f.byID[id]; ok {
+			records[id] = s
+		}
+	}
+	return records, nil
+}
+
+func TestBatchStatus(t *testing.T) {
+	t.Parallel()
+
+	batch := &fakeBatchStatusReader{byID: map[string]map[string]interface{}{}}
+	succeeded := pendingResult(t, batch)
+	failed := pendingResult(t, batch)
+	missing := pendingResult(t, batch)
+	batch.byID[succeeded.SourceID()] = map[string]interface{}{"Status": "Succeeded"}
+	batch.byID[failed.SourceID()] = map[string]interface{}{"Status": "Failed", "FailureStatus": "Permanent", "Details": "bad data"}
+
+	single := pendingResult(t, &fakeStatusReader{statuses: []map[string]interface{}{{"Status": "Succeeded"}}})
+	queued := newResult()
+	queued.record.Status = Queued
+
+	statuses, err := BatchStatus(context.Background(), []*Result{succeeded, failed, missing, single, queued})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]StatusUpdate{
+		succeeded.SourceID(): {Status: Succeeded},
+		failed.SourceID():    {Status: Failed, FailureStatus: Permanent, Details: "bad data"},
+		missing.SourceID():   {Status: Pending},
+		single.SourceID():    {Status: Succeeded},
+		queued.SourceID():    {Status: Queued},
+	}, statuses)
+	assert.Equal(t, [][]string{{succeeded.SourceID(), failed.SourceID(), missing.SourceID()}}, batch.calls)
+	assert.Equal(t, Pending, succeeded.record.Status)
+}
+
+func TestBatchStatusWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	pending := map[string]interface{}{"Status": "Pending"}
+	statuses := make([]map[string]interface{}, 50)
+	for i := range statuses {
+		statuses[i] = pending
+	}
+	statuses = append(statuses, map[string]interface{}{"Status": "Succeeded"})
+	r := pendingResult(t, &fakeStatusReader{statuses: statuses})
+
+	updates := r.WaitStatus(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		collectUpdates(updates)
+	}()
+
+	// Run with -race: BatchStatus reads the record that the polling of WaitStatus updates.
+	for {
+		select {
+		case <-done:
+			got, err := BatchStatus(context.Background(), []*Result{r})
+			require.NoError(t, err)
+			assert.Equal(t, StatusUpdate{Status: Succeeded}, got[r.SourceID()])
+			return
+		default:
+			_, err := BatchStatus(context.Background(), []*Result{r})
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestBatchStatusReadError(t *testing.T) {
+	t.Parallel()
+
+	r := pendingResult(t, &fakeBatchStatusReader{fakeStatusReader: fakeStatusReader{err: fmt.Errorf("table is gone")}})
+	_, err := BatchStatus(context.Background(), []*Result{r})
+
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.OpIngestStatus, e.Op)
+	assert.Equal(t, errors.KBlobstore, e.Kind)
+	assert.Contains(t, e.Error(), "table is gone")
+}
+
+func TestUploadInfo(t *testing.T) {
+	t.Parallel()
+
+	upload := resources.UploadInfo{
+		ETag:         "0x8DB5E1F3A2C4B7D",
+		LastModified: time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC),
+		RequestID:    "6d7c8a5c-301e-0021-5b34-946d1a000000",
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "*.csv")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	ingestFile := func(i *Ingestion) (*Result, error) { return i.FromFile(context.Background(), f.Name()) }
+	ingestReader := func(i *Ingestion) (*Result, error) {
+		return i.FromReader(context.Background(), strings.NewReader("a,b"), FileFormat(CSV))
+	}
+
+	tests := []struct {
+		desc   string
+		ingest func(i *Ingestion) (*Result, error)
+	}{
+		{desc: "FromFile", ingest: ingestFile},
+		{desc: "FromReader", ingest: ingestReader},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				auth:     kusto.Authorization{},
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(client, "db", "table")
+			require.NoError(t, err)
+			ingestion.fs = resources.FsMock{Upload: upload}
+
+			result, err := test.ingest(ingestion)
+			require.NoError(t, err)
+			assert.Equal(t, upload.ETag, result.BlobETag())
+			assert.Equal(t, upload.LastModified, result.BlobLastModified())
+			assert.Equal(t, upload.RequestID, result.BlobRequestID())
+		})
+	}
+}
+
+func TestReportOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		options       []FileOption
+		wantLevel     ReportLevel
+		wantMethod    ReportMethod
+		wantTable     bool
+		wantOptionErr bool
+	}{
+		{desc: "Default", wantLevel: ReportFailuresOnly, wantMethod: ReportToQueue},
+		{desc: "None", options: []FileOption{WithReportLevel(ReportNone)}, wantLevel: ReportNone, wantMethod: ReportToQueue},
+		{
+			desc:       "Queue and table",
+			options:    []FileOption{WithReportLevel(ReportFailuresAndSuccesses), WithReportMethod(ReportToQueueAndTable)},
+			wantLevel:  ReportFailuresAndSuccesses,
+			wantMethod: ReportToQueueAndTable,
+			wantTable:  true,
+		},
+		{
+			desc:       "Level after ReportResultToTable",
+			options:    []FileOption{ReportResultToTable(), WithReportLevel(ReportFailuresOnly)},
+			wantLevel:  ReportFailuresOnly,
+			wantMethod: ReportToTable,
+			wantTable:  true,
+		},
+		{desc: "Unknown level", options: []FileOption{WithReportLevel(5)}, wantOptionErr: true},
+		{desc: "Unknown method", options: []FileOption{WithReportMethod(properties.ReportStatusToAzureMonitoring)}, wantOptionErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			props := properties.All{}
+			for _, o := range test.options {
+				if err := o.Run(&props, QueuedClient, FromFile); err != nil {
+					require.True(t, test.wantOptionErr, "unexpected error: %v", err)
+					e, ok := errors.GetKustoError(err)
+					require.True(t, ok)
+					assert.Equal(t, errors.KClientArgs, e.Kind)
+					return
+				}
+			}
+			require.False(t, test.wantOptionErr)
+
+			assert.Equal(t, test.wantLevel, props.Ingestion.ReportLevel)
+			assert.Equal(t, test.wantMethod, props.Ingestion.ReportMethod)
+			r := newResult()
+			r.putProps(props)
+			assert.Equal(t, test.wantTable, r.reportToTable)
+		})
+	}
+}
+
+func TestReportNoneToTable(t *testing.T) {
+	t.Parallel()
+
+	client := mockClient{
+		endpoint: "https://test.kusto.windows.net",
+		auth:     kusto.Authorization{},
+		onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+			return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+		},
+	}
+	ingestion, err := New(client, "db", "table")
+	require.NoError(t, err)
+	defer ingestion.Close()
+
+	_, err = ingestion.FromReader(context.Background(), strings.NewReader("a,b\n"), WithReportLevel(ReportNone), WithReportMethod(ReportToTable))
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+	assert.Contains(t, e.Error(), "ReportNone")
+}
@@ -0,0 +1,360 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+)
+
+// schemaCacheTTL is how long the schema of a table, and the mappings created on it, are reused by WithSchemaValidation().
+const schemaCacheTTL = 1 * time.Minute
+
+// cslTypeAliases maps the alternative names of the scalar types that a mapping can declare to the names used in table schemas.
+var cslTypeAliases = map[string]string{
+	"boolean":  "bool",
+	"date":     "datetime",
+	"time":     "timespan",
+	"double":   "real",
+	"int32":    "int",
+	"int64":    "long",
+	"uuid":     "guid",
+	"uniqueid": "guid",
+}
+
+// normalizeCslType returns the name a table schema uses for the scalar type t.
+func normalizeCslType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if alias, ok := cslTypeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// cslScalarTypes are the scalar types a column of a table can have.
+var cslScalarTypes = map[string]bool{
+	"bool": true, "datetime": true, "decimal": true, "dynamic": true, "guid": true,
+	"int": true, "long": true, "real": true, "string": true, "timespan": true,
+}
+
+// parseColumnList parses a column definition list, like "(id:long, name:string)", for WithCreateTableIfNotExists().
+// The parentheses are optional, and names that aren't identifiers must be quoted, like ['my column']. It returns the
+// list with the types normalized, without the parentheses.
+func parseColumnList(schema string) (string, error) {
+	schema = strings.TrimSpace(schema)
+	if strings.HasPrefix(schema, "(") && strings.HasSuffix(schema, ")") {
+		schema = strings.TrimSpace(schema[1 : len(schema)-1])
+	}
+	if schema == "" {
+		return "", fmt.Errorf("the column list is empty")
+	}
+
+	var columns []string
+	seen := map[string]bool{}
+	for rest := schema; ; {
+		name, quoted, r, err := parseColumnName(rest)
+		if err != nil {
+			return "", err
+		}
+		r = strings.TrimSpace(r)
+		if !strings.HasPrefix(r, ":") {
+			return "", fmt.Errorf("column %q has no type, expected name:type", name)
+		}
+
+		typ, next, more := strings.Cut(r[1:], ",")
+		cslType := normalizeCslType(typ)
+		if !cslScalarTypes[cslType] {
+			return "", fmt.Errorf("column %q has an unknown type %q", name, strings.TrimSpace(typ))
+		}
+		if seen[name] {
+			return "", fmt.Errorf("column %q is defined more than once", name)
+		}
+		seen[name] = true
+		columns = append(columns, quoted+":"+cslType)
+
+		if !more {
+			break
+		}
+		rest = next
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+// parseColumnName parses the column name at the start of s, which is an identifier or a quoted name like ['name'].
+// It returns the name, its text in s, and the rest of s.
+func parseColumnName(s string) (name, quoted, rest string, err error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "['") || strings.HasPrefix(s, `["`) {
+		end := strings.Index(s[2:], s[1:2]+"]")
+		if end < 0 {
+			return "", "", "", fmt.Errorf("the quoted column name %s is not terminated", s)
+		}
+		name = s[2 : 2+end]
+		if name == "" {
+			return "", "", "", fmt.Errorf("a column name is empty")
+		}
+		return name, s[:end+4], s[end+4:], nil
+	}
+
+	end := strings.IndexAny(s, ":,")
+	if end < 0 {
+		end = len(s)
+	}
+	name = strings.TrimSpace(s[:end])
+	switch {
+	case name == "":
+		return "", "", "", fmt.Errorf("a column name is empty")
+	case kql.RequiresQuoting(name):
+		return "", "", "", fmt.Errorf("the column name %q must be quoted, like ['%s']", name, name)
+	}
+	return name, name, s[end:], nil
+}
+
+// schemaRec is the record returned by ".show table schema as json".
+type schemaRec struct {
+	Schema string `kusto:"Schema"`
+}
+
+// tableSchema is the JSON document held by schemaRec.Schema.
+type tableSchema struct {
+	OrderedColumns []struct {
+		Name    string
+		CslType string
+	}
+}
+
+// mappingRec is the record returned by ".show table ingestion mapping".
+type mappingRec struct {
+	Mapping string `kusto:"Mapping"`
+}
+
+// columnMapping is an entry of an ingestion mapping. Legacy mappings use Name in place of Column.
+type columnMapping struct {
+	Column   string
+	Name     string
+	DataType string
+}
+
+func (c columnMapping) column() string {
+	if c.Column != "" {
+		return c.Column
+	}
+	return c.Name
+}
+
+type schemaCacheEntry struct {
+	columns map[string]string
+	mapping []columnMapping
+	expires time.Time
+}
+
+// schemaCache fetches and caches the column types of tables and the mappings created on them for WithSchemaValidation().
+type schemaCache struct {
+	client QueryClient
+
+	mu        sync.Mutex
+	entries   map[string]schemaCacheEntry
+	creations map[string]*tableCreation
+}
+
+// tableCreation serializes the ".create table ifnotexists" commands run for a table and schema, so the ingestions
+// running in parallel with WithCreateTableIfNotExists() share one command.
+type tableCreation struct {
+	mu      sync.Mutex
+	expires time.Time
+}
+
+func newSchemaCache(client QueryClient) *schemaCache {
+	return &schemaCache{client: client, entries: map[string]schemaCacheEntry{}, creations: map[string]*tableCreation{}}
+}
+
+// prepare readies the table for an ingestion: it creates the table if WithCreateTableIfNotExists() was used, then
+// validates the ingestion mapping against it if WithSchemaValidation() was used.
+func (s *schemaCache) prepare(ctx context.Context, op errors.Op, props *properties.All) error {
+	if err := s.createTable(ctx, props); err != nil {
+		return err
+	}
+	return s.validate(ctx, op, props)
+}
+
+// createTable runs ".create table ifnotexists" with the schema set by WithCreateTableIfNotExists(). The command is
+// idempotent, and is not run again for the same table and schema for schemaCacheTTL once it succeeded. Failures are
+// errors.OpMgmt errors, so they can be told apart from the failures of the ingestion.
+func (s *schemaCache) createTable(ctx context.Context, props *properties.All) error {
+	schema := props.Source.CreateTableSchema
+	if schema == "" || props.Source.DryRun {
+		return nil
+	}
+
+	db, tableName := props.Ingestion.DatabaseName, props.Ingestion.TableName
+	key := db + "\x00" + tableName + "\x00" + schema
+	s.mu.Lock()
+	creation, ok := s.creations[key]
+	if !ok {
+		creation = &tableCreation{}
+		s.creations[key] = creation
+	}
+	s.mu.Unlock()
+
+	creation.mu.Lock()
+	defer creation.mu.Unlock()
+	if time.Now().Before(creation.expires) {
+		return nil
+	}
+
+	query := kql.New(".create table ").AddTable(tableName).AddLiteral(" ifnotexists ").AddUnsafe("(" + schema + ")")
+	if err := s.mgmt(ctx, db, query, func(*table.Row) error { return nil }); err != nil {
+		kind := errors.KOther
+		if e, ok := errors.GetKustoError(err); ok {
+			kind = e.Kind
+		}
+		return errors.E(errors.OpMgmt, kind, fmt.Errorf("WithCreateTableIfNotExists() could not create table %q: %w", tableName, err))
+	}
+	creation.expires = time.Now().Add(schemaCacheTTL)
+	return nil
+}
+
+// validate checks that every column of the ingestion mapping exists in the table, with the type the mapping declares.
+// It does nothing unless WithSchemaValidation() was used.
+func (s *schemaCache) validate(ctx context.Context, op errors.Op, props *properties.All) error {
+	if !props.Source.ValidateSchema || props.Source.DryRun {
+		return nil
+	}
+
+	db, tableName := props.Ingestion.DatabaseName, props.Ingestion.TableName
+	columns, err := s.columns(ctx, db, tableName)
+	if err != nil {
+		return errors.ES(op, errors.KClientArgs, "WithSchemaValidation() could not get the schema of table %q: %s", tableName, err)
+	}
+
+	var mapping []columnMapping
+	additional := props.Ingestion.Additional
+	switch {
+	case additional.IngestionMapping != "":
+		if err := json.Unmarshal([]byte(additional.IngestionMapping), &mapping); err != nil {
+			return errors.ES(op, errors.KClientArgs, "WithSchemaValidation() could not decode the ingestion mapping: %s", err).SetNoRetry()
+		}
+	case additional.IngestionMappingRef != "":
+		mapping, err = s.mapping(ctx, db, tableName, additional.IngestionMappingType.MappingKind(), additional.IngestionMappingRef)
+		if err != nil {
+			return errors.ES(op, errors.KClientArgs, "WithSchemaValidation() could not get the ingestion mapping %q of table %q: %s", additional.IngestionMappingRef, tableName, err)
+		}
+	}
+
+	var problems []string
+	for _, m := range mapping {
+		name := m.column()
+		columnType, ok := columns[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("column %q does not exist", name))
+		case m.DataType != "" && normalizeCslType(m.DataType) != normalizeCslType(columnType):
+			problems = append(problems, fmt.Sprintf("column %q is of type %s, but the mapping declares %s", name, columnType, m.DataType))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.ES(op, errors.KClientArgs, "the ingestion mapping does not match the schema of table %q: %s", tableName, strings.Join(problems, "; ")).SetNoRetry()
+	}
+	return nil
+}
+
+// columns returns the types of the columns of the table, by name.
+func (s *schemaCache) columns(ctx context.Context, db, tableName string) (map[string]string, error) {
+	entry, err := s.get(ctx, "schema\x00"+db+"\x00"+tableName, func() (schemaCacheEntry, error) {
+		query := kql.New(".show table ").AddTable(tableName).AddLiteral(" schema as json")
+		var recs []schemaRec
+		if err := s.mgmt(ctx, db, query, func(r *table.Row) error {
+			rec := schemaRec{}
+			if err := r.ToStruct(&rec); err != nil {
+				return err
+			}
+			recs = append(recs, rec)
+			return nil
+		}); err != nil {
+			return schemaCacheEntry{}, err
+		}
+		if len(recs) != 1 {
+			return schemaCacheEntry{}, fmt.Errorf("expected 1 schema, got %d", len(recs))
+		}
+
+		schema := tableSchema{}
+		if err := json.Unmarshal([]byte(recs[0].Schema), &schema); err != nil {
+			return schemaCacheEntry{}, fmt.Errorf("could not decode the schema: %w", err)
+		}
+		columns := make(map[string]string, len(schema.OrderedColumns))
+		for _, c := range schema.OrderedColumns {
+			columns[c.Name] = c.CslType
+		}
+		return schemaCacheEntry{columns: columns}, nil
+	})
+	return entry.columns, err
+}
+
+// mapping returns the column mappings of the mapping named name, of the given kind, created on the table.
+func (s *schemaCache) mapping(ctx context.Context, db, tableName string, kind DataFormat, name string) ([]columnMapping, error) {
+	entry, err := s.get(ctx, "mapping\x00"+db+"\x00"+tableName+"\x00"+kind.String()+"\x00"+name, func() (schemaCacheEntry, error) {
+		query := kql.New(".show table ").AddTable(tableName).AddLiteral(" ingestion ").AddKeyword(kind.String()).
+			AddLiteral(" mapping ").AddString(name)
+		var mapping []columnMapping
+		found := false
+		if err := s.mgmt(ctx, db, query, func(r *table.Row) error {
+			rec := mappingRec{}
+			if err := r.ToStruct(&rec); err != nil {
+				return err
+			}
+			found = true
+			return json.Unmarshal([]byte(rec.Mapping), &mapping)
+		}); err != nil {
+			return schemaCacheEntry{}, err
+		}
+		if !found {
+			return schemaCacheEntry{}, fmt.Errorf("the mapping does not exist")
+		}
+		return schemaCacheEntry{mapping: mapping}, nil
+	})
+	return entry.mapping, err
+}
+
+// get returns the cached entry for key, calling fetch if there is none or it has expired. Failures are not cached.
+func (s *schemaCache) get(ctx context.Context, key string, fetch func() (schemaCacheEntry, error)) (schemaCacheEntry, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry, nil
+	}
+
+	entry, err := fetch()
+	if err != nil {
+		return schemaCacheEntry{}, err
+	}
+	entry.expires = time.Now().Add(schemaCacheTTL)
+
+	s.mu.Lock()
+	s.entries[key] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+// mgmt runs the command and calls f for every row of the primary result.
+func (s *schemaCache) mgmt(ctx context.Context, db string, query *kql.Builder, f func(r *table.Row) error) error {
+	iter, err := s.client.Mgmt(ctx, db, query)
+	if err != nil {
+		return err
+	}
+	defer iter.Stop()
+
+	return iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+		if e != nil {
+			return e
+		}
+		return f(r)
+	})
+}
@@ -0,0 +1,235 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{"Name":"table","OrderedColumns":[{"Name":"id","Type":"System.Int64","CslType":"long"},` +
+	`{"Name":"name","Type":"System.String","CslType":"string"},{"Name":"ok","Type":"System.SByte","CslType":"bool"}]}`
+
+// schemaClient returns a client that answers the schema and mapping commands, and counts them.
+func schemaClient(t *testing.T, calls *int32) mockClient {
+	return mockClient{
+		endpoint: "https://test.kusto.windows.net",
+		onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+			atomic.AddInt32(calls, 1)
+			assert.Equal(t, "db", db)
+
+			var column, content string
+			switch query.String() {
+			case ".show table table schema as json":
+				column, content = "Schema", testSchema
+			case `.show table table ingestion json mapping "ref"`:
+				column, content = "Mapping", `[{"column":"id","Properties":{"Path":"$.id"}},{"column":"missing","Properties":{"Path":"$.m"}}]`
+			default:
+				t.Errorf("unexpected command %q", query.String())
+				return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "unexpected command")
+			}
+
+			rows, err := kusto.NewMockRows(table.Columns{{Name: column, Type: types.String}})
+			require.NoError(t, err)
+			require.NoError(t, rows.Row(value.Values{value.String{Value: content, Valid: true}}))
+			iter := &kusto.RowIterator{}
+			require.NoError(t, iter.Mock(rows))
+			return iter, nil
+		},
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		options []FileOption
+		wantErr []string
+	}{
+		{
+			desc:    "Valid inline mapping",
+			options: []FileOption{IngestionMapping(`[{"column":"id","datatype":"int64"},{"column":"name","datatype":"string"},{"column":"ok"}]`, properties.CSV)},
+		},
+		{
+			desc:    "Legacy inline mapping",
+			options: []FileOption{IngestionMapping(`[{"Name":"ok","DataType":"boolean","Ordinal":"0"}]`, properties.CSV)},
+		},
+		{
+			desc:    "Invalid inline mapping",
+			options: []FileOption{IngestionMapping(`[{"column":"id","datatype":"string"},{"column":"other"}]`, properties.CSV)},
+			wantErr: []string{`column "id" is of type long, but the mapping declares string`, `column "other" does not exist`},
+		},
+		{
+			desc:    "Invalid mapping reference",
+			options: []FileOption{IngestionMappingRef("ref", properties.JSON)},
+			wantErr: []string{`column "missing" does not exist`},
+		},
+		{
+			desc: "No mapping",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			cache := newSchemaCache(schemaClient(t, &calls))
+			props := properties.All{Ingestion: properties.Ingestion{DatabaseName: "db", TableName: "table"}}
+			for _, o := range append(test.options, WithSchemaValidation()) {
+				require.NoError(t, o.Run(&props, QueuedClient, FromFile))
+			}
+
+			for i := 0; i < 2; i++ {
+				err := cache.validate(context.Background(), errors.OpFileIngest, &props)
+				if test.wantErr == nil {
+					require.NoError(t, err)
+					continue
+				}
+				require.Error(t, err)
+				assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+				for _, want := range test.wantErr {
+					assert.Contains(t, err.Error(), want)
+				}
+			}
+
+			wantCalls := int32(1)
+			if props.Ingestion.Additional.IngestionMappingRef != "" {
+				wantCalls = 2
+			}
+			assert.Equal(t, wantCalls, atomic.LoadInt32(&calls), "the schema and mapping should be cached")
+		})
+	}
+}
+
+func TestSchemaValidationDisabled(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	cache := newSchemaCache(schemaClient(t, &calls))
+	props := properties.All{Ingestion: properties.Ingestion{DatabaseName: "db", TableName: "table"}}
+	require.NoError(t, IngestionMapping(`[{"column":"other"}]`, properties.CSV).Run(&props, QueuedClient, FromFile))
+
+	require.NoError(t, cache.validate(context.Background(), errors.OpFileIngest, &props))
+	assert.Zero(t, atomic.LoadInt32(&calls))
+}
+
+func TestStreamingSchemaValidation(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	client := schemaClient(t, &calls)
+	streamed := false
+	streaming := &Streaming{
+		db:      "db",
+		table:   "table",
+		client:  client,
+		schemas: newSchemaCache(client),
+		streamConn: fakeStreamIngestor{
+			onStreamIngest: func(context.Context, string, string, io.Reader, kusto.DataFormatForStreaming, string, string, bool) error {
+				streamed = true
+				return nil
+			},
+		},
+	}
+
+	_, err := streaming.FromReader(context.Background(), strings.NewReader("{}"), IngestionMappingRef("ref", properties.JSON), WithSchemaValidation())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "missing" does not exist`)
+	assert.False(t, streamed, "nothing should be sent when the validation fails")
+}
+
+func TestCreateTableIfNotExistsSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		schema  string
+		want    string
+		wantErr string
+	}{
+		{schema: "(id:long, name:string)", want: "id:long, name:string"},
+		{schema: " id : int64 ,ok:boolean ", want: "id:long, ok:bool"},
+		{schema: "(['my column']:string, [\"b:c\"]:dynamic)", want: "['my column']:string, [\"b:c\"]:dynamic"},
+		{schema: "()", wantErr: "the column list is empty"},
+		{schema: "(id)", wantErr: `column "id" has no type`},
+		{schema: "(id:number)", wantErr: `column "id" has an unknown type "number"`},
+		{schema: "(id:long, id:string)", wantErr: `column "id" is defined more than once`},
+		{schema: "(id:long,)", wantErr: "a column name is empty"},
+		{schema: "(my column:long)", wantErr: `the column name "my column" must be quoted`},
+		{schema: "(['id:long)", wantErr: "is not terminated"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.schema, func(t *testing.T) {
+			t.Parallel()
+
+			props := properties.All{}
+			err := WithCreateTableIfNotExists(test.schema).Run(&props, QueuedClient, FromFile)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, props.Source.CreateTableSchema)
+		})
+	}
+}
+
+func TestCreateTableIfNotExists(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	fail := false
+	client := mockClient{
+		endpoint: "https://test.kusto.windows.net",
+		onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+			atomic.AddInt32(&calls, 1)
+			assert.Equal(t, "db", db)
+			assert.Equal(t, ".create table table ifnotexists (id:long, name:string)", query.String())
+			if fail {
+				return nil, errors.ES(errors.OpMgmt, errors.KHTTPError, "forbidden")
+			}
+			iter, err := kusto.NewInMemoryRowIterator(table.Columns{{Name: "TableName", Type: types.String}}, nil, nil)
+			require.NoError(t, err)
+			return iter, nil
+		},
+	}
+	cache := newSchemaCache(client)
+	props := properties.All{Ingestion: properties.Ingestion{DatabaseName: "db", TableName: "table"}}
+	require.NoError(t, WithCreateTableIfNotExists("(id:long, name:string)").Run(&props, QueuedClient, FromFile))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, cache.prepare(context.Background(), errors.OpFileIngest, &props))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "parallel ingestions should share the command")
+
+	fail = true
+	failing := newSchemaCache(client)
+	err := failing.prepare(context.Background(), errors.OpFileIngest, &props)
+	require.Error(t, err)
+	assert.Equal(t, errors.OpMgmt, err.(*errors.Error).Op)
+	assert.Equal(t, errors.KHTTPError, err.(*errors.Error).Kind)
+	assert.Contains(t, err.Error(), `could not create table "table"`)
+}
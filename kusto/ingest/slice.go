@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	decimalType  = reflect.TypeOf(decimal.Decimal{})
+)
+
+// ValueMarshaler converts a value of a Go type into the string that FromSlice() writes for it in the ingestion payload.
+type ValueMarshaler func(v interface{}) (string, error)
+
+var (
+	marshalersMu sync.RWMutex
+	// marshalers are the ValueMarshaler of the types that are not written as their JSON encoding, by type.
+	marshalers = map[reflect.Type]ValueMarshaler{
+		timeType: func(v interface{}) (string, error) {
+			return v.(time.Time).Format(time.RFC3339Nano), nil
+		},
+		durationType: func(v interface{}) (string, error) {
+			return value.Timespan{Value: v.(time.Duration), Valid: true}.Marshal(), nil
+		},
+		// Decimals are written as strings in fixed point notation, as JSON numbers of float64 would lose precision.
+		decimalType: func(v interface{}) (string, error) {
+			return v.(decimal.Decimal).String(), nil
+		},
+	}
+)
+
+// RegisterValueMarshaler sets how FromSlice() writes the fields of type t, including pointers to t: the string that
+// f returns is written as a JSON string, which Kusto converts to the type of the column. It replaces the marshaler of t
+// if there is one, including the built-in ones of time.Time, time.Duration and decimal.Decimal, and a nil f removes it,
+// so the fields of type t are written as their JSON encoding. It is safe for concurrent use.
+func RegisterValueMarshaler(t reflect.Type, f ValueMarshaler) {
+	marshalersMu.Lock()
+	defer marshalersMu.Unlock()
+	if f == nil {
+		delete(marshalers, t)
+		return
+	}
+	marshalers[t] = f
+}
+
+// valueMarshaler returns the ValueMarshaler registered for t, if any.
+func valueMarshaler(t reflect.Type) (ValueMarshaler, bool) {
+	marshalersMu.RLock()
+	defer marshalersMu.RUnlock()
+	f, ok := marshalers[t]
+	return f, ok
+}
+
+// sliceField describes a struct field that will be written as a column of a record.
+type sliceField struct {
+	index  int
+	column string
+}
+
+// FromSlice ingests a slice of structs using the provided ingestor (queued or managed). Each struct is encoded as a
+// JSON record on its own line, and the data is ingested with the JSON format.
+// The rules for mapping a struct's exported fields into columns are:
+//
+//  1. If a field has a `kusto:"column_name"` tag, the field is written to the column 'column_name'. A special case is the
+//     `kusto:"-"` tag, which skips the field.
+//
+//  2. Otherwise, the field is written to a column with the same name as the field.
+//
+// time.Time fields are written as a Kusto datetime, time.Duration fields as a Kusto timespan, decimal.Decimal fields
+// as a Kusto decimal, without losing precision, and maps (such as map[string]any) as a Kusto dynamic. How other types
+// are written can be set with RegisterValueMarshaler().
+func FromSlice[T any](ctx context.Context, ingestor Ingestor, data []T, options ...FileOption) (*Result, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := sliceFields(t)
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeSlice(w, fields, data))
+	}()
+	defer r.Close()
+
+	return ingestor.FromReader(ctx, r, append([]FileOption{FileFormat(JSON)}, options...)...)
+}
+
+// sliceFields extracts the columns that the struct type t will be written to.
+func sliceFields(t reflect.Type) ([]sliceField, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromSlice() requires a slice of structs, got a slice of %s", t).SetNoRetry()
+	}
+
+	var fields []sliceField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		column := field.Name
+		if tag := strings.TrimSpace(field.Tag.Get("kusto")); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			column = tag
+		}
+		fields = append(fields, sliceField{index: i, column: column})
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromSlice() type %s has no exported fields to ingest", t).SetNoRetry()
+	}
+
+	return fields, nil
+}
+
+// writeSlice writes every element of data as a line delimited JSON record.
+func writeSlice[T any](w io.Writer, fields []sliceField, data []T) error {
+	enc := json.NewEncoder(w)
+	for i := range data {
+		v := reflect.ValueOf(data[i])
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromSlice() element %d is nil", i).SetNoRetry()
+			}
+			v = v.Elem()
+		}
+
+		rec := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			fv, err := sliceValue(v.Field(f.index))
+			if err != nil {
+				return errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromSlice() element %d column %q could not be marshaled: %s", i, f.column, err).SetNoRetry()
+			}
+			rec[f.column] = fv
+		}
+
+		if err := enc.Encode(rec); err != nil {
+			return errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromSlice() element %d could not be JSON encoded: %s", i, err).SetNoRetry()
+		}
+	}
+	return nil
+}
+
+// sliceValue converts a field into a value that JSON encodes the way Kusto expects for the column type.
+func sliceValue(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if f, ok := valueMarshaler(v.Type()); ok {
+		return f(v.Interface())
+	}
+	return v.Interface(), nil
+}
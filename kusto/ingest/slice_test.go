@@ -0,0 +1,123 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReaderIngestor struct {
+	data    []byte
+	options []FileOption
+}
+
+func (f *fakeReaderIngestor) Close() error {
+	return nil
+}
+
+func (f *fakeReaderIngestor) FromFile(context.Context, string, ...FileOption) (*Result, error) {
+	panic("not implemented")
+}
+
+func (f *fakeReaderIngestor) FromReader(_ context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	f.data = b
+	f.options = options
+	return newResult(), nil
+}
+
+type sliceRecord struct {
+	Name     string
+	Count    int64          `kusto:"count"`
+	Ignored  string         `kusto:"-"`
+	When     time.Time      `kusto:"timestamp"`
+	Duration time.Duration  `kusto:"elapsed"`
+	Props    map[string]any `kusto:"props"`
+	Optional *int32
+	private  string
+}
+
+func TestFromSlice(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)
+	data := []sliceRecord{
+		{
+			Name:     "first",
+			Count:    1,
+			Ignored:  "ignored",
+			When:     when,
+			Duration: 90 * time.Minute,
+			Props:    map[string]any{"a": 1},
+			private:  "private",
+		},
+		{
+			Name: "second",
+		},
+	}
+
+	fake := &fakeReaderIngestor{}
+	result, err := FromSlice(context.Background(), fake, data)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+
+	want := `{"Name":"first","Optional":null,"count":1,"elapsed":"01:30:00","props":{"a":1},"timestamp":"2023-01-02T03:04:05.000000006Z"}
+{"Name":"second","Optional":null,"count":0,"elapsed":"00:00:00","props":null,"timestamp":"0001-01-01T00:00:00Z"}
+`
+	assert.Equal(t, want, string(fake.data))
+	require.Len(t, fake.options, 1)
+	assert.Equal(t, "FileFormat", fake.options[0].String())
+
+	_, err = FromSlice(context.Background(), fake, []*sliceRecord{&data[1]})
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(fake.data, []byte(`{"Name":"second"`)))
+
+	_, err = FromSlice(context.Background(), fake, []int{1, 2})
+	assert.Error(t, err)
+}
+
+// celsius is registered with a custom marshaler by TestRegisterValueMarshaler.
+type celsius float64
+
+func TestRegisterValueMarshaler(t *testing.T) {
+	t.Parallel()
+
+	RegisterValueMarshaler(reflect.TypeOf(celsius(0)), func(v interface{}) (string, error) {
+		if v.(celsius) < -273.15 {
+			return "", fmt.Errorf("below absolute zero")
+		}
+		return fmt.Sprintf("%.1fC", float64(v.(celsius))), nil
+	})
+	t.Cleanup(func() { RegisterValueMarshaler(reflect.TypeOf(celsius(0)), nil) })
+
+	type record struct {
+		Price decimal.Decimal  `kusto:"price"`
+		Tax   *decimal.Decimal `kusto:"tax"`
+		Temp  celsius          `kusto:"temp"`
+	}
+	price := decimal.RequireFromString("12345678901234567890.123456789")
+	tax := decimal.New(1, -30)
+
+	fake := &fakeReaderIngestor{}
+	_, err := FromSlice(context.Background(), fake, []record{{Price: price, Tax: &tax, Temp: 21.5}, {Price: decimal.New(5, 3)}})
+	require.NoError(t, err)
+	want := `{"price":"12345678901234567890.123456789","tax":"0.000000000000000000000000000001","temp":"21.5C"}
+{"price":"5000","tax":null,"temp":"0.0C"}
+`
+	assert.Equal(t, want, string(fake.data))
+
+	_, err = FromSlice(context.Background(), fake, []record{{Temp: -300}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "temp" could not be marshaled: below absolute zero`)
+}
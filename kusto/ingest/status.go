@@ -36,6 +36,10 @@ const (
 	// Part of the data was successfully ingested to Kusto, while other parts failed.
 	PartiallySucceeded StatusCode = "PartiallySucceeded"
 
+	// DryRun status represents a permanent status.
+	// The ingestion was only validated because of the WithDryRun option, nothing was uploaded or ingested.
+	DryRun StatusCode = "DryRun"
+
 	// StatusRetrievalFailed means the client ran into truble reading the status from the service
 	StatusRetrievalFailed StatusCode = "StatusRetrievalFailed"
 	// StatusRetrievalCanceled means the user canceld the status check
@@ -50,7 +54,7 @@ func (i StatusCode) IsFinal() bool {
 // IsSuccess returns true if the status code is a final successfull status code
 func (i StatusCode) IsSuccess() bool {
 	switch i {
-	case Succeeded, Queued:
+	case Succeeded, Queued, DryRun:
 		return true
 
 	default:
@@ -2,10 +2,16 @@ package ingest
 
 import (
 	"bytes"
+	gz "compress/gzip"
 	"context"
 	"encoding/json"
+	goErrors "errors"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -15,6 +21,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/utils"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/google/uuid"
 )
 
@@ -29,6 +36,9 @@ type Streaming struct {
 	table      string
 	client     QueryClient
 	streamConn streamIngestor
+
+	instrumentation instrumentation
+	schemas         *schemaCache
 }
 
 type blobUri struct {
@@ -39,16 +49,23 @@ type blobUri struct {
 // More information can be found here:
 // https://docs.microsoft.com/en-us/azure/kusto/management/create-ingestion-mapping-command
 func NewStreaming(client QueryClient, db, table string) (*Streaming, error) {
-	streamConn, err := kusto.NewConn(removeIngestPrefix(client.Endpoint()), client.Auth(), client.HttpClient(), client.ClientDetails())
+	return newStreaming(client, db, table, client.HttpClient())
+}
+
+// newStreaming is the constructor for Streaming, sending the ingestions with httpClient.
+func newStreaming(client QueryClient, db, table string, httpClient *http.Client) (*Streaming, error) {
+	streamConn, err := kusto.NewConn(removeIngestPrefix(client.Endpoint()), client.Auth(), httpClient, client.ClientDetails())
 	if err != nil {
 		return nil, err
 	}
 
 	i := &Streaming{
-		db:         db,
-		table:      table,
-		client:     client,
-		streamConn: streamConn,
+		db:              db,
+		table:           table,
+		client:          client,
+		streamConn:      streamConn,
+		instrumentation: newInstrumentation(client),
+		schemas:         newSchemaCache(client),
 	}
 
 	return i, nil
@@ -57,19 +74,30 @@ func NewStreaming(client QueryClient, db, table string) (*Streaming, error) {
 // FromFile allows uploading a data file for Kusto from either a local path or a blobstore URI path.
 // This method is thread-safe.
 func (i *Streaming) FromFile(ctx context.Context, fPath string, options ...FileOption) (*Result, error) {
+	return i.instrumentation.run(ctx, "kusto.ingest.FromFile", "streaming", i.db, i.table, func(ctx context.Context) (*Result, error) {
+		return i.fromFile(ctx, fPath, options)
+	})
+}
+
+func (i *Streaming) fromFile(ctx context.Context, fPath string, options []FileOption) (*Result, error) {
 	props := i.newProp()
 	file, err, local := prepFileAndProps(fPath, &props, options, StreamingClient)
-
+	if file != nil {
+		defer file.Close()
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err := i.schemas.prepare(ctx, errors.OpIngestStream, &props); err != nil {
+		return nil, err
+	}
+
 	if !local {
-		return streamImpl(i.streamConn, ctx, generateBlobUriPayloadReader(fPath), props, true)
+		return streamPolling(i.streamConn, ctx, generateBlobUriPayloadReader(fPath), props, true)
 	}
 
-	defer file.Close()
-	return streamImpl(i.streamConn, ctx, file, props, false)
+	return streamPolling(i.streamConn, ctx, file, props, false)
 }
 
 // Returns the opened file, err, boolean indicator if its a local file
@@ -81,6 +109,13 @@ func prepFileAndProps(fPath string, props *properties.All, options []FileOption,
 			return nil, err, true
 		}
 	}
+	op := errors.OpFileIngest
+	if client == StreamingClient {
+		op = errors.OpIngestStream
+	}
+	if err := checkTarget(op, props); err != nil {
+		return nil, err, true
+	}
 
 	local, err := queued.IsLocalPath(fPath)
 	if err != nil {
@@ -98,19 +133,30 @@ func prepFileAndProps(fPath string, props *properties.All, options []FileOption,
 		return nil, err, true
 	}
 
-	props.Source.DontCompress = !queued.ShouldCompress(props, compression)
-
 	file, err := os.Open(fPath)
 	if err != nil {
 		return nil, err, true
 	}
+	size := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	props.Source.DontCompress = !queued.ShouldCompressSize(props, compression, size)
 	return file, nil, true
 }
 
 // FromReader allows uploading a data file for Kusto from an io.Reader. The content is uploaded to Blobstore and
-// ingested after all data in the reader is processed. Content should not use compression as the content will be
-// compressed with gzip. This method is thread-safe.
+// ingested after all data in the reader is processed. Content that is already gzip compressed is detected and sent
+// as is, other content is compressed with gzip unless DontCompress() is used or it is smaller than
+// WithCompressionMinBytes(). If no format is set, it is detected from the content with SniffFormat(), and is CSV if it
+// can't be. This method is thread-safe.
 func (i *Streaming) FromReader(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+	return i.instrumentation.run(ctx, "kusto.ingest.FromReader", "streaming", i.db, i.table, func(ctx context.Context) (*Result, error) {
+		return i.fromReader(ctx, reader, options)
+	})
+}
+
+func (i *Streaming) fromReader(ctx context.Context, reader io.Reader, options []FileOption) (*Result, error) {
 	props := i.newProp()
 
 	for _, prop := range options {
@@ -119,26 +165,272 @@ func (i *Streaming) FromReader(ctx context.Context, reader io.Reader, options ..
 			return nil, err
 		}
 	}
+	if err := checkTarget(errors.OpIngestStream, &props); err != nil {
+		return nil, err
+	}
+
+	reader = sniffReaderFormat(&props, reader)
+
+	if err := i.schemas.prepare(ctx, errors.OpIngestStream, &props); err != nil {
+		return nil, err
+	}
+
+	if props.Streaming.ChunkSize > 0 {
+		return streamChunked(i.streamConn, ctx, reader, props)
+	}
+
+	return streamPolling(i.streamConn, ctx, reader, props, false)
+}
+
+// streamChunkBackoff provides the retry policy for a single chunk. This allows tests to shorten the retry intervals.
+var streamChunkBackoff = func() backoff.BackOff {
+	exp := backoff.NewExponentialBackOff()
+	exp.InitialInterval = defaultInitialInterval
+	exp.Multiplier = defaultMultiplier
+	return exp
+}
+
+// streamChunked sends the reader as a series of streaming ingestions of at most props.Streaming.ChunkSize bytes. Chunks are
+// cut at the last newline so that records are not split between two requests, and a record longer than a chunk fails
+// with an errors.KLimitsExceeded error. A gzip compressed reader is decompressed first, so that it is cut between its
+// records. Only a failed chunk is retried.
+func streamChunked(c streamIngestor, ctx context.Context, reader io.Reader, props properties.All) (*Result, error) {
+	if props.Ingestion.Additional.Format == DFUnknown {
+		props.Ingestion.Additional.Format = CSV
+	}
+
+	// Binary formats are the ones we never compress, and they can't be split at arbitrary points.
+	if !props.Ingestion.Additional.Format.ShouldCompress() {
+		return nil, errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithStreamChunkSize() cannot be used with the binary format %s", props.Ingestion.Additional.Format).SetNoRetry()
+	}
+
+	reader, compressed, err := gzip.Peek(reader)
+	if err != nil {
+		return nil, errors.E(errors.OpFileIngest, errors.KIO, err)
+	}
+	if compressed {
+		zr, err := gz.NewReader(reader)
+		if err != nil {
+			return nil, errors.E(errors.OpFileIngest, errors.KIO, err).SetNoRetry()
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	baseRequestId := props.Streaming.ClientRequestId
+	buf := make([]byte, props.Streaming.ChunkSize)
+	var carry []byte
+	var offset int64
+
+	for chunkNum := 0; ; chunkNum++ {
+		n := copy(buf, carry)
+		carry = nil
+
+		eof := false
+		for n < len(buf) {
+			read, err := reader.Read(buf[n:])
+			n += read
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return nil, errors.E(errors.OpFileIngest, errors.KIO, fmt.Errorf("reader failed after %d bytes were ingested, at offset %d: %w", offset, offset+int64(n), err))
+			}
+		}
+
+		chunk := buf[:n]
+		if !eof {
+			idx := bytes.LastIndexByte(chunk, '\n')
+			if idx < 0 {
+				return nil, errors.ES(errors.OpFileIngest, errors.KLimitsExceeded,
+					"a record at offset %d is longer than the WithStreamChunkSize() of %d bytes", offset, len(buf)).SetNoRetry()
+			}
+			carry = append([]byte(nil), chunk[idx+1:]...)
+			chunk = chunk[:idx+1]
+		}
+
+		if len(chunk) > 0 {
+			chunkProps := props
+			chunkProps.Streaming.ClientRequestId = fmt.Sprintf("%s;%d", baseRequestId, chunkNum)
+
+			err := backoff.Retry(func() error {
+				_, err := streamPolling(c, ctx, bytes.NewReader(chunk), chunkProps, false)
+				if err != nil && !errors.Retry(err) {
+					return backoff.Permanent(err)
+				}
+				return err
+			}, backoff.WithContext(backoff.WithMaxRetries(streamChunkBackoff(), retryCount), ctx))
+			if err != nil {
+				return nil, err
+			}
+
+			offset += int64(len(chunk))
+		}
+
+		if eof {
+			break
+		}
+	}
+
+	result := newResult()
+	result.putProps(props)
+	result.record.Status = "Success"
+	result.bytesIngested = offset
+
+	return result, nil
+}
+
+// streamingEnableBackoff provides the retry policy of WithStreamingEnablePolling(). This allows tests to shorten the
+// retry intervals.
+var streamingEnableBackoff = func(timeout time.Duration) backoff.BackOff {
+	exp := backoff.NewExponentialBackOff()
+	exp.InitialInterval = time.Second
+	exp.MaxInterval = 30 * time.Second
+	exp.MaxElapsedTime = timeout
+	return exp
+}
+
+// streamingNotEnabledCode is the code of the error the service returns when streaming ingestion is not enabled on the
+// table, and streamingNotEnabledMessage is found in its message.
+const (
+	streamingNotEnabledCode    = "StreamingIngestionPolicyNotEnabled"
+	streamingNotEnabledMessage = "streaming ingestion policy is not enabled"
+)
+
+// isStreamingNotEnabled reports if err is the error the service returns when streaming ingestion is not enabled on
+// the table.
+func isStreamingNotEnabled(err error) bool {
+	e, ok := errors.GetKustoError(err)
+	if !ok || e.Kind != errors.KHTTPError {
+		return false
+	}
+	errMap, ok := e.UnmarshalREST()["error"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, key := range []string{"code", "@type"} {
+		if v, ok := errMap[key].(string); ok && strings.Contains(v, streamingNotEnabledCode) {
+			return true
+		}
+	}
+	for _, key := range []string{"@message", "message"} {
+		if v, ok := errMap[key].(string); ok && strings.Contains(strings.ToLower(v), streamingNotEnabledMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// errPayloadTooLarge is wrapped by the error of a streaming ingestion that the service refused as too large.
+var errPayloadTooLarge = goErrors.New("the payload is too large for streaming ingestion")
+
+// streamHTTPError returns the error of a streaming ingestion that the service failed with. Only timeouts, throttling
+// and server errors may be retried: other errors, such as an authorization failure, are not. A payload that is too
+// large wraps errPayloadTooLarge.
+func streamHTTPError(he *errors.HttpError) *errors.Error {
+	e := &he.KustoError
+	switch {
+	case he.StatusCode == http.StatusRequestEntityTooLarge:
+		return errors.E(errors.OpIngestStream, errors.KLimitsExceeded, fmt.Errorf("%w: %s", errPayloadTooLarge, e.Err)).SetNoRetry()
+	case he.StatusCode == http.StatusRequestTimeout, he.StatusCode == http.StatusTooManyRequests, he.StatusCode >= http.StatusInternalServerError:
+		return e
+	}
+	return e.SetNoRetry()
+}
+
+// streamPolling is streamImpl, retrying while streaming ingestion is not enabled on the table if
+// WithStreamingEnablePolling() was used.
+func streamPolling(c streamIngestor, ctx context.Context, payload io.Reader, props properties.All, isBlobUri bool) (*Result, error) {
+	timeout := props.Streaming.EnablePollingTimeout
+	if timeout <= 0 {
+		return streamImpl(c, ctx, payload, props, isBlobUri)
+	}
+
+	// The payload is buffered to be sent again, once compressed, so at most the streaming limit of it is buffered.
+	if !isBlobUri {
+		var err error
+		if payload, err = streamPayload(payload, props); err != nil {
+			return nil, err
+		}
+		if closer, ok := payload.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+	limit := streamingSizeLimit(props)
+	data, err := io.ReadAll(io.LimitReader(payload, limit+1))
+	if err != nil {
+		return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errTooLargeForStreaming(limit)
+	}
 
-	return streamImpl(i.streamConn, ctx, reader, props, false)
+	var result *Result
+	err = backoff.Retry(func() error {
+		var err error
+		result, err = streamImpl(c, ctx, bytes.NewReader(data), props, isBlobUri)
+		if err != nil && !isStreamingNotEnabled(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(streamingEnableBackoff(timeout), ctx))
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// streamPayload returns payload as it is sent to the streaming ingestion endpoint: compressed with gzip unless the options
+// or its size say otherwise. A payload that is already gzip compressed is sent as is, whatever the options say, so it
+// isn't compressed twice.
+func streamPayload(payload io.Reader, props properties.All) (io.Reader, error) {
+	peeked, compressed, err := gzip.Peek(payload)
+	if err != nil {
+		return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+	}
+	payload = peeked
+	if !compressed && queued.ShouldCompress(&props, ingestoptions.CTUnknown) {
+		var size int64
+		if payload, size, err = queued.PeekSize(&props, payload); err != nil {
+			return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+		}
+		if queued.ShouldCompressSize(&props, ingestoptions.CTUnknown, size) {
+			payload = gzip.CompressLevel(payload, props.Source.CompressionLevel)
+		}
+	}
+	return payload, nil
 }
 
 func streamImpl(c streamIngestor, ctx context.Context, payload io.Reader, props properties.All, isBlobUri bool) (*Result, error) {
-	compress := queued.ShouldCompress(&props, ingestoptions.CTUnknown)
-	if compress && !isBlobUri {
-		payload = gzip.Compress(payload)
+	if !isBlobUri {
+		var err error
+		if payload, err = streamPayload(payload, props); err != nil {
+			return nil, err
+		}
 	}
 
 	if props.Ingestion.Additional.Format == DFUnknown {
 		props.Ingestion.Additional.Format = CSV
 	}
 
+	if !props.Ingestion.Additional.MappingMatchesFormat() {
+		return nil, errors.ES(
+			errors.OpIngestStream,
+			errors.KClientArgs,
+			"ingestion mapping type %v does not match the format %v", props.Ingestion.Additional.IngestionMappingType, props.Ingestion.Additional.Format,
+		).SetNoRetry()
+	}
+
 	err := c.StreamIngest(ctx, props.Ingestion.DatabaseName, props.Ingestion.TableName, payload, props.Ingestion.Additional.Format,
 		props.Ingestion.Additional.IngestionMappingRef,
 		props.Streaming.ClientRequestId,
 		isBlobUri)
 
 	if err != nil {
+		if he, ok := err.(*errors.HttpError); ok {
+			return nil, streamHTTPError(he)
+		}
 		if e, ok := errors.GetKustoError(err); ok {
 			return nil, e
 		}
@@ -163,6 +455,9 @@ func (i *Streaming) newProp() properties.All {
 			DatabaseName: i.db,
 			TableName:    i.table,
 		},
+		Source: properties.SourceOptions{
+			CompressionMinBytes: DefaultCompressionMinBytes,
+		},
 		Streaming: properties.Streaming{
 			ClientRequestId: "KGC.executeStreaming;" + uuid.New().String(),
 		},
@@ -2,17 +2,22 @@ package ingest
 
 import (
 	"bytes"
+	gz "compress/gzip"
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/gzip"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/cenkalti/backoff/v4"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -201,6 +206,9 @@ func TestStreaming(t *testing.T) {
 				streamConn: streamIngestor,
 			}
 
+			// The test data is smaller than DefaultCompressionMinBytes, and the payloads are expected compressed.
+			test.options = append([]FileOption{WithCompressionMinBytes(0)}, test.options...)
+
 			result, err := streaming.FromFile(ctx, filePath, test.options...)
 			if test.expectedError != nil {
 				assert.Equal(t, test.expectedError, err)
@@ -223,3 +231,374 @@ func TestStreaming(t *testing.T) {
 	}
 
 }
+
+func TestStreamingPrecompressed(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("a,1\nb,2\n")
+	compressed := bytes.Buffer{}
+	zw := gz.NewWriter(&compressed)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	tests := []struct {
+		desc    string
+		payload []byte
+		options []FileOption
+		want    []byte
+	}{
+		{desc: "Gzip payload is not compressed again", payload: compressed.Bytes(), want: compressed.Bytes()},
+		{desc: "Gzip payload with DontCompress", payload: compressed.Bytes(), options: []FileOption{DontCompress()}, want: compressed.Bytes()},
+		{desc: "Plain payload with DontCompress is sent as is", payload: data, options: []FileOption{DontCompress()}, want: data},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var got []byte
+			streaming := Streaming{
+				db:    "db",
+				table: "table",
+				streamConn: fakeStreamIngestor{
+					onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+						var err error
+						got, err = io.ReadAll(payload)
+						return err
+					},
+				},
+			}
+
+			_, err := streaming.FromReader(context.Background(), bytes.NewReader(test.payload), test.options...)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestStreamingCompressionMinBytes(t *testing.T) {
+	t.Parallel()
+
+	small := []byte("a,1\nb,2\n")
+	large := bytes.Repeat([]byte("a,1\n"), DefaultCompressionMinBytes/4)
+
+	tests := []struct {
+		desc     string
+		payload  []byte
+		options  []FileOption
+		wantGzip bool
+	}{
+		{desc: "Small payload is sent as is", payload: small},
+		{desc: "Payload at the floor is compressed", payload: large, wantGzip: true},
+		{desc: "Lower floor", payload: small, options: []FileOption{WithCompressionMinBytes(len(small))}, wantGzip: true},
+		{desc: "No floor", payload: small, options: []FileOption{WithCompressionMinBytes(0)}, wantGzip: true},
+		{desc: "DontCompress wins", payload: large, options: []FileOption{WithCompressionMinBytes(0), DontCompress()}},
+		{desc: "Binary format wins", payload: large, options: []FileOption{WithCompressionMinBytes(0), FileFormat(Parquet)}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var got [][]byte
+			streaming := Streaming{
+				db:    "db",
+				table: "table",
+				streamConn: fakeStreamIngestor{
+					onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+						b, err := io.ReadAll(payload)
+						got = append(got, b)
+						return err
+					},
+				},
+			}
+
+			path := filepath.Join(t.TempDir(), "data.csv")
+			require.NoError(t, os.WriteFile(path, test.payload, 0644))
+
+			_, err := streaming.FromFile(context.Background(), path, test.options...)
+			require.NoError(t, err)
+			_, err = streaming.FromReader(context.Background(), bytes.NewReader(test.payload), test.options...)
+			require.NoError(t, err)
+
+			require.Len(t, got, 2)
+			for _, b := range got {
+				if !test.wantGzip {
+					assert.Equal(t, test.payload, b)
+					continue
+				}
+				zr, err := gz.NewReader(bytes.NewReader(b))
+				require.NoError(t, err)
+				raw, err := io.ReadAll(zr)
+				require.NoError(t, err)
+				assert.Equal(t, test.payload, raw)
+			}
+		})
+	}
+
+	_, err := (&Streaming{db: "db", table: "table"}).FromReader(context.Background(), bytes.NewReader(small), WithCompressionMinBytes(-1))
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+}
+
+type unexpectedEOFReader struct {
+	data []byte
+}
+
+func (u *unexpectedEOFReader) Read(p []byte) (int, error) {
+	if len(u.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, u.data)
+	u.data = u.data[n:]
+	return n, nil
+}
+
+func TestStreamingChunked(t *testing.T) {
+	origBackoff := streamChunkBackoff
+	streamChunkBackoff = func() backoff.BackOff {
+		return &backoff.ZeroBackOff{}
+	}
+	t.Cleanup(func() {
+		streamChunkBackoff = origBackoff
+	})
+
+	mockClient := mockClient{
+		endpoint: "https://test.kusto.windows.net",
+		auth:     kusto.Authorization{},
+	}
+	ctx := context.Background()
+	data := "a,1\nb,2\nc,3\nd,4\n"
+
+	var chunks []string
+	var requestIds []string
+	failures := 1
+	streamIngestor := fakeStreamIngestor{
+		onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+			zr, err := gz.NewReader(payload)
+			require.NoError(t, err)
+			b, err := io.ReadAll(zr)
+			require.NoError(t, err)
+
+			// Fail the second chunk once, to check that only it is retried.
+			if len(chunks) == 1 && failures > 0 {
+				failures--
+				return errors.E(errors.OpIngestStream, errors.KHTTPError, fmt.Errorf("transient"))
+			}
+			chunks = append(chunks, string(b))
+			requestIds = append(requestIds, clientRequestId)
+			return nil
+		},
+	}
+
+	streaming := Streaming{
+		db:         "defaultDb",
+		table:      "defaultTable",
+		client:     mockClient,
+		streamConn: streamIngestor,
+	}
+
+	result, err := streaming.FromReader(ctx, strings.NewReader(data), WithStreamChunkSize(9), ClientRequestId("id"), WithCompressionMinBytes(0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a,1\nb,2\n", "c,3\nd,4\n"}, chunks)
+	assert.Equal(t, []string{"id;0", "id;1"}, requestIds)
+	assert.Equal(t, int64(len(data)), result.BytesIngested())
+	assert.Equal(t, 0, failures)
+
+	// A compressed reader is cut between the records of its content.
+	chunks, requestIds = nil, nil
+	var zipped bytes.Buffer
+	zw := gz.NewWriter(&zipped)
+	_, err = zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	_, err = streaming.FromReader(ctx, &zipped, WithStreamChunkSize(9), FileFormat(CSV), WithCompressionMinBytes(0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a,1\nb,2\n", "c,3\nd,4\n"}, chunks)
+
+	// A record longer than a chunk is not split.
+	chunks = nil
+	_, err = streaming.FromReader(ctx, strings.NewReader("a,1\nlonger,2\n"), WithStreamChunkSize(5), WithCompressionMinBytes(0))
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KLimitsExceeded, e.Kind)
+	assert.Equal(t, []string{"a,1\n"}, chunks)
+
+	chunks = nil
+	_, err = streaming.FromReader(ctx, &unexpectedEOFReader{data: []byte(data)}, WithStreamChunkSize(100))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	e, ok = err.(*errors.Error)
+	require.True(t, ok)
+	assert.Equal(t, errors.OpFileIngest, e.Op)
+	assert.Contains(t, err.Error(), fmt.Sprintf("offset %d", len(data)))
+	assert.Empty(t, chunks)
+
+	_, err = streaming.FromReader(ctx, strings.NewReader(data), WithStreamChunkSize(9), FileFormat(Parquet))
+	assert.Error(t, err)
+
+	_, err = streaming.FromReader(ctx, strings.NewReader(data), WithStreamChunkSize(0))
+	assert.Error(t, err)
+}
+
+func TestStreamingEnablePolling(t *testing.T) {
+	origBackoff := streamingEnableBackoff
+	streamingEnableBackoff = func(time.Duration) backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 3)
+	}
+	t.Cleanup(func() {
+		streamingEnableBackoff = origBackoff
+	})
+
+	httpErr := func(code, message string) error {
+		body := fmt.Sprintf(`{"error":{"code":%q,"message":"Request is invalid and cannot be executed.","@message":%q}}`, code, message)
+		return errors.HTTP(errors.OpIngestStream, "400 Bad Request", http.StatusBadRequest, io.NopCloser(strings.NewReader(body)), "streaming ingestion failed")
+	}
+	notEnabled := httpErr("BadRequest_StreamingIngestionPolicyNotEnabled", "Streaming ingestion policy is not enabled for the table")
+
+	tests := []struct {
+		desc      string
+		failures  []error
+		wantCalls int
+		err       bool
+	}{
+		{desc: "Policy propagates", failures: []error{notEnabled, notEnabled}, wantCalls: 3},
+		{desc: "Other error", failures: []error{httpErr("BadRequest_EntityNotFound", "Table 'defaultTable' was not found")}, wantCalls: 1, err: true},
+		{desc: "Policy never propagates", failures: []error{notEnabled, notEnabled, notEnabled, notEnabled, notEnabled}, wantCalls: 4, err: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			var payloads []string
+			streaming := Streaming{
+				db:     "defaultDb",
+				table:  "defaultTable",
+				client: mockClient{endpoint: "https://test.kusto.windows.net"},
+				streamConn: fakeStreamIngestor{
+					onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+						zr, err := gz.NewReader(payload)
+						require.NoError(t, err)
+						b, err := io.ReadAll(zr)
+						require.NoError(t, err)
+						payloads = append(payloads, string(b))
+
+						if len(payloads) <= len(test.failures) {
+							return test.failures[len(payloads)-1]
+						}
+						return nil
+					},
+				},
+			}
+
+			_, err := streaming.FromReader(context.Background(), strings.NewReader("a,1\n"), WithStreamingEnablePolling(time.Minute), WithCompressionMinBytes(0))
+			if test.err {
+				require.Error(t, err)
+				assert.Equal(t, errors.KHTTPError, err.(*errors.Error).Kind)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, payloads, test.wantCalls)
+			for _, p := range payloads {
+				assert.Equal(t, "a,1\n", p, "every attempt should send the whole payload")
+			}
+		})
+	}
+
+	// Only up to the streaming limit is held in memory.
+	streaming := Streaming{
+		db:     "defaultDb",
+		table:  "defaultTable",
+		client: mockClient{endpoint: "https://test.kusto.windows.net"},
+		streamConn: fakeStreamIngestor{
+			onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format kusto.DataFormatForStreaming, mappingName string, clientRequestId string, isBlobUri bool) error {
+				t.Error("a payload larger than the streaming limit should not be sent")
+				return nil
+			},
+		},
+	}
+	large := bytes.NewReader(bytes.Repeat([]byte("a,1\n"), int(maxStreamingSize/4)+1))
+	_, err := streaming.FromReader(context.Background(), large, WithStreamingEnablePolling(time.Minute), DontCompress())
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KLimitsExceeded, e.Kind)
+
+	props := properties.All{}
+	assert.Error(t, WithStreamingEnablePolling(0).Run(&props, StreamingClient, FromReader))
+	assert.Error(t, WithStreamingEnablePolling(time.Minute).Run(&props, QueuedClient, FromReader))
+}
+
+func TestStreamingBinaryFormatWithoutExtension(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("PAR1 some parquet content PAR1")
+	fPath := filepath.Join(t.TempDir(), "upload123")
+	require.NoError(t, os.WriteFile(fPath, data, 0644))
+
+	var got []byte
+	var gotFormat kusto.DataFormatForStreaming
+	streaming := &Streaming{
+		db:    "db",
+		table: "table",
+		streamConn: fakeStreamIngestor{onStreamIngest: func(_ context.Context, _, _ string, payload io.Reader, format kusto.DataFormatForStreaming, _ string, _ string, _ bool) error {
+			gotFormat = format
+			var err error
+			got, err = io.ReadAll(payload)
+			return err
+		}},
+	}
+
+	_, err := streaming.FromFile(context.Background(), fPath, WithFileFormat(Parquet))
+	require.NoError(t, err)
+	assert.Equal(t, Parquet, gotFormat)
+	assert.Equal(t, data, got, "a binary format should be sent without being compressed")
+}
+
+func TestStreamingFromReaderSniffsFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		data    string
+		options []FileOption
+		want    DataFormat
+	}{
+		{desc: "JSON", data: "{\"a\":1}\n{\"a\":2}\n", want: JSON},
+		{desc: "TSV", data: "a\tb\nc\td\n", want: TSV},
+		{desc: "Ambiguous defaults to CSV", data: "a\nb\n", want: CSV},
+		{desc: "Explicit format", data: "{\"a\":1}\n", options: []FileOption{WithFileFormat(MultiJSON)}, want: MultiJSON},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var gotFormat kusto.DataFormatForStreaming
+			var got []byte
+			streaming := &Streaming{
+				db:    "db",
+				table: "table",
+				streamConn: fakeStreamIngestor{onStreamIngest: func(_ context.Context, _, _ string, payload io.Reader, format kusto.DataFormatForStreaming, _ string, _ string, _ bool) error {
+					gotFormat = format
+					zr, err := gz.NewReader(payload)
+					if err != nil {
+						return err
+					}
+					got, err = io.ReadAll(zr)
+					return err
+				}},
+			}
+
+			options := append([]FileOption{WithCompressionMinBytes(0)}, test.options...)
+			_, err := streaming.FromReader(context.Background(), strings.NewReader(test.data), options...)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, gotFormat)
+			assert.Equal(t, test.data, string(got))
+		})
+	}
+}
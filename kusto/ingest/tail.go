@@ -0,0 +1,230 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// DefaultTailPollInterval is how often TailFile() checks the file for new lines when WithTailPollInterval() is not used.
+const DefaultTailPollInterval = 1 * time.Second
+
+// tailer holds the state of a TailFile() call.
+type tailer struct {
+	ingestor     Ingestor
+	path         string
+	offset       int64
+	pollInterval time.Duration
+	maxBytes     int
+	onOffset     func(offset int64) error
+	fileOptions  []FileOption
+
+	file *os.File
+	// buf holds a batch read from the file. It is allocated on the first batch and reused by the next ones.
+	buf []byte
+}
+
+// TailOption is an optional argument to TailFile().
+type TailOption func(t *tailer)
+
+// WithStartOffset makes TailFile() start at offset, in bytes, instead of at the start of the file. It is usually the
+// last offset reported to the handler set by WithTailOffsetHandler(), and should be at the start of a line.
+func WithStartOffset(offset int64) TailOption {
+	return func(t *tailer) {
+		t.offset = offset
+	}
+}
+
+// WithTailPollInterval sets how often TailFile() checks the file for new lines. Defaults to DefaultTailPollInterval.
+func WithTailPollInterval(d time.Duration) TailOption {
+	return func(t *tailer) {
+		t.pollInterval = d
+	}
+}
+
+// WithTailBatchMaxBytes sets the largest batch of lines that TailFile() ingests at once. A line must fit in a batch.
+// Defaults to DefaultBatchMaxBytes.
+func WithTailBatchMaxBytes(n int) TailOption {
+	return func(t *tailer) {
+		t.maxBytes = n
+	}
+}
+
+// WithTailOffsetHandler sets a function that TailFile() calls with the offset of the first byte that is not ingested
+// yet, after every ingested batch and when the file is truncated or rotated. Persisting it and passing it to
+// WithStartOffset() makes a restarted TailFile() resume where the previous one stopped. If f returns an error,
+// TailFile() stops and returns it.
+func WithTailOffsetHandler(f func(offset int64) error) TailOption {
+	return func(t *tailer) {
+		t.onOffset = f
+	}
+}
+
+// WithTailFileOptions sets the options passed to FromReader() for every batch, such as the format or the mapping.
+func WithTailFileOptions(options ...FileOption) TailOption {
+	return func(t *tailer) {
+		t.fileOptions = options
+	}
+}
+
+// TailFile ingests the lines appended to the file at path, like "tail -f" would print them, until ctx is done. Every
+// WithTailPollInterval(), the complete lines appended since the last check are ingested with ingestor.FromReader(),
+// which is usually a *Streaming client and is not closed, in batches of at most WithTailBatchMaxBytes(). A last line
+// without a newline waits for it, so TailFile should only be used with line delimited formats (CSV, JSON, ...).
+// If the file is truncated, it is tailed from its start again. If it is rotated, that is, path is now another file,
+// the rest of the rotated file is ingested, and the new file is tailed from its start. A file that is truncated and
+// appended to past the last offset between two checks can't be told apart from a file that was only appended to.
+// TailFile returns the error of the context once it is done, or the first error of an ingestion, the file system or
+// the offset handler. The lines of a batch that failed are not reported as ingested, see WithTailOffsetHandler().
+func TailFile(ctx context.Context, ingestor Ingestor, path string, options ...TailOption) error {
+	if ingestor == nil {
+		return errors.ES(errors.OpIngestStream, errors.KClientArgs, "TailFile() requires an ingestor").SetNoRetry()
+	}
+
+	t := &tailer{
+		ingestor:     ingestor,
+		path:         path,
+		pollInterval: DefaultTailPollInterval,
+		maxBytes:     DefaultBatchMaxBytes,
+	}
+	for _, o := range options {
+		o(t)
+	}
+
+	if t.offset < 0 {
+		return errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithStartOffset() requires a non-negative offset, got %d", t.offset).SetNoRetry()
+	}
+	if t.pollInterval <= 0 {
+		return errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithTailPollInterval() requires a positive duration, got %s", t.pollInterval).SetNoRetry()
+	}
+	if t.maxBytes <= 0 {
+		return errors.ES(errors.OpIngestStream, errors.KClientArgs, "WithTailBatchMaxBytes() requires a positive size, got %d", t.maxBytes).SetNoRetry()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "TailFile() could not open %q: %s", path, err).SetNoRetry()
+	}
+	t.file = file
+	defer func() { t.file.Close() }()
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll ingests the lines appended to the file since the last call, and switches to the new file if it was rotated.
+func (t *tailer) poll(ctx context.Context) error {
+	info, err := t.file.Stat()
+	if err != nil {
+		return t.fsErr(err)
+	}
+	if info.Size() < t.offset {
+		if err := t.setOffset(0); err != nil {
+			return err
+		}
+	}
+	// The file is only read when it grew since the last call.
+	if info.Size() > t.offset {
+		if err := t.ingestLines(ctx, false); err != nil {
+			return err
+		}
+	}
+
+	current, err := os.Stat(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// The file was rotated, and the new one is not created yet.
+			return nil
+		}
+		return t.fsErr(err)
+	}
+	if os.SameFile(info, current) {
+		return nil
+	}
+
+	// The rotated file is complete, so its last line is ingested even without a newline.
+	if err := t.ingestLines(ctx, true); err != nil {
+		return err
+	}
+	file, err := os.Open(t.path)
+	if err != nil {
+		return t.fsErr(err)
+	}
+	t.file.Close()
+	t.file = file
+	if err := t.setOffset(0); err != nil {
+		return err
+	}
+	return t.ingestLines(ctx, false)
+}
+
+// ingestLines ingests the complete lines of the file after the offset, in batches of at most maxBytes. If final is
+// set, the last line is ingested even if it has no newline.
+func (t *tailer) ingestLines(ctx context.Context, final bool) error {
+	if t.buf == nil {
+		t.buf = make([]byte, t.maxBytes)
+	}
+	buf := t.buf
+	for {
+		n, err := t.file.ReadAt(buf, t.offset)
+		if err != nil && err != io.EOF {
+			return t.fsErr(err)
+		}
+		more := n == len(buf)
+
+		end := bytes.LastIndexByte(buf[:n], '\n') + 1
+		if final && !more {
+			end = n
+		}
+		if end == 0 {
+			if more {
+				return errors.ES(errors.OpIngestStream, errors.KLimitsExceeded,
+					"TailFile() found a line at offset %d of %q that is longer than the batch size of %d bytes, see WithTailBatchMaxBytes()",
+					t.offset, t.path, t.maxBytes).SetNoRetry()
+			}
+			return nil
+		}
+
+		if _, err := t.ingestor.FromReader(ctx, bytes.NewReader(buf[:end]), t.fileOptions...); err != nil {
+			return err
+		}
+		if err := t.setOffset(t.offset + int64(end)); err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// setOffset sets the offset of the first byte that is not ingested yet, and reports it to the offset handler.
+func (t *tailer) setOffset(offset int64) error {
+	t.offset = offset
+	if t.onOffset == nil {
+		return nil
+	}
+	return t.onOffset(offset)
+}
+
+func (t *tailer) fsErr(err error) error {
+	return errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "TailFile() could not read %q: %s", t.path, err).SetNoRetry()
+}
@@ -0,0 +1,226 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offsetRecorder records the offsets reported to the handler of WithTailOffsetHandler().
+type offsetRecorder struct {
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (r *offsetRecorder) handle(offset int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offsets = append(r.offsets, offset)
+	return nil
+}
+
+func (r *offsetRecorder) get() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.offsets...)
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// startTail runs TailFile() until the test ends, and returns a function that stops it and returns its error.
+func startTail(t *testing.T, ingestor Ingestor, path string, options ...TailOption) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- TailFile(ctx, ingestor, path, append([]TailOption{WithTailPollInterval(5 * time.Millisecond)}, options...)...)
+	}()
+
+	stopped := false
+	var err error
+	stop := func() error {
+		if !stopped {
+			cancel()
+			err = <-done
+			stopped = true
+		}
+		return err
+	}
+	t.Cleanup(func() { _ = stop() })
+	return stop
+}
+
+func waitPayloads(t *testing.T, rec *batchRecorder, want ...string) {
+	require.Eventually(t, func() bool { return len(rec.get()) >= len(want) }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, want, rec.get())
+}
+
+func TestTailFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a,1\nb,2\n")
+
+	rec := &batchRecorder{}
+	offsets := &offsetRecorder{}
+	stop := startTail(t, rec, path, WithTailOffsetHandler(offsets.handle))
+
+	waitPayloads(t, rec, "a,1\nb,2\n")
+
+	// A line without a newline waits for it.
+	appendFile(t, path, "c,3\nd,")
+	waitPayloads(t, rec, "a,1\nb,2\n", "c,3\n")
+	appendFile(t, path, "4\n")
+	waitPayloads(t, rec, "a,1\nb,2\n", "c,3\n", "d,4\n")
+
+	assert.Equal(t, context.Canceled, stop())
+	assert.Equal(t, []int64{8, 12, 16}, offsets.get())
+}
+
+func TestTailFileStartOffset(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a,1\nb,2\n")
+
+	rec := &batchRecorder{}
+	startTail(t, rec, path, WithStartOffset(4))
+	waitPayloads(t, rec, "b,2\n")
+}
+
+func TestTailFileBatches(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a,1\nb,2\nc,3\n")
+
+	rec := &batchRecorder{}
+	startTail(t, rec, path, WithTailBatchMaxBytes(9))
+	waitPayloads(t, rec, "a,1\nb,2\n", "c,3\n")
+}
+
+func TestTailFileTruncated(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a,1\nb,2\n")
+
+	rec := &batchRecorder{}
+	offsets := &offsetRecorder{}
+	startTail(t, rec, path, WithTailOffsetHandler(offsets.handle))
+	waitPayloads(t, rec, "a,1\nb,2\n")
+
+	require.NoError(t, os.Truncate(path, 0))
+	require.Eventually(t, func() bool { return len(offsets.get()) == 2 }, 5*time.Second, time.Millisecond)
+	appendFile(t, path, "c,3\n")
+	waitPayloads(t, rec, "a,1\nb,2\n", "c,3\n")
+	assert.Equal(t, []int64{8, 0, 4}, offsets.get())
+}
+
+func TestTailFileRotated(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "a,1\n")
+
+	rec := &batchRecorder{}
+	offsets := &offsetRecorder{}
+	startTail(t, rec, path, WithTailOffsetHandler(offsets.handle))
+	waitPayloads(t, rec, "a,1\n")
+
+	rotated := filepath.Join(dir, "app.log.1")
+	require.NoError(t, os.Rename(path, rotated))
+	appendFile(t, rotated, "b,2")
+	waitPayloads(t, rec, "a,1\n")
+
+	appendFile(t, path, "c,3\n")
+	waitPayloads(t, rec, "a,1\n", "b,2", "c,3\n")
+	assert.Equal(t, []int64{4, 7, 0, 4}, offsets.get())
+}
+
+func TestTailFileBuffer(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	rec := &batchRecorder{}
+	tl := &tailer{ingestor: rec, path: path, maxBytes: DefaultBatchMaxBytes, file: file}
+	require.NoError(t, tl.poll(context.Background()))
+	assert.Nil(t, tl.buf, "the buffer should not be allocated while the file does not grow")
+
+	appendFile(t, path, "a,1\n")
+	require.NoError(t, tl.poll(context.Background()))
+	require.Len(t, tl.buf, DefaultBatchMaxBytes)
+	buf := &tl.buf[0]
+
+	appendFile(t, path, "b,2\n")
+	require.NoError(t, tl.poll(context.Background()))
+	assert.Same(t, buf, &tl.buf[0], "the buffer should be reused by the next batches")
+	assert.Equal(t, []string{"a,1\n", "b,2\n"}, rec.get())
+}
+
+func TestTailFileErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "a,1\n")
+	long := filepath.Join(dir, "long.log")
+	appendFile(t, long, "a,123456789\n")
+
+	ctx := context.Background()
+	tests := []struct {
+		desc     string
+		ingestor Ingestor
+		path     string
+		options  []TailOption
+		wantKind errors.Kind
+	}{
+		{desc: "No ingestor", path: path, wantKind: errors.KClientArgs},
+		{desc: "Negative offset", ingestor: &batchRecorder{}, path: path, options: []TailOption{WithStartOffset(-1)}, wantKind: errors.KClientArgs},
+		{desc: "Poll interval", ingestor: &batchRecorder{}, path: path, options: []TailOption{WithTailPollInterval(0)}, wantKind: errors.KClientArgs},
+		{desc: "Batch size", ingestor: &batchRecorder{}, path: path, options: []TailOption{WithTailBatchMaxBytes(0)}, wantKind: errors.KClientArgs},
+		{desc: "Missing file", ingestor: &batchRecorder{}, path: filepath.Join(dir, "missing.log"), wantKind: errors.KLocalFileSystem},
+		{desc: "Line too long", ingestor: &batchRecorder{}, path: long, options: []TailOption{WithTailBatchMaxBytes(8)}, wantKind: errors.KLimitsExceeded},
+		{
+			desc:     "Ingestion failure",
+			ingestor: &batchRecorder{err: errors.ES(errors.OpIngestStream, errors.KHTTPError, "ingestion failed")},
+			path:     path,
+			wantKind: errors.KHTTPError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := TailFile(ctx, test.ingestor, test.path, test.options...)
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok, "got %v", err)
+			assert.Equal(t, test.wantKind, e.Kind)
+		})
+	}
+
+	handlerErr := fmt.Errorf("could not persist the offset")
+	err := TailFile(ctx, &batchRecorder{}, path, WithTailOffsetHandler(func(int64) error { return handlerErr }))
+	assert.Equal(t, handlerErr, err)
+}
@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"archive/tar"
+	gz "compress/gzip"
+	"context"
+	goErrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+)
+
+// FromTarGz ingests the files of the gzip compressed tar archive at the local path archive, each one the same way
+// FromReader would, without extracting the archive to disk. The data format and the compression of every file are
+// discovered from its name in the archive, unless set by the options, and its size in the archive is used as its raw
+// data size, see WithRawDataSize() and WithProgress(). Directories and other entries that are not regular files are
+// skipped, and WithGlob only ingests the files whose base name matches a pattern.
+// The archive is read sequentially, so every file is read while it is uploaded, and the rest of the ingestion of up to
+// a few files at once continues while the next ones are read. The results are returned in the order of the files in
+// the archive. Failures are handled like FromDir does: it stops at the first one unless WithContinueOnError is used.
+func (i *Ingestion) FromTarGz(ctx context.Context, archive string, options ...FileOption) ([]*Result, error) {
+	props := i.newProp()
+	for _, o := range options {
+		if err := o.Run(&props, QueuedClient, FromFile); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "could not open archive %q: %s", archive, err).SetNoRetry()
+	}
+	defer f.Close()
+
+	zr, err := gz.NewReader(f)
+	if err != nil {
+		return nil, errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "archive %q is not gzip compressed: %s", archive, err).SetNoRetry()
+	}
+	defer zr.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		results  []*Result
+		errs     []error
+		firstErr error
+		once     sync.Once
+	)
+	sem := make(chan struct{}, dirConcurrency)
+	wg := sync.WaitGroup{}
+
+	fail := func(err error) {
+		if !props.Dir.ContinueOnError {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+	}
+
+	tr := tar.NewReader(zr)
+	var archiveErr error
+	for ctx.Err() == nil {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			archiveErr = errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "could not read archive %q: %s", archive, err).SetNoRetry()
+			break
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if match, _ := filepath.Match(tarGlob(props.Dir), path.Base(hdr.Name)); !match {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		mu.Lock()
+		n := len(results)
+		results, errs = append(results, nil), append(errs, nil)
+		mu.Unlock()
+
+		entry := props
+		entry.Source.OriginalSource = hdr.Name
+		entry.Ingestion.RawDataSize = hdr.Size
+		if entry.Ingestion.Additional.Format == DFUnknown {
+			entry.Ingestion.Additional.Format = properties.DataFormatDiscovery(hdr.Name)
+		}
+
+		pr, pw := io.Pipe()
+		wg.Add(1)
+		go func(n int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res, err := i.instrumentation.run(ctx, "kusto.ingest.FromTarGz", "queued", i.db, i.table, func(ctx context.Context) (*Result, error) {
+				return i.fromReader(ctx, pr, nil, entry)
+			})
+			// Unblocks the copy of the entry if the ingestion did not read all of it.
+			pr.CloseWithError(io.ErrClosedPipe)
+
+			if err != nil {
+				err = tarEntryErr(archive, name, err)
+				fail(err)
+			}
+			mu.Lock()
+			results[n], errs[n] = res, err
+			mu.Unlock()
+		}(n, hdr.Name)
+
+		_, err = io.Copy(pw, tr)
+		pw.CloseWithError(err)
+		if err != nil && !goErrors.Is(err, io.ErrClosedPipe) {
+			archiveErr = errors.ES(errors.OpFileIngest, errors.KLocalFileSystem, "could not read file %q of archive %q: %s", hdr.Name, archive, err).SetNoRetry()
+			break
+		}
+	}
+	if archiveErr != nil {
+		fail(archiveErr)
+	}
+	wg.Wait()
+
+	var failed []error
+	if archiveErr != nil {
+		failed = append(failed, archiveErr)
+	}
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	switch {
+	case len(failed) == 0 && ctx.Err() != nil:
+		return nil, errors.E(errors.OpFileIngest, errors.KTimeout, ctx.Err())
+	case len(failed) == 0:
+		return results, nil
+	case props.Dir.ContinueOnError:
+		return results, errors.GetCombinedError(failed...)
+	case firstErr != nil:
+		return nil, firstErr
+	default:
+		return nil, failed[0]
+	}
+}
+
+// tarGlob returns the pattern set with WithGlob, or one that matches every name.
+func tarGlob(opts properties.Dir) string {
+	if opts.Glob == "" {
+		return "*"
+	}
+	return opts.Glob
+}
+
+// tarEntryErr annotates err with the file of the archive that failed to ingest.
+func tarEntryErr(archive, name string, err error) error {
+	return dirFileErr(fmt.Sprintf("%s:%s", archive, name), err)
+}
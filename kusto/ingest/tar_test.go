@@ -0,0 +1,181 @@
+package ingest
+
+import (
+	"archive/tar"
+	gz "compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTarGz writes a gzip compressed tar archive with the files, by name, and the directory "data/".
+func writeTarGz(t *testing.T, files map[string]string) string {
+	archive := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := gz.NewWriter(f)
+	tw := tar.NewWriter(zw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755}))
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))}))
+		_, err := io.WriteString(tw, files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+	return archive
+}
+
+func TestFromTarGz(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"data/a.csv":  "a,b\n",
+		"data/b.json": `{"a":1}` + "\n",
+		"data/c.csv":  "c,d\ne,f\n",
+	}
+	archive := writeTarGz(t, files)
+
+	tests := []struct {
+		desc    string
+		options []FileOption
+		failOn  string
+		want    []string
+		wantErr bool
+		wantNil []int
+	}{
+		{
+			desc: "All files",
+			want: []string{"data/a.csv", "data/b.json", "data/c.csv"},
+		},
+		{
+			desc:    "Glob",
+			options: []FileOption{WithGlob("*.csv")},
+			want:    []string{"data/a.csv", "data/c.csv"},
+		},
+		{
+			desc:    "Stop on error",
+			failOn:  "data/a.csv",
+			wantErr: true,
+		},
+		{
+			desc:    "Continue on error",
+			options: []FileOption{WithContinueOnError()},
+			failOn:  "data/b.json",
+			want:    []string{"data/a.csv", "data/b.json", "data/c.csv"},
+			wantErr: true,
+			wantNil: []int{1},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := mockClient{
+				endpoint: "https://test.kusto.windows.net",
+				auth:     kusto.Authorization{},
+				onMgmt: func(ctx context.Context, db string, query kusto.Statement, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+					if query.String() == ".get ingestion resources" {
+						return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+					}
+					return nil, nil
+				},
+			}
+			ingestion, err := New(client, "db", "table")
+			require.NoError(t, err)
+
+			mu := sync.Mutex{}
+			var ingested []string
+			ingestion.fs = resources.FsMock{
+				OnReader: func(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+					name := props.Source.OriginalSource
+					if name == test.failOn {
+						return "", errors.ES(errors.OpFileIngest, errors.KBlobstore, "upload failed").SetNoRetry()
+					}
+
+					data, err := io.ReadAll(reader)
+					require.NoError(t, err)
+					assert.Equal(t, files[name], string(data))
+					assert.Equal(t, int64(len(files[name])), props.Ingestion.RawDataSize)
+					assert.Equal(t, properties.DataFormatDiscovery(name), props.Ingestion.Additional.Format)
+
+					mu.Lock()
+					ingested = append(ingested, name)
+					mu.Unlock()
+					return name, nil
+				},
+			}
+
+			results, err := ingestion.FromTarGz(context.Background(), archive, test.options...)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.failOn)
+				if test.wantNil == nil {
+					e, ok := errors.GetKustoError(err)
+					require.True(t, ok)
+					assert.Equal(t, errors.KBlobstore, e.Kind)
+					assert.Nil(t, results)
+					return
+				}
+				assert.IsType(t, &errors.CombinedError{}, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Len(t, results, len(test.want))
+			for n, result := range results {
+				if len(test.wantNil) > 0 && test.wantNil[0] == n {
+					assert.Nil(t, result)
+					continue
+				}
+				require.NotNil(t, result)
+				assert.Equal(t, Queued, result.record.Status)
+				assert.Equal(t, test.want[n], result.record.IngestionSourcePath)
+			}
+			sort.Strings(ingested)
+			var succeeded []string
+			for _, name := range test.want {
+				if name != test.failOn {
+					succeeded = append(succeeded, name)
+				}
+			}
+			assert.Equal(t, succeeded, ingested)
+		})
+	}
+}
+
+func TestFromTarGzNotAnArchive(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "a.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n"), 0644))
+
+	ingestion, err := New(mockClient{endpoint: "https://test.kusto.windows.net"}, "db", "table")
+	require.NoError(t, err)
+
+	_, err = ingestion.FromTarGz(context.Background(), path)
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.KLocalFileSystem, e.Kind)
+}
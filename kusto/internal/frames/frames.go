@@ -79,6 +79,8 @@ type Frame interface {
 // where we encountered an error. Error implements error.
 type Error struct {
 	Msg string
+	// Kind is the errors.Kind of the error, if the decoder knows it.
+	Kind errors.Kind
 }
 
 // Error implements error.Error().
@@ -89,6 +91,17 @@ func (e Error) Error() string {
 // IsFrame implements Frame.IsFrame().
 func (Error) IsFrame() {}
 
+// Send sends fr on ch. If ctx is done before the frame is received, the frame is dropped and the context error is
+// returned, so that a decoder can stop and close the response body instead of blocking forever.
+func Send(ctx context.Context, ch chan Frame, fr Frame) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ch <- fr:
+		return nil
+	}
+}
+
 // Errorf write a frames.Error to ch with fmt.Sprint(s, a...).
 func Errorf(ctx context.Context, ch chan Frame, s string, a ...interface{}) {
 	select {
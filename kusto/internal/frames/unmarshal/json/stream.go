@@ -7,6 +7,7 @@ package json
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -22,8 +23,23 @@ type Decoder struct {
 
 	tokenState int
 	tokenStack []int
+
+	maxValue int64
+}
+
+// ValueTooLargeError is returned by Decode when a value is larger than the limit set with SetMaxValueBytes.
+type ValueTooLargeError struct {
+	Limit int64
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("json: value is larger than %d bytes", e.Limit)
 }
 
+// SetMaxValueBytes makes Decode fail with a *ValueTooLargeError, instead of buffering it, on a value that is
+// larger than n bytes. There is no limit if n is zero or less.
+func (dec *Decoder) SetMaxValueBytes(n int64) { dec.maxValue = n }
+
 // NewDecoder returns a new decoder that reads from r.
 //
 // The decoder introduces its own buffering and may
@@ -137,9 +153,17 @@ Input:
 		}
 
 		n := scanp - dec.scanp
+		if dec.maxValue > 0 && int64(n) > dec.maxValue {
+			dec.err = &ValueTooLargeError{Limit: dec.maxValue}
+			return 0, dec.err
+		}
 		err = dec.refill()
 		scanp = dec.scanp + n
 	}
+	if n := scanp - dec.scanp; dec.maxValue > 0 && int64(n) > dec.maxValue {
+		dec.err = &ValueTooLargeError{Limit: dec.maxValue}
+		return 0, dec.err
+	}
 	return scanp - dec.scanp, nil
 }
 
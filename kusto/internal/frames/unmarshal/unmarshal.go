@@ -77,7 +77,7 @@ func Rows(columns table.Columns, interRows []interface{}, op errors.Op) ([]value
 			case types.GUID:
 				v := value.GUID{}
 				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a GUID value: %s", col.Name, err)
+					return nil, nil, errors.ES(op, errors.KInternal, "unable to unmarshal column %s into a GUID value: %s", col.Name, err)
 				}
 				row[i] = v
 			case types.Int:
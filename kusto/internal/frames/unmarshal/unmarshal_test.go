@@ -1,6 +1,7 @@
 package unmarshal
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -61,3 +62,58 @@ func TestUnmarshalRows(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalRowsBadGUID(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := Rows(table.Columns{table.Column{Name: "id", Type: types.GUID}}, []interface{}{[]interface{}{"not-a-guid"}}, errors.OpQuery)
+	if err == nil {
+		t.Fatalf("TestUnmarshalRowsBadGUID: got err == nil, want err != nil")
+	}
+
+	if e, ok := err.(*errors.Error); !ok || e.Kind != errors.KInternal {
+		t.Fatalf("TestUnmarshalRowsBadGUID: got err == %v, want errors.KInternal", err)
+	}
+	if !strings.Contains(err.Error(), "column id") {
+		t.Errorf("TestUnmarshalRowsBadGUID: got err == %s, want it to name the column", err)
+	}
+}
+
+func TestUnmarshalRowsNullableBool(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "Ptr", Type: types.Bool}, {Name: "Kusto", Type: types.Bool}}
+	rows, _, err := Rows(columns, []interface{}{
+		[]interface{}{true, true},
+		[]interface{}{false, false},
+		[]interface{}{nil, nil},
+		[]interface{}{true, true},
+	}, errors.OpQuery)
+	if err != nil {
+		t.Fatalf("TestUnmarshalRowsNullableBool: got err == %s, want err == nil", err)
+	}
+
+	type rec struct {
+		Ptr   *bool
+		Kusto value.Bool
+	}
+	boolPtr := func(b bool) *bool { return &b }
+	want := []rec{
+		{Ptr: boolPtr(true), Kusto: value.Bool{Value: true, Valid: true}},
+		{Ptr: boolPtr(false), Kusto: value.Bool{Valid: true}},
+		{},
+		{Ptr: boolPtr(true), Kusto: value.Bool{Value: true, Valid: true}},
+	}
+
+	// The same struct is reused for every row, so a null must clear the value of the previous row.
+	got := rec{}
+	for i, values := range rows {
+		row := &table.Row{ColumnTypes: columns, Values: values, Op: errors.OpQuery}
+		if err := row.ToStruct(&got); err != nil {
+			t.Fatalf("TestUnmarshalRowsNullableBool(row %d): got err == %s, want err == nil", i, err)
+		}
+		if diff := pretty.Compare(want[i], got); diff != "" {
+			t.Errorf("TestUnmarshalRowsNullableBool(row %d): -want/+got:\n%s", i, diff)
+		}
+	}
+}
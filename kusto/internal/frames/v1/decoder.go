@@ -126,7 +126,9 @@ func (d *Decoder) processTables(ctx context.Context, ch chan frames.Frame) error
 		}
 		dt.Rows = nil
 
-		ch <- dt
+		if err := frames.Send(ctx, ch, dt); err != nil {
+			return err
+		}
 	}
 	return nil
 }
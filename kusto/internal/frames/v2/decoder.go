@@ -3,6 +3,7 @@ package v2
 import (
 	"bytes"
 	"context"
+	goErrors "errors"
 	"fmt"
 	"io"
 
@@ -14,6 +15,10 @@ import (
 
 // Decoder implements frames.Decoder on the REST v2 frames.
 type Decoder struct {
+	// MaxFrameBytes is the size of the largest frame the Decoder accepts. A larger frame ends the stream with a
+	// frames.Error of Kind errors.KLimitsExceeded. There is no limit if it is zero or less.
+	MaxFrameBytes int64
+
 	columns table.Columns
 	dec     *json.Decoder
 	op      errors.Op
@@ -26,6 +31,7 @@ func (d *Decoder) Decode(ctx context.Context, r io.ReadCloser, op errors.Op) cha
 	d.columns = nil
 	d.dec = json.NewDecoder(r)
 	d.dec.UseNumber()
+	d.dec.SetMaxValueBytes(d.MaxFrameBytes)
 	d.op = op
 
 	ch := make(chan frames.Frame, 1) // Channel is sized to 1. We read from the channel faster than we put on the channel.
@@ -54,10 +60,14 @@ func (d *Decoder) Decode(ctx context.Context, r io.ReadCloser, op errors.Op) cha
 			frames.Errorf(ctx, ch, "first frame had error: %s", err)
 			return
 		}
-		ch <- dsh
+		if err := frames.Send(ctx, ch, dsh); err != nil {
+			return
+		}
 
 		// Start decoding the rest of the frames.
-		d.decodeFrames(ctx, ch)
+		if !d.decodeFrames(ctx, ch) {
+			return
+		}
 
 		// Expect to recieve the end of our JSON list of frames, marked by the ']' delimiter.
 		t, err = d.dec.Token()
@@ -82,14 +92,22 @@ func (d *Decoder) dataSetHeader() (DataSetHeader, error) {
 	return dsh, err
 }
 
-// decodeFrames is used to decode incoming frames after the DataSetHeader has been received.
-func (d *Decoder) decodeFrames(ctx context.Context, ch chan frames.Frame) {
+// decodeFrames is used to decode incoming frames after the DataSetHeader has been received. It returns false if it
+// sent a frames.Error, which ends the stream.
+func (d *Decoder) decodeFrames(ctx context.Context, ch chan frames.Frame) bool {
 	for d.dec.More() {
 		if err := d.decode(ctx, ch); err != nil {
+			var tooLarge *json.ValueTooLargeError
+			if goErrors.As(err, &tooLarge) {
+				msg := fmt.Sprintf("a frame of the response is larger than the limit of %d bytes, see kusto.WithMaxFrameBytes()", tooLarge.Limit)
+				_ = frames.Send(ctx, ch, frames.Error{Msg: msg, Kind: errors.KLimitsExceeded})
+				return false
+			}
 			frames.Errorf(ctx, ch, err.Error())
-			return
+			return false
 		}
 	}
+	return true
 }
 
 var (
@@ -123,14 +141,14 @@ func (d *Decoder) decode(ctx context.Context, ch chan frames.Frame) error {
 			return err
 		}
 		dt.Op = d.op
-		ch <- dt
+		return frames.Send(ctx, ch, dt)
 	case bytes.Equal(ft, ftDataSetCompletion):
 		dc := DataSetCompletion{}
 		if err := dc.UnmarshalRaw(d.frameRaw); err != nil {
 			return err
 		}
 		dc.Op = d.op
-		ch <- dc
+		return frames.Send(ctx, ch, dc)
 	case bytes.Equal(ft, ftTableHeader):
 		th := TableHeader{}
 		if err := th.UnmarshalRaw(d.frameRaw); err != nil {
@@ -138,21 +156,21 @@ func (d *Decoder) decode(ctx context.Context, ch chan frames.Frame) error {
 		}
 		th.Op = d.op
 		d.columns = th.Columns
-		ch <- th
+		return frames.Send(ctx, ch, th)
 	case bytes.Equal(ft, ftTableFragment):
 		tf := TableFragment{Columns: d.columns}
 		if err := tf.UnmarshalRaw(d.frameRaw); err != nil {
 			return err
 		}
 		tf.Op = d.op
-		ch <- tf
+		return frames.Send(ctx, ch, tf)
 	case bytes.Equal(ft, ftTableProgress):
 		tp := TableProgress{}
 		if err := tp.UnmarshalRaw(d.frameRaw); err != nil {
 			return err
 		}
 		tp.Op = d.op
-		ch <- tp
+		return frames.Send(ctx, ch, tp)
 	case bytes.Equal(ft, ftTableCompletion):
 		tc := TableCompletion{}
 		if err := tc.UnmarshalRaw(d.frameRaw); err != nil {
@@ -160,11 +178,10 @@ func (d *Decoder) decode(ctx context.Context, ch chan frames.Frame) error {
 		}
 		tc.Op = d.op
 		d.columns = nil
-		ch <- tc
+		return frames.Send(ctx, ch, tc)
 	default:
 		return fmt.Errorf("received FrameType %s, which we did not expect", ft)
 	}
-	return nil
 }
 
 var (
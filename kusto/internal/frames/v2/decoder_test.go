@@ -11,6 +11,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
 	"github.com/stretchr/testify/require"
 
 	"github.com/google/uuid"
@@ -515,3 +516,71 @@ func timeMustParse(layout string, p string) time.Time {
 	}
 	return t
 }
+
+// closeRecorder is an io.ReadCloser that records when it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (c *closeRecorder) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestDecodeCancel(t *testing.T) {
+	t.Parallel()
+
+	jsonStr := `[
+  {"FrameType":"dataSetHeader","IsProgressive":true,"Version":"v2.0"},
+  {"FrameType":"TableHeader","TableId":0,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Name","ColumnType":"string"}]},
+  {"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":0,"Rows":[["a"]]},
+  {"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":0,"Rows":[["b"]]},
+  {"FrameType":"TableCompletion","TableId":0,"RowCount":2},
+  {"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}
+]`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body := &closeRecorder{Reader: strings.NewReader(jsonStr), closed: make(chan struct{})}
+
+	dec := Decoder{}
+	ch := dec.Decode(ctx, body, errors.OpQuery)
+
+	// Read the first frame and then stop reading, like a caller that stopped the iteration mid-stream.
+	<-ch
+	cancel()
+
+	select {
+	case <-body.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("TestDecodeCancel: the body was not closed after the context was cancelled")
+	}
+}
+
+func TestDecodeMaxFrameBytes(t *testing.T) {
+	t.Parallel()
+
+	huge := strings.Repeat("x", 4096)
+	jsonStr := `[
+  {"FrameType":"dataSetHeader","IsProgressive":true,"Version":"v2.0"},
+  {"FrameType":"TableHeader","TableId":0,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Name","ColumnType":"string"}]},
+  {"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":0,"Rows":[["a"]]},
+  {"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":0,"Rows":[["` + huge + `"]]},
+  {"FrameType":"TableCompletion","TableId":0,"RowCount":2},
+  {"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}
+]`
+
+	dec := Decoder{MaxFrameBytes: 1024}
+	ch := dec.Decode(context.Background(), io.NopCloser(strings.NewReader(jsonStr)), errors.OpQuery)
+
+	var got []frames.Frame
+	for fr := range ch {
+		got = append(got, fr)
+	}
+
+	require.Len(t, got, 4, "the frames before the large one should be decoded")
+	fErr, ok := got[3].(frames.Error)
+	require.True(t, ok, "got %T", got[3])
+	require.Equal(t, errors.KLimitsExceeded, fErr.Kind)
+	require.Contains(t, fErr.Msg, "1024 bytes")
+}
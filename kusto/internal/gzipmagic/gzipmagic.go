@@ -0,0 +1,22 @@
+// Package gzipmagic detects gzip compressed content from its first bytes. It is shared by the streaming ingestion of
+// the kusto package and the ingest clients, so that they agree on what is already compressed.
+package gzipmagic
+
+import (
+	"bufio"
+	"io"
+)
+
+// magic is the header every gzip stream starts with.
+var magic = []byte{0x1f, 0x8b}
+
+// Peek reports whether the content of payload is already gzip compressed, by looking for the gzip magic number in its
+// first two bytes. The returned reader must be used in place of payload, as it holds the bytes that were peeked.
+func Peek(payload io.Reader) (io.Reader, bool, error) {
+	br := bufio.NewReader(payload)
+	header, err := br.Peek(len(magic))
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	return br, len(header) == len(magic) && header[0] == magic[0] && header[1] == magic[1], nil
+}
@@ -0,0 +1,68 @@
+// Package tracing holds the OpenTelemetry helpers that the kusto and ingest packages use to create spans.
+// A nil trace.Tracer means tracing is disabled, in which case no span is created.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-kusto-go/kusto/internal/version"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer the spans are created with.
+const instrumentationName = "github.com/Azure/azure-kusto-go/kusto"
+
+// The attributes set on the spans.
+const (
+	Database        = attribute.Key("kusto.database")
+	Table           = attribute.Key("kusto.table")
+	Format          = attribute.Key("kusto.format")
+	BlobSize        = attribute.Key("kusto.blob_size")
+	ClientRequestID = attribute.Key("kusto.client_request_id")
+	IngestClient    = attribute.Key("kusto.ingest.client")
+)
+
+// propagator writes the W3C trace context of the outgoing requests.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the tracer of tp, or nil if tp is nil.
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		return nil
+	}
+	return tp.Tracer(instrumentationName, trace.WithInstrumentationVersion(version.Kusto))
+}
+
+// Start starts a client span named name with the attributes. If tracer is nil, it returns ctx and a nil span.
+func Start(ctx context.Context, tracer trace.Tracer, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it. It does nothing if span is nil.
+func End(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject adds the trace context of the span in ctx to the headers of an outgoing request. It does nothing if ctx
+// doesn't hold a valid span context.
+func Inject(ctx context.Context, header http.Header) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,171 @@
+package kusto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// jsonlFlushRows is the number of rows WriteJSONL() writes between two flushes of its buffer.
+const jsonlFlushRows = 1000
+
+// WriteJSONL writes the rows of the iterator to w as JSON Lines: one JSON object per row, keyed by column name in the
+// order of the columns. Values are mapped to JSON by type: int, long and real values are numbers, bool values are
+// booleans, dynamic values are embedded as JSON, datetime values are RFC 3339 strings, decimal values are strings so
+// they keep their precision, and null values are null. Real values that are NaN or infinite are written as the strings
+// "NaN", "Infinity" and "-Infinity", like the service does.
+// Rows are written as they are read and flushed periodically, so the result is never held in memory. If the query
+// fails, or is cancelled through its context, the rows read before the failure are written and the error is returned.
+// The RowIterator must not be used directly while WriteJSONL is in use.
+func (r *RowIterator) WriteJSONL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return errors.E(r.op, errors.KIO, err)
+		}
+		return nil
+	}
+
+	keys := make([][]byte, len(r.columns))
+	for i, col := range r.columns {
+		key, err := json.Marshal(col.Name)
+		if err != nil {
+			return errors.E(r.op, errors.KInternal, err)
+		}
+		keys[i] = key
+	}
+
+	line := bytes.Buffer{}
+	for n := 1; ; n++ {
+		row, err := r.Next()
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return ferr
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		line.Reset()
+		line.WriteByte('{')
+		for i, v := range row.Values {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			if i < len(keys) {
+				line.Write(keys[i])
+			} else {
+				line.WriteString(strconv.Quote(strconv.Itoa(i)))
+			}
+			line.WriteByte(':')
+			writeJSONValue(&line, v)
+		}
+		line.WriteString("}\n")
+
+		if _, err := bw.Write(line.Bytes()); err != nil {
+			return errors.E(r.op, errors.KIO, err)
+		}
+		if n%jsonlFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeJSONValue writes v to buf as a JSON value, for WriteJSONL().
+func writeJSONValue(buf *bytes.Buffer, v value.Kusto) {
+	switch v := v.(type) {
+	case value.Bool:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		buf.WriteString(strconv.FormatBool(v.Value))
+	case value.Int:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		buf.WriteString(strconv.FormatInt(int64(v.Value), 10))
+	case value.Long:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		buf.WriteString(strconv.FormatInt(v.Value, 10))
+	case value.Real:
+		switch {
+		case !v.Valid:
+			buf.WriteString("null")
+		case math.IsNaN(v.Value):
+			buf.WriteString(`"NaN"`)
+		case math.IsInf(v.Value, 1):
+			buf.WriteString(`"Infinity"`)
+		case math.IsInf(v.Value, -1):
+			buf.WriteString(`"-Infinity"`)
+		default:
+			buf.WriteString(strconv.FormatFloat(v.Value, 'g', -1, 64))
+		}
+	case value.DateTime:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		writeJSONString(buf, v.Value.Format(time.RFC3339Nano))
+	case value.Timespan:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		writeJSONString(buf, v.Marshal())
+	case value.Dynamic:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		n := buf.Len()
+		if err := json.Compact(buf, v.Value); err != nil {
+			// Not JSON, which only happens for values that weren't received from the service.
+			buf.Truncate(n)
+			writeJSONString(buf, string(v.Value))
+		}
+	case value.String:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		writeJSONString(buf, v.Value)
+	case value.GUID:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		writeJSONString(buf, v.Value.String())
+	case value.Decimal:
+		if !v.Valid {
+			buf.WriteString("null")
+			return
+		}
+		writeJSONString(buf, v.Value)
+	case nil:
+		buf.WriteString("null")
+	default:
+		writeJSONString(buf, v.String())
+	}
+}
+
+// writeJSONString writes s to buf as a JSON string.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s) // Marshaling a string never fails.
+	buf.Write(b)
+}
@@ -0,0 +1,91 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSONL(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{
+		{Name: "Name", Type: types.String},
+		{Name: "Count", Type: types.Long},
+		{Name: "Time", Type: types.DateTime},
+		{Name: "Bag", Type: types.Dynamic},
+		{Name: "Price", Type: types.Decimal},
+		{Name: "Ratio", Type: types.Real},
+		{Name: "Ok", Type: types.Bool},
+		{Name: "ID", Type: types.GUID},
+	}
+	id := uuid.MustParse("5f4a0e6c-3c8a-4f7e-9d40-1b9f6f0e1a2b")
+	rows := [][]value.Kusto{
+		{
+			value.String{Value: `say "hi"`, Valid: true},
+			value.Long{Value: 42, Valid: true},
+			value.DateTime{Value: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+			value.Dynamic{Value: []byte(`{ "a": [1, 2] }`), Valid: true},
+			value.Decimal{Value: "1.50", Valid: true},
+			value.Real{Value: 0.25, Valid: true},
+			value.Bool{Value: true, Valid: true},
+			value.GUID{Value: id, Valid: true},
+		},
+		{
+			value.String{},
+			value.Long{},
+			value.DateTime{},
+			value.Dynamic{},
+			value.Decimal{},
+			value.Real{Value: math.NaN(), Valid: true},
+			value.Bool{},
+			value.GUID{},
+		},
+	}
+	want := `{"Name":"say \"hi\"","Count":42,"Time":"2023-01-02T03:04:05Z","Bag":{"a":[1,2]},"Price":"1.50","Ratio":0.25,"Ok":true,"ID":"5f4a0e6c-3c8a-4f7e-9d40-1b9f6f0e1a2b"}` + "\n" +
+		`{"Name":null,"Count":null,"Time":null,"Bag":null,"Price":null,"Ratio":"NaN","Ok":null,"ID":null}` + "\n"
+
+	tests := []struct {
+		desc    string
+		iterErr error
+		wantErr bool
+	}{
+		{desc: "Success"},
+		{desc: "Rows written before the error", iterErr: errors.ES(errors.OpQuery, errors.KHTTPError, "stream failed"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter, err := NewInMemoryRowIterator(columns, rows, test.iterErr)
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			buf := &bytes.Buffer{}
+			err = iter.WriteJSONL(buf)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, want, buf.String())
+
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				assert.True(t, json.Valid([]byte(line)), line)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package kusto
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 
 	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 )
 
@@ -34,6 +38,8 @@ type ConnectionStringBuilder struct {
 	ApplicationForTracing            string
 	UserForTracing                   string
 	TokenCredential                  azcore.TokenCredential
+	// Cloud is the cloud to authenticate against, set with WithCloud(). If nil, it is discovered from the cluster.
+	Cloud *cloud.Configuration
 }
 
 const (
@@ -267,6 +273,29 @@ func (kcsb *ConnectionStringBuilder) WithKubernetesWorkloadIdentity(appId, token
 	return kcsb
 }
 
+// These are the environment variables set by the Azure Workload Identity webhook.
+const (
+	azureClientIDEnv           = "AZURE_CLIENT_ID"
+	azureTenantIDEnv           = "AZURE_TENANT_ID"
+	azureFederatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+)
+
+// WithWorkloadIdentity Creates a Kusto Connection string builder that will authenticate with federated workload identity,
+// exchanging the OIDC token in tokenFilePath for an AAD token. Empty arguments are taken from the AZURE_CLIENT_ID,
+// AZURE_TENANT_ID and AZURE_FEDERATED_TOKEN_FILE environment variables.
+func (kcsb *ConnectionStringBuilder) WithWorkloadIdentity(clientID, tenantID, tokenFilePath string) *ConnectionStringBuilder {
+	if isEmpty(clientID) {
+		clientID = os.Getenv(azureClientIDEnv)
+	}
+	if isEmpty(tenantID) {
+		tenantID = os.Getenv(azureTenantIDEnv)
+	}
+	if isEmpty(tokenFilePath) {
+		tokenFilePath = os.Getenv(azureFederatedTokenFileEnv)
+	}
+	return kcsb.WithKubernetesWorkloadIdentity(clientID, tokenFilePath, tenantID)
+}
+
 // WithInteractiveLogin Creates a Kusto Connection string builder that will authenticate by launching the system default browser
 // to interactively authenticate a user, and obtain an access token
 func (kcsb *ConnectionStringBuilder) WithInteractiveLogin(authorityID string) *ConnectionStringBuilder {
@@ -297,7 +326,14 @@ func (kcsb *ConnectionStringBuilder) WithDefaultAzureCredential() *ConnectionStr
 	return kcsb
 }
 
+// WithTokenCredential Creates a Kusto Connection string builder that will authenticate with the provided azcore.TokenCredential,
+// instead of one of the credentials the builder constructs. The credential is asked for a token for the Kusto resource scope,
+// as discovered from the cluster's metadata.
 func (kcsb *ConnectionStringBuilder) WithTokenCredential(tokenCredential azcore.TokenCredential) *ConnectionStringBuilder {
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	if tokenCredential == nil {
+		panic("Error: TokenCredential cannot be null")
+	}
 	kcsb.resetConnectionString()
 	kcsb.TokenCredential = tokenCredential
 	return kcsb
@@ -305,6 +341,12 @@ func (kcsb *ConnectionStringBuilder) WithTokenCredential(tokenCredential azcore.
 
 // Method to be used for generating TokenCredential
 func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error) {
+	if kcsb.Cloud != nil {
+		if err := validateCloud(kcsb.Cloud); err != nil {
+			return nil, err
+		}
+	}
+
 	tkp := &TokenProvider{}
 	tkp.tokenScheme = BEARER_TYPE
 
@@ -411,11 +453,11 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 
 			cred, err := azidentity.NewWorkloadIdentityCredential(opts)
 			if err != nil {
-				return nil, kustoErrors.E(kustoErrors.OpTokenProvider, kustoErrors.KOther,
+				return nil, kustoErrors.E(kustoErrors.OpTokenProvider, kustoErrors.KClientArgs,
 					fmt.Errorf("error: Couldn't retrieve client credentials using Workload Identity: %s", err))
 			}
 
-			return cred, nil
+			return workloadIdentityCredential{cred: cred}, nil
 		}
 	case !isEmpty(kcsb.UserToken):
 		{
@@ -479,6 +521,22 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 	return tkp, nil
 }
 
+// workloadIdentityCredential reports failures to acquire a token with workload identity as errors.KClientArgs, as they
+// are caused by a misconfigured client id, tenant or token file.
+type workloadIdentityCredential struct {
+	cred azcore.TokenCredential
+}
+
+// GetToken implements azcore.TokenCredential.
+func (w workloadIdentityCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := w.cred.GetToken(ctx, opts)
+	if err != nil {
+		return token, kustoErrors.E(kustoErrors.OpTokenProvider, kustoErrors.KClientArgs,
+			fmt.Errorf("error: Couldn't acquire a token using Workload Identity: %s", err))
+	}
+	return token, nil
+}
+
 func isEmpty(str string) bool {
 	return strings.TrimSpace(str) == ""
 }
@@ -1,9 +1,16 @@
 package kusto
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/tj/assert"
 )
 
@@ -121,6 +128,44 @@ func TestWithWorkloadIdentity(t *testing.T) {
 	assert.EqualValues(t, want, *actual)
 }
 
+func TestWithWorkloadIdentityEnv(t *testing.T) {
+	t.Setenv("AZURE_CLIENT_ID", "envClientID")
+	t.Setenv("AZURE_TENANT_ID", "envTenantID")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "envTokenFile")
+
+	want := ConnectionStringBuilder{
+		DataSource:              "endpoint",
+		ApplicationClientId:     "envClientID",
+		AuthorityId:             "envTenantID",
+		FederationTokenFilePath: "envTokenFile",
+		WorkloadAuthentication:  true,
+	}
+	actual := NewConnectionStringBuilder("endpoint").WithWorkloadIdentity("", "", "")
+	assert.EqualValues(t, want, *actual)
+
+	want.ApplicationClientId = "clientID"
+	want.AuthorityId = "tenantID"
+	want.FederationTokenFilePath = "tokenfilepath"
+	actual = NewConnectionStringBuilder("endpoint").WithWorkloadIdentity("clientID", "tenantID", "tokenfilepath")
+	assert.EqualValues(t, want, *actual)
+}
+
+type failingCredential struct{}
+
+func (failingCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{}, fmt.Errorf("AADSTS700024: Client assertion is not within its valid time range")
+}
+
+func TestWorkloadIdentityCredentialError(t *testing.T) {
+	_, err := workloadIdentityCredential{cred: failingCredential{}}.GetToken(context.Background(), policy.TokenRequestOptions{})
+	assert.Error(t, err)
+
+	e, ok := err.(*errors.Error)
+	assert.True(t, ok)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+	assert.Contains(t, err.Error(), "AADSTS700024")
+}
+
 func TestWitAadUserTokenErr(t *testing.T) {
 	defer func() {
 		if res := recover(); res == nil {
@@ -215,3 +260,86 @@ func TestGetTokenProviderHappy(t *testing.T) {
 	}
 
 }
+
+type recordingCredential struct {
+	scopes []string
+}
+
+func (r *recordingCredential) GetToken(_ context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	r.scopes = opts.Scopes
+	return azcore.AccessToken{Token: "customtoken"}, nil
+}
+
+func TestWithTokenCredential(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNotFound) }))
+	defer s.Close()
+
+	cred := &recordingCredential{}
+	kcsb := NewConnectionStringBuilder(s.URL).WithAadAppKey("clientID", "key", "tenantID").WithTokenCredential(cred)
+	assert.Equal(t, "", kcsb.ApplicationKey)
+
+	tkp, err := kcsb.newTokenProvider()
+	assert.NoError(t, err)
+	tkp.SetHttp(s.Client())
+
+	token, scheme, err := tkp.AcquireToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "customtoken", token)
+	assert.Equal(t, BEARER_TYPE, scheme)
+	assert.Equal(t, []string{defaultKustoServiceResourceId + "/.default"}, cred.scopes)
+}
+
+func TestWithTokenCredentialNil(t *testing.T) {
+	assert.PanicsWithValue(t, "Error: TokenCredential cannot be null", func() {
+		NewConnectionStringBuilder("endpoint").WithTokenCredential(nil)
+	})
+}
+
+func TestWithCloud(t *testing.T) {
+	metadataCalls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		metadataCalls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	cred := &recordingCredential{}
+	kcsb := NewConnectionStringBuilder(s.URL).WithCloud(AzureGovernmentCloud).WithTokenCredential(cred)
+	assert.Equal(t, &AzureGovernmentCloud, kcsb.Cloud, "setting the authentication should keep the cloud")
+
+	tkp, err := kcsb.newTokenProvider()
+	assert.NoError(t, err)
+	tkp.SetHttp(s.Client())
+
+	_, _, err = tkp.AcquireToken(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://kusto.kusto.usgovcloudapi.net/.default"}, cred.scopes)
+	assert.Equal(t, 0, metadataCalls, "the cloud should not be discovered")
+
+	ci, cliOpts, _, err := getCommonCloudInfo(kcsb, s.Client, DefaultCloudInfoTTL, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://login.microsoftonline.us", ci.LoginEndpoint)
+	assert.Equal(t, "https://login.microsoftonline.us/", cliOpts.Cloud.ActiveDirectoryAuthorityHost)
+}
+
+func TestWithCloudInvalid(t *testing.T) {
+	tests := []struct {
+		desc  string
+		cloud cloud.Configuration
+	}{
+		{desc: "No authority host", cloud: cloud.Configuration{Services: AzureChinaCloud.Services}},
+		{desc: "Authority host is not a URL", cloud: cloud.Configuration{ActiveDirectoryAuthorityHost: "login", Services: AzureChinaCloud.Services}},
+		{desc: "No Kusto service", cloud: cloud.AzureChina},
+		{desc: "No Kusto audience", cloud: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: AzureChinaCloud.ActiveDirectoryAuthorityHost,
+			Services:                     map[cloud.ServiceName]cloud.ServiceConfiguration{KustoService: {}},
+		}},
+	}
+
+	for _, test := range tests {
+		_, err := New(NewConnectionStringBuilder("https://help.kusto.windows.net").WithCloud(test.cloud).WithAzCli())
+		e, ok := errors.GetKustoError(err)
+		assert.True(t, ok, "%s: expected errors.Error, got %v", test.desc, err)
+		assert.Equal(t, errors.KClientArgs, e.Kind, test.desc)
+	}
+}
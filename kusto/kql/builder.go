@@ -24,7 +24,13 @@ type Builder struct {
 	builder strings.Builder
 }
 
-func New(value stringConstant) *Builder {
+// New returns a Builder for the query value. If args are given, the placeholders of value, such as {table}, are
+// replaced with them in order, so args are typically made with Ident() and Literal(). Braces in string literals are
+// not placeholders. It panics if the number of placeholders is not the number of args.
+func New(value stringConstant, args ...stringConstant) *Builder {
+	if len(args) > 0 {
+		value = stringConstant(interpolate(value.String(), args))
+	}
 	return (&Builder{
 		builder: strings.Builder{},
 	}).AddLiteral(value)
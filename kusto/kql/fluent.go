@@ -0,0 +1,188 @@
+package kql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// Query is a minimal fluent builder for the common tabular operators of KQL.
+// Each method appends a pipe operator to the query, and the fragments are validated when Build() is called:
+//
+//	stmt, err := kql.Table("Events").Where("Level == 'Error'").Project("Timestamp", "Message").Take(100).Build()
+//
+// Table and column names are normalized, while predicates and expressions are added as written and are only checked
+// for obviously malformed input (unbalanced quotes or brackets, a pipe or a statement separator outside of a string).
+// Values that come from users should be added with a Builder instead.
+type Query struct {
+	table     string
+	operators []operator
+}
+
+// operator is a single piped operator, such as "where", with its text and the fragments that must be validated.
+type operator struct {
+	name      string
+	text      string
+	fragments []string
+	err       error
+}
+
+// Table starts a new Query that reads from the table name.
+func Table(name string) *Query {
+	return &Query{table: name}
+}
+
+// Where appends a "where" operator filtering with predicate.
+func (q *Query) Where(predicate string) *Query {
+	return q.add(operator{name: "where", text: predicate, fragments: []string{predicate}})
+}
+
+// Project appends a "project" operator that keeps the columns.
+func (q *Query) Project(columns ...string) *Query {
+	return q.add(columnsOperator("project", columns))
+}
+
+// Extend appends an "extend" operator with the expressions, such as "Duration = EndTime - StartTime".
+func (q *Query) Extend(expressions ...string) *Query {
+	return q.add(operator{name: "extend", text: strings.Join(expressions, ", "), fragments: expressions})
+}
+
+// Summarize appends a "summarize" operator with the aggregation, such as "count()", grouped by the columns.
+func (q *Query) Summarize(aggregation string, by ...string) *Query {
+	op := operator{name: "summarize", text: aggregation, fragments: []string{aggregation}}
+	if len(by) > 0 {
+		byOp := columnsOperator("summarize", by)
+		op.text += " by " + byOp.text
+		op.err = byOp.err
+	}
+	return q.add(op)
+}
+
+// Take appends a "take" operator returning at most n rows.
+func (q *Query) Take(n int) *Query {
+	op := operator{name: "take", text: strconv.Itoa(n)}
+	if n < 0 {
+		op.err = fmt.Errorf("take cannot be negative, was %d", n)
+	}
+	return q.add(op)
+}
+
+// OrderBy appends an "order by" operator with the expressions, such as "Timestamp desc".
+func (q *Query) OrderBy(expressions ...string) *Query {
+	return q.add(operator{name: "order by", text: strings.Join(expressions, ", "), fragments: expressions})
+}
+
+// String implements fmt.Stringer. It does not validate the query, use Build() for that.
+func (q *Query) String() string {
+	var sb strings.Builder
+	sb.WriteString(NormalizeName(q.table))
+	for _, op := range q.operators {
+		sb.WriteString("\n| ")
+		sb.WriteString(op.name)
+		sb.WriteString(" ")
+		sb.WriteString(op.text)
+	}
+	return sb.String()
+}
+
+// Build validates the query and returns it as a Builder, which implements kusto.Statement and can be passed to Query().
+func (q *Query) Build() (*Builder, error) {
+	if strings.TrimSpace(q.table) == "" {
+		return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "kql.Table() requires a table name").SetNoRetry()
+	}
+
+	for i, op := range q.operators {
+		if op.err != nil {
+			return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "operator %d(%s) is invalid: %s", i, op.name, op.err).SetNoRetry()
+		}
+		if strings.TrimSpace(op.text) == "" {
+			return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "operator %d(%s) is empty", i, op.name).SetNoRetry()
+		}
+		for _, f := range op.fragments {
+			if err := validateFragment(f); err != nil {
+				return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "operator %d(%s) has a malformed fragment %q: %s", i, op.name, f, err).SetNoRetry()
+			}
+		}
+	}
+
+	return New(stringConstant(q.String())), nil
+}
+
+func (q *Query) add(op operator) *Query {
+	q.operators = append(q.operators, op)
+	return q
+}
+
+// columnsOperator builds an operator out of a list of column names.
+func columnsOperator(name string, columns []string) operator {
+	op := operator{name: name}
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if strings.TrimSpace(c) == "" {
+			op.err = fmt.Errorf("column names cannot be empty")
+		}
+		names = append(names, NormalizeName(c))
+	}
+	op.text = strings.Join(names, ", ")
+	return op
+}
+
+var closing = map[rune]rune{')': '(', ']': '[', '}': '{'}
+
+// validateFragment checks that a fragment has balanced quotes and brackets and that it cannot end the
+// current operator, either with a pipe, a statement separator or a comment.
+func validateFragment(f string) error {
+	if strings.TrimSpace(f) == "" {
+		return fmt.Errorf("fragment is empty")
+	}
+
+	var (
+		stack    []rune
+		quote    rune
+		verbatim bool
+	)
+	runes := []rune(f)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if quote != 0 {
+			switch {
+			case c == '\\' && !verbatim:
+				i++
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"':
+			quote = c
+			verbatim = i > 0 && runes[i-1] == '@'
+		case '(', '[', '{':
+			stack = append(stack, c)
+		case ')', ']', '}':
+			if len(stack) == 0 || stack[len(stack)-1] != closing[c] {
+				return fmt.Errorf("unbalanced %q at position %d", c, i)
+			}
+			stack = stack[:len(stack)-1]
+		case '|':
+			return fmt.Errorf("pipe at position %d is not allowed, use another operator method", i)
+		case ';':
+			return fmt.Errorf("statement separator at position %d is not allowed", i)
+		case '/':
+			if i+1 < len(runes) && runes[i+1] == '/' {
+				return fmt.Errorf("comment at position %d is not allowed", i)
+			}
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("unterminated string literal")
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("unbalanced %q", stack[len(stack)-1])
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package kql
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		q        *Query
+		expected string
+	}{
+		{
+			"Test table only",
+			Table("Events"),
+			"Events",
+		},
+		{
+			"Test where project take",
+			Table("Events").Where("Level == 'Error'").Project("Timestamp", "Message").Take(100),
+			"Events\n| where Level == 'Error'\n| project Timestamp, Message\n| take 100",
+		},
+		{
+			"Test extend summarize order",
+			Table("My Events").Extend("Duration = EndTime - StartTime").Summarize("avg(Duration)", "Region", "Service Name").OrderBy("avg_Duration desc"),
+			"[\"My Events\"]\n| extend Duration = EndTime - StartTime\n| summarize avg(Duration) by Region, [\"Service Name\"]\n| order by avg_Duration desc",
+		},
+		{
+			"Test pipe inside string",
+			Table("Events").Where(`Message has "a|b;c//d" and Name == 'it\'s'`),
+			"Events\n| where Message has \"a|b;c//d\" and Name == 'it\\'s'",
+		},
+		{
+			"Test verbatim string",
+			Table("Events").Where(`Path == @'C:\temp'`),
+			"Events\n| where Path == @'C:\\temp'",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := test.q.Build()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, b.String())
+		})
+	}
+}
+
+func TestQueryBuildErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		q    *Query
+	}{
+		{"Empty table", Table("")},
+		{"Unbalanced quote", Table("Events").Where("Level == 'Error")},
+		{"Unbalanced paren", Table("Events").Where("(Level == 'Error'")},
+		{"Mismatched brackets", Table("Events").Extend("x = pack_array(1, 2]")},
+		{"Extra closing paren", Table("Events").Summarize("count())")},
+		{"Pipe injection", Table("Events").Where("true | take 1")},
+		{"Statement separator", Table("Events").Where("true; .drop table Events")},
+		{"Comment", Table("Events").Where("true // comment")},
+		{"Empty where", Table("Events").Where(" ")},
+		{"Empty project", Table("Events").Project()},
+		{"Empty column", Table("Events").Project("Timestamp", "")},
+		{"Negative take", Table("Events").Take(-1)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := test.q.Build()
+			require.Error(t, err)
+			e, ok := err.(*errors.Error)
+			require.True(t, ok)
+			assert.Equal(t, errors.KClientArgs, e.Kind)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package kql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Ident returns the name of an entity, such as a table, a column or a function, to use in a query built with New() or
+// AddLiteral(). Names that are not made only of letters, digits and underscores, or that start with a digit, are
+// quoted, so that a name from user input can't change the query. It panics if name is empty.
+func Ident(name string) stringConstant {
+	if name == "" {
+		panic("Invalid identifier. Cannot add an empty identifier.")
+	}
+	if RequiresQuoting(name) || unicode.IsDigit([]rune(name)[0]) {
+		return stringConstant("[" + QuoteString(name, false) + "]")
+	}
+	return stringConstant(name)
+}
+
+// Literal returns v as a KQL literal, escaped so that it can't change the query, to use in a query built with New()
+// or AddLiteral(). v can be a string, a bool, a signed or unsigned integer, which is a long literal unless it is an
+// int32, a float32 or float64, a time.Time, a time.Duration, a uuid.UUID or a decimal.Decimal. Prefer query parameters,
+// see NewParameters(), which the service also caches the query plans of. Literal panics on other types.
+func Literal(v interface{}) stringConstant {
+	var value Value
+	switch v := v.(type) {
+	case string:
+		value = newValue(v, types.String)
+	case bool:
+		value = newValue(v, types.Bool)
+	case int32:
+		value = newValue(v, types.Int)
+	case int, int8, int16, int64, uint8, uint16, uint32:
+		value = newValue(v, types.Long)
+	case uint, uint64:
+		if reflect.ValueOf(v).Uint() > math.MaxInt64 {
+			panic(fmt.Sprintf("Invalid literal. %d overflows a long.", v))
+		}
+		value = newValue(v, types.Long)
+	case float32:
+		return realLiteral(float64(v))
+	case float64:
+		return realLiteral(v)
+	case time.Time:
+		value = newValue(v, types.DateTime)
+	case time.Duration:
+		value = newValue(v, types.Timespan)
+	case uuid.UUID:
+		value = newValue(v, types.GUID)
+	case decimal.Decimal:
+		value = newValue(v, types.Decimal)
+	default:
+		panic(fmt.Sprintf("Invalid literal. Cannot add a literal of type %T.", v))
+	}
+	return stringConstant(value.String())
+}
+
+// realLiteral returns f as a real literal, including the special values.
+func realLiteral(f float64) stringConstant {
+	switch {
+	case math.IsNaN(f):
+		return "real(nan)"
+	case math.IsInf(f, 1):
+		return "real(+inf)"
+	case math.IsInf(f, -1):
+		return "real(-inf)"
+	}
+	return stringConstant(newValue(f, types.Real).String())
+}
+
+// interpolate replaces the placeholders of query, such as {table}, with args, in order. Braces in string literals are
+// kept as is. It panics if the number of placeholders is not the number of args.
+func interpolate(query string, args []stringConstant) string {
+	var (
+		b        strings.Builder
+		quote    rune
+		verbatim bool
+		n        int
+	)
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if quote != 0 {
+			b.WriteRune(c)
+			switch {
+			case c == '\\' && !verbatim && i+1 < len(runes):
+				i++
+				b.WriteRune(runes[i])
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"':
+			quote = c
+			verbatim = i > 0 && runes[i-1] == '@'
+		case '{':
+			if end := placeholderEnd(runes, i); end > 0 {
+				if n >= len(args) {
+					panic(fmt.Sprintf("Invalid query. The query has more placeholders than the %d arguments.", len(args)))
+				}
+				b.WriteString(args[n].String())
+				n++
+				i = end
+				continue
+			}
+		}
+		b.WriteRune(c)
+	}
+
+	if n != len(args) {
+		panic(fmt.Sprintf("Invalid query. The query has %d placeholders for %d arguments.", n, len(args)))
+	}
+	return b.String()
+}
+
+// placeholderEnd returns the position of the closing brace of the placeholder that starts at start, or -1 if there is
+// none, such as for the braces of a dynamic literal.
+func placeholderEnd(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '}' && i > start+1:
+			return i
+		case c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return -1
+		}
+	}
+	return -1
+}
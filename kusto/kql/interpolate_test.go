@@ -0,0 +1,96 @@
+package kql
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdent(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Table", "Table"},
+		{"_col1", "_col1"},
+		{"my table", `["my table"]`},
+		{"1col", `["1col"]`},
+		{`x"] | drop T; print ["`, `["x\"] | drop T; print [\""]`},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Ident(test.name).String(), test.name)
+	}
+	assert.Panics(t, func() { Ident("") })
+}
+
+func TestLiteral(t *testing.T) {
+	id := uuid.MustParse("12345678-1234-1234-1234-123456789012")
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{"String", "foo", `"foo"`},
+		{"Empty string", "", `""`},
+		{"String injection", `x" | drop T; print "`, `"x\" | drop T; print \""`},
+		{"Bool", true, "bool(true)"},
+		{"Int32", int32(1), "int(1)"},
+		{"Int", 2, "long(2)"},
+		{"Uint64", uint64(3), "long(3)"},
+		{"Real", 1.5, "real(1.5)"},
+		{"Float32", float32(0.5), "real(0.5)"},
+		{"NaN", math.NaN(), "real(nan)"},
+		{"Inf", math.Inf(-1), "real(-inf)"},
+		{"Datetime", time.Date(2019, 1, 2, 3, 4, 5, 600, time.UTC), "datetime(2019-01-02T03:04:05.0000006Z)"},
+		{"Timespan", time.Hour, "timespan(01:00:00.0000000)"},
+		{"Guid", id, "guid(12345678-1234-1234-1234-123456789012)"},
+		{"Decimal", decimal.RequireFromString("1.25"), "decimal(1.25)"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Literal(test.value).String(), test.name)
+	}
+	assert.Panics(t, func() { Literal([]int{1}) })
+	assert.Panics(t, func() { Literal(uint64(math.MaxUint64)) })
+}
+
+func TestNewInterpolate(t *testing.T) {
+	tests := []struct {
+		name     string
+		b        func() *Builder
+		expected string
+	}{
+		{
+			"Placeholders",
+			func() *Builder {
+				return New("{table} | where {col} == {val}", Ident("T"), Ident("my col"), Literal("v"))
+			},
+			`T | where ["my col"] == "v"`,
+		},
+		{
+			"Braces in strings and dynamic literals",
+			func() *Builder {
+				return New(`{table} | extend s = "{x}", v = @'{y}', d = dynamic({"a": 1})`, Ident("T"))
+			},
+			`T | extend s = "{x}", v = @'{y}', d = dynamic({"a": 1})`,
+		},
+		{
+			"Escaped quotes",
+			func() *Builder { return New(`print "\"{x}" | {col}`, Ident("C")) },
+			`print "\"{x}" | C`,
+		},
+		{
+			"No args",
+			func() *Builder { return New("print {x}") },
+			"print {x}",
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.b().String(), test.name)
+	}
+	assert.Panics(t, func() { New("{table} | where {col} == 1", Ident("T")) })
+	assert.Panics(t, func() { New("{table}", Ident("T"), Ident("C")) })
+}
@@ -1,15 +1,20 @@
 package kql
 
 import (
+	"fmt"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type Parameters struct {
 	parameters map[string]Value
+	// err is the first invalid parameter that was added.
+	err error
 }
 
 func NewParameters() *Parameters {
@@ -20,13 +25,160 @@ func (q *Parameters) Count() int {
 	return len(q.parameters)
 }
 func (q *Parameters) addBase(key string, value Value) *Parameters {
-	if RequiresQuoting(key) {
-		panic("Invalid parameter values. make sure to adhere to KQL entity name conventions and escaping rules.")
+	if !isIdentifier(key) {
+		if q.err == nil {
+			q.err = fmt.Errorf("invalid parameter name %q, names must start with a letter or '_' and contain only letters, digits and '_'", key)
+		}
+		return q
 	}
 	q.parameters[key] = value
 	return q
 }
 
+// Err returns an error if a parameter with an invalid name was added. Such parameters are not added.
+func (q *Parameters) Err() error {
+	return q.err
+}
+
+// Validate checks that all parameters were added with valid names and that each one is referenced by query, see
+// ValidateParameterReferences().
+func (q *Parameters) Validate(query string) error {
+	return q.validate(query, false)
+}
+
+// ValidateAllowUnused is Validate, except that parameters that query doesn't reference are allowed, such as in a
+// generated query that only uses some of them.
+func (q *Parameters) ValidateAllowUnused(query string) error {
+	return q.validate(query, true)
+}
+
+func (q *Parameters) validate(query string, allowUnused bool) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	declared := make([]string, 0, len(q.parameters))
+	for key := range q.parameters {
+		declared = append(declared, key)
+	}
+	return ValidateParameterReferences(query, declared, allowUnused)
+}
+
+// ValidateParameterReferences cross-checks the declared parameter names with the references to them in query. KQL
+// references a parameter by its name, so an @name outside of string literals, comments and the data of an
+// `.ingest inline` command, as in SQL, is reported: as undeclared if no parameter is named name, and as a wrong
+// reference otherwise. Unless allowUnused is set, declared
+// names that query doesn't reference are reported too.
+func ValidateParameterReferences(query string, declared []string, allowUnused bool) error {
+	referenced, atNames := scanNames(query)
+	isDeclared := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		isDeclared[name] = true
+	}
+
+	var undeclared, prefixed, unused []string
+	for _, name := range atNames {
+		if isDeclared[name] {
+			prefixed = append(prefixed, name)
+		} else {
+			undeclared = append(undeclared, name)
+		}
+	}
+	if !allowUnused {
+		for _, name := range declared {
+			if !referenced[name] {
+				unused = append(unused, name)
+			}
+		}
+		sort.Strings(unused)
+	}
+
+	var problems []string
+	if len(undeclared) > 0 {
+		problems = append(problems, fmt.Sprintf("parameters %v are used in the query but not declared", undeclared))
+	}
+	if len(prefixed) > 0 {
+		problems = append(problems, fmt.Sprintf("parameters %v are referenced with '@', KQL references them by name only", prefixed))
+	}
+	if len(unused) > 0 {
+		problems = append(problems, fmt.Sprintf("parameters %v are not used in the query", unused))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isIdentifier reports if name can be used as a KQL parameter name.
+func isIdentifier(name string) bool {
+	if name == "" || RequiresQuoting(name) {
+		return false
+	}
+	return !unicode.IsDigit([]rune(name)[0])
+}
+
+// scanNames returns the identifiers that appear in query, outside of string literals, comments and the data of an
+// `.ingest inline` command, and the distinct identifiers that are right after an '@', in order.
+func scanNames(query string) (map[string]bool, []string) {
+	names := map[string]bool{}
+	var atNames []string
+	seen := map[string]bool{}
+	inline := isInlineIngestion(query)
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case inline && c == '<' && i+1 < len(runes) && runes[i+1] == '|':
+			// The rest of the command is the data to ingest.
+			return names, atNames
+		case (c == '`' || c == '~') && isTripleAt(runes, i, c):
+			// A multi-line string literal, which ends with the same three characters.
+			for i += 3; i < len(runes) && !isTripleAt(runes, i, c); i++ {
+			}
+			i += 2
+		case c == '\'' || c == '"':
+			verbatim := i > 0 && runes[i-1] == '@'
+			for i++; i < len(runes) && runes[i] != c; i++ {
+				if runes[i] == '\\' && !verbatim {
+					i++
+				}
+			}
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			name := string(runes[start : i+1])
+			names[name] = true
+			if start > 0 && runes[start-1] == '@' && !seen[name] {
+				seen[name] = true
+				atNames = append(atNames, name)
+			}
+		case unicode.IsDigit(c):
+			// Skip numbers, so that literals such as 1d aren't read as identifiers.
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+		}
+	}
+	return names, atNames
+}
+
+// isTripleAt reports whether c is repeated three times in runes from i.
+func isTripleAt(runes []rune, i int, c rune) bool {
+	return i+2 < len(runes) && runes[i] == c && runes[i+1] == c && runes[i+2] == c
+}
+
+// isInlineIngestion reports whether query is an `.ingest inline` command, whose data follows the first "<|".
+func isInlineIngestion(query string) bool {
+	fields := strings.Fields(query)
+	return len(fields) >= 2 && strings.EqualFold(fields[0], ".ingest") && strings.EqualFold(fields[1], "inline")
+}
+
 func (q *Parameters) AddBool(key string, value bool) *Parameters {
 	return q.addBase(key, newValue(value, types.Bool))
 }
@@ -129,3 +129,114 @@ func TestQueryParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryParametersValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		qp      *Parameters
+		wantErr string
+	}{
+		{
+			"Test all used",
+			"T | where name == name_param and since > since | take limit",
+			NewParameters().AddString("name_param", "x").AddDateTime("since", time.Now()).AddInt("limit", 100),
+			"",
+		},
+		{
+			"Test unused",
+			"T | take limit",
+			NewParameters().AddInt("limit", 100).AddString("name", "x"),
+			"parameters [name] are not used in the query",
+		},
+		{
+			"Test only in string literal",
+			"T | where col == 'name' and col2 == @\"name\" | take limit // name",
+			NewParameters().AddInt("limit", 100).AddString("name", "x"),
+			"parameters [name] are not used in the query",
+		},
+		{
+			"Test undeclared reference",
+			"T | where name == @user | take limit",
+			NewParameters().AddInt("limit", 100),
+			"parameters [user] are used in the query but not declared",
+		},
+		{
+			"Test reference with @",
+			"T | take @limit",
+			NewParameters().AddInt("limit", 100),
+			"parameters [limit] are referenced with '@'",
+		},
+		{
+			"Test @ in string literals and comments",
+			"T | where col == '@user' and col2 == @\"x@user\" | take limit // @user",
+			NewParameters().AddInt("limit", 100),
+			"",
+		},
+		{
+			"Test @ in a multi-line string literal",
+			"T | where col == ```a@user\nb@user``` and col2 == ~~~x@user~~~ | take limit",
+			NewParameters().AddInt("limit", 100),
+			"",
+		},
+		{
+			"Test @ in the data of an inline ingestion",
+			".ingest inline into table T with (format='csv') <| alice@contoso.com,1\nbob@contoso.com,2",
+			NewParameters(),
+			"",
+		},
+		{
+			"Test undeclared and unused",
+			"T | take @count",
+			NewParameters().AddInt("limit", 100),
+			"parameters [count] are used in the query but not declared; parameters [limit] are not used in the query",
+		},
+		{
+			"Test invalid name",
+			"T | take limit",
+			NewParameters().AddInt("limit", 100).AddString("na me", "x"),
+			"invalid parameter name \"na me\"",
+		},
+		{
+			"Test leading digit",
+			"T | take limit",
+			NewParameters().AddInt("limit", 100).AddString("1name", "x"),
+			"invalid parameter name \"1name\"",
+		},
+		{
+			"Test empty name",
+			"T | take limit",
+			NewParameters().AddInt("limit", 100).AddString("", "x"),
+			"invalid parameter name \"\"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.qp.Validate(test.query)
+			if test.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
+
+func TestQueryParametersValidateAllowUnused(t *testing.T) {
+	qp := NewParameters().AddInt("limit", 100).AddString("name", "x")
+	require.NoError(t, qp.ValidateAllowUnused("T | take limit"))
+	require.Error(t, qp.ValidateAllowUnused("T | take @limit"))
+	require.Error(t, NewParameters().AddString("na me", "x").ValidateAllowUnused("T"))
+}
+
+func TestQueryParametersEscaping(t *testing.T) {
+	params := NewParameters().
+		AddString("empty", "").
+		AddString("inject", "x\" | take 1; .drop table T //")
+
+	require.Equal(t, map[string]string{
+		"empty":  `""`,
+		"inject": `"x\" | take 1; .drop table T //"`,
+	}, params.ToParameterCollection())
+}
@@ -30,6 +30,9 @@ func (v *kqlValue) String() string {
 	val := v.value
 	switch v.kustoType {
 	case types.String:
+		if val.(string) == "" {
+			return `""`
+		}
 		return QuoteString(val.(string), false)
 	case types.DateTime:
 		val = FormatDatetime(val.(time.Time))
@@ -10,8 +10,13 @@ import (
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // queryer provides for getting a stream of Kusto frames. Exists to allow fake Kusto streams in tests.
@@ -34,7 +39,10 @@ const (
 	clientServerDelta   = 30 * time.Second
 )
 
-// Client is a client to a Kusto instance.
+// Client is a client to a Kusto instance. A Client is safe for concurrent use by multiple goroutines, and is meant to
+// be long-lived: it caches its tokens and the cloud metadata of its cluster, and by default shares
+// http.DefaultTransport, and its connections, with the other Clients. See ClientPool to share Clients by connection
+// string.
 type Client struct {
 	conn, ingestConn queryer
 	endpoint         string
@@ -42,12 +50,23 @@ type Client struct {
 	mgmtConnMu       sync.Mutex
 	http             *http.Client
 	clientDetails    *ClientDetails
+	cloudInfoTTL     time.Duration
+	maxFrameBytes    int64
+	retryPolicy      *RetryPolicy
+	tracerProvider   trace.TracerProvider
+	tracer           trace.Tracer
+	metrics          Metrics
+	traceExtractor   func(ctx context.Context) map[string]string
+	logger           Logger
+	// tokenRefreshBuffer is how long before it expires the token is renewed, see WithTokenRefreshBuffer().
+	tokenRefreshBuffer time.Duration
 }
 
 // Option is an optional argument type for New().
 type Option func(c *Client)
 
-// New returns a new Client.
+// New returns a new Client. The endpoint of kcsb is the query endpoint of the cluster. Its data management endpoint,
+// "https://ingest-cluster...", is accepted too: the Client then queries the cluster it belongs to.
 func New(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error) {
 	tkp, err := kcsb.newTokenProvider()
 	if err != nil {
@@ -62,18 +81,20 @@ func New(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error) {
 		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "could not parse the endpoint(%s): %s", endpoint, err).SetNoRetry()
 	}
 	if strings.HasPrefix(u.Hostname(), "ingest-") {
-		return nil, errors.ES(
-			errors.OpServConn,
-			errors.KClientArgs,
-			"endpoint argument started with 'ingest-'. Adding 'ingest-' is taken care of by the client. "+
-				"If using Mgmt() on an ingestion endpoint, use option QueryIngestion(). This is very uncommon",
-		)
+		// A data management endpoint is accepted for the cluster it belongs to. Adding 'ingest-' is taken care of by
+		// the client, for the ingest clients and the Mgmt() calls with IngestionEndpoint().
+		u.Host = strings.TrimPrefix(u.Host, "ingest-")
+		endpoint = u.String()
 	}
 
-	client := &Client{auth: *auth, endpoint: endpoint, clientDetails: NewClientDetails(kcsb.ApplicationForTracing, kcsb.UserForTracing)}
+	client := &Client{auth: *auth, endpoint: endpoint, clientDetails: NewClientDetails(kcsb.ApplicationForTracing, kcsb.UserForTracing), metrics: NopMetrics{}, maxFrameBytes: DefaultMaxFrameBytes}
 	for _, o := range options {
 		o(client)
 	}
+	tkp.cloudInfoTTL = client.cloudInfoTTL
+	tkp.logger = client.Logger()
+	tkp.setRefreshBuffer(client.tokenRefreshBuffer, client.Logger())
+	client.tracer = tracing.Tracer(client.tracerProvider)
 
 	if client.http == nil {
 		client.http = &http.Client{
@@ -87,17 +108,56 @@ func New(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	conn.cloudInfoTTL = client.cloudInfoTTL
+	conn.maxFrameBytes = client.maxFrameBytes
+	conn.retryPolicy = client.retryPolicy
+	conn.logger = client.Logger()
 	client.conn = conn
 
 	return client, nil
 }
 
-func WithHttpClient(client *http.Client) Option {
+// WithHTTPClient sets the *http.Client used for every request the client sends: queries, management commands, and the
+// cloud metadata and token requests made to authenticate them. Use it to route the traffic through a proxy or to trust
+// a custom CA bundle with a custom http.Transport. The headers required by the service and the Authorization header are
+// still added by the client to every request, so the injected client only needs to provide the transport.
+// The injected client is used as is. Unlike the default one, it follows redirects unless its CheckRedirect says otherwise.
+// Note that http.Client.Timeout bounds every request regardless of the context passed to Query() or Mgmt(), so a
+// non-zero Timeout shorter than a context deadline will cut long running queries short. Prefer leaving it at zero and
+// using contexts to bound calls.
+func WithHTTPClient(client *http.Client) Option {
 	return func(c *Client) {
 		c.http = client
 	}
 }
 
+// Deprecated: WithHttpClient will be removed in a future release. Use WithHTTPClient instead.
+func WithHttpClient(client *http.Client) Option {
+	return WithHTTPClient(client)
+}
+
+// WithCloudInfoTTL sets how long the CloudInfo metadata discovered from the cluster is reused by clients before it is
+// fetched again. The metadata is cached for all clients of the same cluster. Defaults to DefaultCloudInfoTTL.
+func WithCloudInfoTTL(d time.Duration) Option {
+	return func(c *Client) {
+		c.cloudInfoTTL = d
+	}
+}
+
+// DefaultMaxFrameBytes is the default size of the largest frame of a query response the client accepts, see
+// WithMaxFrameBytes().
+const DefaultMaxFrameBytes = 1 << 30
+
+// WithMaxFrameBytes bounds the size of the frames of the query responses the client decodes, which are buffered whole.
+// A frame holds a table, or a fragment of a table for progressive queries, so n bounds the memory a single huge value
+// or row can take. A query whose response has a larger frame fails with an errors.KLimitsExceeded error, instead of
+// running the process out of memory. Defaults to DefaultMaxFrameBytes, and a zero or negative n removes the limit.
+func WithMaxFrameBytes(n int64) Option {
+	return func(c *Client) {
+		c.maxFrameBytes = n
+	}
+}
+
 // QueryOption is an option type for a call to Query().
 type QueryOption func(q *queryOptions) error
 
@@ -126,27 +186,57 @@ const (
 	mgmtCall        = 2
 )
 
+// callEnded returns the function that ends a Query() or Mgmt() call that started at start, once the stream of its
+// iterator ended or the call failed: it ends span and records the metrics of the call, with the error that ended it.
+func (c *Client) callEnded(call, db string, start time.Time, span trace.Span) func(iter *RowIterator, err error) {
+	return func(iter *RowIterator, err error) {
+		endSpan(span, iter, err)
+		c.recordCall(call, db, start, err)
+	}
+}
+
 // Query queries Kusto for data. context can set a timeout or cancel the query.
 // query is a injection safe Stmt object. Queries cannot take longer than 5 minutes by default and have row/size limitations.
 // Note that the server has a timeout of 4 minutes for a query by default unless the context deadline is set. Queries can
 // take a maximum of 1 hour.
 func (c *Client) Query(ctx context.Context, db string, query Statement, options ...QueryOption) (*RowIterator, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, c.tracer, "kusto.Query", tracing.Database.String(db))
+	ended := c.callEnded("query", db, start, span)
+	iter, err := c.query(ctx, db, query, ended, options...)
+	if err != nil {
+		ended(nil, err)
+	}
+	return iter, err
+}
+
+// query runs the query. ended is called with the iterator and the error that ended its stream once the stream of the
+// returned iterator ended, but not if query returns an error.
+func (c *Client) query(ctx context.Context, db string, query Statement, ended func(iter *RowIterator, err error), options ...QueryOption) (*RowIterator, error) {
+	parent := ctx
 	ctx, cancel := contextSetup(ctx) // Note: cancel is called when *RowIterator has Stop() called.
 
 	opts, err := setQueryOptions(ctx, errors.OpQuery, query, queryCall, options...)
 	if err != nil {
 		return nil, err
 	}
+	refs, err := c.checkCrossCluster(errors.OpQuery, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.extractTraceProperties(ctx, opts)
 
 	conn, err := c.getConn(queryCall, connOptions{queryOptions: opts})
 	if err != nil {
 		return nil, err
 	}
 
+	finished := c.watchCancellation(parent, opts)
 	execResp, err := conn.query(ctx, db, query, opts)
 	if err != nil {
+		finished()
 		cancel()
-		return nil, err
+		return nil, crossClusterError(errors.OpQuery, refs, err)
 	}
 
 	var header v2.DataSetHeader
@@ -156,11 +246,19 @@ func (c *Client) Query(ctx context.Context, db string, query Statement, options
 	case v2.DataSetHeader:
 		header = v
 	case frames.Error:
+		finished()
 		cancel()
 		return nil, v
 	}
 
 	iter, columnsReady := newRowIterator(ctx, cancel, execResp, header, errors.OpQuery)
+	iter.caseInsensitiveColumns = opts.caseInsensitiveColumns
+	iter.columnNameMapper = opts.columnNameMapper
+	iter.errorOnEmpty = opts.errorOnEmpty
+	iter.streamEnded = func(err error) {
+		finished()
+		ended(iter, err)
+	}
 
 	var sm stateMachine
 	if header.IsProgressive {
@@ -188,22 +286,31 @@ func (c *Client) Query(ctx context.Context, db string, query Statement, options
 }
 
 func (c *Client) QueryToJson(ctx context.Context, db string, query Statement, options ...QueryOption) (string, error) {
+	parent := ctx
 	ctx, cancel := contextSetup(ctx) // Note: cancel is called when *RowIterator has Stop() called.
 
 	opts, err := setQueryOptions(ctx, errors.OpQuery, query, queryCall, options...)
 	if err != nil {
 		return "", err
 	}
+	refs, err := c.checkCrossCluster(errors.OpQuery, query, opts)
+	if err != nil {
+		return "", err
+	}
+
+	c.extractTraceProperties(ctx, opts)
 
 	conn, err := c.getConn(queryCall, connOptions{queryOptions: opts})
 	if err != nil {
 		return "", err
 	}
 
+	finished := c.watchCancellation(parent, opts)
 	json, err := conn.queryToJson(ctx, db, query, opts)
+	finished()
 	if err != nil {
 		cancel()
-		return "", err
+		return "", crossClusterError(errors.OpQuery, refs, err)
 	}
 
 	return json, nil
@@ -215,6 +322,18 @@ func (c *Client) QueryToJson(ctx context.Context, db string, query Statement, op
 // Note that the server has a timeout of 10 minutes for a management call by default unless the context deadline is set.
 // There is a maximum of 1 hour.
 func (c *Client) Mgmt(ctx context.Context, db string, query Statement, options ...QueryOption) (*RowIterator, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, c.tracer, "kusto.Mgmt", tracing.Database.String(db))
+	ended := c.callEnded("mgmt", db, start, span)
+	iter, err := c.mgmt(ctx, db, query, ended, options...)
+	if err != nil {
+		ended(nil, err)
+	}
+	return iter, err
+}
+
+// mgmt runs the management command, and calls ended like query().
+func (c *Client) mgmt(ctx context.Context, db string, query Statement, ended func(iter *RowIterator, err error), options ...QueryOption) (*RowIterator, error) {
 	if stmt, ok := query.(Stmt); ok {
 		if !stmt.params.IsZero() || !stmt.defs.IsZero() {
 			return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "a Mgmt() call cannot accept a Stmt object that has Definitions or Parameters attached")
@@ -228,6 +347,8 @@ func (c *Client) Mgmt(ctx context.Context, db string, query Statement, options .
 		return nil, err
 	}
 
+	c.extractTraceProperties(ctx, opts)
+
 	conn, err := c.getConn(mgmtCall, connOptions{queryOptions: opts})
 	if err != nil {
 		return nil, err
@@ -240,6 +361,10 @@ func (c *Client) Mgmt(ctx context.Context, db string, query Statement, options .
 	}
 
 	iter, columnsReady := newRowIterator(ctx, cancel, execResp, v2.DataSetHeader{}, errors.OpMgmt)
+	iter.caseInsensitiveColumns = opts.caseInsensitiveColumns
+	iter.columnNameMapper = opts.columnNameMapper
+	iter.errorOnEmpty = opts.errorOnEmpty
+	iter.streamEnded = func(err error) { ended(iter, err) }
 	sm := &v1SM{
 		op:   errors.OpQuery,
 		iter: iter,
@@ -278,8 +403,23 @@ func setQueryOptions(ctx context.Context, op errors.Op, query Statement, queryTy
 		if err != nil {
 			return nil, errors.ES(op, errors.KClientArgs, "Parameter validation error: %s", err).SetNoRetry()
 		}
+		if stmt, ok := query.(Stmt); ok {
+			if names := stmt.defs.names(); len(names) != 0 {
+				if err := kql.ValidateParameterReferences(stmt.queryStr, names, opt.allowUnusedParameters); err != nil {
+					return nil, errors.ES(op, errors.KClientArgs, "Parameter validation error: %s", err).SetNoRetry()
+				}
+			}
+		}
 
 		opt.requestProperties.Parameters = params
+	} else if params := &opt.requestProperties.QueryParameters; params.Count() != 0 || params.Err() != nil {
+		validate := params.Validate
+		if opt.allowUnusedParameters {
+			validate = params.ValidateAllowUnused
+		}
+		if err := validate(query.String()); err != nil {
+			return nil, errors.ES(op, errors.KClientArgs, "Parameter validation error: %s", err).SetNoRetry()
+		}
 	}
 	return opt, nil
 }
@@ -293,6 +433,16 @@ func CalculateTimeout(ctx context.Context, opt *queryOptions, queryType int) {
 		return
 	}
 
+	// If the user has set a request timeout, use it unless the context deadline is sooner.
+	if opt.requestTimeout > 0 {
+		timeout := opt.requestTimeout
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+		}
+		opt.requestProperties.Options[ServerTimeoutValue] = value.Timespan{Valid: true, Value: timeout}.Marshal()
+		return
+	}
+
 	// Otherwise use the context deadline, if it exists. If it doesn't, use the default timeout.
 	if deadline, ok := ctx.Deadline(); ok {
 		opt.requestProperties.Options[ServerTimeoutValue] = deadline.Sub(time.Now())
@@ -335,6 +485,10 @@ func (c *Client) getConn(callType callType, options connOptions) (queryer, error
 			if err != nil {
 				return nil, err
 			}
+			iconn.cloudInfoTTL = c.cloudInfoTTL
+			iconn.maxFrameBytes = c.maxFrameBytes
+			iconn.retryPolicy = c.retryPolicy
+			iconn.logger = c.Logger()
 			c.ingestConn = iconn
 
 			return iconn, nil
@@ -358,6 +512,9 @@ func (c *Client) ClientDetails() *ClientDetails {
 }
 
 func (c *Client) Close() error {
+	if c.auth.TokenProvider != nil {
+		c.auth.TokenProvider.close()
+	}
 	var err error
 	if c.conn != nil {
 		err = c.conn.Close()
@@ -316,7 +316,7 @@ func ExampleCustomHttpClient() { // nolint:govet // Example code
 	httpClient.Transport = &http.Transport{Proxy: http.ProxyURL(url)}
 
 	// Normally here you take a client.
-	_, err = New(kcsb, WithHttpClient(httpClient))
+	_, err = New(kcsb, WithHTTPClient(httpClient))
 	if err != nil {
 		panic(err.Error())
 	}
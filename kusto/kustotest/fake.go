@@ -0,0 +1,136 @@
+// Package kustotest provides a fake of kusto.Querier, to unit test code that runs queries without a cluster.
+//
+// Results are enqueued on a FakeClient, and returned in order by its Query() and Mgmt() calls, which are recorded:
+//
+//	fake := kustotest.NewFakeClient()
+//	err := fake.AddRows(table.Columns{{Name: "ID", Type: types.Long}}, [][]value.Kusto{{value.Long{Value: 1, Valid: true}}})
+//	...
+//	got, err := NodeIDs(ctx, fake) // NodeIDs takes a kusto.Querier.
+//	...
+//	calls := fake.Calls()
+package kustotest
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// Call is a Query() or Mgmt() call received by a FakeClient.
+type Call struct {
+	kusto.CallDetails
+
+	// Mgmt is true for a Mgmt() call, false for a Query() call.
+	Mgmt bool
+	// DB is the database the call was made on.
+	DB string
+}
+
+// result is a result enqueued on a FakeClient. If err is set the call fails, otherwise it returns the rows, and
+// then iterErr if it is set.
+type result struct {
+	columns table.Columns
+	rows    [][]value.Kusto
+	iterErr error
+	err     error
+}
+
+// FakeClient implements kusto.Querier, returning the results enqueued with its Add methods. It is safe for concurrent
+// use. A call made when no result is enqueued fails with an errors.KInternal error.
+type FakeClient struct {
+	mu      sync.Mutex
+	results []result
+	calls   []Call
+}
+
+var _ kusto.Querier = (*FakeClient)(nil)
+
+// NewFakeClient is the constructor for FakeClient.
+func NewFakeClient() *FakeClient {
+	return &FakeClient{}
+}
+
+// AddRows enqueues a result with the columns and rows. The values of every row must match the columns.
+func (f *FakeClient) AddRows(columns table.Columns, rows [][]value.Kusto) error {
+	return f.addRows(result{columns: columns, rows: rows})
+}
+
+// AddRowsThenError enqueues a result that returns the rows, and then fails with an error of the op and kind, like a
+// query that fails after returning some results.
+func (f *FakeClient) AddRowsThenError(columns table.Columns, rows [][]value.Kusto, op errors.Op, kind errors.Kind, msg string) error {
+	return f.addRows(result{columns: columns, rows: rows, iterErr: errors.ES(op, kind, "%s", msg)})
+}
+
+// AddError enqueues a call that fails with an error of the op and kind, before returning any row.
+func (f *FakeClient) AddError(op errors.Op, kind errors.Kind, msg string) {
+	f.add(result{err: errors.ES(op, kind, "%s", msg)})
+}
+
+// Calls returns the calls received so far, in order.
+func (f *FakeClient) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Query implements kusto.Querier.Query().
+func (f *FakeClient) Query(ctx context.Context, db string, query kusto.Statement, options ...kusto.QueryOption) (*kusto.RowIterator, error) {
+	if strings.HasPrefix(strings.TrimSpace(query.String()), ".") {
+		return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "a Stmt to Query() cannot begin with a period(.), only Mgmt() calls can do that").SetNoRetry()
+	}
+	return f.call(ctx, false, db, query, options)
+}
+
+// Mgmt implements kusto.Querier.Mgmt().
+func (f *FakeClient) Mgmt(ctx context.Context, db string, query kusto.Statement, options ...kusto.QueryOption) (*kusto.RowIterator, error) {
+	return f.call(ctx, true, db, query, options)
+}
+
+func (f *FakeClient) call(ctx context.Context, mgmt bool, db string, query kusto.Statement, options []kusto.QueryOption) (*kusto.RowIterator, error) {
+	op := errors.OpQuery
+	if mgmt {
+		op = errors.OpMgmt
+	}
+
+	details, err := kusto.NewCallDetails(ctx, query, mgmt, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{CallDetails: details, Mgmt: mgmt, DB: db})
+	if len(f.results) == 0 {
+		f.mu.Unlock()
+		return nil, errors.ES(op, errors.KInternal, "the FakeClient has no result enqueued for %q", query.String())
+	}
+	res := f.results[0]
+	f.results = f.results[1:]
+	f.mu.Unlock()
+
+	if res.err != nil {
+		return nil, res.err
+	}
+	return kusto.NewInMemoryRowIterator(res.columns, res.rows, res.iterErr)
+}
+
+// addRows checks that the rows of r match its columns, and enqueues it.
+func (f *FakeClient) addRows(r result) error {
+	iter, err := kusto.NewInMemoryRowIterator(r.columns, r.rows, nil)
+	if err != nil {
+		return err
+	}
+	iter.Stop()
+	f.add(r)
+	return nil
+}
+
+func (f *FakeClient) add(r result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, r)
+}
@@ -0,0 +1,99 @@
+package kustotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var columns = table.Columns{{Name: "ID", Type: types.Long}, {Name: "Name", Type: types.String}}
+
+func rows(n int) [][]value.Kusto {
+	var rows [][]value.Kusto
+	for i := 0; i < n; i++ {
+		rows = append(rows, []value.Kusto{value.Long{Value: int64(i), Valid: true}, value.String{Value: "name", Valid: true}})
+	}
+	return rows
+}
+
+// ids reads the ID column of all the rows of iter.
+func ids(iter *kusto.RowIterator) ([]int64, error) {
+	defer iter.Stop()
+	var got []int64
+	err := iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+		if e != nil {
+			return e
+		}
+		got = append(got, r.Values[0].(value.Long).Value)
+		return nil
+	})
+	return got, err
+}
+
+func TestFakeClient(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeClient()
+	require.NoError(t, fake.AddRows(columns, rows(2)))
+	require.NoError(t, fake.AddRowsThenError(columns, rows(1), errors.OpMgmt, errors.KLimitsExceeded, "limits exceeded"))
+	fake.AddError(errors.OpQuery, errors.KHTTPError, "unavailable")
+
+	var querier kusto.Querier = fake
+	ctx := context.Background()
+
+	iter, err := querier.Query(ctx, "db", kql.New("T | where ID > id"), kusto.WithParameters(kql.NewParameters().AddLong("id", 1)), kusto.WithClientRequestID("request"))
+	require.NoError(t, err)
+	got, err := ids(iter)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 1}, got)
+
+	iter, err = querier.Mgmt(ctx, "db", kql.New(".show tables"))
+	require.NoError(t, err)
+	got, err = ids(iter)
+	assert.Equal(t, []int64{0}, got, "the rows should be returned before the error")
+	require.Error(t, err)
+	assert.Equal(t, errors.OpMgmt, err.(*errors.Error).Op)
+	assert.Equal(t, errors.KLimitsExceeded, err.(*errors.Error).Kind)
+
+	_, err = querier.Query(ctx, "other", kql.New("T"))
+	require.Error(t, err)
+	assert.Equal(t, errors.KHTTPError, err.(*errors.Error).Kind)
+
+	_, err = querier.Query(ctx, "db", kql.New("T"))
+	require.Error(t, err, "there should be no result left")
+	assert.Equal(t, errors.KInternal, err.(*errors.Error).Kind)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 4)
+	assert.Equal(t, "T | where ID > id", calls[0].Query)
+	assert.Equal(t, map[string]string{"id": "long(1)"}, calls[0].Parameters)
+	assert.Equal(t, "request", calls[0].ClientRequestID)
+	assert.False(t, calls[0].Mgmt)
+	assert.True(t, calls[1].Mgmt)
+	assert.Equal(t, ".show tables", calls[1].Query)
+	assert.Equal(t, "other", calls[2].DB)
+}
+
+func TestFakeClientInvalidCalls(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeClient()
+	require.Error(t, fake.AddRows(columns, [][]value.Kusto{{value.String{Value: "wrong", Valid: true}}}))
+
+	_, err := fake.Query(context.Background(), "db", kql.New(".show tables"))
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+
+	_, err = fake.Query(context.Background(), "db", kql.New("T"), kusto.WithParameters(kql.NewParameters().AddLong("missing", 1)))
+	require.Error(t, err)
+	assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+	assert.Empty(t, fake.Calls(), "invalid calls should not be recorded")
+}
@@ -0,0 +1,40 @@
+package kusto
+
+// Logger receives the diagnostic messages of a client and the ingest clients created from it, such as retries,
+// refreshes of the ingestion resources, fallbacks of the managed client to queued ingestion and changes of the storage
+// account an upload is attempted on. Set it with WithLogger().
+// keysAndValues are alternating keys and values, like with log/slog, which SlogLogger() adapts to this interface.
+// Implementations must be safe for concurrent use. New methods may be added to Logger in a future release, so
+// implementations should embed NopLogger.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// NopLogger is a Logger that discards all the messages. It is the default.
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...interface{}) {}
+func (NopLogger) Info(string, ...interface{})  {}
+func (NopLogger) Warn(string, ...interface{})  {}
+func (NopLogger) Error(string, ...interface{}) {}
+
+// WithLogger sets l to receive the diagnostic messages of the client, see Logger.
+func WithLogger(l Logger) Option {
+	return func(c *Client) {
+		if l == nil {
+			l = NopLogger{}
+		}
+		c.logger = l
+	}
+}
+
+// Logger returns the Logger set with WithLogger(), or NopLogger.
+func (c *Client) Logger() Logger {
+	if c.logger == nil {
+		return NopLogger{}
+	}
+	return c.logger
+}
@@ -0,0 +1,18 @@
+//go:build go1.21
+
+package kusto
+
+import (
+	"log/slog"
+)
+
+var _ Logger = (*slog.Logger)(nil)
+
+// SlogLogger returns a Logger that writes the messages to l, or to slog.Default() if l is nil.
+// It is only available when building with Go 1.21 or later, which introduced log/slog.
+func SlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return l
+}
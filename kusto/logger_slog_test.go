@@ -0,0 +1,22 @@
+//go:build go1.21
+
+package kusto
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	l := SlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	l.Warn("kusto: retrying the request", "attempt", 1)
+	assert.Contains(t, buf.String(), `level=WARN msg="kusto: retrying the request" attempt=1`)
+
+	assert.Equal(t, slog.Default(), SlogLogger(nil))
+}
@@ -0,0 +1,48 @@
+package kusto
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger records the messages logged at the Warn level.
+type recordingLogger struct {
+	NopLogger
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *recordingLogger) Warn(msg string, _ ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, msg)
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(s.Close)
+
+	l := &recordingLogger{}
+	policy := RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	client := retryClient(t, s.URL, WithLogger(l), WithRetryPolicy(policy))
+	assert.Equal(t, l, client.Logger())
+
+	_, err := client.Query(context.Background(), "db", kql.New("test"))
+	require.Error(t, err)
+	assert.Equal(t, []string{"kusto: retrying the request", "kusto: retrying the request"}, l.warns, "every retry should be logged")
+
+	assert.Equal(t, NopLogger{}, retryClient(t, s.URL).Logger())
+	assert.Equal(t, NopLogger{}, retryClient(t, s.URL, WithLogger(nil)).Logger())
+}
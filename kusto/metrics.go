@@ -0,0 +1,63 @@
+package kusto
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of the calls made by a client and the ingest clients created from it, so that they
+// can be exported to a metrics system such as Prometheus. Set it with WithMetrics().
+// Implementations must be safe for concurrent use, and should return quickly, as they are called inline.
+// New methods may be added to Metrics in a future release, so implementations should embed NopMetrics.
+type Metrics interface {
+	// QueryDuration is called when a Query() or Mgmt() call completes, with the time until its response was read, the
+	// call failed, or the RowIterator was stopped. call is "query" or "mgmt".
+	QueryDuration(call, db string, d time.Duration)
+	// QueryError is called when a Query() or Mgmt() call fails, including with an error of its response that the
+	// RowIterator returns after the rows, after QueryDuration.
+	QueryError(call, db string, err error)
+	// IngestBytes is called after each upload of a queued ingestion to Blob Storage, with the raw (uncompressed) size
+	// of the uploaded data.
+	IngestBytes(db, table string, n int64)
+	// IngestDuration is called when a FromFile() or FromReader() call of an ingest client returns, whether it
+	// succeeded or not.
+	IngestDuration(db, table string, d time.Duration)
+	// BlobUploadRetry is called when an upload to Blob Storage failed and is retried, with the storage account and
+	// the error of the failed attempt.
+	BlobUploadRetry(account string, err error)
+}
+
+// NopMetrics is a Metrics that discards all the measurements. It is the default.
+type NopMetrics struct{}
+
+func (NopMetrics) QueryDuration(string, string, time.Duration)  {}
+func (NopMetrics) QueryError(string, string, error)             {}
+func (NopMetrics) IngestBytes(string, string, int64)            {}
+func (NopMetrics) IngestDuration(string, string, time.Duration) {}
+func (NopMetrics) BlobUploadRetry(string, error)                {}
+
+// WithMetrics sets m to receive the measurements of the client, see Metrics.
+func WithMetrics(m Metrics) Option {
+	return func(c *Client) {
+		if m == nil {
+			m = NopMetrics{}
+		}
+		c.metrics = m
+	}
+}
+
+// Metrics returns the Metrics set with WithMetrics(), or NopMetrics.
+func (c *Client) Metrics() Metrics {
+	if c.metrics == nil {
+		return NopMetrics{}
+	}
+	return c.metrics
+}
+
+// recordCall reports a Query() or Mgmt() call that started at start and completed with err.
+func (c *Client) recordCall(call, db string, start time.Time, err error) {
+	m := c.Metrics()
+	m.QueryDuration(call, db, time.Since(start))
+	if err != nil {
+		m.QueryError(call, db, err)
+	}
+}
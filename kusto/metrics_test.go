@@ -0,0 +1,82 @@
+package kusto
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callMetrics records the QueryDuration and QueryError calls.
+type callMetrics struct {
+	NopMetrics
+	mu        sync.Mutex
+	durations []string
+	errors    []string
+}
+
+func (m *callMetrics) QueryDuration(call, db string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations = append(m.durations, call+"/"+db)
+}
+
+func (m *callMetrics) QueryError(call, db string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors = append(m.errors, call+"/"+db)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	s, _ := traceparentServer(t)
+	m := &callMetrics{}
+	client := retryClient(t, s.URL, WithMetrics(m))
+	assert.Equal(t, m, client.Metrics())
+
+	_, err := client.Query(context.Background(), "db", kql.New("test"))
+	require.Error(t, err)
+	_, err = client.Mgmt(context.Background(), "db", kql.New(".show tables"))
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"query/db", "mgmt/db"}, m.durations)
+	assert.Equal(t, []string{"query/db", "mgmt/db"}, m.errors)
+
+	assert.Equal(t, NopMetrics{}, retryClient(t, s.URL).Metrics())
+	assert.Equal(t, NopMetrics{}, retryClient(t, s.URL, WithMetrics(nil)).Metrics())
+}
+
+func TestMetricsStream(t *testing.T) {
+	t.Parallel()
+
+	m := &callMetrics{}
+	client := retryClient(t, tablesServer(t).URL, WithMetrics(m))
+	recorded := func() ([]string, []string) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return append([]string(nil), m.durations...), append([]string(nil), m.errors...)
+	}
+
+	iter, err := client.Query(context.Background(), "db", kql.New("test"), WithClientRequestID("failing"))
+	require.NoError(t, err, "the error should only come with the end of the stream")
+	require.Error(t, iter.DoOnRowOrError(func(*table.Row, *errors.Error) error { return nil }))
+	iter.Stop()
+	require.Eventually(t, func() bool { d, _ := recorded(); return len(d) == 1 }, time.Second, time.Millisecond)
+	_, errs := recorded()
+	assert.Equal(t, []string{"query/db"}, errs, "an error of the stream should be recorded")
+
+	iter, err = client.Query(context.Background(), "db", kql.New("test"))
+	require.NoError(t, err)
+	require.NoError(t, iter.DoOnRowOrError(func(*table.Row, *errors.Error) error { return nil }))
+	iter.Stop()
+	require.Eventually(t, func() bool { d, _ := recorded(); return len(d) == 2 }, time.Second, time.Millisecond)
+	_, errs = recorded()
+	assert.Equal(t, []string{"query/db"}, errs, "a successful call should not be recorded as an error")
+}
@@ -0,0 +1,80 @@
+package kusto
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// ClusterDatabase is the database that MgmtCluster() sends commands to. The service runs cluster level commands
+// in its context.
+const ClusterDatabase = "NetDefaultDB"
+
+// databaseScopedCommands are the beginnings, word by word, of the management commands that act on a database,
+// and can't be sent with MgmtCluster().
+var databaseScopedCommands = [][]string{
+	{".show", "database"},
+	{".show", "tables"},
+	{".show", "table"},
+	{".show", "external", "tables"},
+	{".show", "external", "table"},
+	{".show", "functions"},
+	{".show", "function"},
+	{".show", "materialized-views"},
+	{".show", "materialized-view"},
+	{".show", "ingestion", "mappings"},
+	{".create", "table"},
+	{".create", "tables"},
+	{".create-merge", "table"},
+	{".create-merge", "tables"},
+	{".create", "function"},
+	{".create-or-alter", "function"},
+	{".create", "materialized-view"},
+	{".alter", "table"},
+	{".alter-merge", "table"},
+	{".alter", "function"},
+	{".drop", "table"},
+	{".drop", "tables"},
+	{".drop", "function"},
+	{".rename", "table"},
+	{".rename", "tables"},
+	{".delete", "table"},
+	{".ingest"},
+	{".set"},
+	{".append"},
+	{".set-or-append"},
+	{".set-or-replace"},
+}
+
+// databaseScoped returns the beginning of command that makes it act on a database, if it does.
+func databaseScoped(command string) (string, bool) {
+	words := strings.Fields(strings.ToLower(command))
+	for _, prefix := range databaseScopedCommands {
+		if len(words) < len(prefix) {
+			continue
+		}
+		matches := true
+		for i, word := range prefix {
+			if words[i] != word {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return strings.Join(prefix, " "), true
+		}
+	}
+	return "", false
+}
+
+// MgmtCluster is like Mgmt(), for the management commands that are not scoped to a database, such as
+// ".show databases" or ".show cluster". It is useful to discover the databases of a cluster before targeting one.
+// It returns an errors.KClientArgs error for the commands that act on a database, such as ".show tables", which
+// must be sent with Mgmt().
+func (c *Client) MgmtCluster(ctx context.Context, query Statement, options ...QueryOption) (*RowIterator, error) {
+	if prefix, ok := databaseScoped(query.String()); ok {
+		return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "%q commands act on a database, use Mgmt() with the database instead of MgmtCluster()", prefix).SetNoRetry()
+	}
+	return c.Mgmt(ctx, ClusterDatabase, query, options...)
+}
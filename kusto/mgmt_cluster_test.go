@@ -0,0 +1,69 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMgmtCluster(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		msgs []queryMsg
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := queryMsg{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		mu.Lock()
+		msgs = append(msgs, msg)
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer s.Close()
+	client := retryClient(t, s.URL)
+
+	tests := []struct {
+		desc    string
+		command string
+		reject  bool
+	}{
+		{desc: "Show databases", command: ".show databases"},
+		{desc: "Show cluster", command: ".show   cluster"},
+		{desc: "Show database schema", command: ".show database MyDb schema", reject: true},
+		{desc: "Show tables", command: ".show tables", reject: true},
+		{desc: "Uppercase create table", command: ".CREATE TABLE T (a:string)", reject: true},
+		{desc: "Ingest", command: ".ingest into table T ('https://blob')", reject: true},
+	}
+
+	for _, test := range tests {
+		mu.Lock()
+		sent := len(msgs)
+		mu.Unlock()
+
+		_, err := client.MgmtCluster(context.Background(), kql.New("").AddUnsafe(test.command))
+		require.Error(t, err, test.desc)
+
+		mu.Lock()
+		if test.reject {
+			e, ok := err.(*errors.Error)
+			require.True(t, ok, test.desc)
+			assert.Equal(t, errors.KClientArgs, e.Kind, test.desc)
+			assert.Len(t, msgs, sent, "%s: nothing should be sent", test.desc)
+		} else {
+			require.Len(t, msgs, sent+1, test.desc)
+			assert.Equal(t, ClusterDatabase, msgs[sent].DB, test.desc)
+			assert.Equal(t, test.command, msgs[sent].CSL, test.desc)
+		}
+		mu.Unlock()
+	}
+}
@@ -118,6 +118,33 @@ func (m *MockRows) Error(err error) error {
 	return nil
 }
 
+// NewInMemoryRowIterator returns a RowIterator over the rows, which must match the columns. If err is not nil, it is
+// returned after the rows, like the error of a query that fails after returning some results.
+// The RowIterator behaves like one returned by Query(), so it can be used to test code that reads results, or to
+// present data that doesn't come from Kusto to such code. Unlike Mock(), it can be used outside of tests.
+func NewInMemoryRowIterator(columns table.Columns, rows [][]value.Kusto, err error) (*RowIterator, error) {
+	m, e := NewMockRows(columns)
+	if e != nil {
+		return nil, e
+	}
+	for _, row := range rows {
+		if e := m.Row(row); e != nil {
+			return nil, e
+		}
+	}
+	if err != nil {
+		if e := m.Error(err); e != nil {
+			return nil, e
+		}
+	}
+
+	iter := &RowIterator{op: errors.OpQuery}
+	if e := iter.setMock(m); e != nil {
+		return nil, e
+	}
+	return iter, nil
+}
+
 type mockConn struct {
 }
 
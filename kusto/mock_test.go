@@ -5,6 +5,10 @@ import (
 	"io"
 	"testing"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
@@ -187,3 +191,50 @@ func TestRow(t *testing.T) {
 		}
 	}
 }
+
+func TestNewInMemoryRowIterator(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "ID", Type: types.Long}}
+	rows := [][]value.Kusto{{value.Long{Value: 1, Valid: true}}, {value.Long{Value: 2, Valid: true}}}
+	failure := errors.ES(errors.OpQuery, errors.KLimitsExceeded, "limits exceeded")
+
+	type rec struct {
+		ID int64
+	}
+
+	iter, err := NewInMemoryRowIterator(columns, rows, nil)
+	require.NoError(t, err)
+	var got []rec
+	require.NoError(t, iter.Do(func(r *table.Row) error {
+		v := rec{}
+		if err := r.ToStruct(&v); err != nil {
+			return err
+		}
+		got = append(got, v)
+		return nil
+	}))
+	assert.Equal(t, []rec{{1}, {2}}, got)
+	_, err = iter.Next()
+	assert.Equal(t, io.EOF, err)
+
+	iter, err = NewInMemoryRowIterator(columns, rows, failure)
+	require.NoError(t, err)
+	for i := 0; i < len(rows); i++ {
+		row, err := iter.Next()
+		require.NoError(t, err)
+		assert.Equal(t, value.Values(rows[i]), row.Values)
+	}
+	_, err = iter.Next()
+	assert.Equal(t, failure, err, "the error should be returned after the rows")
+	_, err = iter.Next()
+	assert.Equal(t, failure, err)
+
+	iter, err = NewInMemoryRowIterator(columns, nil, nil)
+	require.NoError(t, err)
+	_, err = iter.Next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewInMemoryRowIterator(columns, [][]value.Kusto{{value.String{Value: "wrong", Valid: true}}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+)
+
+// DefaultPingTimeout is the time Ping() waits for the service, unless ctx has an earlier deadline.
+const DefaultPingTimeout = 10 * time.Second
+
+// Ping checks that the cluster can be reached and that the client is authorized, by running the ".show version"
+// command, which only reads the cluster metadata and has no meaningful cost. It is cheap enough to be used as a
+// readiness probe, and waits at most DefaultPingTimeout.
+// It returns nil on success. Otherwise the error is categorized: errors.KClientArgs if the token could not be acquired
+// or the service refused the credentials, errors.KTimeout if the service didn't answer in time and
+// errors.KHTTPError for network and other service failures.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultPingTimeout)
+	defer cancel()
+
+	err := c.ping(ctx)
+	if err == nil {
+		return nil
+	}
+
+	var tkErr tokenError
+	var httpErr *errors.HttpError
+	switch {
+	case ctx.Err() != nil:
+		return errors.E(errors.OpMgmt, errors.KTimeout, err)
+	case goErrors.As(err, &tkErr):
+		return errors.E(errors.OpMgmt, errors.KClientArgs, err).SetNoRetry()
+	case goErrors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden):
+		return errors.E(errors.OpMgmt, errors.KClientArgs, err).SetNoRetry()
+	}
+	if kErr, ok := errors.GetKustoError(err); ok && kErr.Kind == errors.KHTTPError {
+		return err
+	}
+	return errors.E(errors.OpMgmt, errors.KHTTPError, err)
+}
+
+func (c *Client) ping(ctx context.Context) error {
+	iter, err := c.MgmtCluster(ctx, kql.New(".show version"))
+	if err != nil {
+		return err
+	}
+	defer iter.Stop()
+
+	for {
+		_, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
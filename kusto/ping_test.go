@@ -0,0 +1,110 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const showVersion = `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"BuildVersion","DataType":"String","ColumnType":"string"}],"Rows":[["1.0.0"]]}]}`
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		handler http.HandlerFunc
+		ctx     func() (context.Context, context.CancelFunc)
+		kind    errors.Kind
+		ok      bool
+	}{
+		{
+			desc: "Success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				msg := queryMsg{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+				assert.Equal(t, ".show version", msg.CSL)
+				assert.Equal(t, ClusterDatabase, msg.DB)
+				_, _ = w.Write([]byte(showVersion))
+			},
+			ok: true,
+		},
+		{
+			desc:    "Unauthorized",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+			kind:    errors.KClientArgs,
+		},
+		{
+			desc:    "Service failure",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			kind:    errors.KHTTPError,
+		},
+		{
+			desc: "Timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			kind: errors.KTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			s := httptest.NewServer(test.handler)
+			defer s.Close()
+
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if test.ctx != nil {
+				ctx, cancel = test.ctx()
+			}
+			defer cancel()
+
+			err := retryClient(t, s.URL).Ping(ctx)
+			if test.ok {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			e, ok := errors.GetKustoError(err)
+			require.True(t, ok)
+			assert.Equal(t, test.kind, e.Kind)
+		})
+	}
+}
+
+func TestPingTokenFailure(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("nothing should be sent without a token")
+	}))
+	defer s.Close()
+
+	kcsb := NewConnectionStringBuilder(s.URL)
+	client, err := New(kcsb)
+	require.NoError(t, err)
+	conn := client.conn.(*Conn)
+	conn.endpointValidated.Store(true)
+	conn.auth.TokenProvider = &TokenProvider{tokenCred: failingCredential{}}
+
+	err = client.Ping(context.Background())
+	require.Error(t, err)
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.KClientArgs, e.Kind)
+}
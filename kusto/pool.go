@@ -0,0 +1,121 @@
+package kusto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// DefaultPoolIdleTimeout is the default time a ClientPool keeps a Client that was not requested.
+const DefaultPoolIdleTimeout = 30 * time.Minute
+
+// ClientPool caches Clients by connection string, so code that needs a Client per request, like an HTTP handler,
+// does not pay for creating one and acquiring its tokens every time. A Client is safe for concurrent use and is meant
+// to be long-lived, so the Client returned for a connection string is shared by all the callers of Get().
+// Clients that were not requested for the idle timeout of the pool are evicted by the next call to Get(). An evicted
+// Client is not closed, as callers may still use it, but its token is no longer renewed in the background: it is
+// acquired on demand until the Client is released once they are done with it.
+// A ClientPool is safe for concurrent use.
+type ClientPool struct {
+	mu          sync.Mutex
+	clients     map[string]*pooledClient
+	idleTimeout time.Duration
+	options     []Option
+}
+
+// pooledClient is a Client of a ClientPool, with the last time it was requested.
+type pooledClient struct {
+	client   *Client
+	lastUsed time.Time
+}
+
+// NewClientPool is the constructor for ClientPool. The Clients are created with options. idleTimeout is the time a
+// Client that is not requested is kept, DefaultPoolIdleTimeout if it is zero or less.
+func NewClientPool(idleTimeout time.Duration, options ...Option) *ClientPool {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultPoolIdleTimeout
+	}
+	return &ClientPool{clients: map[string]*pooledClient{}, idleTimeout: idleTimeout, options: options}
+}
+
+// Get returns the Client of the connection string connStr, which is created on the first call, see
+// NewConnectionStringBuilder(). The connection string includes the authentication, so each identity gets its own
+// Client, and the same connection string must be used to share one. An invalid connection string returns an
+// errors.KClientArgs error.
+func (p *ClientPool) Get(connStr string) (*Client, error) {
+	key := strings.TrimSpace(connStr)
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clients == nil {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "the ClientPool is closed").SetNoRetry()
+	}
+
+	for k, pc := range p.clients {
+		if k != key && now.Sub(pc.lastUsed) > p.idleTimeout {
+			if tkp := pc.client.auth.TokenProvider; tkp != nil {
+				tkp.close()
+			}
+			delete(p.clients, k)
+		}
+	}
+
+	if pc, ok := p.clients[key]; ok {
+		pc.lastUsed = now
+		return pc.client, nil
+	}
+
+	kcsb, err := parseConnectionString(key)
+	if err != nil {
+		return nil, err
+	}
+	client, err := New(kcsb, p.options...)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[key] = &pooledClient{client: client, lastUsed: now}
+	return client, nil
+}
+
+// Len returns the number of Clients in the pool.
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Close closes the Clients of the pool. The pool can't be used afterwards.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = nil
+	p.mu.Unlock()
+
+	var err error
+	for _, pc := range clients {
+		if cerr := pc.client.Close(); cerr != nil {
+			if err == nil {
+				err = cerr
+			} else {
+				err = errors.GetCombinedError(err, cerr)
+			}
+		}
+	}
+	return err
+}
+
+// parseConnectionString is NewConnectionStringBuilder(), which panics on an invalid connection string, returning an
+// errors.KClientArgs error instead.
+func parseConnectionString(connStr string) (kcsb *ConnectionStringBuilder, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.ES(errors.OpServConn, errors.KClientArgs, "invalid connection string: %s", fmt.Sprint(r)).SetNoRetry()
+		}
+	}()
+	return NewConnectionStringBuilder(connStr), nil
+}
@@ -0,0 +1,109 @@
+package kusto
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientPool(t *testing.T) {
+	t.Parallel()
+
+	const (
+		connStr      = "https://a.kusto.windows.net;application token=token"
+		otherConnStr = "https://a.kusto.windows.net;application token=other"
+	)
+
+	pool := NewClientPool(time.Hour)
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	clients := make([]*Client, 10)
+	for i := range clients {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := pool.Get(connStr)
+			assert.NoError(t, err)
+			clients[i] = c
+		}()
+	}
+	wg.Wait()
+	for _, c := range clients {
+		assert.Same(t, clients[0], c)
+	}
+
+	other, err := pool.Get(otherConnStr)
+	require.NoError(t, err)
+	assert.NotSame(t, clients[0], other)
+	assert.Equal(t, 2, pool.Len())
+}
+
+func TestClientPoolIdleEviction(t *testing.T) {
+	t.Parallel()
+
+	pool := NewClientPool(10 * time.Millisecond)
+	defer pool.Close()
+
+	first, err := pool.Get("https://a.kusto.windows.net;application token=token")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = pool.Get("https://b.kusto.windows.net;application token=token")
+	require.NoError(t, err)
+	assert.Equal(t, 1, pool.Len())
+
+	again, err := pool.Get("https://a.kusto.windows.net;application token=token")
+	require.NoError(t, err)
+	assert.NotSame(t, first, again)
+}
+
+func TestClientPoolEvictionStopsTokenRefresh(t *testing.T) {
+	t.Parallel()
+
+	pool := NewClientPool(10 * time.Millisecond)
+	defer pool.Close()
+
+	client, err := pool.Get("https://a.kusto.windows.net;application token=token")
+	require.NoError(t, err)
+	cred := &expiringCredential{ttl: 300 * time.Millisecond}
+	client.auth.TokenProvider = refreshingProvider(cred, 250*time.Millisecond, NopLogger{})
+
+	_, _, err = client.auth.TokenProvider.AcquireToken(context.Background())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return cred.count() >= 2 }, 5*time.Second, 5*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = pool.Get("https://b.kusto.windows.net;application token=token")
+	require.NoError(t, err)
+	assert.Equal(t, 1, pool.Len())
+
+	calls := cred.count()
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, calls, cred.count(), "the token of an evicted Client should not be refreshed")
+
+	_, _, err = client.auth.TokenProvider.AcquireToken(context.Background())
+	assert.NoError(t, err, "an evicted Client should still acquire its token")
+}
+
+func TestClientPoolErrors(t *testing.T) {
+	t.Parallel()
+
+	pool := NewClientPool(0)
+	for _, connStr := range []string{"", "https://a.kusto.windows.net;unknown key=1"} {
+		_, err := pool.Get(connStr)
+		e, ok := errors.GetKustoError(err)
+		require.True(t, ok, connStr)
+		assert.Equal(t, errors.KClientArgs, e.Kind)
+	}
+
+	require.NoError(t, pool.Close())
+	_, err := pool.Get("https://a.kusto.windows.net;application token=token")
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package kusto
+
+import (
+	"context"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// Querier is implemented by the types that can run queries and management commands. *Client implements it, and
+// kustotest.FakeClient fakes it, so code written against Querier can be unit tested without a cluster.
+// ingest.Ingestor plays the same role for the ingest clients.
+type Querier interface {
+	Query(ctx context.Context, db string, query Statement, options ...QueryOption) (*RowIterator, error)
+	Mgmt(ctx context.Context, db string, query Statement, options ...QueryOption) (*RowIterator, error)
+}
+
+var _ Querier = (*Client)(nil)
+
+// CallDetails describes the request that a call to Query() or Mgmt() sends. It lets fakes of Querier, such as
+// kustotest.FakeClient, check the QueryOptions they receive, which can't be read otherwise.
+type CallDetails struct {
+	// Query is the text of the query.
+	Query string
+	// Parameters are the values of the query parameters, from WithParameters() or the parameters of the Statement.
+	// They are KQL literals, like `int(1)`.
+	Parameters map[string]string
+	// Options are the request properties set by the QueryOptions, by option name.
+	Options map[string]interface{}
+	// ClientRequestID is the ID set with WithClientRequestID(), if any.
+	ClientRequestID string
+	// Application and User are the values set with Application() and User(), if any.
+	Application string
+	User        string
+}
+
+// NewCallDetails returns the CallDetails of a call to Query(), or Mgmt() if mgmt is true, with query and options.
+// It returns the same errors.KClientArgs errors as those calls if the options are invalid.
+func NewCallDetails(ctx context.Context, query Statement, mgmt bool, options ...QueryOption) (CallDetails, error) {
+	op, call := errors.OpQuery, queryCall
+	if mgmt {
+		op, call = errors.OpMgmt, mgmtCall
+	}
+	opts, err := setQueryOptions(ctx, op, query, call, options...)
+	if err != nil {
+		return CallDetails{}, err
+	}
+
+	props := opts.requestProperties
+	params := props.Parameters
+	if props.QueryParameters.Count() != 0 {
+		params = props.QueryParameters.ToParameterCollection()
+	}
+	return CallDetails{
+		Query:           query.String(),
+		Parameters:      params,
+		Options:         props.Options,
+		ClientRequestID: props.ClientRequestID,
+		Application:     props.Application,
+		User:            props.User,
+	}, nil
+}
@@ -306,6 +306,16 @@ func (p Definitions) clone() Definitions {
 	return p
 }
 
+// names returns the names of the parameters, sorted.
+func (p Definitions) names() []string {
+	names := make([]string, 0, len(p.m))
+	for name := range p.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // QueryValues represents a set of values that are substituted in Parameters. Every QueryValue key
 // must have a corresponding Parameter name. All values must be compatible with the Kusto Column type
 // it will go into (int64 for a long, int32 for int, time.Time for datetime, ...)
@@ -0,0 +1,170 @@
+package kusto
+
+import (
+	"encoding/json"
+	goErrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// ErrQueryStatsNotAvailable is returned by RowIterator.QueryStats() when the response has no query statistics. This is
+// the case for Mgmt() calls, which use the v1 protocol, and for queries that haven't finished yet.
+var ErrQueryStatsNotAvailable = goErrors.New("query statistics are not available")
+
+// queryResourceConsumption is the EventTypeName of the QueryCompletionInformation row that holds the statistics.
+const queryResourceConsumption = "QueryResourceConsumption"
+
+// QueryStats holds the resources a query used, as reported by the service. See
+// https://learn.microsoft.com/azure/data-explorer/kusto/api/rest/response-v2#the-meaning-of-tables-in-the-response
+type QueryStats struct {
+	// ExecutionTime is the time the service spent running the query.
+	ExecutionTime time.Duration
+	// CPUUser, CPUKernel and CPUTotal are the CPU time used by the query, in user and kernel mode, and in total.
+	CPUUser   time.Duration
+	CPUKernel time.Duration
+	CPUTotal  time.Duration
+	// MemoryPeakPerNode is the peak memory used by the query on a node, in bytes.
+	MemoryPeakPerNode int64
+	// CacheMemoryHits, CacheMemoryMisses, CacheDiskHits and CacheDiskMisses are the hits and misses of the memory
+	// and disk caches.
+	CacheMemoryHits   int64
+	CacheMemoryMisses int64
+	CacheDiskHits     int64
+	CacheDiskMisses   int64
+	// ExtentsTotal and ExtentsScanned are the number of extents in the queried tables, and the number the query
+	// scanned.
+	ExtentsTotal   int64
+	ExtentsScanned int64
+	// RowsTotal and RowsScanned are the number of rows in the queried tables, and the number the query scanned.
+	RowsTotal   int64
+	RowsScanned int64
+	// InterClusterBytes and CrossClusterBytes are the bytes sent over the network between the nodes of the cluster,
+	// and to other clusters.
+	InterClusterBytes int64
+	CrossClusterBytes int64
+
+	// Payload is the JSON document the statistics were read from, which has more details.
+	Payload string
+}
+
+// queryStatsPayload is the Payload of the QueryResourceConsumption row.
+type queryStatsPayload struct {
+	ExecutionTime float64
+	ResourceUsage struct {
+		Cache struct {
+			Memory struct {
+				Hits   int64 `json:"hits"`
+				Misses int64 `json:"misses"`
+			} `json:"memory"`
+			Disk struct {
+				Hits   int64 `json:"hits"`
+				Misses int64 `json:"misses"`
+			} `json:"disk"`
+		} `json:"cache"`
+		CPU struct {
+			User   string `json:"user"`
+			Kernel string `json:"kernel"`
+			Total  string `json:"total cpu"`
+		} `json:"cpu"`
+		Memory struct {
+			PeakPerNode int64 `json:"peak_per_node"`
+		} `json:"memory"`
+		Network struct {
+			InterClusterTotalBytes int64 `json:"inter_cluster_total_bytes"`
+			CrossClusterTotalBytes int64 `json:"cross_cluster_total_bytes"`
+		} `json:"network"`
+	} `json:"resource_usage"`
+	InputDatasetStatistics struct {
+		Extents struct {
+			Total   int64 `json:"total"`
+			Scanned int64 `json:"scanned"`
+		} `json:"extents"`
+		Rows struct {
+			Total   int64 `json:"total"`
+			Scanned int64 `json:"scanned"`
+		} `json:"rows"`
+	} `json:"input_dataset_statistics"`
+}
+
+// QueryStats returns the resources the query used, read from the QueryCompletionInformation table of the response.
+// The table is sent after the results, so this is only available once the iteration has ended. It returns
+// ErrQueryStatsNotAvailable if the response has no statistics.
+func (r *RowIterator) QueryStats() (QueryStats, error) {
+	tbl, err := r.GetQueryCompletionInformation()
+	if err != nil {
+		return QueryStats{}, ErrQueryStatsNotAvailable
+	}
+
+	eventCol, payloadCol := -1, -1
+	for i, col := range tbl.Columns {
+		switch col.Name {
+		case "EventTypeName":
+			eventCol = i
+		case "Payload":
+			payloadCol = i
+		}
+	}
+	if eventCol < 0 || payloadCol < 0 {
+		return QueryStats{}, ErrQueryStatsNotAvailable
+	}
+
+	for _, row := range tbl.KustoRows {
+		if len(row) <= eventCol || len(row) <= payloadCol {
+			continue
+		}
+		if event, ok := row[eventCol].(value.String); !ok || event.Value != queryResourceConsumption {
+			continue
+		}
+		payload, ok := row[payloadCol].(value.String)
+		if !ok {
+			continue
+		}
+		return parseQueryStats(payload.Value)
+	}
+	return QueryStats{}, ErrQueryStatsNotAvailable
+}
+
+func parseQueryStats(payload string) (QueryStats, error) {
+	p := queryStatsPayload{}
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return QueryStats{}, fmt.Errorf("could not decode the query statistics: %w", err)
+	}
+
+	stats := QueryStats{
+		ExecutionTime:     time.Duration(p.ExecutionTime * float64(time.Second)),
+		MemoryPeakPerNode: p.ResourceUsage.Memory.PeakPerNode,
+		CacheMemoryHits:   p.ResourceUsage.Cache.Memory.Hits,
+		CacheMemoryMisses: p.ResourceUsage.Cache.Memory.Misses,
+		CacheDiskHits:     p.ResourceUsage.Cache.Disk.Hits,
+		CacheDiskMisses:   p.ResourceUsage.Cache.Disk.Misses,
+		ExtentsTotal:      p.InputDatasetStatistics.Extents.Total,
+		ExtentsScanned:    p.InputDatasetStatistics.Extents.Scanned,
+		RowsTotal:         p.InputDatasetStatistics.Rows.Total,
+		RowsScanned:       p.InputDatasetStatistics.Rows.Scanned,
+		InterClusterBytes: p.ResourceUsage.Network.InterClusterTotalBytes,
+		CrossClusterBytes: p.ResourceUsage.Network.CrossClusterTotalBytes,
+		Payload:           payload,
+	}
+
+	cpu := []struct {
+		s string
+		d *time.Duration
+	}{
+		{p.ResourceUsage.CPU.User, &stats.CPUUser},
+		{p.ResourceUsage.CPU.Kernel, &stats.CPUKernel},
+		{p.ResourceUsage.CPU.Total, &stats.CPUTotal},
+	}
+	for _, c := range cpu {
+		if c.s == "" {
+			continue
+		}
+		ts := value.Timespan{}
+		if err := ts.Unmarshal(c.s); err != nil {
+			return QueryStats{}, fmt.Errorf("could not decode the CPU time of the query statistics: %w", err)
+		}
+		*c.d = ts.Value
+	}
+	return stats, nil
+}
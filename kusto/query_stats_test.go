@@ -0,0 +1,117 @@
+package kusto
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStatsPayload = `{"ExecutionTime":0.0156,"resource_usage":{"cache":{"memory":{"hits":3,"misses":1,"total":4},` +
+	`"disk":{"hits":2,"misses":0,"total":2}},"cpu":{"user":"00:00:00.0312500","kernel":"00:00:00","total cpu":"00:00:00.0312500"},` +
+	`"memory":{"peak_per_node":524384},"network":{"inter_cluster_total_bytes":1024,"cross_cluster_total_bytes":0}},` +
+	`"input_dataset_statistics":{"extents":{"total":10,"scanned":4},"rows":{"total":1000,"scanned":400}}}`
+
+func completionInformation(events ...string) v2.DataTable {
+	tbl := v2.DataTable{
+		TableKind: frames.QueryCompletionInformation,
+		TableName: frames.QueryCompletionInformation,
+		Columns: table.Columns{
+			{Name: "Level", Type: "int"},
+			{Name: "EventTypeName", Type: "string"},
+			{Name: "Payload", Type: "string"},
+		},
+	}
+	for i := 0; i < len(events); i += 2 {
+		tbl.KustoRows = append(tbl.KustoRows, value.Values{
+			value.Int{Value: 4, Valid: true},
+			value.String{Value: events[i], Valid: true},
+			value.String{Value: events[i+1], Valid: true},
+		})
+	}
+	return tbl
+}
+
+func TestQueryStats(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		stream  []frames.Frame
+		want    QueryStats
+		wantErr error
+	}{
+		{
+			desc: "Statistics",
+			stream: []frames.Frame{
+				v2.DataTable{TableKind: frames.PrimaryResult, Columns: table.Columns{{Name: "ID", Type: "long"}}},
+				completionInformation("QueryInfo", `{"Count":1,"Text":"Query completed successfully"}`, queryResourceConsumption, testStatsPayload),
+				v2.DataSetCompletion{},
+			},
+			want: QueryStats{
+				ExecutionTime:     15600 * time.Microsecond,
+				CPUUser:           31250 * time.Microsecond,
+				CPUTotal:          31250 * time.Microsecond,
+				MemoryPeakPerNode: 524384,
+				CacheMemoryHits:   3,
+				CacheMemoryMisses: 1,
+				CacheDiskHits:     2,
+				ExtentsTotal:      10,
+				ExtentsScanned:    4,
+				RowsTotal:         1000,
+				RowsScanned:       400,
+				InterClusterBytes: 1024,
+				Payload:           testStatsPayload,
+			},
+		},
+		{
+			desc: "No statistics row",
+			stream: []frames.Frame{
+				v2.DataTable{TableKind: frames.PrimaryResult, Columns: table.Columns{{Name: "ID", Type: "long"}}},
+				completionInformation("QueryInfo", `{"Count":1,"Text":"Query completed successfully"}`),
+				v2.DataSetCompletion{},
+			},
+			wantErr: ErrQueryStatsNotAvailable,
+		},
+		{
+			desc: "No QueryCompletionInformation table",
+			stream: []frames.Frame{
+				v2.DataTable{TableKind: frames.PrimaryResult, Columns: table.Columns{{Name: "ID", Type: "long"}}},
+				v2.DataSetCompletion{},
+			},
+			wantErr: ErrQueryStatsNotAvailable,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			createSm := func(iter *RowIterator, toSM chan frames.Frame) stateMachine {
+				return &nonProgressiveSM{op: errors.OpQuery, iter: iter, in: toSM, ctx: context.Background(), wg: &sync.WaitGroup{}}
+			}
+			streamStateMachine(test.stream, createSm, func(iter *RowIterator) {
+				_, err := iterateRows(iter)
+				require.NoError(t, err)
+
+				got, err := iter.QueryStats()
+				if test.wantErr != nil {
+					assert.ErrorIs(t, err, test.wantErr)
+					assert.Equal(t, QueryStats{}, got)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, test.want, got)
+			})
+		})
+	}
+}
@@ -4,7 +4,9 @@ package kusto
 // it clogs up the main kusto.go file.
 
 import (
+	"fmt"
 	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"io"
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
@@ -25,7 +27,25 @@ type requestProperties struct {
 type queryOptions struct {
 	requestProperties *requestProperties
 	queryIngestion    bool
-}
+	requestTimeout    time.Duration
+	// caseInsensitiveColumns is set on the rows returned by the query, see table.Row.CaseInsensitiveColumns.
+	caseInsensitiveColumns bool
+	// columnNameMapper is set on the rows returned by the query, see table.Row.ColumnNameMapper.
+	columnNameMapper func(column string) string
+	// errorOnEmpty is set by WithErrorOnEmpty().
+	errorOnEmpty bool
+	// responseCapture receives a copy of the response body, see WithResponseCapture().
+	responseCapture io.Writer
+	// allowUnusedParameters is set by WithAllowUnusedParameters().
+	allowUnusedParameters bool
+	// crossClusterAuth is set by WithCrossClusterAuth().
+	crossClusterAuth bool
+	// serverCancellation is set by WithServerCancellation().
+	serverCancellation bool
+}
+
+// maxRequestTimeout is the longest server timeout Kusto accepts for a request.
+const maxRequestTimeout = time.Hour
 
 const ResultsProgressiveEnabledValue = "results_progressive_enabled"
 const NoRequestTimeoutValue = "norequesttimeout"
@@ -87,6 +107,19 @@ func ClientRequestID(clientRequestID string) QueryOption {
 	}
 }
 
+// WithClientRequestID overrides the generated "KGC.execute;<uuid>" x-ms-client-request-id header of a Query() or
+// Mgmt() call with id. This is the same as ClientRequestID(). The id that was sent for a call is returned by
+// RowIterator.ClientRequestID(), whether it was set with this option or generated.
+func WithClientRequestID(id string) QueryOption {
+	return func(q *queryOptions) error {
+		if id == "" {
+			return fmt.Errorf("WithClientRequestID() cannot be given an empty id")
+		}
+		q.requestProperties.ClientRequestID = id
+		return nil
+	}
+}
+
 // Application sets the x-ms-app header, and can be used to identify the application making the request in the `.show queries` output.
 func Application(appName string) QueryOption {
 	return func(q *queryOptions) error {
@@ -95,11 +128,35 @@ func Application(appName string) QueryOption {
 	}
 }
 
-// QueryParameters sets the parameters to be used in the query.
+// QueryParameters sets the parameters to be used in the query. It is the same as WithParameters().
 func QueryParameters(queryParameters *kql.Parameters) QueryOption {
+	return WithParameters(queryParameters)
+}
+
+// WithParameters sets the parameters to be used in the query. The `declare query_parameters(...)` statement is
+// added to the query for them, and their values are sent as typed KQL literals, so they can't change the query.
+// Parameters with invalid names, or that the query never references, cause the call to fail, see
+// WithAllowUnusedParameters(), and so do references to parameters with '@', as in SQL.
+func WithParameters(params *kql.Parameters) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.QueryParameters = *queryParameters
-		q.requestProperties.Parameters = queryParameters.ToParameterCollection()
+		if params == nil {
+			return fmt.Errorf("WithParameters() cannot be given nil parameters")
+		}
+		if err := params.Err(); err != nil {
+			return err
+		}
+		q.requestProperties.QueryParameters = *params
+		q.requestProperties.Parameters = params.ToParameterCollection()
+		return nil
+	}
+}
+
+// WithAllowUnusedParameters allows parameters that the query doesn't reference, which otherwise cause the call to
+// fail, such as for a generated query that only uses some of them. It applies to both WithParameters() and the
+// Definitions of a Stmt. This is a client side option and is not sent to the service.
+func WithAllowUnusedParameters() QueryOption {
+	return func(q *queryOptions) error {
+		q.allowUnusedParameters = true
 		return nil
 	}
 }
@@ -136,6 +193,61 @@ func ResultsProgressiveEnabled() QueryOption {
 	}
 }
 
+// WithProgressiveResults enables the progressive query stream, so the rows of large results are returned as the
+// table fragments arrive instead of after each table has been fully received. Fragments of the same table are
+// stitched together by the RowIterator; a Row with Replace set means the rows received before it should be discarded.
+// This is the same as ResultsProgressiveEnabled().
+func WithProgressiveResults() QueryOption {
+	return ResultsProgressiveEnabled()
+}
+
+// WithCaseInsensitiveColumns makes Row.ToStruct() match the columns of the result to struct fields ignoring case,
+// when no field has the exact name or a matching `kusto` tag. Columns that match more than one field this way
+// return an errors.KClientArgs error. This is a client side option and is not sent to the service.
+func WithCaseInsensitiveColumns() QueryOption {
+	return func(q *queryOptions) error {
+		q.caseInsensitiveColumns = true
+		return nil
+	}
+}
+
+// WithColumnNameMapper makes Row.ToStruct() store each column of the result that doesn't match a field by its name or
+// its `kusto` tag in the untagged struct field named mapper(column), such as with a function that converts the
+// snake_case column names to the CamelCase field names. With WithCaseInsensitiveColumns(), the names returned by mapper
+// are matched ignoring case. Columns that map to the same field return an errors.KClientArgs error. This is a client
+// side option and is not sent to the service.
+func WithColumnNameMapper(mapper func(column string) string) QueryOption {
+	return func(q *queryOptions) error {
+		q.columnNameMapper = mapper
+		return nil
+	}
+}
+
+// WithErrorOnEmpty makes the RowIterator of the call return an error that matches errors.ErrNoRows, with errors.Is(),
+// instead of io.EOF, if the primary result has no rows. It only applies to the calls that use it, so queries that
+// are expected to return an empty table are not affected. This is a client side option and is not sent to the service.
+func WithErrorOnEmpty() QueryOption {
+	return func(q *queryOptions) error {
+		q.errorOnEmpty = true
+		return nil
+	}
+}
+
+// WithResponseCapture writes a copy of the body of the response to w, as it is read, which helps to report responses
+// that the client fails to parse. The body is written after it is decompressed, so w receives the JSON frames.
+// Only the response of the successful attempt is written, and the bodies of HTTP errors are not. Errors from w
+// are ignored and stop the capture, so they don't change how the response is parsed.
+// This is a client side option that is not sent to the service. It has a cost, so it shouldn't be used in production.
+func WithResponseCapture(w io.Writer) QueryOption {
+	return func(q *queryOptions) error {
+		if w == nil {
+			return fmt.Errorf("WithResponseCapture() cannot be given a nil io.Writer")
+		}
+		q.responseCapture = w
+		return nil
+	}
+}
+
 // ServerTimeout overrides the default request timeout.
 func ServerTimeout(d time.Duration) QueryOption {
 	return func(q *queryOptions) error {
@@ -144,6 +256,22 @@ func ServerTimeout(d time.Duration) QueryOption {
 	}
 }
 
+// WithRequestTimeout sets the server side timeout of the request to d, so that the service stops working on it once
+// d has passed. If the context has a deadline that is sooner, the time remaining until the deadline is used instead.
+// d must be positive and at most one hour, the maximum Kusto allows.
+func WithRequestTimeout(d time.Duration) QueryOption {
+	return func(q *queryOptions) error {
+		if d <= 0 {
+			return fmt.Errorf("WithRequestTimeout() must be positive, was %s", d)
+		}
+		if d > maxRequestTimeout {
+			return fmt.Errorf("WithRequestTimeout() cannot be more than %s, was %s", maxRequestTimeout, d)
+		}
+		q.requestTimeout = d
+		return nil
+	}
+}
+
 // CustomQueryOption exists to allow a QueryOption that is not defined in the Go SDK, as all options
 // are not defined. Please Note: you should always use the type safe options provided below when available.
 // Also note that Kusto does not error on non-existent parameter names or bad values, it simply doesn't
@@ -19,6 +19,8 @@ import (
 
 // send allows us to send a table on a channel and know when everything has been written.
 type send struct {
+	// inTable, if set, starts a new result table, whose rows are inRows and the ones sent after it.
+	inTable             *tableInfo
 	inColumns           table.Columns
 	inRows              []value.Values
 	inRowErrors         []errors.Error
@@ -43,9 +45,17 @@ type Row struct {
 	Values  value.Values
 	Error   *errors.Error
 	Replace bool
+
+	// table, if set, marks the start of a result table, and the Row has no values. See RowIterator.Tables().
+	table *tableInfo
 }
 
 // RowIterator is used to iterate over the returned Row objects returned by Kusto.
+//
+// When a query fails after the service has returned some rows, for instance because it exceeded a query limit, the
+// rows received before the failure are returned first, and the error that ended the query is returned last, instead of
+// io.EOF. Limit errors are of Kind errors.KLimitsExceeded. Callers that iterate must always check that trailing error,
+// as the rows they have seen are then incomplete.
 type RowIterator struct {
 	op     errors.Op
 	ctx    context.Context
@@ -62,7 +72,6 @@ type RowIterator struct {
 	inProgress   chan send
 	inNonPrimary chan send
 	inCompletion chan send
-	inErr        chan send
 
 	rows chan Row
 
@@ -76,12 +85,29 @@ type RowIterator struct {
 	nonPrimary map[frames.TableKind]v2.DataTable
 	// dsCompletion is the completion frame for a non-progressive query.
 	dsCompletion v2.DataSetCompletion
+	// partial indicates that the service reported the results are incomplete.
+	partial bool
+	// caseInsensitiveColumns is set on every returned Row, see WithCaseInsensitiveColumns().
+	caseInsensitiveColumns bool
+	// columnNameMapper is set on every returned Row, see WithColumnNameMapper().
+	columnNameMapper func(column string) string
+	// errorOnEmpty makes the end of a result with no rows an errors.ErrNoRows error, see WithErrorOnEmpty().
+	// sawRow is set once a row was returned.
+	errorOnEmpty, sawRow bool
+	// streamEnded, if set, is called once the response was read, before the end of the rows is returned, with the error
+	// that ended the stream, or nil. If Stop() is called first, it is called then with nil. See endStream().
+	streamEnded func(err error)
+	// streamEndedOnce makes streamEnded be called only once.
+	streamEndedOnce sync.Once
 
 	columns table.Columns
 
 	// error holds an error that was encountered. Once this is set, all calls on Rowiterator will
 	// just return the error here.
 	error error
+	// streamErr holds the error that ended the stream. It is only returned once the rows received before it have
+	// been read.
+	streamErr error
 
 	// mock hold our MockRows data if it has been provided for tests.
 	mock *MockRows
@@ -105,7 +131,6 @@ func newRowIterator(ctx context.Context, cancel context.CancelFunc, execResp exe
 		inProgress:   make(chan send, 5),
 		inNonPrimary: make(chan send, 5),
 		inCompletion: make(chan send, 5),
-		inErr:        make(chan send),
 
 		rows:       make(chan Row, 1000),
 		nonPrimary: make(map[frames.TableKind]v2.DataTable),
@@ -114,6 +139,20 @@ func newRowIterator(ctx context.Context, cancel context.CancelFunc, execResp exe
 	return ri, columnsReady
 }
 
+// Partial reports if the service indicated that the results are incomplete, because the query had partial failures
+// or was cancelled. It is only final once the iteration has ended.
+func (r *RowIterator) Partial() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.partial
+}
+
+// ClientRequestID returns the x-ms-client-request-id that was sent to the server with the request. Support can use it
+// to find the request in the service logs. See WithClientRequestID().
+func (r *RowIterator) ClientRequestID() string {
+	return r.RequestHeader.Get(ClientRequestIdHeader)
+}
+
 func (r *RowIterator) start() chan struct{} {
 	done := make(chan struct{})
 	once := sync.Once{}
@@ -136,6 +175,19 @@ func (r *RowIterator) start() chan struct{} {
 					close(r.rows)
 					return
 				}
+				if sent.inErr != nil {
+					r.mu.Lock()
+					r.streamErr = sent.inErr
+					r.mu.Unlock()
+					close(r.rows)
+					return
+				}
+				if sent.inTable != nil {
+					select {
+					case <-r.ctx.Done():
+					case r.rows <- Row{table: sent.inTable}:
+					}
+				}
 				if sent.inRows != nil {
 					for k, values := range sent.inRows {
 						select {
@@ -145,6 +197,11 @@ func (r *RowIterator) start() chan struct{} {
 					}
 				}
 
+				if len(sent.inRowErrors) > 0 {
+					r.mu.Lock()
+					r.partial = true
+					r.mu.Unlock()
+				}
 				if sent.inRowErrors != nil {
 					for _, e := range sent.inRowErrors {
 						e := e // capture so we can send reference
@@ -168,13 +225,11 @@ func (r *RowIterator) start() chan struct{} {
 			case sent := <-r.inCompletion:
 				r.mu.Lock()
 				r.dsCompletion = sent.inCompletion
+				if sent.inCompletion.HasErrors || sent.inCompletion.Cancelled {
+					r.partial = true
+				}
 				sent.done()
 				r.mu.Unlock()
-			case sent := <-r.inErr:
-				r.setError(sent.inErr)
-				sent.done()
-				close(r.rows)
-				return
 			}
 		}
 	}()
@@ -187,12 +242,17 @@ func (r *RowIterator) Mock(m *MockRows) error {
 	if !isTest() {
 		panic("cannot call Mock outside a test")
 	}
+	return r.setMock(m)
+}
+
+func (r *RowIterator) setMock(m *MockRows) error {
 	if r.mock != nil {
 		return fmt.Errorf("RowIterator already has mock data")
 	}
 	r.ctx, r.cancel = context.WithCancel(context.Background())
 
 	r.mock = m
+	r.columns = m.columns
 	return nil
 }
 
@@ -200,15 +260,40 @@ func (r *RowIterator) Mock(m *MockRows) error {
 // Do calls f for every row returned by the query. If f returns a non-nil error, iteration stops.
 // This method will fail on errors inline within the rows, even though they could potentially be recovered and more data might be available.
 // This behavior is to keep the interface compatible.
+// If the query fails after returning rows, f is called for those rows and the error is returned once they are done,
+// so a nil error is the only indication that all the rows were received.
 func (r *RowIterator) Do(f func(r *table.Row) error) error {
+	return r.DoContext(context.Background(), f)
+}
+
+// DoContext is like Do, but stops once ctx is done, even while waiting for the next row. f is not called after ctx is
+// done. The query is then stopped, like calling Stop(), and a KTimeout error wrapping ctx.Err() is returned.
+func (r *RowIterator) DoContext(ctx context.Context, f func(r *table.Row) error) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Stop()
+		case <-done:
+		}
+	}()
+
 	for {
 		row, err := r.Next()
 		if err != nil {
 			if err == io.EOF {
 				return nil
 			}
+			if ctx.Err() != nil {
+				return errors.E(r.op, errors.KTimeout, ctx.Err())
+			}
 			return err
 		}
+
+		if ctx.Err() != nil {
+			return errors.E(r.op, errors.KTimeout, ctx.Err())
+		}
 		if err := f(row); err != nil {
 			return err
 		}
@@ -216,7 +301,7 @@ func (r *RowIterator) Do(f func(r *table.Row) error) error {
 }
 
 // DoOnRowOrError calls f for every row returned by the query. If errors occur inline within the rows, they are passed to f.
-// Other errors will stop the iteration and be returned.
+// Other errors will stop the iteration and be returned, after f was called for the rows received before them.
 // If f returns a non-nil error, iteration stops.
 func (r *RowIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error) error {
 	for {
@@ -236,9 +321,19 @@ func (r *RowIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error
 // Stop is called to stop any further iteration. Always defer a Stop() call after
 // receiving a RowIterator.
 func (r *RowIterator) Stop() {
+	r.endStream(nil)
 	r.cancel()
 }
 
+// endStream calls streamEnded, if set, with err the first time it is called.
+func (r *RowIterator) endStream(err error) {
+	r.streamEndedOnce.Do(func() {
+		if r.streamEnded != nil {
+			r.streamEnded(err)
+		}
+	})
+}
+
 // Deprecated: Use NextRowOrError() instead for more robust error handling. In a future version, this will be removed, and NextRowOrError will replace it.
 // Next gets the next Row from the query. io.EOF is returned if there are no more entries in the output.
 // This method will fail on errors inline within the rows, even though they could potentially be recovered and more data might be available.
@@ -259,7 +354,8 @@ func (r *RowIterator) Next() (row *table.Row, finalError error) {
 // On partial success, inlineError will be set.
 // Once finalError returns non-nil, all subsequent calls will return the same error.
 // finalError will be set to io.EOF is when frame parsing completed with success or partial success (data + errors).
-// if finalError is not io.EOF, reading the frame has resulted in a failure state (no data is expected).
+// if finalError is not io.EOF, reading the frame has resulted in a failure state. It is only returned after the rows
+// that were received before the failure, and no more rows are expected.
 func (r *RowIterator) NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
 	if err := r.getError(); err != nil {
 		return nil, nil, err
@@ -273,23 +369,46 @@ func (r *RowIterator) NextRowOrError() (row *table.Row, inlineError *errors.Erro
 		if err != nil {
 			return nil, nil, err
 		}
+		nextRow.CaseInsensitiveColumns = r.caseInsensitiveColumns
+		nextRow.ColumnNameMapper = r.columnNameMapper
 		return nextRow, nil, nil
 	}
 
+	for {
+		kvs, err := r.nextStreamRow()
+		if err == io.EOF && r.errorOnEmpty && !r.sawRow {
+			err = errors.E(r.op, errors.KOther, errors.ErrNoRows).SetNoRetry()
+			r.setError(err)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if kvs.table != nil {
+			// The rows of all the result tables are returned as the rows of the first one.
+			continue
+		}
+		if kvs.Error != nil {
+			return nil, kvs.Error, nil
+		}
+		r.sawRow = true
+		return &table.Row{ColumnTypes: r.columns, Values: kvs.Values, Op: r.op, Replace: kvs.Replace, CaseInsensitiveColumns: r.caseInsensitiveColumns, ColumnNameMapper: r.columnNameMapper}, nil, nil
+	}
+}
+
+// nextStreamRow returns the next Row received from the service, including the ones that start a result table. It
+// returns io.EOF, or the error that ended the stream, once all the rows have been read.
+func (r *RowIterator) nextStreamRow() (Row, error) {
 	select {
 	case <-r.ctx.Done():
-		return nil, nil, r.ctx.Err()
+		return Row{}, r.ctx.Err()
 	case kvs, ok := <-r.rows:
 		if !ok {
-			if err := r.getError(); err != nil {
-				return nil, nil, err
+			if err := r.endError(); err != nil {
+				return Row{}, err
 			}
-			return nil, nil, io.EOF
-		}
-		if kvs.Error != nil {
-			return nil, kvs.Error, nil
+			return Row{}, io.EOF
 		}
-		return &table.Row{ColumnTypes: r.columns, Values: kvs.Values, Op: r.op, Replace: kvs.Replace}, nil, nil
+		return kvs, nil
 	}
 }
 
@@ -305,6 +424,17 @@ func (r *RowIterator) setError(e error) {
 	r.error = e
 }
 
+// endError returns the error that ended the stream, if any, once all the rows have been read. It is then returned by
+// all subsequent calls.
+func (r *RowIterator) endError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.error == nil {
+		r.error = r.streamErr
+	}
+	return r.error
+}
+
 // Progress returns the progress of the query, 0-100%. This is only valid on Progressive data returns.
 func (r *RowIterator) Progress() float64 {
 	r.mu.Lock()
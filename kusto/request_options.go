@@ -0,0 +1,185 @@
+package kusto
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// optionType is the type of the value of a client request property.
+type optionType int
+
+const (
+	optionBool optionType = iota
+	optionString
+	optionInt
+	optionTimespan
+	optionDatetime
+	optionStrings
+)
+
+func (t optionType) String() string {
+	switch t {
+	case optionBool:
+		return "a bool"
+	case optionString:
+		return "a string"
+	case optionInt:
+		return "an integer"
+	case optionTimespan:
+		return "a time.Duration or a timespan string"
+	case optionDatetime:
+		return "a time.Time or a datetime string"
+	case optionStrings:
+		return "a []string"
+	}
+	return "unknown"
+}
+
+// knownOptionTypes are the types of the values of the client request properties the SDK knows of, by name.
+var knownOptionTypes = map[string]optionType{
+	ResultsProgressiveEnabledValue:           optionBool,
+	NoRequestTimeoutValue:                    optionBool,
+	NoTruncationValue:                        optionBool,
+	ServerTimeoutValue:                       optionTimespan,
+	DeferPartialQueryFailuresValue:           optionBool,
+	MaxMemoryConsumptionPerQueryPerNodeValue: optionInt,
+	MaxMemoryConsumptionPerIteratorValue:     optionInt,
+	MaxOutputColumnsValue:                    optionInt,
+	PushSelectionThroughAggregationValue:     optionBool,
+	QueryCursorAfterDefaultValue:             optionString,
+	QueryCursorBeforeOrAtDefaultValue:        optionString,
+	QueryCursorCurrentValue:                  optionString,
+	QueryCursorDisabledValue:                 optionString,
+	QueryCursorScopedTablesValue:             optionStrings,
+	QueryDatascopeValue:                      optionString,
+	QueryDateTimeScopeColumnValue:            optionString,
+	QueryDateTimeScopeFromValue:              optionDatetime,
+	QueryDateTimeScopeToValue:                optionDatetime,
+	ClientMaxRedirectCountValue:              optionInt,
+	MaterializedViewShuffleValue:             optionString,
+	QueryBinAutoAtValue:                      optionString,
+	QueryBinAutoSizeValue:                    optionString,
+	QueryDistributionNodesSpanValue:          optionInt,
+	QueryFanoutNodesPercentValue:             optionInt,
+	QueryFanoutThreadsPercentValue:           optionInt,
+	QueryForceRowLevelSecurityValue:          optionBool,
+	QueryLanguageValue:                       optionString,
+	QueryLogQueryParametersValue:             optionBool,
+	QueryMaxEntitiesInUnionValue:             optionInt,
+	QueryNowValue:                            optionDatetime,
+	QueryPythonDebugValue:                    optionInt,
+	QueryResultsApplyGetschemaValue:          optionBool,
+	QueryResultsCacheMaxAgeValue:             optionTimespan,
+	QueryResultsCachePerShardValue:           optionBool,
+	QueryResultsProgressiveRowCountValue:     optionInt,
+	QueryResultsProgressiveUpdatePeriodValue: optionInt,
+	QueryTakeMaxRecordsValue:                 optionInt,
+	QueryConsistencyValue:                    optionString,
+	RequestAppNameValue:                      optionString,
+	RequestBlockRowLevelSecurityValue:        optionBool,
+	RequestCalloutDisabledValue:              optionBool,
+	RequestDescriptionValue:                  optionString,
+	RequestExternalTableDisabledValue:        optionBool,
+	RequestImpersonationDisabledValue:        optionBool,
+	RequestReadonlyValue:                     optionBool,
+	RequestRemoteEntitiesDisabledValue:       optionBool,
+	RequestSandboxedExecutionDisabledValue:   optionBool,
+	RequestUserValue:                         optionString,
+	TruncationMaxRecordsValue:                optionInt,
+	TruncationMaxSizeValue:                   optionInt,
+	ValidatePermissionsValue:                 optionBool,
+}
+
+// WithRequestOption sets the client request property name to v, for the properties the SDK has no option for, like
+// the restrictions of a request. The value of a property the SDK knows of must have its type: a bool, a string, an
+// integer, a time.Duration for timespans, a time.Time for datetimes or a []string. The value of any other property
+// must marshal to JSON. Unlike CustomQueryOption(), invalid values fail the call with an errors.KClientArgs error.
+func WithRequestOption(name string, v interface{}) QueryOption {
+	return func(q *queryOptions) error {
+		if name == "" {
+			return fmt.Errorf("WithRequestOption() requires a name")
+		}
+		serialized, err := serializeOption(name, v)
+		if err != nil {
+			return fmt.Errorf("WithRequestOption(%q): %w", name, err)
+		}
+		q.requestProperties.Options[name] = serialized
+		return nil
+	}
+}
+
+// serializeOption returns the value of the client request property name, as it is sent to the service.
+func serializeOption(name string, v interface{}) (interface{}, error) {
+	t, ok := knownOptionTypes[name]
+	if !ok {
+		if _, err := json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("the value cannot be marshaled to JSON: %w", err)
+		}
+		return v, nil
+	}
+
+	invalid := fmt.Errorf("the value must be %s, was %T", t, v)
+	switch t {
+	case optionBool:
+		if _, ok := v.(bool); ok {
+			return v, nil
+		}
+	case optionString:
+		if _, ok := v.(string); ok {
+			return v, nil
+		}
+	case optionInt:
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return v, nil
+		}
+	case optionTimespan:
+		switch v := v.(type) {
+		case time.Duration:
+			return value.Timespan{Value: v, Valid: true}.Marshal(), nil
+		case string:
+			return v, nil
+		}
+	case optionDatetime:
+		switch v := v.(type) {
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		case string:
+			return v, nil
+		}
+	case optionStrings:
+		if _, ok := v.([]string); ok {
+			return v, nil
+		}
+	}
+	return nil, invalid
+}
+
+// WithReadOnly makes the service fail the request if it would write data or metadata. This is the same as
+// RequestReadonly().
+func WithReadOnly() QueryOption {
+	return RequestReadonly()
+}
+
+// WithNoTruncation suppresses the truncation of the results returned to the caller. This is the same as
+// NoTruncation().
+func WithNoTruncation() QueryOption {
+	return NoTruncation()
+}
+
+// WithMaxMemoryPerQueryPerNode sets the maximum amount of memory, in bytes, the query may allocate per node. bytes must be
+// positive.
+func WithMaxMemoryPerQueryPerNode(bytes int64) QueryOption {
+	return func(q *queryOptions) error {
+		if bytes <= 0 {
+			return fmt.Errorf("WithMaxMemoryPerQueryPerNode() must be positive, was %d", bytes)
+		}
+		q.requestProperties.Options[MaxMemoryConsumptionPerQueryPerNodeValue] = bytes
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package kusto
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRequestOption(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		desc    string
+		option  QueryOption
+		name    string
+		want    interface{}
+		wantErr string
+	}{
+		{desc: "Unknown option", option: WithRequestOption("my_option", map[string]int{"a": 1}), name: "my_option", want: map[string]int{"a": 1}},
+		{desc: "Bool", option: WithRequestOption(RequestReadonlyValue, true), name: RequestReadonlyValue, want: true},
+		{desc: "String", option: WithRequestOption(RequestAppNameValue, "app"), name: RequestAppNameValue, want: "app"},
+		{desc: "Integer", option: WithRequestOption(TruncationMaxRecordsValue, int32(10)), name: TruncationMaxRecordsValue, want: int32(10)},
+		{desc: "Timespan", option: WithRequestOption(ServerTimeoutValue, time.Minute), name: ServerTimeoutValue, want: "00:01:00"},
+		{desc: "Datetime", option: WithRequestOption(QueryNowValue, now), name: QueryNowValue, want: "2023-01-02T03:04:05Z"},
+		{desc: "Strings", option: WithRequestOption(QueryCursorScopedTablesValue, []string{"a"}), name: QueryCursorScopedTablesValue, want: []string{"a"}},
+		{desc: "WithReadOnly", option: WithReadOnly(), name: RequestReadonlyValue, want: true},
+		{desc: "WithNoTruncation", option: WithNoTruncation(), name: NoTruncationValue, want: true},
+		{desc: "WithMaxMemoryPerQueryPerNode", option: WithMaxMemoryPerQueryPerNode(1 << 30), name: MaxMemoryConsumptionPerQueryPerNodeValue, want: int64(1 << 30)},
+		{desc: "Empty name", option: WithRequestOption("", true), wantErr: "requires a name"},
+		{desc: "Wrong type", option: WithRequestOption(RequestReadonlyValue, "true"), wantErr: "the value must be a bool, was string"},
+		{desc: "Wrong integer type", option: WithRequestOption(TruncationMaxRecordsValue, 1.5), wantErr: "the value must be an integer, was float64"},
+		{desc: "Not JSON", option: WithRequestOption("my_option", math.Inf(1)), wantErr: "cannot be marshaled to JSON"},
+		{desc: "Non positive memory", option: WithMaxMemoryPerQueryPerNode(0), wantErr: "must be positive"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			details, err := NewCallDetails(context.Background(), kql.New("test"), false, test.option)
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, errors.KClientArgs, err.(*errors.Error).Kind)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, details.Options[test.name])
+		})
+	}
+}
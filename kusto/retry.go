@@ -0,0 +1,161 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// RetryPolicy controls how Query() and Mgmt() calls are retried when the request to the service fails.
+// Only the request is retried: once the service starts streaming a response, errors are returned as is.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. Values <= 1 disable retries.
+	MaxAttempts int
+	// BaseBackoff is the backoff before the first retry. It doubles on every retry, with jitter.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the backoff between two attempts, including the one asked for by the Retry-After header of a
+	// throttled response.
+	MaxBackoff time.Duration
+	// ShouldRetry decides if a failed attempt should be retried. attempt starts at 1. If nil, DefaultShouldRetry is used.
+	ShouldRetry func(err error, attempt int) bool
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by WithRetryPolicy() for any field that is not set.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		ShouldRetry: DefaultShouldRetry,
+	}
+}
+
+// connRetries is the number of times a query is resent, on top of the retry policy, when the connection fails before
+// any response was received.
+const connRetries = 2
+
+// isConnectionError reports whether the request failed because the connection was reset or closed before any response
+// was received, in which case the service did not get, or did not answer, the request.
+func isConnectionError(err error) bool {
+	var send sendError
+	if !goErrors.As(err, &send) {
+		return false
+	}
+	return goErrors.Is(send, syscall.ECONNRESET) || goErrors.Is(send, io.EOF)
+}
+
+// DefaultShouldRetry retries errors of kind errors.KTimeout, responses with HTTP status 429 (throttling) or 503, and
+// connections that were reset before any response was received, unless the error was marked as permanent.
+func DefaultShouldRetry(err error, _ int) bool {
+	if isConnectionError(err) {
+		return true
+	}
+
+	var httpErr *errors.HttpError
+	if goErrors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return errors.Retry(&httpErr.KustoError)
+		}
+		return false
+	}
+
+	var e *errors.Error
+	if goErrors.As(err, &e) && e.Kind == errors.KTimeout {
+		return errors.Retry(e)
+	}
+	return false
+}
+
+// WithRetryPolicy sets the policy to retry Query() and Mgmt() requests with. Fields that are not set use the
+// values from DefaultRetryPolicy(). By default requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		def := DefaultRetryPolicy()
+		if policy.MaxAttempts == 0 {
+			policy.MaxAttempts = def.MaxAttempts
+		}
+		if policy.BaseBackoff <= 0 {
+			policy.BaseBackoff = def.BaseBackoff
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = def.MaxBackoff
+		}
+		if policy.ShouldRetry == nil {
+			policy.ShouldRetry = def.ShouldRetry
+		}
+		c.retryPolicy = &policy
+	}
+}
+
+// backoff returns how long to wait before retrying after the attempt failed with err.
+func (p *RetryPolicy) backoff(err error, attempt int) time.Duration {
+	var httpErr *errors.HttpError
+	if goErrors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		if p.MaxBackoff > 0 && httpErr.RetryAfter > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return httpErr.RetryAfter
+	}
+
+	d := p.BaseBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	// Jitter between half and all of the backoff, so that clients that failed together don't retry together.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// do calls f until it succeeds, the policy decides not to retry or the context is done. If the next backoff would
+// go past the context deadline, the last error is returned without waiting. Retries are logged to logger, if not nil.
+func (p *RetryPolicy) do(ctx context.Context, logger Logger, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || p == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !p.ShouldRetry(err, attempt) {
+			return err
+		}
+
+		wait := p.backoff(err, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		if logger != nil {
+			logger.Warn("kusto: retrying the request", "attempt", attempt, "backoff", wait, "error", err)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// retryAfter parses the Retry-After header of a response, which is either a number of seconds or an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer fails the first `failures` requests with the status code and header, then echoes the request body.
+func flakyServer(t *testing.T, failures int32, code int, header http.Header) (*httptest.Server, *int32) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, body, "every attempt should send the full request body")
+
+		if atomic.AddInt32(&calls, 1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(code)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	return s, &calls
+}
+
+func retryClient(t *testing.T, url string, options ...Option) *Client {
+	client, err := New(NewConnectionStringBuilder(url), options...)
+	require.NoError(t, err)
+	client.conn.(*Conn).endpointValidated.Store(true)
+	return client
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	fast := RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	tests := []struct {
+		desc      string
+		failures  int32
+		code      int
+		header    http.Header
+		options   []Option
+		err       bool
+		wantCalls int32
+	}{
+		{desc: "No policy does not retry", failures: 1, code: http.StatusServiceUnavailable, err: true, wantCalls: 1},
+		{desc: "Retries 503", failures: 2, code: http.StatusServiceUnavailable, options: []Option{WithRetryPolicy(fast)}, wantCalls: 3},
+		{desc: "Retries 429 with Retry-After", failures: 1, code: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"1"}},
+			options: []Option{WithRetryPolicy(fast)}, wantCalls: 2},
+		{desc: "Stops after MaxAttempts", failures: 5, code: http.StatusServiceUnavailable, options: []Option{WithRetryPolicy(fast)}, err: true, wantCalls: 3},
+		{desc: "Does not retry 400", failures: 1, code: http.StatusBadRequest, options: []Option{WithRetryPolicy(fast)}, err: true, wantCalls: 1},
+		{desc: "Custom predicate", failures: 1, code: http.StatusBadRequest, options: []Option{WithRetryPolicy(RetryPolicy{
+			BaseBackoff: time.Millisecond,
+			ShouldRetry: func(err error, attempt int) bool { return attempt < 2 },
+		})}, wantCalls: 2},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			s, calls := flakyServer(t, test.failures, test.code, test.header)
+			defer s.Close()
+
+			client := retryClient(t, s.URL, test.options...)
+			_, err := client.QueryToJson(context.Background(), "db", kql.New("test"))
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.wantCalls, atomic.LoadInt32(calls))
+		})
+	}
+}
+
+func TestRetryPolicyDeadline(t *testing.T) {
+	t.Parallel()
+
+	s, calls := flakyServer(t, 5, http.StatusTooManyRequests, http.Header{"Retry-After": []string{"60"}})
+	defer s.Close()
+
+	client := retryClient(t, s.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 5}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.QueryToJson(ctx, "db", kql.New("test"))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second, "a backoff past the deadline should not be waited for")
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestDefaultShouldRetry(t *testing.T) {
+	t.Parallel()
+
+	httpErr := func(code int) error {
+		return &errors.HttpError{KustoError: errors.KustoError{Kind: errors.KHTTPError}, StatusCode: code}
+	}
+
+	assert.True(t, DefaultShouldRetry(httpErr(http.StatusTooManyRequests), 1))
+	assert.True(t, DefaultShouldRetry(httpErr(http.StatusServiceUnavailable), 1))
+	assert.False(t, DefaultShouldRetry(httpErr(http.StatusInternalServerError), 1))
+	assert.True(t, DefaultShouldRetry(errors.ES(errors.OpQuery, errors.KTimeout, "timeout"), 1))
+	assert.False(t, DefaultShouldRetry(errors.ES(errors.OpQuery, errors.KTimeout, "timeout").SetNoRetry(), 1))
+	assert.False(t, DefaultShouldRetry(errors.ES(errors.OpQuery, errors.KClientArgs, "bad"), 1))
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{}))
+	assert.Equal(t, 2*time.Second, retryAfter(http.Header{"Retry-After": []string{"2"}}))
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{"Retry-After": []string{"soon"}}))
+
+	d := retryAfter(http.Header{"Retry-After": []string{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}})
+	assert.InDelta(t, float64(time.Minute), float64(d), float64(2*time.Second))
+
+	policy := RetryPolicy{BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, 2*time.Second, policy.backoff(&errors.HttpError{RetryAfter: 2 * time.Second}, 1))
+	assert.Equal(t, 5*time.Second, policy.backoff(&errors.HttpError{RetryAfter: time.Minute}, 1), "Retry-After is capped by MaxBackoff")
+}
+
+// resettingServer closes the connection of the first `failures` requests without answering, then echoes the request body.
+func resettingServer(t *testing.T, failures int32) (*httptest.Server, *int32) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, body, "every attempt should send the full request body")
+
+		if atomic.AddInt32(&calls, 1) <= failures {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	return s, &calls
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	t.Parallel()
+
+	fast := RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	tests := []struct {
+		desc      string
+		mgmt      bool
+		failures  int32
+		options   []Option
+		err       bool
+		wantCalls int32
+	}{
+		{desc: "Query is resent", failures: 2, wantCalls: 3},
+		{desc: "Query is resent a bounded number of times", failures: 5, err: true, wantCalls: 3},
+		{desc: "Mgmt is not resent without a policy", mgmt: true, failures: 1, err: true, wantCalls: 1},
+		{desc: "Mgmt is retried by the policy", mgmt: true, failures: 1, options: []Option{WithRetryPolicy(fast)}, wantCalls: 2},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			s, calls := resettingServer(t, test.failures)
+			defer s.Close()
+
+			client := retryClient(t, s.URL, test.options...)
+			var err error
+			if test.mgmt {
+				_, _, _, _, err = client.conn.(*Conn).doRequest(context.Background(), execMgmt, "db", kql.New(".show tables"), requestProperties{})
+			} else {
+				_, err = client.QueryToJson(context.Background(), "db", kql.New("test"))
+			}
+			if test.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.wantCalls, atomic.LoadInt32(calls))
+		})
+	}
+
+	assert.False(t, isConnectionError(errors.E(errors.OpQuery, errors.KHTTPError, io.EOF)), "only errors sending the request are connection errors")
+}
@@ -5,6 +5,7 @@ package kusto
 
 import (
 	"context"
+	"strings"
 	"sync"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -26,15 +27,24 @@ type stateMachine interface {
 
 // runSM runs a stateMachine to its conclusion.
 func runSM(sm stateMachine) {
-	defer close(sm.rowIter().inRows)
+	var err error
+	defer func() {
+		iter := sm.rowIter()
+		iter.endStream(err)
+		close(iter.inRows)
+	}()
 
 	var fn = sm.start
-	var err error
 	for {
 		fn, err = fn()
 		switch {
 		case err != nil:
-			sm.rowIter().inErr <- send{inErr: err} // Unique case, don't send a WaitGroup (also means, design needs to be fixed)
+			// The error is sent on the rows channel, so that the rows received before it are returned first.
+			iter := sm.rowIter()
+			select {
+			case <-iter.ctx.Done():
+			case iter.inRows <- send{inErr: err}: // Unique case, don't send a WaitGroup (also means, design needs to be fixed)
+			}
 			return
 		case fn == nil && err == nil:
 			return
@@ -42,6 +52,36 @@ func runSM(sm stateMachine) {
 	}
 }
 
+// limitsExceededCodes are found in the messages of the errors the service returns when a query exceeds one of its
+// limits, such as the result set size or the memory it can use.
+var limitsExceededCodes = []string{"LimitsExceeded", "E_QUERY_RESULT_SET_TOO_LARGE", "E_RUNAWAY_QUERY", "E_LOW_MEMORY_CONDITION"}
+
+// streamError converts the frames.Error that ended a stream into an *errors.Error. It is of Kind errors.KLimitsExceeded
+// if the service reported that a query limit was exceeded, and of the Kind of fr otherwise.
+func streamError(op errors.Op, fr frames.Error) error {
+	kind := fr.Kind
+	for _, code := range limitsExceededCodes {
+		if strings.Contains(fr.Msg, code) {
+			kind = errors.KLimitsExceeded
+			break
+		}
+	}
+	return errors.ES(op, kind, "%s", fr.Msg)
+}
+
+// completionError returns the error that a DataSetCompletion reports, or nil if it reports none. Errors that were
+// already returned inline with the rows are not returned again.
+func completionError(op errors.Op, completion v2.DataSetCompletion, rowErrors bool) error {
+	if !completion.HasErrors || rowErrors || completion.Error.Err == nil {
+		return nil
+	}
+	err := completion.Error
+	if err.Op == errors.OpUnknown {
+		err.Op = op
+	}
+	return &err
+}
+
 // nonProgressiveSM implements a stateMachine that processes Kusto data that is not non-streaming.
 type nonProgressiveSM struct {
 	op            errors.Op
@@ -50,6 +90,11 @@ type nonProgressiveSM struct {
 	columnSetOnce sync.Once
 	ctx           context.Context
 	hasCompletion bool
+	completion    v2.DataSetCompletion
+	// rowErrors indicates that errors were returned inline with the rows.
+	rowErrors bool
+	// tables is the number of primary result tables received so far.
+	tables int
 
 	wg *sync.WaitGroup // Used to know when everything has finished
 }
@@ -81,7 +126,7 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 			if !d.hasCompletion {
 				return nil, errors.ES(d.op, errors.KInternal, "non-progressive stream did not have DataSetCompletion frame")
 			}
-			return nil, nil
+			return nil, completionError(d.op, d.completion, d.rowErrors)
 		}
 
 		if d.hasCompletion {
@@ -98,10 +143,13 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 					d.iter.inColumns <- send{inColumns: table.Columns, wg: d.wg}
 				})
 
+				info := &tableInfo{ordinal: d.tables, name: string(table.TableName), columns: table.Columns}
+				d.tables++
 				select {
 				case <-d.ctx.Done():
 					return nil, d.ctx.Err()
-				case d.iter.inRows <- send{inRows: table.KustoRows, inRowErrors: table.RowErrors, wg: d.wg}:
+				case d.iter.inRows <- send{inTable: info, inRows: table.KustoRows, inRowErrors: table.RowErrors, wg: d.wg}:
+					d.rowErrors = d.rowErrors || len(table.RowErrors) > 0
 				}
 			default:
 				select {
@@ -111,7 +159,7 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 				}
 			}
 		case frames.Error:
-			return nil, table
+			return nil, streamError(d.op, table)
 		case v2.DataSetCompletion:
 			d.wg.Add(1)
 
@@ -121,6 +169,7 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 			case d.iter.inCompletion <- send{inCompletion: table, wg: d.wg}:
 			}
 			d.hasCompletion = true
+			d.completion = table
 		}
 	}
 	return d.process, nil
@@ -165,6 +214,10 @@ type progressiveSM struct {
 	currentHeader *v2.TableHeader
 	currentFrame  frames.Frame
 	nonPrimary    *v2.DataTable
+	// rowErrors indicates that errors were returned inline with the rows.
+	rowErrors bool
+	// tables is the number of primary result tables received so far.
+	tables int
 
 	wg *sync.WaitGroup
 }
@@ -209,7 +262,7 @@ func (p *progressiveSM) nextFrame() (stateFn, error) {
 		case v2.TableCompletion:
 			return p.completion, nil
 		case frames.Error:
-			return nil, table
+			return nil, streamError(p.op, table)
 		default:
 			return nil, errors.ES(p.op, errors.KInternal, "received an unknown frame in a progressive table stream we didn't understand: %T", table)
 		}
@@ -239,10 +292,11 @@ func (p *progressiveSM) dataTable() (stateFn, error) {
 func (p *progressiveSM) dataSetCompletion() (stateFn, error) {
 	p.wg.Add(1)
 
+	completion := p.currentFrame.(v2.DataSetCompletion)
 	select {
 	case <-p.ctx.Done():
 		return nil, p.ctx.Err()
-	case p.iter.inCompletion <- send{inCompletion: p.currentFrame.(v2.DataSetCompletion), wg: p.wg}:
+	case p.iter.inCompletion <- send{inCompletion: completion, wg: p.wg}:
 	}
 
 	select {
@@ -251,7 +305,7 @@ func (p *progressiveSM) dataSetCompletion() (stateFn, error) {
 	case frame, ok := <-p.in:
 		if !ok {
 			p.wg.Wait()
-			return nil, nil
+			return nil, completionError(p.op, completion, p.rowErrors)
 		}
 		return nil, errors.ES(p.op, errors.KInternal, "received a dataSetCompletion frame and then a %T frame", frame)
 	}
@@ -265,6 +319,15 @@ func (p *progressiveSM) tableHeader() (stateFn, error) {
 			p.wg.Add(1)
 			p.iter.inColumns <- send{inColumns: table.Columns, wg: p.wg}
 		})
+
+		info := &tableInfo{ordinal: p.tables, name: string(table.TableName), columns: table.Columns}
+		p.tables++
+		p.wg.Add(1)
+		select {
+		case <-p.ctx.Done():
+			return nil, p.ctx.Err()
+		case p.iter.inRows <- send{inTable: info, wg: p.wg}:
+		}
 	} else {
 		p.nonPrimary = &v2.DataTable{
 			Base:      v2.Base{FrameType: frames.TypeDataTable},
@@ -291,9 +354,12 @@ func (p *progressiveSM) fragment() (stateFn, error) {
 		case <-p.ctx.Done():
 			return nil, p.ctx.Err()
 		case p.iter.inRows <- send{inRows: table.KustoRows, inRowErrors: table.RowErrors, inTableFragmentType: table.TableFragmentType, wg: p.wg}:
+			p.rowErrors = p.rowErrors || len(table.RowErrors) > 0
 		}
 	} else {
-		p.nonPrimary.Rows = append(p.nonPrimary.Rows, p.currentFrame.(v2.TableFragment).Rows...)
+		fragment := p.currentFrame.(v2.TableFragment)
+		p.nonPrimary.Rows = append(p.nonPrimary.Rows, fragment.Rows...)
+		p.nonPrimary.KustoRows = append(p.nonPrimary.KustoRows, fragment.KustoRows...)
 	}
 	return p.nextFrame, nil
 }
@@ -334,6 +400,10 @@ type v1SM struct {
 
 	currentTable v1.DataTable
 	tables       []v1.DataTable
+	// currentName is the name of currentTable in the table of contents, if there is one, and results is the number
+	// of result tables sent so far.
+	currentName string
+	results     int
 
 	receivedDT bool
 
@@ -386,7 +456,7 @@ func (p *v1SM) nextFrame() (stateFn, error) {
 			p.tables = append(p.tables, tbl)
 			return p.nextFrame, nil
 		case frames.Error:
-			return nil, tbl
+			return nil, streamError(p.op, tbl)
 		default:
 			return nil, errors.ES(p.op, errors.KInternal, "received an unknown frame in a v1 table stream we didn't understand: %T", tbl)
 		}
@@ -419,6 +489,7 @@ func (p *v1SM) tableOfContents() (stateFn, error) {
 		kind := frames.TableKind(current.Kind)
 		if kind == frames.QueryResult {
 			p.currentTable = p.tables[current.Ordinal]
+			p.currentName = current.Name
 			if _, err := p.dataTable(); err != nil {
 				return nil, err
 			}
@@ -428,24 +499,29 @@ func (p *v1SM) tableOfContents() (stateFn, error) {
 }
 
 func (p *v1SM) dataTable() (stateFn, error) {
-	var err error
 	currentTable := p.currentTable
 
+	cols, err := currentTable.DataTypes.ToColumns()
+	if err != nil {
+		return nil, err
+	}
 	p.columnSetOnce.Do(func() {
-		var cols table.Columns
-		cols, err = currentTable.DataTypes.ToColumns()
-		if err != nil {
-			return
-		}
 		p.wg.Add(1)
 		p.iter.inColumns <- send{inColumns: cols, wg: p.wg}
 	})
 
+	name := p.currentName
+	if name == "" {
+		name = string(currentTable.TableName)
+	}
+	info := &tableInfo{ordinal: p.results, name: name, columns: cols}
+	p.results++
+
 	p.wg.Add(1)
 	select {
 	case <-p.ctx.Done():
 		return nil, p.ctx.Err()
-	case p.iter.inRows <- send{inRows: currentTable.KustoRows, inRowErrors: currentTable.RowErrors, wg: p.wg}:
+	case p.iter.inRows <- send{inTable: info, inRows: currentTable.KustoRows, inRowErrors: currentTable.RowErrors, wg: p.wg}:
 		p.receivedDT = true
 	}
 
@@ -16,6 +16,7 @@ import (
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -1060,6 +1061,198 @@ func TestProgressive(t *testing.T) {
 	}
 }
 
+func TestProgressivePartial(t *testing.T) {
+	t.Parallel()
+
+	header := v2.TableHeader{
+		Base:      v2.Base{FrameType: frames.TypeTableHeader},
+		TableKind: frames.PrimaryResult,
+		Columns:   table.Columns{{Name: "Name", Type: "string"}},
+	}
+	fragment := func(name string) v2.TableFragment {
+		return v2.TableFragment{KustoRows: []value.Values{{value.String{Value: name, Valid: true}}}}
+	}
+
+	tests := []struct {
+		desc    string
+		stream  []frames.Frame
+		want    int
+		partial bool
+	}{
+		{
+			desc:   "Complete",
+			stream: []frames.Frame{header, fragment("a"), fragment("b"), v2.TableCompletion{}, v2.DataSetCompletion{}},
+			want:   2,
+		},
+		{
+			desc:    "Completion has errors",
+			stream:  []frames.Frame{header, fragment("a"), v2.TableCompletion{}, v2.DataSetCompletion{HasErrors: true}},
+			want:    1,
+			partial: true,
+		},
+		{
+			desc:    "Cancelled",
+			stream:  []frames.Frame{header, fragment("a"), v2.TableCompletion{}, v2.DataSetCompletion{Cancelled: true}},
+			want:    1,
+			partial: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			createSm := func(iter *RowIterator, toSM chan frames.Frame) stateMachine {
+				return &progressiveSM{
+					iter: iter,
+					in:   toSM,
+					ctx:  context.Background(),
+					wg:   &sync.WaitGroup{},
+				}
+			}
+
+			streamStateMachine(test.stream, createSm, func(iter *RowIterator) {
+				got, err := iterateRows(iter)
+				require.NoError(t, err)
+				assert.Len(t, got, test.want)
+				assert.Equal(t, test.partial, iter.Partial())
+			})
+		})
+	}
+}
+
+func TestRowsBeforeError(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "ID", Type: "long"}}
+	rows := func(from, to int) []value.Values {
+		var vals []value.Values
+		for i := from; i < to; i++ {
+			vals = append(vals, value.Values{value.Long{Value: int64(i), Valid: true}})
+		}
+		return vals
+	}
+	limitErr := frames.Error{Msg: "Query execution has exceeded the allowed limits (80DA0003): E_QUERY_RESULT_SET_TOO_LARGE"}
+	completion := v2.DataSetCompletion{HasErrors: true, Error: *errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "limits exceeded")}
+
+	nonProgressive := func(iter *RowIterator, toSM chan frames.Frame) stateMachine {
+		return &nonProgressiveSM{op: errors.OpQuery, iter: iter, in: toSM, ctx: context.Background(), wg: &sync.WaitGroup{}}
+	}
+	progressive := func(iter *RowIterator, toSM chan frames.Frame) stateMachine {
+		return &progressiveSM{op: errors.OpQuery, iter: iter, in: toSM, ctx: context.Background(), wg: &sync.WaitGroup{}}
+	}
+	header := v2.TableHeader{Base: v2.Base{FrameType: frames.TypeTableHeader}, TableKind: frames.PrimaryResult, Columns: columns}
+
+	tests := []struct {
+		desc     string
+		createSm func(iter *RowIterator, toSM chan frames.Frame) stateMachine
+		stream   []frames.Frame
+		wantKind errors.Kind
+	}{
+		{
+			desc:     "Non-progressive, stream error",
+			createSm: nonProgressive,
+			stream:   []frames.Frame{v2.DataTable{TableKind: frames.PrimaryResult, Columns: columns, KustoRows: rows(0, 200)}, limitErr},
+			wantKind: errors.KLimitsExceeded,
+		},
+		{
+			desc:     "Non-progressive, completion error",
+			createSm: nonProgressive,
+			stream:   []frames.Frame{v2.DataTable{TableKind: frames.PrimaryResult, Columns: columns, KustoRows: rows(0, 200)}, completion},
+			wantKind: errors.KLimitsExceeded,
+		},
+		{
+			desc:     "Progressive, stream error",
+			createSm: progressive,
+			stream:   []frames.Frame{header, v2.TableFragment{KustoRows: rows(0, 100)}, v2.TableFragment{KustoRows: rows(100, 200)}, limitErr},
+			wantKind: errors.KLimitsExceeded,
+		},
+		{
+			desc:     "Progressive, completion error",
+			createSm: progressive,
+			stream:   []frames.Frame{header, v2.TableFragment{KustoRows: rows(0, 200)}, v2.TableCompletion{}, completion},
+			wantKind: errors.KLimitsExceeded,
+		},
+		{
+			desc:     "Progressive, other error",
+			createSm: progressive,
+			stream:   []frames.Frame{header, v2.TableFragment{KustoRows: rows(0, 200)}, frames.Error{Msg: "unexpected EOF"}},
+			wantKind: errors.KOther,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			streamStateMachine(test.stream, test.createSm, func(iter *RowIterator) {
+				got, err := iterateRows(iter)
+				require.Error(t, err)
+				require.Len(t, got, 200, "the rows received before the error should be returned")
+				for i, row := range got {
+					assert.Equal(t, value.Long{Value: int64(i), Valid: true}, row.Values[0])
+				}
+
+				kerr, ok := err.(*errors.Error)
+				require.True(t, ok, "got %T", err)
+				assert.Equal(t, test.wantKind, kerr.Kind)
+				assert.Equal(t, errors.OpQuery, kerr.Op)
+
+				_, err2 := iter.Next()
+				assert.Equal(t, err, err2, "the error should be returned by all subsequent calls")
+			})
+		})
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	t.Parallel()
+
+	stream := []frames.Frame{
+		v2.TableHeader{
+			Base:      v2.Base{FrameType: frames.TypeTableHeader},
+			TableKind: frames.PrimaryResult,
+			Columns:   table.Columns{{Name: "ID", Type: "long"}},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		stream = append(stream, v2.TableFragment{KustoRows: []value.Values{{value.Long{Value: int64(i), Valid: true}}}})
+	}
+	stream = append(stream, v2.TableCompletion{}, v2.DataSetCompletion{})
+
+	createSm := func(iter *RowIterator, toSM chan frames.Frame) stateMachine {
+		return &progressiveSM{
+			iter: iter,
+			in:   toSM,
+			ctx:  context.Background(),
+			wg:   &sync.WaitGroup{},
+		}
+	}
+
+	streamStateMachine(stream, createSm, func(iter *RowIterator) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		calls := 0
+		err := iter.DoContext(ctx, func(r *table.Row) error {
+			calls++
+			if calls == 2 {
+				cancel()
+			}
+			return nil
+		})
+
+		assert.Equal(t, 2, calls)
+		require.Error(t, err)
+		assert.True(t, goErr.Is(err, context.Canceled))
+		e, ok := errors.GetKustoError(err)
+		require.True(t, ok)
+		assert.Equal(t, errors.KTimeout, e.Kind)
+	})
+}
+
 func TestV1SM(t *testing.T) {
 	t.Parallel()
 
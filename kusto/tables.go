@@ -0,0 +1,167 @@
+package kusto
+
+import (
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// tableInfo describes a primary result table, and is sent to the RowIterator before the rows of the table.
+type tableInfo struct {
+	ordinal int
+	name    string
+	columns table.Columns
+}
+
+// ResultTable is one of the primary result tables of a query, such as the result of each statement of a query with
+// several tabular statements, or of each output of a fork() operator. It is returned by TableIterator.Next().
+type ResultTable struct {
+	// Ordinal is the position of the table among the primary result tables, starting at 0.
+	Ordinal int
+	// Name is the name of the table, as reported by the service, such as "PrimaryResult".
+	Name string
+	// Columns are the columns of the rows of the table.
+	Columns table.Columns
+
+	tables *TableIterator
+	done   bool
+}
+
+// NextRowOrError gets the next Row or service-side error of the table, like RowIterator.NextRowOrError().
+// finalError is io.EOF once all the rows of this table were read, even if other tables follow. Otherwise, it is the
+// error that ended the query, which is also returned by TableIterator.Next().
+func (t *ResultTable) NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
+	if t.done {
+		return nil, nil, io.EOF
+	}
+	ti := t.tables
+	iter := ti.iter
+
+	if iter.mock != nil {
+		row, inlineErr, err := iter.NextRowOrError()
+		if err != nil {
+			t.done = true
+			ti.end(err)
+		}
+		return row, inlineErr, err
+	}
+
+	if err := iter.getError(); err != nil {
+		return nil, nil, err
+	}
+	kvs, err := iter.nextStreamRow()
+	if err != nil {
+		t.done = true
+		ti.end(err)
+		if err == io.EOF {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, ti.err
+	}
+	if kvs.table != nil {
+		t.done = true
+		ti.pending = kvs.table
+		return nil, nil, io.EOF
+	}
+	if kvs.Error != nil {
+		return nil, kvs.Error, nil
+	}
+	iter.sawRow = true
+	return &table.Row{ColumnTypes: t.Columns, Values: kvs.Values, Op: iter.op, Replace: kvs.Replace, CaseInsensitiveColumns: iter.caseInsensitiveColumns, ColumnNameMapper: iter.columnNameMapper}, nil, nil
+}
+
+// DoOnRowOrError calls f for every row of the table, like RowIterator.DoOnRowOrError(). It returns nil once all the
+// rows of this table were read.
+func (t *ResultTable) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error) error {
+	for {
+		row, inlineErr, err := t.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row, inlineErr); err != nil {
+			return err
+		}
+	}
+}
+
+// TableIterator iterates over the primary result tables of a query. It is returned by RowIterator.Tables().
+type TableIterator struct {
+	iter *RowIterator
+
+	current *ResultTable
+	// pending is the table that starts with the next row, read while reading the rows of current.
+	pending *tableInfo
+	// ended is set once all the rows were read, and err is then io.EOF or the error that ended the query.
+	ended bool
+	err   error
+}
+
+// Tables returns a TableIterator over all the primary result tables of the query, in the order the service returned
+// them, each one with its own name, columns and rows. Next(), NextRowOrError(), Do() and their variants return the rows
+// of all the tables as the rows of the first one, so they must not be used along with Tables().
+// This works for queries and management commands, with both the v1 and the v2 protocols. The results of a
+// RowIterator that was mocked are a single table.
+func (r *RowIterator) Tables() *TableIterator {
+	return &TableIterator{iter: r}
+}
+
+// Next returns the next result table. Rows of the previous table that were not read are skipped. It returns io.EOF
+// once all the tables were returned, or the error that ended the query, after the tables received before it.
+func (ti *TableIterator) Next() (*ResultTable, error) {
+	if ti.current != nil {
+		for !ti.current.done {
+			if _, _, err := ti.current.NextRowOrError(); err != nil {
+				break
+			}
+		}
+		ti.current = nil
+	}
+	if err := ti.iter.getError(); err != nil {
+		return nil, err
+	}
+
+	info := ti.pending
+	ti.pending = nil
+	if info == nil && !ti.ended {
+		if ti.iter.mock != nil {
+			info = &tableInfo{columns: ti.iter.columns}
+		} else {
+			kvs, err := ti.iter.nextStreamRow()
+			switch {
+			case err != nil:
+				ti.end(err)
+			case kvs.table == nil:
+				return nil, errors.ES(ti.iter.op, errors.KInternal, "received a row before the start of a result table")
+			default:
+				info = kvs.table
+			}
+		}
+	}
+	if info == nil {
+		if !ti.ended {
+			ti.end(io.EOF)
+		}
+		return nil, ti.err
+	}
+
+	ti.current = &ResultTable{Ordinal: info.ordinal, Name: info.name, Columns: info.columns, tables: ti}
+	return ti.current, nil
+}
+
+// end records that all the rows were read, and err is how the stream ended.
+func (ti *TableIterator) end(err error) {
+	if ti.ended {
+		return
+	}
+	ti.ended = true
+	iter := ti.iter
+	if err == io.EOF && iter.errorOnEmpty && !iter.sawRow {
+		err = errors.E(iter.op, errors.KOther, errors.ErrNoRows).SetNoRetry()
+		iter.setError(err)
+	}
+	ti.err = err
+}
@@ -0,0 +1,219 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tablesResponse = `[{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+	`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"First",` +
+	`"Columns":[{"ColumnName":"ID","ColumnType":"long"}],"Rows":[[1],[2]]},` +
+	`{"FrameType":"DataTable","TableId":2,"TableKind":"QueryCompletionInformation","TableName":"QueryCompletionInformation",` +
+	`"Columns":[{"ColumnName":"Level","ColumnType":"int"}],"Rows":[[4]]},` +
+	`{"FrameType":"DataTable","TableId":3,"TableKind":"PrimaryResult","TableName":"Second",` +
+	`"Columns":[{"ColumnName":"Name","ColumnType":"string"}],"Rows":[["a"]]},` +
+	`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+
+const progressiveTablesResponse = `[{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},` +
+	`{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"First","Columns":[{"ColumnName":"ID","ColumnType":"long"}]},` +
+	`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[[1],[2]]},` +
+	`{"FrameType":"TableCompletion","TableId":1,"RowCount":2},` +
+	`{"FrameType":"TableHeader","TableId":2,"TableKind":"PrimaryResult","TableName":"Second","Columns":[{"ColumnName":"Name","ColumnType":"string"}]},` +
+	`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":2,"Rows":[["a"]]},` +
+	`{"FrameType":"TableCompletion","TableId":2,"RowCount":1},` +
+	`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+
+// failingTablesResponse is tablesResponse with a DataSetCompletion that reports an exceeded limit after the rows.
+var failingTablesResponse = strings.Replace(tablesResponse, `{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}`,
+	`{"FrameType":"DataSetCompletion","HasErrors":true,"Cancelled":false,"OneApiErrors":[{"error":{"code":"LimitsExceeded","message":"Request is invalid and cannot be executed.","@type":"Kusto.DataNode.Exceptions.QueryLimitsExceeded","@message":"limit reached","@permanent":true}}]}`, 1)
+
+const v1TablesResponse = `{"Tables":[` +
+	`{"TableName":"Table_0","Columns":[{"ColumnName":"ID","DataType":"Int64","ColumnType":"long"}],"Rows":[[1],[2]]},` +
+	`{"TableName":"Table_1","Columns":[{"ColumnName":"Name","DataType":"String","ColumnType":"string"}],"Rows":[["a"]]},` +
+	`{"TableName":"Table_2","Columns":[{"ColumnName":"Value","DataType":"String","ColumnType":"string"}],"Rows":[["stats"]]},` +
+	`{"TableName":"Table_3","Columns":[{"ColumnName":"Ordinal","DataType":"Int64","ColumnType":"long"},` +
+	`{"ColumnName":"Kind","DataType":"String","ColumnType":"string"},{"ColumnName":"Name","DataType":"String","ColumnType":"string"},` +
+	`{"ColumnName":"Id","DataType":"String","ColumnType":"string"},{"ColumnName":"PrettyName","DataType":"String","ColumnType":"string"}],` +
+	`"Rows":[[0,"QueryResult","First","1",""],[1,"QueryResult","Second","2",""],[2,"QueryProperties","@ExtendedProperties","3",""]]}]}`
+
+// resultTable is a ResultTable and its rows, as read by collectTables.
+type resultTable struct {
+	ordinal int
+	name    string
+	columns table.Columns
+	rows    []value.Values
+}
+
+func collectTables(t *testing.T, iter *RowIterator) []resultTable {
+	var got []resultTable
+	tables := iter.Tables()
+	for {
+		tbl, err := tables.Next()
+		if err == io.EOF {
+			return got
+		}
+		require.NoError(t, err)
+
+		rt := resultTable{ordinal: tbl.Ordinal, name: tbl.Name, columns: tbl.Columns}
+		require.NoError(t, tbl.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+			require.Nil(t, e)
+			rt.rows = append(rt.rows, r.Values)
+			return nil
+		}))
+		got = append(got, rt)
+	}
+}
+
+func TestTables(t *testing.T) {
+	t.Parallel()
+
+	wantTables := []resultTable{
+		{
+			ordinal: 0,
+			name:    "First",
+			columns: table.Columns{{Name: "ID", Type: types.Long}},
+			rows:    []value.Values{{value.Long{Value: 1, Valid: true}}, {value.Long{Value: 2, Valid: true}}},
+		},
+		{
+			ordinal: 1,
+			name:    "Second",
+			columns: table.Columns{{Name: "Name", Type: types.String}},
+			rows:    []value.Values{{value.String{Value: "a", Valid: true}}},
+		},
+	}
+
+	tests := []struct {
+		desc     string
+		response string
+		v1       bool
+	}{
+		{desc: "Non progressive", response: tablesResponse},
+		{desc: "Progressive", response: progressiveTablesResponse},
+		{desc: "V1", response: v1TablesResponse, v1: true},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(test.response))
+			}))
+			t.Cleanup(s.Close)
+			client := retryClient(t, s.URL)
+
+			run := func() *RowIterator {
+				var iter *RowIterator
+				var err error
+				if test.v1 {
+					iter, err = client.Mgmt(context.Background(), "db", kql.New(".show tables"))
+				} else {
+					iter, err = client.Query(context.Background(), "db", kql.New("test"))
+				}
+				require.NoError(t, err)
+				t.Cleanup(iter.Stop)
+				return iter
+			}
+
+			assert.Equal(t, wantTables, collectTables(t, run()))
+
+			// Without Tables(), the rows of all the tables are returned as the rows of the first one.
+			iter := run()
+			rows := 0
+			require.NoError(t, iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+				rows++
+				assert.Equal(t, wantTables[0].columns, r.ColumnTypes)
+				return nil
+			}))
+			assert.Equal(t, 3, rows)
+
+			// Skipping the rows of a table.
+			tables := run().Tables()
+			first, err := tables.Next()
+			require.NoError(t, err)
+			assert.Equal(t, "First", first.Name)
+			second, err := tables.Next()
+			require.NoError(t, err)
+			assert.Equal(t, "Second", second.Name)
+			_, _, err = first.NextRowOrError()
+			assert.Equal(t, io.EOF, err)
+			_, err = tables.Next()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestTablesErrors(t *testing.T) {
+	t.Parallel()
+
+	failing := failingTablesResponse
+	empty := strings.Replace(strings.Replace(tablesResponse, `"Rows":[[1],[2]]`, `"Rows":[]`, 1), `"Rows":[["a"]]`, `"Rows":[]`, 1)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get(ClientRequestIdHeader) {
+		case "failing":
+			_, _ = w.Write([]byte(failing))
+		case "empty":
+			_, _ = w.Write([]byte(empty))
+		}
+	}))
+	t.Cleanup(s.Close)
+	client := retryClient(t, s.URL)
+
+	iter, err := client.Query(context.Background(), "db", kql.New("test"), WithClientRequestID("failing"))
+	require.NoError(t, err)
+	defer iter.Stop()
+	tables := iter.Tables()
+	for err == nil {
+		var tbl *ResultTable
+		if tbl, err = tables.Next(); err == nil {
+			err = tbl.DoOnRowOrError(func(r *table.Row, e *errors.Error) error { return nil })
+		}
+	}
+	e, ok := errors.GetKustoError(err)
+	require.True(t, ok, "got %v", err)
+	assert.Equal(t, errors.KLimitsExceeded, e.Kind)
+	_, err = tables.Next()
+	assert.Equal(t, e, err, "the error is returned by every later call")
+
+	iter, err = client.Query(context.Background(), "db", kql.New("test"), WithClientRequestID("empty"), WithErrorOnEmpty())
+	require.NoError(t, err)
+	defer iter.Stop()
+	tables = iter.Tables()
+	for i := 0; i < 2; i++ {
+		_, err := tables.Next()
+		require.NoError(t, err)
+	}
+	_, err = tables.Next()
+	assert.ErrorIs(t, err, errors.ErrNoRows)
+}
+
+func TestTablesMock(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "ID", Type: types.Long}}
+	rows, err := NewMockRows(columns)
+	require.NoError(t, err)
+	require.NoError(t, rows.Row(value.Values{value.Long{Value: 1, Valid: true}}))
+
+	iter := &RowIterator{}
+	require.NoError(t, iter.Mock(rows))
+
+	got := collectTables(t, iter)
+	require.Len(t, got, 1)
+	assert.Equal(t, columns, got[0].columns)
+	assert.Len(t, got[0].rows, 1)
+}
@@ -213,8 +213,11 @@ func colToValueCheck(cols table.Columns, values value.Values) error {
 func convertBool(v reflect.Value) (value.Bool, error) {
 	t := v.Type()
 
-	// If it is a pointer, dereference it.
+	// If it is a pointer, dereference it. A nil *bool is a null.
 	if t.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return value.Bool{}, nil
+		}
 		t = t.Elem()
 		v = v.Elem()
 	}
@@ -208,6 +208,7 @@ func TestConvertBool(t *testing.T) {
 		{value: val, want: value.Bool{Value: true, Valid: true}},
 		{value: ptr, want: value.Bool{Value: true, Valid: true}},
 		{value: ty, want: value.Bool{Value: true, Valid: true}},
+		{value: (*bool)(nil), want: value.Bool{}},
 	}
 	for _, test := range tests {
 		got, err := convertBool(reflect.ValueOf(test.value))
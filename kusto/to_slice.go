@@ -0,0 +1,40 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// QueryToSlice runs the query and decodes every row of the primary result into a T, using the same rules as
+// table.Row.ToStruct(). It is meant for small result sets, as all the rows are held in memory.
+// Decoding stops at the first row that can't be decoded, and the error includes the index of that row.
+// If ctx is done before all the rows are read, the query is stopped and a KTimeout error is returned.
+func QueryToSlice[T any](ctx context.Context, client *Client, db string, query Statement, options ...QueryOption) ([]T, error) {
+	iter, err := client.Query(ctx, db, query, options...)
+	if err != nil {
+		return nil, err
+	}
+	return toSlice[T](ctx, iter)
+}
+
+// toSlice decodes the rows of iter into a []T and stops iter once done.
+func toSlice[T any](ctx context.Context, iter *RowIterator) ([]T, error) {
+	defer iter.Stop()
+
+	var out []T
+	err := iter.DoContext(ctx, func(row *table.Row) error {
+		var v T
+		if err := row.ToStruct(&v); err != nil {
+			return errors.E(iter.op, errors.KInternal, fmt.Errorf("could not decode row %d into %T: %w", len(out), v, err)).SetNoRetry()
+		}
+		out = append(out, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package kusto
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToSlice(t *testing.T) {
+	t.Parallel()
+
+	type person struct {
+		ID   int64
+		Name string `kusto:"FullName"`
+	}
+
+	columns := table.Columns{{Name: "ID", Type: types.Long}, {Name: "FullName", Type: types.String}}
+
+	mockIter := func(t *testing.T, rows ...value.Values) *RowIterator {
+		m, err := NewMockRows(columns)
+		require.NoError(t, err)
+		for _, row := range rows {
+			require.NoError(t, m.Row(row))
+		}
+		iter := &RowIterator{}
+		require.NoError(t, iter.Mock(m))
+		return iter
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		t.Parallel()
+		iter := mockIter(t,
+			value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "Ada", Valid: true}},
+			value.Values{value.Long{Value: 2, Valid: true}, value.String{Value: "Grace", Valid: true}},
+		)
+
+		got, err := toSlice[person](context.Background(), iter)
+		require.NoError(t, err)
+		assert.Equal(t, []person{{ID: 1, Name: "Ada"}, {ID: 2, Name: "Grace"}}, got)
+	})
+
+	t.Run("Case insensitive columns", func(t *testing.T) {
+		t.Parallel()
+		iter := mockIter(t, value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "Ada", Valid: true}})
+		opts, err := setQueryOptions(context.Background(), errors.OpQuery, kql.New("test"), queryCall, WithCaseInsensitiveColumns())
+		require.NoError(t, err)
+		iter.caseInsensitiveColumns = opts.caseInsensitiveColumns
+
+		got, err := toSlice[struct {
+			Id       int64
+			FULLNAME string
+		}](context.Background(), iter)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, int64(1), got[0].Id)
+		assert.Equal(t, "Ada", got[0].FULLNAME)
+	})
+
+	t.Run("Column name mapper", func(t *testing.T) {
+		t.Parallel()
+		iter := mockIter(t, value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "Ada", Valid: true}})
+		opts, err := setQueryOptions(context.Background(), errors.OpQuery, kql.New("test"), queryCall, WithColumnNameMapper(strings.ToUpper))
+		require.NoError(t, err)
+		iter.columnNameMapper = opts.columnNameMapper
+
+		got, err := toSlice[struct {
+			ID       int64
+			FULLNAME string
+		}](context.Background(), iter)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, int64(1), got[0].ID)
+		assert.Equal(t, "Ada", got[0].FULLNAME)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+		got, err := toSlice[person](context.Background(), mockIter(t))
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("Decode error has the row index", func(t *testing.T) {
+		t.Parallel()
+		iter := mockIter(t,
+			value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "Ada", Valid: true}},
+			value.Values{value.Long{Value: 2, Valid: true}, value.String{Value: "Grace", Valid: true}},
+		)
+
+		_, err := toSlice[struct{ ID string }](context.Background(), iter)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "row 0")
+		e, ok := err.(*errors.Error)
+		require.True(t, ok)
+		assert.Equal(t, errors.KInternal, e.Kind)
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		iter := mockIter(t, value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "Ada", Valid: true}})
+		_, err := toSlice[person](ctx, iter)
+		require.Error(t, err)
+		e, ok := err.(*errors.Error)
+		require.True(t, ok)
+		assert.Equal(t, errors.KTimeout, e.Kind)
+	})
+}
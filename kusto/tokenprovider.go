@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/utils"
 
@@ -14,12 +15,15 @@ import (
 )
 
 type TokenProvider struct {
-	tokenCred   azcore.TokenCredential                  //Holds the received token credential as per the authorization
-	tokenScheme string                                  //Contains token scheme for tokenprovider
-	customToken string                                  //Holds the custom auth token to be used for authorization
-	initOnce    utils.OnceWithInit[*tokenWrapperResult] //To ensure tokenprovider will be initialized only once while aquiring token
-	scopes      []string                                //Contains scopes of the auth token
-	http        atomic.Value                            //Contains the http client to be used for token provider
+	tokenCred    azcore.TokenCredential                  //Holds the received token credential as per the authorization
+	tokenScheme  string                                  //Contains token scheme for tokenprovider
+	customToken  string                                  //Holds the custom auth token to be used for authorization
+	initOnce     utils.OnceWithInit[*tokenWrapperResult] //To ensure tokenprovider will be initialized only once while aquiring token
+	scopes       []string                                //Contains scopes of the auth token
+	http         atomic.Value                            //Contains the http client to be used for token provider
+	cloudInfoTTL time.Duration                           //How long the discovered CloudInfo is cached
+	logger       Logger                                  //Receives the warnings of the CloudInfo discovery
+	refresher    *tokenRefresher                         //Caches and renews the token, if set with WithTokenRefreshBuffer()
 }
 
 // tokenProvider need to be received as reference, to reflect updations to the structs
@@ -28,22 +32,46 @@ func (tkp *TokenProvider) AcquireToken(ctx context.Context) (string, string, err
 		return tkp.customToken, tkp.tokenScheme, nil
 	}
 
+	get := tkp.getToken
+	if tkp.refresher != nil {
+		get = tkp.refresher.acquire
+	}
+	token, err := get(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return token.Token, tkp.tokenScheme, nil
+}
+
+// getToken acquires a token from the credential.
+func (tkp *TokenProvider) getToken(ctx context.Context) (azcore.AccessToken, error) {
 	if tkp.initOnce != nil {
 		_, err := tkp.initOnce.DoWithInit()
 		if err != nil {
-			return "", "", err
+			return azcore.AccessToken{}, err
 		}
 	}
 
 	if tkp.tokenCred != nil {
-		token, err := tkp.tokenCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: tkp.scopes})
-		if err != nil {
-			return "", "", err
-		}
-		return token.Token, tkp.tokenScheme, nil
+		return tkp.tokenCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: tkp.scopes})
+	}
+
+	return azcore.AccessToken{}, fmt.Errorf("Error: No token info present in token provider")
+}
+
+// setRefreshBuffer makes the token be renewed in the background buffer before it expires, see WithTokenRefreshBuffer().
+func (tkp *TokenProvider) setRefreshBuffer(buffer time.Duration, logger Logger) {
+	if buffer <= 0 || !isEmpty(tkp.customToken) {
+		return
 	}
+	tkp.refresher = newTokenRefresher(tkp.getToken, buffer, logger)
+}
 
-	return "", "", fmt.Errorf("Error: No token info present in token provider")
+// close stops the background refresh of the token.
+func (tkp *TokenProvider) close() {
+	if tkp.refresher != nil {
+		tkp.refresher.close()
+	}
 }
 
 func (tkp *TokenProvider) AuthorizationRequired() bool {
@@ -57,7 +85,7 @@ type tokenWrapperResult struct {
 
 func (tkp *TokenProvider) setInit(kcsb *ConnectionStringBuilder, f func(*CloudInfo, *azcore.ClientOptions, string) (azcore.TokenCredential, error)) {
 	tkp.initOnce = utils.NewOnceWithInit(func() (*tokenWrapperResult, error) {
-		wrapper, err := tokenWrapper(kcsb, func() *http.Client { return tkp.http.Load().(*http.Client) }, f)
+		wrapper, err := tokenWrapper(kcsb, func() *http.Client { return tkp.http.Load().(*http.Client) }, tkp.cloudInfoTTL, tkp.logger, f)
 		if err != nil {
 			return nil, err
 		}
@@ -73,9 +101,9 @@ func (tkp *TokenProvider) SetHttp(http *http.Client) {
 	tkp.http.Store(http)
 }
 
-func tokenWrapper(kcsb *ConnectionStringBuilder, http func() *http.Client, f func(*CloudInfo, *azcore.ClientOptions, string) (azcore.TokenCredential, error)) (*tokenWrapperResult,
+func tokenWrapper(kcsb *ConnectionStringBuilder, http func() *http.Client, cloudInfoTTL time.Duration, logger Logger, f func(*CloudInfo, *azcore.ClientOptions, string) (azcore.TokenCredential, error)) (*tokenWrapperResult,
 	error) {
-	ci, cliOpts, appClientId, err := getCommonCloudInfo(kcsb, http)
+	ci, cliOpts, appClientId, err := getCommonCloudInfo(kcsb, http, cloudInfoTTL, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -97,15 +125,31 @@ func tokenWrapper(kcsb *ConnectionStringBuilder, http func() *http.Client, f fun
 	}, nil
 }
 
-func getCommonCloudInfo(kcsb *ConnectionStringBuilder, http func() *http.Client) (*CloudInfo, *azcore.ClientOptions, string, error) {
-	client := http()
+// getCommonCloudInfo discovers the CloudInfo of the cluster, unless a cloud was set with WithCloud(). If the cluster has
+// no metadata endpoint, the public cloud defaults are used, and a warning is sent to logger. Other failures, such as
+// redirects and server errors, are returned.
+func getCommonCloudInfo(kcsb *ConnectionStringBuilder, http func() *http.Client, cloudInfoTTL time.Duration, logger Logger) (*CloudInfo, *azcore.ClientOptions, string, error) {
 	if http == nil {
 		return nil, nil, "", fmt.Errorf("error: No http client provided")
 	}
+	client := http()
+	if client == nil {
+		return nil, nil, "", fmt.Errorf("error: No http client provided")
+	}
 
-	cloud, err := GetMetadata(kcsb.DataSource, client)
-	if err != nil {
-		return nil, nil, "", err
+	var cloud CloudInfo
+	if kcsb.Cloud != nil {
+		cloud = cloudInfoOf(kcsb.Cloud)
+	} else {
+		var notFound bool
+		var err error
+		if cloud, notFound, err = discoverMetadata(kcsb.DataSource, client, cloudInfoTTL); err != nil {
+			return nil, nil, "", err
+		}
+		if notFound && logger != nil {
+			logger.Warn("kusto: the cluster has no cloud metadata endpoint, using the public cloud defaults", "cluster", kcsb.DataSource,
+				"loginEndpoint", cloud.LoginEndpoint)
+		}
 	}
 	cliOpts := kcsb.ClientOptions
 	appClientId := kcsb.ApplicationClientId
@@ -117,7 +161,9 @@ func getCommonCloudInfo(kcsb *ConnectionStringBuilder, http func() *http.Client)
 	if cliOpts.Transport == nil {
 		cliOpts.Transport = client
 	}
-	if isEmpty(cliOpts.Cloud.ActiveDirectoryAuthorityHost) {
+	if kcsb.Cloud != nil {
+		cliOpts.Cloud = *kcsb.Cloud
+	} else if isEmpty(cliOpts.Cloud.ActiveDirectoryAuthorityHost) {
 		cliOpts.Cloud.ActiveDirectoryAuthorityHost = cloud.LoginEndpoint
 	}
 	if isEmpty(appClientId) {
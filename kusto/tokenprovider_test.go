@@ -2,10 +2,16 @@ package kusto
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAcquireTokenErr(t *testing.T) {
@@ -43,3 +49,119 @@ func TestAcquireTokenErr(t *testing.T) {
 	}
 
 }
+
+// expiringCredential returns tokens valid for ttl, numbered by the call, after failing the first fails calls.
+type expiringCredential struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	fails int
+	calls int
+}
+
+func (c *expiringCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.fails > 0 {
+		c.fails--
+		return azcore.AccessToken{}, fmt.Errorf("token service unavailable")
+	}
+	return azcore.AccessToken{Token: fmt.Sprintf("token-%d", c.calls), ExpiresOn: time.Now().Add(c.ttl)}, nil
+}
+
+func (c *expiringCredential) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func refreshingProvider(cred azcore.TokenCredential, buffer time.Duration, logger Logger) *TokenProvider {
+	tkp := &TokenProvider{tokenCred: cred, tokenScheme: "Bearer"}
+	tkp.setRefreshBuffer(buffer, logger)
+	tkp.refresher.minWait = 0
+	tkp.refresher.backoff = &RetryPolicy{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	return tkp
+}
+
+func TestTokenRefresh(t *testing.T) {
+	t.Parallel()
+
+	cred := &expiringCredential{ttl: 300 * time.Millisecond}
+	tkp := refreshingProvider(cred, 250*time.Millisecond, NopLogger{})
+	defer tkp.close()
+
+	token, scheme, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, "Bearer", scheme)
+
+	token, _, err = tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "the cached token should be used")
+
+	require.Eventually(t, func() bool { return cred.count() >= 2 }, 5*time.Second, 5*time.Millisecond)
+	token, _, err = tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, "token-1", token, "the token should have been refreshed in the background")
+
+	tkp.close()
+	calls := cred.count()
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, calls, cred.count(), "the token should not be refreshed once closed")
+}
+
+func TestTokenRefreshFailures(t *testing.T) {
+	t.Parallel()
+
+	cred := &expiringCredential{ttl: time.Second}
+	l := &recordingLogger{}
+	tkp := refreshingProvider(cred, 900*time.Millisecond, l)
+	defer tkp.close()
+
+	token, _, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	cred.mu.Lock()
+	cred.fails = 2
+	cred.mu.Unlock()
+	require.Eventually(t, func() bool { return cred.count() >= 4 }, 5*time.Second, 5*time.Millisecond)
+
+	token, _, err = tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, "token-1", token, "the token should have been refreshed after the failures")
+	l.mu.Lock()
+	assert.Equal(t, []string{"kusto: could not refresh the token, retrying", "kusto: could not refresh the token, retrying"}, l.warns)
+	l.mu.Unlock()
+}
+
+func TestTokenRefreshExpired(t *testing.T) {
+	t.Parallel()
+
+	cred := &expiringCredential{ttl: 20 * time.Millisecond}
+	tkp := refreshingProvider(cred, time.Millisecond, NopLogger{})
+	tkp.refresher.minWait = time.Hour
+	defer tkp.close()
+
+	token, _, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	time.Sleep(50 * time.Millisecond)
+	token, _, err = tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "an expired token should be acquired again")
+}
+
+func TestTokenRefreshDisabled(t *testing.T) {
+	t.Parallel()
+
+	tkp := &TokenProvider{tokenCred: &expiringCredential{ttl: time.Minute}}
+	tkp.setRefreshBuffer(0, NopLogger{})
+	assert.Nil(t, tkp.refresher, "a buffer of 0 disables the refresh")
+
+	tkp = &TokenProvider{customToken: "custom"}
+	tkp.setRefreshBuffer(time.Minute, NopLogger{})
+	assert.Nil(t, tkp.refresher, "custom tokens are not refreshed")
+}
@@ -0,0 +1,132 @@
+package kusto
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+const (
+	// tokenRefreshTimeout bounds every background attempt to refresh the token.
+	tokenRefreshTimeout = time.Minute
+	// minTokenRefreshInterval is the least time between two refreshes of the token, so that a token that is valid for
+	// less than the refresh buffer is not refreshed continuously.
+	minTokenRefreshInterval = 10 * time.Second
+)
+
+// tokenRefreshBackoff is the backoff between the failed attempts to refresh the token in the background.
+var tokenRefreshBackoff = RetryPolicy{BaseBackoff: time.Second, MaxBackoff: time.Minute}
+
+// WithTokenRefreshBuffer makes the client renew its token in the background d before it expires, instead of when a
+// request finds it expired, so that queries never wait for a token once the first one was acquired. Failed refreshes are
+// retried with a backoff and logged as warnings, see WithLogger(), and the last token keeps being used until it
+// expires. Only an expired token is acquired while sending a request. It has no effect with an application token or a
+// user token, and a d of 0, the default, disables the background refresh.
+func WithTokenRefreshBuffer(d time.Duration) Option {
+	return func(c *Client) {
+		c.tokenRefreshBuffer = d
+	}
+}
+
+// tokenRefresher caches the token of a TokenProvider and renews it in the background before it expires.
+type tokenRefresher struct {
+	get    func(ctx context.Context) (azcore.AccessToken, error)
+	buffer time.Duration
+	logger Logger
+	// minWait is the least time between two refreshes, and backoff the backoff between failed ones. They are
+	// minTokenRefreshInterval and tokenRefreshBackoff outside of tests.
+	minWait time.Duration
+	backoff *RetryPolicy
+
+	mu     sync.Mutex
+	token  azcore.AccessToken
+	timer  *time.Timer
+	closed bool
+	done   chan struct{}
+}
+
+func newTokenRefresher(get func(ctx context.Context) (azcore.AccessToken, error), buffer time.Duration, logger Logger) *tokenRefresher {
+	return &tokenRefresher{get: get, buffer: buffer, logger: logger, minWait: minTokenRefreshInterval, backoff: &tokenRefreshBackoff, done: make(chan struct{})}
+}
+
+// acquire returns the cached token, or acquires one if it expired, which the callers wait for.
+func (r *tokenRefresher) acquire(ctx context.Context) (azcore.AccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Now().Before(r.token.ExpiresOn) {
+		return r.token, nil
+	}
+	token, err := r.get(ctx)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	r.setLocked(token)
+	return token, nil
+}
+
+// setLocked caches token and schedules its refresh. r.mu must be held.
+func (r *tokenRefresher) setLocked(token azcore.AccessToken) {
+	r.token = token
+	if r.closed {
+		return
+	}
+	wait := time.Until(token.ExpiresOn) - r.buffer
+	if wait < r.minWait {
+		wait = r.minWait
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(wait, r.refresh)
+}
+
+// refresh acquires a new token, retrying until it succeeds, the cached token expires, or the refresher is closed. Once
+// the token expired, the next request acquires it.
+func (r *tokenRefresher) refresh() {
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+		token, err := r.get(ctx)
+		cancel()
+
+		r.mu.Lock()
+		if err == nil {
+			r.setLocked(token)
+			r.mu.Unlock()
+			return
+		}
+		expiresOn := r.token.ExpiresOn
+		r.mu.Unlock()
+
+		wait := r.backoff.backoff(err, attempt)
+		if time.Now().Add(wait).After(expiresOn) {
+			r.logger.Warn("kusto: could not refresh the token before it expires", "attempt", attempt, "error", err)
+			return
+		}
+		r.logger.Warn("kusto: could not refresh the token, retrying", "attempt", attempt, "backoff", wait, "error", err)
+
+		t := time.NewTimer(wait)
+		select {
+		case <-r.done:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// close stops refreshing the token.
+func (r *tokenRefresher) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	close(r.done)
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
@@ -0,0 +1,75 @@
+package kusto
+
+import (
+	"context"
+
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracerProvider makes the client create OpenTelemetry spans with tp for Query() and Mgmt() calls, and for the
+// ingestions made by the ingest clients created from it. The W3C trace context is also sent with the requests.
+// Without it, no spans are created.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracerProvider = tp
+	}
+}
+
+// TracerProvider returns the provider set with WithTracerProvider(), or nil.
+func (c *Client) TracerProvider() trace.TracerProvider {
+	return c.tracerProvider
+}
+
+// WithContextTraceExtractor makes the client call extract once for every Query() and Mgmt() call, and send the key/value
+// pairs it returns from the call's context as client request properties, so that trace metadata carried by contexts
+// shows in the ClientRequestProperties of ".show queries" without being passed to every call.
+// A property set by a QueryOption of the call is never overridden. A panic of extract is recovered and logged, and the
+// call is sent without the extracted properties.
+func WithContextTraceExtractor(extract func(ctx context.Context) map[string]string) Option {
+	return func(c *Client) {
+		c.traceExtractor = extract
+	}
+}
+
+// extractTraceProperties adds the properties returned by the extractor set with WithContextTraceExtractor() to the
+// request properties of opts.
+func (c *Client) extractTraceProperties(ctx context.Context, opts *queryOptions) {
+	if c.traceExtractor == nil {
+		return
+	}
+
+	props, ok := c.callTraceExtractor(ctx)
+	if !ok {
+		return
+	}
+	for k, v := range props {
+		if _, ok := opts.requestProperties.Options[k]; ok {
+			continue
+		}
+		opts.requestProperties.Options[k] = v
+	}
+}
+
+func (c *Client) callTraceExtractor(ctx context.Context) (props map[string]string, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.Logger().Warn("kusto: the context trace extractor panicked, the request is sent without its properties", "panic", r)
+			props, ok = nil, false
+		}
+	}()
+	return c.traceExtractor(ctx), true
+}
+
+// endSpan ends the span of a Query() or Mgmt() call, which covers the request and the reading of its response, until
+// the stream of the iterator ended or Stop() was called, with the error that ended it.
+func endSpan(span trace.Span, iter *RowIterator, err error) {
+	if span == nil {
+		return
+	}
+	if iter != nil {
+		span.SetAttributes(tracing.ClientRequestID.String(iter.ClientRequestID()))
+	}
+	tracing.End(span, err)
+}
@@ -0,0 +1,205 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/internal/tracing"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// traceparentServer fails every request with a 400, and records the traceparent header of the last one.
+func traceparentServer(t *testing.T) (*httptest.Server, func() string) {
+	var (
+		mu          sync.Mutex
+		traceparent string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparent = r.Header.Get("traceparent")
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(s.Close)
+
+	return s, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return traceparent
+	}
+}
+
+func TestTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		call func(c *Client) error
+		name string
+	}{
+		{
+			desc: "Query",
+			call: func(c *Client) error {
+				_, err := c.Query(context.Background(), "db", kql.New("test"))
+				return err
+			},
+			name: "kusto.Query",
+		},
+		{
+			desc: "Mgmt",
+			call: func(c *Client) error {
+				_, err := c.Mgmt(context.Background(), "db", kql.New(".show tables"))
+				return err
+			},
+			name: "kusto.Mgmt",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			s, traceparent := traceparentServer(t)
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			client := retryClient(t, s.URL, WithTracerProvider(tp))
+			assert.Equal(t, tp, client.TracerProvider())
+
+			require.Error(t, test.call(client))
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, test.name, span.Name())
+			assert.Contains(t, span.Attributes(), tracing.Database.String("db"))
+			assert.Equal(t, codes.Error, span.Status().Code)
+			require.Len(t, span.Events(), 1, "the error should be recorded")
+			assert.Equal(t, "exception", span.Events()[0].Name)
+
+			assert.Contains(t, traceparent(), span.SpanContext().TraceID().String(), "the trace context should be sent with the request")
+		})
+	}
+}
+
+func TestNoTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	s, traceparent := traceparentServer(t)
+	client := retryClient(t, s.URL)
+	assert.Nil(t, client.TracerProvider())
+
+	_, err := client.Query(context.Background(), "db", kql.New("test"))
+	require.Error(t, err)
+	assert.Empty(t, traceparent())
+}
+
+// tablesServer returns failingTablesResponse to the requests with the "failing" client request ID, and
+// tablesResponse to the others.
+func tablesServer(t *testing.T) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ClientRequestIdHeader) == "failing" {
+			_, _ = w.Write([]byte(failingTablesResponse))
+			return
+		}
+		_, _ = w.Write([]byte(tablesResponse))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestTracerProviderStream(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := retryClient(t, tablesServer(t).URL, WithTracerProvider(tp))
+
+	iter, err := client.Query(context.Background(), "db", kql.New("test"), WithClientRequestID("failing"))
+	require.NoError(t, err, "the error should only come with the end of the stream")
+	err = iter.DoOnRowOrError(func(*table.Row, *errors.Error) error { return nil })
+	require.Error(t, err)
+	iter.Stop()
+
+	require.Eventually(t, func() bool { return len(recorder.Ended()) == 1 }, time.Second, time.Millisecond)
+	span := recorder.Ended()[0]
+	assert.Equal(t, codes.Error, span.Status().Code, "an error of the stream should set the status of the span")
+	require.Len(t, span.Events(), 1, "the error of the stream should be recorded")
+	assert.Contains(t, span.Attributes(), tracing.ClientRequestID.String("failing"))
+
+	iter, err = client.Query(context.Background(), "db", kql.New("test"), WithClientRequestID("stopped"))
+	require.NoError(t, err)
+	iter.Stop()
+
+	require.Eventually(t, func() bool { return len(recorder.Ended()) == 2 }, time.Second, time.Millisecond)
+	span = recorder.Ended()[1]
+	assert.Equal(t, codes.Unset, span.Status().Code, "Stop() should end the span without an error")
+	assert.Empty(t, span.Events())
+}
+
+type traceKey struct{}
+
+func TestWithContextTraceExtractor(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		options map[string]interface{}
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := queryMsg{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err == nil {
+			mu.Lock()
+			options = msg.Properties.Options
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(s.Close)
+	sent := func() map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return options
+	}
+
+	calls := 0
+	l := &recordingLogger{}
+	client := retryClient(t, s.URL, WithLogger(l), WithContextTraceExtractor(func(ctx context.Context) map[string]string {
+		calls++
+		id, _ := ctx.Value(traceKey{}).(string)
+		if id == "panic" {
+			panic("no trace")
+		}
+		return map[string]string{"x-trace-id": id, NoTruncationValue: "overridden"}
+	}))
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "trace-1")
+	_, err := client.Query(ctx, "db", kql.New("test"), NoTruncation())
+	require.Error(t, err)
+	assert.Equal(t, 1, calls, "the extractor should be called once per call")
+	assert.Equal(t, "trace-1", sent()["x-trace-id"])
+	assert.Equal(t, true, sent()[NoTruncationValue], "an option of the call should not be overridden")
+
+	_, err = client.Mgmt(context.WithValue(context.Background(), traceKey{}, "trace-2"), "db", kql.New(".show tables"))
+	require.Error(t, err)
+	assert.Equal(t, "trace-2", sent()["x-trace-id"])
+
+	_, err = client.Query(context.WithValue(context.Background(), traceKey{}, "panic"), "db", kql.New("test"))
+	require.Error(t, err)
+	assert.NotContains(t, sent(), "x-trace-id", "the request should be sent without the properties of a failed extractor")
+	assert.Equal(t, []string{"kusto: the context trace extractor panicked, the request is sent without its properties"}, l.warns,
+		"the panic should be logged with the client's logger")
+}